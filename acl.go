@@ -0,0 +1,111 @@
+package sse
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+)
+
+// NetworkACL restricts which networks requests are served from, by their IP address, e.g. for streams that
+// are only meant for internal services, see Handler.ACL. It is safe for concurrent use.
+//
+// A request is served if its IP is in none of the Deny prefixes, and either Allow is empty, or its IP is in
+// one of the Allow prefixes. Otherwise, it is rejected with 403 Forbidden, before any of the stream's
+// headers are written. Requests whose IP cannot be determined are rejected.
+// Behind a reverse proxy, the request's RemoteAddr is the proxy's, unless it is rewritten by a middleware
+// that trusts it.
+type NetworkACL struct {
+	// Allow, if not empty, holds the only networks requests are served from.
+	Allow []netip.Prefix
+
+	// Deny holds the networks requests are never served from, even if they are allowed.
+	Deny []netip.Prefix
+
+	rejected atomic.Uint64
+}
+
+// ParseNetworkACL returns a *NetworkACL allowing and denying the networks given in CIDR notation,
+// e.g. "10.0.0.0/8", or as single IP addresses.
+func ParseNetworkACL(allow, deny []string) (*NetworkACL, error) {
+	var (
+		acl NetworkACL
+		err error
+	)
+	if acl.Allow, err = parsePrefixes(allow); err != nil {
+		return nil, err
+	}
+	if acl.Deny, err = parsePrefixes(deny); err != nil {
+		return nil, err
+	}
+	return &acl, nil
+}
+
+func parsePrefixes(networks []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(networks))
+	for _, network := range networks {
+		network = strings.TrimSpace(network)
+
+		if !strings.Contains(network, "/") {
+			addr, err := netip.ParseAddr(network)
+			if err != nil {
+				return nil, fmt.Errorf("sse: invalid network %q: %w", network, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return nil, fmt.Errorf("sse: invalid network %q: %w", network, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Allows reports whether requests from ip are served.
+func (a *NetworkACL) Allows(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	for _, prefix := range a.Deny {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+
+	if len(a.Allow) == 0 {
+		return ip.IsValid()
+	}
+	for _, prefix := range a.Allow {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Rejected returns the number of requests a has rejected.
+func (a *NetworkACL) Rejected() uint64 { return a.rejected.Load() }
+
+// Wrap returns an http.Handler serving the requests a allows with next, e.g. to restrict the other
+// endpoints of an internal stream, such as a Handler's DebugHandler, to the same networks.
+func (a *NetworkACL) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.check(w, r) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// check reports whether r is allowed; if not, it has been rejected.
+func (a *NetworkACL) check(w http.ResponseWriter, r *http.Request) bool {
+	ip, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err == nil && a.Allows(ip.Addr()) {
+		return true
+	}
+
+	a.rejected.Add(1)
+	NewHTTPError(http.StatusForbidden, "").write(w)
+	return false
+}
@@ -0,0 +1,116 @@
+package sse
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestNetworkACL(t *testing.T) {
+	t.Parallel()
+
+	t.Run("allows and denies networks", func(t *testing.T) {
+		t.Parallel()
+
+		acl, err := ParseNetworkACL([]string{"10.0.0.0/8", "fd00::/8", "192.168.1.7"}, []string{"10.1.0.0/16"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tt := range []struct {
+			ip      string
+			allowed bool
+		}{
+			{"10.2.3.4", true},
+			{"::ffff:10.2.3.4", true},
+			{"10.1.2.3", false},
+			{"fd00::1", true},
+			{"192.168.1.7", true},
+			{"192.168.1.8", false},
+			{"8.8.8.8", false},
+		} {
+			if allowed := acl.Allows(netip.MustParseAddr(tt.ip)); allowed != tt.allowed {
+				t.Errorf("%s: expected allowed %v, but got %v", tt.ip, tt.allowed, allowed)
+			}
+		}
+	})
+
+	t.Run("allows everything not denied without an allow list", func(t *testing.T) {
+		t.Parallel()
+
+		acl, err := ParseNetworkACL(nil, []string{"203.0.113.0/24"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !acl.Allows(netip.MustParseAddr("198.51.100.1")) || acl.Allows(netip.MustParseAddr("203.0.113.9")) {
+			t.Error("expected only the denied network to be denied")
+		}
+		if acl.Allows(netip.Addr{}) {
+			t.Error("expected an invalid address to be denied")
+		}
+	})
+
+	t.Run("rejects invalid networks", func(t *testing.T) {
+		t.Parallel()
+
+		for _, network := range []string{"10.0.0.0/33", "example.com"} {
+			if _, err := ParseNetworkACL([]string{network}, nil); err == nil {
+				t.Errorf("%s: expected an error, but got none", network)
+			}
+		}
+	})
+
+	t.Run("rejects requests before they are served", func(t *testing.T) {
+		t.Parallel()
+
+		called := false
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			called = true
+			return stream.Close()
+		})
+		acl, _ := ParseNetworkACL([]string{"10.0.0.0/8"}, nil)
+		h.ACL = acl
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("expected status %d, but got %d", http.StatusForbidden, resp.StatusCode)
+		}
+		if resp.Header.Get("Content-Type") == "text/event-stream" {
+			t.Error("expected no stream headers")
+		}
+		if called {
+			t.Error("expected NewEventStreamHandler not to be called")
+		}
+		if acl.Rejected() != 1 || h.Stats().Rejected != 1 {
+			t.Errorf("expected 1 rejection, but got %d, and %d in the Handler's stats", acl.Rejected(), h.Stats().Rejected)
+		}
+	})
+
+	t.Run("wraps other handlers", func(t *testing.T) {
+		t.Parallel()
+
+		acl, _ := ParseNetworkACL([]string{"127.0.0.1", "::1"}, nil)
+		srv := httptest.NewServer(acl.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})))
+		t.Cleanup(srv.Close)
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("expected status %d, but got %d", http.StatusNoContent, resp.StatusCode)
+		}
+	})
+}
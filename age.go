@@ -0,0 +1,45 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// DefaultMaxAgeRetry is the reconnection delay sent to clients whose connection has reached
+// Handler.MaxConnectionAge, when Handler.DefaultRetry is 0.
+const DefaultMaxAgeRetry = time.Second
+
+// errMaxConnectionAge is the cause of a connection's Context being canceled once it has reached
+// Handler.MaxConnectionAge.
+var errMaxConnectionAge = errors.New("sse: connection reached its maximum age")
+
+// maxConnectionAge returns how long a connection is served for. See MaxConnectionAge.
+func (h *Handler) maxConnectionAge() time.Duration {
+	tenth := h.MaxConnectionAge / 10
+	return h.MaxConnectionAge - time.Duration(rand.Int63n(int64(tenth)+1))
+}
+
+// expired reports whether the connection's Context is done because it has reached the Handler's
+// MaxConnectionAge.
+func (c *conn) expired() bool {
+	return errors.Is(context.Cause(c.ctx), errMaxConnectionAge)
+}
+
+// sendExpired sends the events already queued on stream, followed by a ReasonMaxAge StreamErrorEvent
+// telling the client when to reconnect, and flushes them.
+func (c *conn) sendExpired(stream *EventStream) {
+	if !c.sendQueued(stream) {
+		return
+	}
+
+	evt := NewStreamError(ReasonMaxAge, "").Event()
+	evt.Retry = c.h.DefaultRetry
+	if evt.Retry <= 0 {
+		evt.Retry = DefaultMaxAgeRetry
+	}
+	if c.write(&evt) {
+		c.flush()
+	}
+}
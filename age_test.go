@@ -0,0 +1,76 @@
+package sse
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMaxConnectionAge(t *testing.T) {
+	t.Parallel()
+
+	for _, park := range []bool{false, true} {
+		park := park
+
+		t.Run("ends connections gracefully", func(t *testing.T) {
+			t.Parallel()
+
+			done := make(chan struct{})
+			h := NewHandler(func(stream EventStream, lastEventID string) error {
+				stream.Go(func(ctx context.Context) error {
+					if err := stream.Send(Event{Data: []byte("before")}); err != nil {
+						return err
+					}
+					<-ctx.Done()
+					close(done)
+					return ctx.Err()
+				})
+				return nil
+			}, WithMaxConnectionAge(50*time.Millisecond), WithKeepAlive(time.Hour))
+			h.Park = park
+			srv := httptest.NewServer(h)
+			t.Cleanup(srv.Close)
+
+			start := time.Now()
+			resp, err := srv.Client().Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			if elapsed := time.Since(start); elapsed < 45*time.Millisecond {
+				t.Errorf("park %v: expected the connection to last about 50ms, but it ended after %v", park, elapsed)
+			}
+
+			var expected bytes.Buffer
+			expected.WriteString("data:before\n\n")
+			evt := NewStreamError(ReasonMaxAge, "").Event()
+			evt.Retry = DefaultMaxAgeRetry
+			EncodeEvent(&expected, evt)
+			if string(body) != expected.String() {
+				t.Errorf("park %v: expected %q, but got %q", park, expected.String(), body)
+			}
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Errorf("park %v: expected the stream's Context to be done", park)
+			}
+		})
+	}
+
+	t.Run("jitters the age of each connection", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(nil, WithMaxConnectionAge(time.Minute))
+		for i := 0; i < 100; i++ {
+			if age := h.maxConnectionAge(); age < 54*time.Second || age > time.Minute {
+				t.Fatalf("expected an age within the last tenth of a minute, but got %v", age)
+			}
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidAggregation is returned (wrapped) by ParseAggregation when its parameters are malformed.
+var ErrInvalidAggregation = errors.New("sse: invalid aggregation")
+
+// MinAggregationWindow is the shortest window Aggregate, and ParseAggregation, accept, so that clients cannot
+// request aggregates more often than the events they summarize.
+const MinAggregationWindow = 100 * time.Millisecond
+
+// WindowAggregate is the data of an event produced by Aggregate, as JSON, summarizing the events
+// with the same name produced during a window:
+//
+//	{"start":"2024-01-02T15:04:00Z","end":"2024-01-02T15:04:10Z","count":3,
+//	 "fields":{"cpu":{"count":3,"min":0.2,"max":0.9,"avg":0.5}}}
+type WindowAggregate struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// Count is the number of events produced during the window.
+	Count int `json:"count"`
+
+	// Fields summarizes each of the aggregated fields, for the events in which it was a number.
+	Fields map[string]FieldAggregate `json:"fields"`
+}
+
+// FieldAggregate summarizes the values of a numeric field over a window.
+type FieldAggregate struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+}
+
+// Aggregate returns a Transform that replaces the events produced during each tumbling window of the given length
+// with a single event per Event name, whose data is a WindowAggregate of the numeric fields of their JSON data
+// named by fields. Fields that are missing, or not numbers, are left out of an event's aggregate, as are events
+// whose data is not a JSON object, though they are still counted.
+//
+// Each aggregate event has the ID of the last event it summarizes, so that clients resuming from it miss nothing.
+// Windows in which no events were produced are skipped.
+// If window is shorter than MinAggregationWindow, its EventSources fail with an error wrapping ErrInvalidAggregation.
+func Aggregate(window time.Duration, fields ...string) Transform {
+	if window < MinAggregationWindow {
+		return failTransform(fmt.Errorf("%w: window is shorter than %s", ErrInvalidAggregation, MinAggregationWindow))
+	}
+
+	return func(src EventSource) EventSource {
+		return EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+			var (
+				start = time.Now()
+				names []string
+				aggs  = make(map[string]*eventAggregate)
+			)
+
+			return windowed(ctx, src, window, send,
+				func(evt Event, _ time.Time) {
+					agg, ok := aggs[evt.Event]
+					if !ok {
+						agg = &eventAggregate{fields: make(map[string]*FieldAggregate)}
+						aggs[evt.Event] = agg
+						names = append(names, evt.Event)
+					}
+					agg.add(evt, fields)
+				},
+				func(now time.Time) []Event {
+					events := make([]Event, 0, len(names))
+					for _, name := range names {
+						events = append(events, aggs[name].event(name, start, now))
+						delete(aggs, name)
+					}
+					names, start = names[:0], now
+					return events
+				})
+		})
+	}
+}
+
+// eventAggregate accumulates the events with one name during a window.
+type eventAggregate struct {
+	count  int
+	lastID string
+	fields map[string]*FieldAggregate
+}
+
+func (a *eventAggregate) add(evt Event, fields []string) {
+	a.count++
+	if evt.ID != "" {
+		a.lastID = evt.ID
+	}
+
+	var values map[string]json.RawMessage
+	if json.Unmarshal(evt.Data, &values) != nil {
+		return
+	}
+
+	for _, name := range fields {
+		var v float64
+		if raw, ok := values[name]; !ok || json.Unmarshal(raw, &v) != nil {
+			continue
+		}
+
+		f, ok := a.fields[name]
+		if !ok {
+			f = &FieldAggregate{Min: math.Inf(1), Max: math.Inf(-1)}
+			a.fields[name] = f
+		}
+		f.Count++
+		f.Min = math.Min(f.Min, v)
+		f.Max = math.Max(f.Max, v)
+		// Avg holds the sum until the window ends
+		f.Avg += v
+	}
+}
+
+func (a *eventAggregate) event(name string, start, end time.Time) Event {
+	agg := WindowAggregate{
+		Start:  start.UTC(),
+		End:    end.UTC(),
+		Count:  a.count,
+		Fields: make(map[string]FieldAggregate, len(a.fields)),
+	}
+	for field, f := range a.fields {
+		f.Avg /= float64(f.Count)
+		agg.Fields[field] = *f
+	}
+
+	data, _ := json.Marshal(agg)
+	return Event{Event: name, ID: a.lastID, Data: data}
+}
+
+// ParseAggregation returns the Aggregate Transform requested by query, e.g. a request's URL.Query(),
+// for a SubscriptionHandler's Transform hook:
+//
+//	?aggregate=10s&fields=cpu,memory
+//
+// It returns nil if query does not request aggregation.
+// The returned error wraps ErrInvalidAggregation if the window is not a valid duration of at least
+// MinAggregationWindow, or no fields are given.
+func ParseAggregation(query url.Values) (Transform, error) {
+	if !query.Has("aggregate") {
+		return nil, nil
+	}
+
+	window, err := time.ParseDuration(query.Get("aggregate"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAggregation, err)
+	}
+	if window < MinAggregationWindow {
+		return nil, fmt.Errorf("%w: window is shorter than %s", ErrInvalidAggregation, MinAggregationWindow)
+	}
+
+	var fields []string
+	for _, f := range strings.Split(query.Get("fields"), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%w: no fields", ErrInvalidAggregation)
+	}
+
+	return Aggregate(window, fields...), nil
+}
@@ -0,0 +1,123 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestAggregate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("aggregates numeric fields per event name", func(t *testing.T) {
+		t.Parallel()
+
+		src := EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+			for _, evt := range []Event{
+				{Event: "cpu", ID: "1", Data: []byte(`{"load":0.5,"host":"a"}`)},
+				{Event: "cpu", ID: "2", Data: []byte(`{"load":1.5}`)},
+				{Event: "mem", ID: "3", Data: []byte(`{"used":100}`)},
+				{Event: "cpu", ID: "4", Data: []byte(`{"load":"high"}`)},
+				{Event: "cpu", Data: []byte(`not json`)},
+			} {
+				if err := send(evt); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+		events := collectEvents(t, Aggregate(time.Hour, "load", "used")(src))
+		if len(events) != 2 {
+			t.Fatalf("expected an event per name, but got %d", len(events))
+		}
+
+		var cpu, mem WindowAggregate
+		if err := json.Unmarshal(events[0].Data, &cpu); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(events[1].Data, &mem); err != nil {
+			t.Fatal(err)
+		}
+
+		if events[0].Event != "cpu" || events[0].ID != "4" || cpu.Count != 4 {
+			t.Errorf("expected 4 cpu events, up to ID 4, but got %q, ID %q, with %d", events[0].Event, events[0].ID, cpu.Count)
+		}
+		if expected := (FieldAggregate{Count: 2, Min: 0.5, Max: 1.5, Avg: 1}); cpu.Fields["load"] != expected {
+			t.Errorf("expected load %+v, but got %+v", expected, cpu.Fields["load"])
+		}
+		if _, ok := cpu.Fields["used"]; ok {
+			t.Errorf("expected no aggregate of missing fields, but got %+v", cpu.Fields)
+		}
+
+		if expected := (FieldAggregate{Count: 1, Min: 100, Max: 100, Avg: 100}); events[1].Event != "mem" || mem.Fields["used"] != expected {
+			t.Errorf("expected mem used %+v, but got %q %+v", expected, events[1].Event, mem.Fields)
+		}
+		if cpu.End.Before(cpu.Start) {
+			t.Errorf("expected window to end after it starts, but got %s to %s", cpu.Start, cpu.End)
+		}
+	})
+
+	t.Run("delivers each window", func(t *testing.T) {
+		t.Parallel()
+
+		events := make(chan Event, 1)
+		events <- Event{Event: "cpu", Data: []byte(`{"load":1}`)}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var received []Event
+		Aggregate(MinAggregationWindow, "load")(ChanSource(events)).Stream(ctx, func(evt Event) error {
+			received = append(received, evt)
+			cancel()
+			return nil
+		})
+
+		if len(received) != 1 || received[0].Event != "cpu" {
+			t.Errorf("expected one aggregate of cpu events, but got %+v", received)
+		}
+	})
+}
+
+func TestAggregateInvalidWindow(t *testing.T) {
+	t.Parallel()
+
+	for _, window := range []time.Duration{0, -time.Second, MinAggregationWindow - 1} {
+		err := Aggregate(window, "load")(numberedSource(noName, 3)).Stream(context.Background(), func(Event) error { return nil })
+		if !errors.Is(err, ErrInvalidAggregation) {
+			t.Errorf("%s: expected ErrInvalidAggregation, but got %v", window, err)
+		}
+	}
+}
+
+func TestParseAggregation(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		query     string
+		transform bool
+		valid     bool
+	}{
+		{"", false, true},
+		{"aggregate=10s&fields=cpu,memory", true, true},
+		{"aggregate=10s&fields=cpu,+", true, true},
+		{"aggregate=soon&fields=cpu", false, false},
+		{"aggregate=1ms&fields=cpu", false, false},
+		{"aggregate=10s", false, false},
+		{"aggregate=10s&fields=,", false, false},
+	} {
+		query, _ := url.ParseQuery(tc.query)
+		transform, err := ParseAggregation(query)
+
+		if valid := !errors.Is(err, ErrInvalidAggregation); valid != tc.valid || tc.valid && err != nil {
+			t.Errorf("%q: expected valid = %v, but got %v", tc.query, tc.valid, err)
+		}
+		if (transform != nil) != tc.transform {
+			t.Errorf("%q: expected a transform = %v, but got %v", tc.query, tc.transform, transform != nil)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package sse
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrMemoryLimit is returned when holding an event would exceed an Allocator's limit.
+var ErrMemoryLimit = errors.New("sse: memory limit exceeded")
+
+// Allocator manages the memory used for encoding, queueing, and retaining events.
+// Implementations must be safe for concurrent use.
+type Allocator interface {
+	// GetBuffer returns an empty buffer to encode an event into.
+	GetBuffer() *bytes.Buffer
+
+	// PutBuffer returns a buffer obtained from GetBuffer once it is no longer used.
+	PutBuffer(buf *bytes.Buffer)
+
+	// Reserve records that n more bytes are held, e.g. by events queued on an EventStream,
+	// or retained by a ReplayBuffer. It returns false, and records nothing, if that would
+	// exceed the Allocator's limit.
+	Reserve(n int64) bool
+
+	// Release records that n bytes previously reserved are no longer held.
+	Release(n int64)
+}
+
+// DefaultAllocator is the Allocator used by Handlers and ReplayBuffers that are not given one.
+// It has no limit.
+var DefaultAllocator = NewMemoryAllocator(0)
+
+// MemoryAllocator is an Allocator that pools encoding buffers, and enforces a limit on the
+// total number of bytes reserved.
+type MemoryAllocator struct {
+	limit int64
+	inUse int64
+	pool  sync.Pool
+}
+
+// NewMemoryAllocator returns a *MemoryAllocator that allows up to limit bytes to be reserved.
+// A limit of 0 or less means there is no limit.
+func NewMemoryAllocator(limit int64) *MemoryAllocator {
+	return &MemoryAllocator{
+		limit: limit,
+		pool: sync.Pool{
+			New: func() interface{} { return new(bytes.Buffer) },
+		},
+	}
+}
+
+// GetBuffer implements Allocator.
+func (a *MemoryAllocator) GetBuffer() *bytes.Buffer { return a.pool.Get().(*bytes.Buffer) }
+
+// PutBuffer implements Allocator.
+func (a *MemoryAllocator) PutBuffer(buf *bytes.Buffer) {
+	buf.Reset()
+	a.pool.Put(buf)
+}
+
+// Reserve implements Allocator.
+func (a *MemoryAllocator) Reserve(n int64) bool {
+	for {
+		inUse := atomic.LoadInt64(&a.inUse)
+		if limit := atomic.LoadInt64(&a.limit); limit > 0 && inUse+n > limit {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&a.inUse, inUse, inUse+n) {
+			return true
+		}
+	}
+}
+
+// Release implements Allocator.
+func (a *MemoryAllocator) Release(n int64) { atomic.AddInt64(&a.inUse, -n) }
+
+// InUse returns the number of bytes currently reserved.
+func (a *MemoryAllocator) InUse() int64 { return atomic.LoadInt64(&a.inUse) }
+
+// Limit returns the maximum number of bytes that may be reserved, or 0 if there is no limit.
+func (a *MemoryAllocator) Limit() int64 {
+	if limit := atomic.LoadInt64(&a.limit); limit > 0 {
+		return limit
+	}
+	return 0
+}
+
+// SetLimit changes the maximum number of bytes that may be reserved. A limit of 0 or less means there is no limit.
+// Lowering the limit does not release memory already reserved; further reservations fail until enough is released.
+func (a *MemoryAllocator) SetLimit(limit int64) { atomic.StoreInt64(&a.limit, limit) }
+
+// eventSize returns the number of bytes held by evt, for accounting purposes.
+// The contents of a DataReader are not included.
+func eventSize(evt *Event) int64 {
+	n := len(evt.Event) + len(evt.Data) + len(evt.ID) + len(evt.Comment) + len(evt.TraceParent)
+	for _, tag := range evt.Tags {
+		n += len(tag)
+	}
+	for cohort, data := range evt.Variants {
+		n += len(cohort) + len(data)
+	}
+	return int64(n)
+}
+
+// queueAccount accounts for the memory held by the events queued on an EventStream.
+type queueAccount struct {
+	alloc Allocator
+	mu    sync.Mutex
+	held  int64
+	done  bool
+
+	// exceeded is closed the first time a reservation fails, so that the connection can be ended.
+	exceeded chan struct{}
+}
+
+func newQueueAccount(alloc Allocator) *queueAccount {
+	return &queueAccount{
+		alloc:    alloc,
+		exceeded: make(chan struct{}),
+	}
+}
+
+// reserve reserves n bytes for an event being queued.
+// Once the connection has ended, queued events are never held, so nothing is reserved.
+func (q *queueAccount) reserve(n int64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.done {
+		return true
+	}
+	if !q.alloc.Reserve(n) {
+		select {
+		case <-q.exceeded:
+		default:
+			close(q.exceeded)
+		}
+		return false
+	}
+	q.held += n
+	return true
+}
+
+// release releases n bytes for an event that has been taken from the queue.
+func (q *queueAccount) release(n int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.done {
+		return
+	}
+	q.held -= n
+	q.alloc.Release(n)
+}
+
+// bytes returns the number of bytes held by queued events.
+func (q *queueAccount) bytes() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.held
+}
+
+// close releases everything still held by the queue, once the connection has ended.
+func (q *queueAccount) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.done = true
+	q.alloc.Release(q.held)
+	q.held = 0
+}
@@ -0,0 +1,141 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestMemoryAllocator(t *testing.T) {
+	t.Parallel()
+
+	t.Run("enforces limit", func(t *testing.T) {
+		t.Parallel()
+
+		a := NewMemoryAllocator(10)
+		if !a.Reserve(6) {
+			t.Fatal("expected reservation within limit to succeed")
+		}
+		if a.Reserve(5) {
+			t.Error("expected reservation over limit to fail")
+		}
+		if !a.Reserve(4) {
+			t.Error("expected reservation up to limit to succeed")
+		}
+
+		a.Release(10)
+		if a.InUse() != 0 {
+			t.Errorf("expected 0 bytes in use, but got %d", a.InUse())
+		}
+	})
+
+	t.Run("changes limit", func(t *testing.T) {
+		t.Parallel()
+
+		a := NewMemoryAllocator(10)
+		if !a.Reserve(8) {
+			t.Fatal("expected reservation within limit to succeed")
+		}
+
+		a.SetLimit(5)
+		if a.Limit() != 5 {
+			t.Errorf("expected limit 5, but got %d", a.Limit())
+		}
+		if a.Reserve(1) {
+			t.Error("expected reservation over lowered limit to fail")
+		}
+
+		a.SetLimit(0)
+		if !a.Reserve(100) {
+			t.Error("expected reservation without a limit to succeed")
+		}
+	})
+
+	t.Run("reuses buffers", func(t *testing.T) {
+		t.Parallel()
+
+		a := NewMemoryAllocator(0)
+		buf := a.GetBuffer()
+		buf.WriteString("data")
+		a.PutBuffer(buf)
+
+		if buf := a.GetBuffer(); buf.Len() != 0 {
+			t.Errorf("expected an empty buffer, but got %q", buf.Bytes())
+		}
+	})
+
+	t.Run("Handler accounts for queued events", func(t *testing.T) {
+		t.Parallel()
+
+		alloc := NewMemoryAllocator(10)
+		results := make(chan error, 2)
+
+		h := NewHandlerBuffered(func(stream EventStream, lastEventID string) error {
+			results <- stream.Send(Event{Data: []byte("12345678")})
+			results <- stream.Send(Event{Data: []byte("12345678")})
+			return stream.Close()
+		}, 2)
+		h.Allocator = alloc
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal("failed to read response body:", err)
+		}
+
+		if err := <-results; err != nil {
+			t.Errorf("expected first event to be queued, but got %v", err)
+		}
+		if err := <-results; !errors.Is(err, ErrMemoryLimit) {
+			t.Errorf("expected ErrMemoryLimit, but got %v", err)
+		}
+
+		expected := "data:12345678\n\n" + "event:" + StreamErrorEvent + "\n" +
+			`data:{"code":"quota_exceeded","message":"sse: memory limit exceeded","retry":true}` + "\n\n"
+		if string(body) != expected {
+			t.Errorf("expected response body %q, but got %q", expected, body)
+		}
+
+		if alloc.InUse() != 0 {
+			t.Errorf("expected all memory to be released, but %d bytes are in use", alloc.InUse())
+		}
+	})
+
+	t.Run("ReplayBuffer evicts to stay within limit", func(t *testing.T) {
+		t.Parallel()
+
+		alloc := NewMemoryAllocator(30)
+		b := NewReplayBuffer(100)
+		b.Allocator = alloc
+
+		for i := 0; i < 10; i++ {
+			if err := b.Add(Event{ID: strconv.Itoa(i), Data: []byte("123456789")}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if alloc.InUse() > 30 {
+			t.Errorf("expected at most 30 bytes in use, but got %d", alloc.InUse())
+		}
+
+		if _, _, err := b.EventsSince(context.Background(), "7", 10); err != nil {
+			t.Errorf("expected recent events to be retained, but got %v", err)
+		}
+		if _, _, err := b.EventsSince(context.Background(), "6", 10); !errors.Is(err, ErrUnknownEventID) {
+			t.Errorf("expected old events to be evicted, but got %v", err)
+		}
+
+		if err := b.Add(Event{ID: "too large", Data: make([]byte, 100)}); !errors.Is(err, ErrMemoryLimit) {
+			t.Errorf("expected ErrMemoryLimit, but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultTimelineSize is the number of annotations each stream's timeline keeps when Handler.TimelineSize is 0.
+const DefaultTimelineSize = 64
+
+// Annotation is a checkpoint recorded by EventStream.Annotate.
+type Annotation struct {
+	Time  time.Time
+	Name  string
+	Attrs []slog.Attr
+}
+
+// MarshalJSON encodes a as a JSON object, with its attributes as an object of their values:
+//
+//	{"time":"2024-01-02T14:32:00Z","name":"query done","attrs":{"rows":12}}
+func (a Annotation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Time  time.Time              `json:"time"`
+		Name  string                 `json:"name"`
+		Attrs map[string]interface{} `json:"attrs,omitempty"`
+	}{a.Time, a.Name, attrMap(a.Attrs)})
+}
+
+// attrMap returns attrs as a map of their values, with groups as nested maps.
+func attrMap(attrs []slog.Attr) map[string]interface{} {
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	m := make(map[string]interface{}, len(attrs))
+	for _, attr := range attrs {
+		value := attr.Value.Resolve()
+		if value.Kind() == slog.KindGroup {
+			m[attr.Key] = attrMap(value.Group())
+		} else {
+			m[attr.Key] = value.Any()
+		}
+	}
+	return m
+}
+
+// timeline keeps the latest annotations of a stream.
+type timeline struct {
+	mu      sync.Mutex
+	entries []Annotation
+	next    int
+	full    bool
+
+	// onAnnotate reports each annotation, see Handler.OnAnnotate.
+	onAnnotate func(a Annotation)
+}
+
+func newTimeline(size int, onAnnotate func(a Annotation)) *timeline {
+	if size <= 0 {
+		size = DefaultTimelineSize
+	}
+	return &timeline{entries: make([]Annotation, size), onAnnotate: onAnnotate}
+}
+
+func (t *timeline) add(a Annotation) {
+	t.mu.Lock()
+	t.entries[t.next] = a
+	t.next = (t.next + 1) % len(t.entries)
+	t.full = t.full || t.next == 0
+	t.mu.Unlock()
+
+	if t.onAnnotate != nil {
+		t.onAnnotate(a)
+	}
+}
+
+// annotations returns the annotations kept, oldest first.
+func (t *timeline) annotations() []Annotation {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.full {
+		return append([]Annotation(nil), t.entries[:t.next]...)
+	}
+	return append(append([]Annotation(nil), t.entries[t.next:]...), t.entries[:t.next]...)
+}
+
+// Annotate records a checkpoint named name, with attrs, in the stream's timeline, e.g. "query done", or
+// "upstream reconnected", to help diagnose why a client stopped receiving events. The latest are kept,
+// see Handler.TimelineSize, and served by Handler.DebugHandler; each is also reported to Handler.OnAnnotate,
+// e.g. to add it to the connection's trace span, or logged to Handler.Logger at the debug level.
+func (s EventStream) Annotate(name string, attrs ...slog.Attr) {
+	if s.timeline != nil {
+		s.timeline.add(Annotation{Time: time.Now(), Name: name, Attrs: attrs})
+	}
+}
+
+// Timeline returns the annotations recorded by the stream h is serving with the given ID, oldest first,
+// and whether there is such a stream.
+func (h *Handler) Timeline(id string) ([]Annotation, bool) {
+	stream, ok := h.Get(id)
+	if !ok || stream.timeline == nil {
+		return nil, ok
+	}
+	return stream.timeline.annotations(), true
+}
+
+// DebugHandler returns an http.Handler that serves the timelines of the streams h is serving, see
+// EventStream.Annotate, as JSON:
+//
+//	{"streams":[{"id":"3f2a9c","annotations":[{"time":"2024-01-02T14:32:00Z","name":"query done"}]}]}
+//
+// Annotations may include sensitive details, so it should only be served to operators.
+func (h *Handler) DebugHandler() http.Handler {
+	type streamTimeline struct {
+		ID          string       `json:"id"`
+		Annotations []Annotation `json:"annotations"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Streams []streamTimeline `json:"streams"`
+		}
+		body.Streams = []streamTimeline{}
+		for _, stream := range h.Streams() {
+			t := streamTimeline{ID: stream.id, Annotations: []Annotation{}}
+			if stream.timeline != nil {
+				t.Annotations = stream.timeline.annotations()
+			}
+			body.Streams = append(body.Streams, t)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(body)
+	})
+}
+
+// annotated returns the hook reporting a connection's annotations, see Handler.OnAnnotate.
+func (c *conn) annotated(ctx context.Context) func(a Annotation) {
+	if c.h.OnAnnotate != nil {
+		return func(a Annotation) { c.h.OnAnnotate(ctx, c.id, a) }
+	}
+	if c.h.Logger == nil {
+		return nil
+	}
+	return func(a Annotation) {
+		c.h.Logger.LogAttrs(ctx, slog.LevelDebug, a.Name, append([]slog.Attr{slog.String("conn_id", c.id)}, a.Attrs...)...)
+	}
+}
@@ -0,0 +1,99 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAnnotate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("records checkpoints in the timeline", func(t *testing.T) {
+		t.Parallel()
+
+		reported := make(chan Annotation, 4)
+		release := make(chan struct{})
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			stream.Annotate("subscribed", slog.String("topic", "orders"))
+			stream.Go(func(ctx context.Context) error {
+				stream.Annotate("query done", slog.Int("rows", 12), slog.Group("db", slog.String("host", "primary")))
+				if err := stream.Send(Event{Data: []byte("ready")}); err != nil {
+					return err
+				}
+				<-release
+				return nil
+			})
+			return nil
+		})
+		h.OnAnnotate = func(ctx context.Context, connID string, a Annotation) { reported <- a }
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+		t.Cleanup(func() { close(release) })
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if line, _ := bufio.NewReader(resp.Body).ReadString('\n'); line != "data:ready\n" {
+			t.Fatalf("expected the event, but got %q", line)
+		}
+
+		if a := <-reported; a.Name != "subscribed" {
+			t.Errorf("expected OnAnnotate to be called with %q, but got %q", "subscribed", a.Name)
+		}
+
+		streams := h.Streams()
+		if len(streams) != 1 {
+			t.Fatalf("expected 1 stream, but got %d", len(streams))
+		}
+		timeline, ok := h.Timeline(streams[0].ID())
+		if !ok || len(timeline) != 2 || timeline[0].Name != "subscribed" || timeline[1].Name != "query done" {
+			t.Errorf("expected both annotations, in order, but got %+v", timeline)
+		}
+
+		rec := httptest.NewRecorder()
+		h.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug", nil))
+		var body struct {
+			Streams []struct {
+				ID          string `json:"id"`
+				Annotations []struct {
+					Name  string                 `json:"name"`
+					Attrs map[string]interface{} `json:"attrs"`
+				} `json:"annotations"`
+			} `json:"streams"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Streams) != 1 || len(body.Streams[0].Annotations) != 2 {
+			t.Fatalf("expected the stream's timeline, but got %+v", body)
+		}
+		attrs := body.Streams[0].Annotations[1].Attrs
+		if attrs["rows"] != 12.0 || attrs["db"].(map[string]interface{})["host"] != "primary" {
+			t.Errorf("expected the annotation's attributes, but got %v", attrs)
+		}
+	})
+
+	t.Run("keeps the latest annotations", func(t *testing.T) {
+		t.Parallel()
+
+		tl := newTimeline(3, nil)
+		for _, name := range []string{"a", "b", "c", "d", "e"} {
+			tl.add(Annotation{Name: name})
+		}
+
+		var names []string
+		for _, a := range tl.annotations() {
+			names = append(names, a.Name)
+		}
+		if got := strings.Join(names, ","); got != "c,d,e" {
+			t.Errorf("expected %q, but got %q", "c,d,e", got)
+		}
+	})
+}
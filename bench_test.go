@@ -0,0 +1,120 @@
+package sse
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func BenchmarkEncodeEvent(b *testing.B) {
+	benchmarks := []struct {
+		name string
+		evt  Event
+	}{
+		{"small", Event{Data: []byte("42")}},
+		{"all fields", Event{Event: "update", Data: []byte(`{"value":42}`), ID: "1234", Retry: time.Second}},
+		{"multi-line", Event{Data: []byte("line one\nline two\nline three\nline four")}},
+		{"CRLF multi-line", Event{Data: []byte("line one\r\nline two\r\nline three\r\nline four")}},
+	}
+
+	for _, bb := range benchmarks {
+		bb := bb
+		b.Run(bb.name, func(b *testing.B) {
+			buf := DefaultAllocator.GetBuffer()
+			defer DefaultAllocator.PutBuffer(buf)
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				evt := bb.evt
+				writeEvent(io.Discard, buf, &evt, false)
+				buf.Reset()
+			}
+		})
+	}
+}
+
+func BenchmarkEncoder(b *testing.B) {
+	evt := Event{Event: "update", Data: []byte(`{"value":42}`), ID: "1234"}
+
+	for _, space := range []bool{false, true} {
+		enc := NewEncoder(io.Discard)
+		enc.FieldSpace = space
+		b.Run("field space "+strconv.FormatBool(space), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				enc.WriteEvent(evt)
+			}
+		})
+	}
+}
+
+func BenchmarkAppendTo(b *testing.B) {
+	evt := Event{Event: "update", Data: []byte(`{"value":42}`), ID: "1234"}
+	dst := make([]byte, 0, 256)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dst = evt.AppendTo(dst[:0])
+	}
+}
+
+func BenchmarkSmallEvents(b *testing.B) {
+	var w discardResponseWriter
+	c := &conn{
+		h:     &Handler{},
+		ctx:   context.Background(),
+		alloc: DefaultAllocator,
+		w:     w,
+		rc:    http.NewResponseController(w),
+		buf:   DefaultAllocator.GetBuffer(),
+	}
+
+	// events are queued on a channel, as by EventStream.Send, so that their data escapes to the heap
+	queue := make(chan Event, 64)
+
+	b.Run("make", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			queue <- Event{Event: "tick", Data: []byte(strconv.Itoa(i))}
+			c.send(<-queue)
+			c.flush()
+		}
+	})
+
+	b.Run("slab", func(b *testing.B) {
+		slab := NewSlab(0)
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			queue <- slab.Event("tick", strconv.Itoa(i))
+			c.send(<-queue)
+			c.flush()
+		}
+	})
+
+	b.Run("slab batched", func(b *testing.B) {
+		slab := NewSlab(0)
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i += cap(queue) {
+			for j := 0; j < cap(queue); j++ {
+				queue <- slab.Event("tick", strconv.Itoa(i+j))
+			}
+			for j := 0; j < cap(queue); j++ {
+				c.send(<-queue)
+			}
+			c.flush()
+		}
+	})
+}
+
+// discardResponseWriter is a flushable http.ResponseWriter that discards everything written to it.
+type discardResponseWriter struct{}
+
+func (discardResponseWriter) Header() http.Header         { return http.Header{} }
+func (discardResponseWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (discardResponseWriter) WriteHeader(int)             {}
+func (discardResponseWriter) Flush()                      {}
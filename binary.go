@@ -0,0 +1,109 @@
+package sse
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+)
+
+// BinaryPrefix marks the data of an Event as a base64 encoded binary payload.
+const BinaryPrefix = "base64:"
+
+// ErrNotBinary is returned by DecodeBinary if data does not start with BinaryPrefix.
+var ErrNotBinary = errors.New("sse: data is not a binary payload")
+
+// NewBinaryEvent returns an Event named event, with payload base64 encoded into its data.
+// See DecodeBinary for receiving it.
+func NewBinaryEvent(event string, payload []byte) Event {
+	data := make([]byte, len(BinaryPrefix)+base64.StdEncoding.EncodedLen(len(payload)))
+	copy(data, BinaryPrefix)
+	base64.StdEncoding.Encode(data[len(BinaryPrefix):], payload)
+
+	return Event{
+		Event: event,
+		Data:  data,
+	}
+}
+
+// NewBinaryReaderEvent is like NewBinaryEvent, but the payload is read from r, and base64 encoded
+// while the event is being written. This is intended for payloads too large to buffer in memory.
+// See Event.DataReader.
+func NewBinaryReaderEvent(event string, r io.Reader) Event {
+	return Event{
+		Event:      event,
+		DataReader: newBase64Reader(r),
+	}
+}
+
+// IsBinary reports whether data contains a payload encoded by NewBinaryEvent or NewBinaryReaderEvent.
+func IsBinary(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(BinaryPrefix))
+}
+
+// DecodeBinary returns the payload of an Event sent by NewBinaryEvent or NewBinaryReaderEvent.
+// If data does not start with BinaryPrefix, ErrNotBinary is returned.
+func DecodeBinary(data []byte) ([]byte, error) {
+	if !IsBinary(data) {
+		return nil, ErrNotBinary
+	}
+
+	data = data[len(BinaryPrefix):]
+	payload := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+	n, err := base64.StdEncoding.Decode(payload, data)
+	if err != nil {
+		return nil, err
+	}
+	return payload[:n], nil
+}
+
+// base64ChunkSize is the number of bytes read by a base64Reader at a time.
+// It must be a multiple of 3 so that padding only occurs at the end of the payload.
+const base64ChunkSize = 3 * 1024
+
+// base64Reader encodes the contents of an io.Reader as base64, prefixed by BinaryPrefix.
+type base64Reader struct {
+	src     io.Reader
+	in      []byte
+	encoded []byte
+	out     []byte
+	err     error
+}
+
+func newBase64Reader(src io.Reader) *base64Reader {
+	return &base64Reader{
+		src:     src,
+		in:      make([]byte, base64ChunkSize),
+		encoded: make([]byte, base64.StdEncoding.EncodedLen(base64ChunkSize)),
+		out:     []byte(BinaryPrefix),
+	}
+}
+
+func (r *base64Reader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		n, err := io.ReadFull(r.src, r.in)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		r.err = err
+
+		r.out = r.encoded[:base64.StdEncoding.EncodedLen(n)]
+		base64.StdEncoding.Encode(r.out, r.in[:n])
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// Close closes the underlying io.Reader, if it is an io.Closer.
+func (r *base64Reader) Close() error {
+	if closer, ok := r.src.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
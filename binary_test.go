@@ -0,0 +1,94 @@
+package sse
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestBinary(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round trips", func(t *testing.T) {
+		t.Parallel()
+
+		payload := []byte{0x00, 0xff, '\n', '\r', 0x7f}
+		evt := NewBinaryEvent("blob", payload)
+
+		if evt.Event != "blob" {
+			t.Errorf("expected event name 'blob', but got %q", evt.Event)
+		}
+
+		if bytes.ContainsAny(evt.Data, "\r\n") {
+			t.Errorf("expected data to not contain line breaks, but got %q", evt.Data)
+		}
+
+		decoded, err := DecodeBinary(evt.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(decoded, payload) {
+			t.Errorf("expected decoded payload %v, but got %v", payload, decoded)
+		}
+	})
+
+	t.Run("rejects data without marker", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := DecodeBinary([]byte(base64.StdEncoding.EncodeToString([]byte("hello"))))
+		if !errors.Is(err, ErrNotBinary) {
+			t.Errorf("expected ErrNotBinary, but got %v", err)
+		}
+	})
+
+	t.Run("streams encoding", func(t *testing.T) {
+		t.Parallel()
+
+		for _, size := range []int{0, 1, 2, 3, base64ChunkSize - 1, base64ChunkSize, base64ChunkSize + 1, 3*base64ChunkSize + 2} {
+			payload := make([]byte, size)
+			for i := range payload {
+				payload[i] = byte(i)
+			}
+
+			evt := NewBinaryReaderEvent("blob", bytes.NewReader(payload))
+			data, err := io.ReadAll(evt.DataReader)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := NewBinaryEvent("blob", payload).Data
+			if !bytes.Equal(data, expected) {
+				t.Errorf("size %d: expected streamed data to equal %q, but got %q", size, expected, data)
+			}
+		}
+	})
+
+	t.Run("closes the source reader", func(t *testing.T) {
+		t.Parallel()
+
+		src := &closeRecorder{Reader: bytes.NewReader([]byte("hello"))}
+		evt := NewBinaryReaderEvent("blob", src)
+
+		var buf bytes.Buffer
+		if err := writeEvent(io.Discard, &buf, &evt, false); err != nil {
+			t.Fatal(err)
+		}
+
+		if !src.closed {
+			t.Error("expected source reader to be closed")
+		}
+	})
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
@@ -0,0 +1,16 @@
+package sse
+
+import (
+	"context"
+)
+
+// Broker delivers the events published to a topic to the topic's subscribers, e.g. an adapter for a
+// service's message queue. Topics are names such as "orders/123", see Mux.HandleTopic.
+type Broker interface {
+	// Publish delivers evt to the subscribers of topic.
+	Publish(ctx context.Context, topic string, evt Event) error
+
+	// Subscribe returns an EventSource producing the events published to topic, after the event with
+	// lastEventID if it is not empty, and the Broker still knows of it.
+	Subscribe(topic, lastEventID string) EventSource
+}
@@ -0,0 +1,176 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// DefaultBusHistory is the default Bus.History.
+const DefaultBusHistory = 100
+
+// DefaultBusBuffer is the default Bus.Buffer.
+const DefaultBusBuffer = 1024
+
+// ErrBusOverflow is returned by the EventSources of a Bus when their subscriber fell more than Bus.Buffer
+// events behind, so that its client reconnects, resuming from the Bus's history.
+var ErrBusOverflow = errors.New("sse: subscriber fell too far behind")
+
+// Bus is an in-process Broker, delivering the events published to a topic to the subscribers of the topic
+// in the same process, e.g. to start out on a single node, and swap in a Broker adapting a message queue once
+// it is needed. Events are delivered to each subscriber in the order they are published.
+//
+// Publish does not wait for subscribers: each subscriber's events are queued until it accepts them,
+// and one falling more than Buffer events behind is ended with ErrBusOverflow. The History of each topic is
+// kept, so that subscribers can resume after the event with their last event ID, if it is still known.
+// The events published are shared by all of the subscribers, and must not be modified.
+//
+// The zero Bus is ready to use, and it is safe for concurrent use. Its fields must not be modified once it is in use.
+type Bus struct {
+	// History is the number of the latest events of each topic kept for subscribers to resume after.
+	// If 0, DefaultBusHistory is used.
+	History int
+
+	// Buffer is the number of events queued for a subscriber before it is ended. If 0, DefaultBusBuffer is used.
+	Buffer int
+
+	mu     sync.Mutex
+	topics map[string]*busTopic
+}
+
+// busTopic is the history and subscribers of a topic of a Bus.
+type busTopic struct {
+	history     []Event
+	subscribers map[*busSubscriber]struct{}
+}
+
+// busSubscriber is the queue of the events published to a subscriber of a Bus.
+type busSubscriber struct {
+	mu       sync.Mutex
+	pending  []Event
+	overflow bool
+	notify   chan struct{}
+}
+
+// topic returns the busTopic for name, creating it if needed. b.mu must be held.
+func (b *Bus) topic(name string) *busTopic {
+	if b.topics == nil {
+		b.topics = make(map[string]*busTopic)
+	}
+
+	t, ok := b.topics[name]
+	if !ok {
+		t = &busTopic{subscribers: make(map[*busSubscriber]struct{})}
+		b.topics[name] = t
+	}
+	return t
+}
+
+// Publish delivers evt to the subscribers of topic, and adds it to the topic's history.
+// If evt has no TraceParent, it is published with that of ctx, if any, see ContextWithTraceParent.
+func (b *Bus) Publish(ctx context.Context, topic string, evt Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if evt.TraceParent == "" {
+		evt.TraceParent = TraceParentFromContext(ctx)
+	}
+
+	history := b.History
+	if history <= 0 {
+		history = DefaultBusHistory
+	}
+	buffer := b.Buffer
+	if buffer <= 0 {
+		buffer = DefaultBusBuffer
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t := b.topic(topic)
+	t.history = append(t.history, evt)
+	if n := len(t.history) - history; n > 0 {
+		t.history = append(t.history[:0], t.history[n:]...)
+	}
+
+	for s := range t.subscribers {
+		s.mu.Lock()
+		if len(s.pending) >= buffer {
+			s.overflow = true
+			delete(t.subscribers, s)
+		} else {
+			s.pending = append(s.pending, evt)
+		}
+		s.mu.Unlock()
+
+		select {
+		case s.notify <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Subscribe returns an EventSource producing the events published to topic while it is streaming, after
+// the events of the topic's history published after the event with lastEventID, if it is still known.
+func (b *Bus) Subscribe(topic, lastEventID string) EventSource {
+	return EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+		s := &busSubscriber{notify: make(chan struct{}, 1)}
+
+		b.mu.Lock()
+		t := b.topic(topic)
+		if lastEventID != "" {
+			for i := len(t.history) - 1; i >= 0; i-- {
+				if t.history[i].ID == lastEventID {
+					s.pending = append(s.pending, t.history[i+1:]...)
+					break
+				}
+			}
+		}
+		t.subscribers[s] = struct{}{}
+		b.mu.Unlock()
+
+		defer func() {
+			b.mu.Lock()
+			delete(t.subscribers, s)
+			if len(t.subscribers) == 0 && len(t.history) == 0 {
+				delete(b.topics, topic)
+			}
+			b.mu.Unlock()
+		}()
+
+		for {
+			s.mu.Lock()
+			events, overflow := s.pending, s.overflow
+			s.pending = nil
+			s.mu.Unlock()
+
+			for _, evt := range events {
+				if err := send(evt); err != nil {
+					return err
+				}
+			}
+			if overflow {
+				return ErrBusOverflow
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-s.notify:
+			}
+		}
+	})
+}
+
+// Subscribers returns the number of subscribers streaming the events published to topic.
+func (b *Bus) Subscribers(topic string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if t, ok := b.topics[topic]; ok {
+		return len(t.subscribers)
+	}
+	return 0
+}
@@ -0,0 +1,209 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// subscribeBus streams the events of topic from b into the returned channel, until ctx is done,
+// once the subscriber is streaming. The error the stream ends with is sent to done.
+func subscribeBus(t *testing.T, ctx context.Context, b *Bus, topic, lastEventID string) (events chan Event, done chan error) {
+	t.Helper()
+
+	before := b.Subscribers(topic)
+	events, done = make(chan Event, 16), make(chan error, 1)
+	go func() {
+		done <- b.Subscribe(topic, lastEventID).Stream(ctx, func(evt Event) error {
+			select {
+			case events <- evt:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	for deadline := time.Now().Add(time.Second); b.Subscribers(topic) == before; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the subscriber to start streaming")
+		}
+	}
+	return events, done
+}
+
+func receiveBus(t *testing.T, events chan Event, expected ...string) {
+	t.Helper()
+
+	for _, id := range expected {
+		select {
+		case evt := <-events:
+			if evt.ID != id {
+				t.Errorf("expected event %q, but got %q", id, evt.ID)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected event %q", id)
+		}
+	}
+}
+
+func TestBus(t *testing.T) {
+	t.Parallel()
+
+	t.Run("delivers events to each subscriber in order", func(t *testing.T) {
+		t.Parallel()
+
+		var b Bus
+		ctx, cancel := context.WithCancel(context.Background())
+		first, done := subscribeBus(t, ctx, &b, "orders", "")
+		second, _ := subscribeBus(t, ctx, &b, "orders", "")
+		other, _ := subscribeBus(t, ctx, &b, "users", "")
+
+		for _, id := range []string{"1", "2", "3"} {
+			if err := b.Publish(context.Background(), "orders", Event{ID: id}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		receiveBus(t, first, "1", "2", "3")
+		receiveBus(t, second, "1", "2", "3")
+		select {
+		case evt := <-other:
+			t.Errorf("expected no events for another topic, but got %+v", evt)
+		default:
+		}
+
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, but got %v", err)
+		}
+		for deadline := time.Now().Add(time.Second); b.Subscribers("orders") != 0; time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected subscribers to be removed, but got %d", b.Subscribers("orders"))
+			}
+		}
+	})
+
+	t.Run("resumes after the last event ID", func(t *testing.T) {
+		t.Parallel()
+
+		b := Bus{History: 3}
+		for _, id := range []string{"1", "2", "3", "4"} {
+			b.Publish(context.Background(), "orders", Event{ID: id})
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		resumed, _ := subscribeBus(t, ctx, &b, "orders", "2")
+		forgotten, _ := subscribeBus(t, ctx, &b, "orders", "1")
+
+		b.Publish(context.Background(), "orders", Event{ID: "5"})
+		receiveBus(t, resumed, "3", "4", "5")
+		receiveBus(t, forgotten, "5")
+	})
+
+	t.Run("publishes events with the traceparent of the context", func(t *testing.T) {
+		t.Parallel()
+
+		const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+		var b Bus
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, _ := subscribeBus(t, ctx, &b, "orders", "")
+
+		b.Publish(ContextWithTraceParent(context.Background(), tp), "orders", Event{ID: "1"})
+		b.Publish(context.Background(), "orders", Event{ID: "2"})
+		for _, expected := range []string{tp, ""} {
+			select {
+			case evt := <-events:
+				if evt.TraceParent != expected {
+					t.Errorf("expected event %s to have traceparent %q, but got %q", evt.ID, expected, evt.TraceParent)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("expected an event")
+			}
+		}
+	})
+
+	t.Run("ends subscribers that fall too far behind", func(t *testing.T) {
+		t.Parallel()
+
+		b := Bus{Buffer: 2}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		accepted := make(chan Event, 8)
+		taken, release := make(chan struct{}, 1), make(chan struct{})
+		done := make(chan error, 1)
+		go func() {
+			done <- b.Subscribe("orders", "").Stream(ctx, func(evt Event) error {
+				select {
+				case taken <- struct{}{}:
+				default:
+				}
+				<-release
+				accepted <- evt
+				return nil
+			})
+		}()
+		for b.Subscribers("orders") == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		b.Publish(context.Background(), "orders", Event{ID: "1"})
+		select {
+		case <-taken:
+		case <-time.After(time.Second):
+			t.Fatal("expected the first event to be taken by the subscriber")
+		}
+		for _, id := range []string{"2", "3", "4"} {
+			b.Publish(context.Background(), "orders", Event{ID: id})
+		}
+		if n := b.Subscribers("orders"); n != 0 {
+			t.Errorf("expected the subscriber to be removed, but got %d", n)
+		}
+
+		close(release)
+		if err := <-done; !errors.Is(err, ErrBusOverflow) {
+			t.Errorf("expected ErrBusOverflow, but got %v", err)
+		}
+		receiveBus(t, accepted, "1", "2", "3")
+	})
+
+	t.Run("streams topics with a Mux", func(t *testing.T) {
+		t.Parallel()
+
+		var b Bus
+		mux := NewMux()
+		mux.HandleTopic("/orders/{id}/events", "orders/{id}", &b)
+		srv := httptest.NewServer(mux)
+		t.Cleanup(srv.Close)
+
+		b.Publish(context.Background(), "orders/42", Event{Data: []byte("created"), ID: "1"})
+
+		go func() {
+			for b.Subscribers("orders/42") == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			b.Publish(context.Background(), "orders/42", Event{Data: []byte("shipped"), ID: "2"})
+		}()
+
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/orders/42/events", nil)
+		req.Header.Set("Last-Event-ID", "1")
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		r := bufio.NewReader(resp.Body)
+		for _, expected := range []string{"data:shipped\n", "id:2\n"} {
+			if line, _ := r.ReadString('\n'); line != expected {
+				t.Errorf("expected %q, but got %q", expected, line)
+			}
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CachedState is the state a StateCache persists: the latest full data of each event name, and
+// what a client needs to resume the stream without fetching it all again.
+type CachedState struct {
+	// LastEventID is the ID of the last event received, to resume from with a Last-Event-ID.
+	LastEventID string `json:"last_event_id,omitempty"`
+
+	// SnapshotVersion is the version of the snapshot the state is based on, to present with
+	// an If-None-Match, so that the snapshot is not sent again if it has not changed. See Handler.Snapshot.
+	SnapshotVersion string `json:"snapshot_version,omitempty"`
+
+	// Events holds the latest full data of each event name whose data is JSON, keyed by the name.
+	Events map[string]json.RawMessage `json:"events,omitempty"`
+}
+
+// StateStore persists the state of a StateCache, e.g. in a file (see FileStateStore), or a local database.
+type StateStore interface {
+	// Load returns the state last saved, or the zero CachedState if none has been.
+	Load(ctx context.Context) (CachedState, error)
+
+	// Save replaces the state saved.
+	Save(ctx context.Context, state CachedState) error
+}
+
+// StateCache keeps the latest state of a stream on the client, decoding delta encoded events (see DeltaDecoder)
+// against it, and persists it to a StateStore, so that a consumer that restarts can resume the stream where it
+// left off: with the events since the last one it received, and without the snapshot if it has not changed,
+// rather than by fetching the full state again.
+//
+//	cache := sse.NewStateCache(sse.FileStateStore("state.json"))
+//	if err := cache.Restore(ctx); err != nil {
+//	    return err
+//	}
+//	cache.ResumeRequest(req)
+//	// for each response: cache.Connected(resp)
+//	// for each event: evt, err = cache.Decode(evt)
+//	// periodically, and before exiting: cache.Save(ctx)
+//
+// It is not safe for concurrent use.
+type StateCache struct {
+	store   StateStore
+	decoder DeltaDecoder
+	state   CachedState
+}
+
+// NewStateCache returns a *StateCache persisting its state to store.
+func NewStateCache(store StateStore) *StateCache {
+	return &StateCache{store: store}
+}
+
+// Restore replaces the cache's state with the state saved in its StateStore.
+func (c *StateCache) Restore(ctx context.Context) error {
+	state, err := c.store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.state = state
+	c.decoder.Reset()
+	for name, data := range state.Events {
+		c.decoder.Decode(Event{Event: name, Data: data})
+	}
+	return nil
+}
+
+// Save saves the cache's state to its StateStore.
+func (c *StateCache) Save(ctx context.Context) error {
+	return c.store.Save(ctx, c.state)
+}
+
+// ResumeRequest sets the headers of req, a request for the stream, that resume it from the cache's state.
+func (c *StateCache) ResumeRequest(req *http.Request) {
+	if c.state.LastEventID != "" {
+		req.Header.Set("Last-Event-ID", c.state.LastEventID)
+	}
+	if c.state.SnapshotVersion != "" {
+		req.Header.Set("If-None-Match", `"`+c.state.SnapshotVersion+`"`)
+	}
+}
+
+// Connected records the version of the snapshot sent with resp, the response to a request for the stream, if any.
+func (c *StateCache) Connected(resp *http.Response) {
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		c.state.SnapshotVersion = strings.Trim(strings.TrimPrefix(etag, "W/"), `"`)
+	}
+}
+
+// Decode returns evt with its data restored in full, if it was a delta, as DeltaDecoder.Decode does,
+// and records it as the latest state of its event name, and the last event received.
+func (c *StateCache) Decode(evt Event) (Event, error) {
+	evt, err := c.decoder.Decode(evt)
+	if err != nil {
+		return evt, err
+	}
+
+	if evt.ID != "" || evt.ResetID {
+		c.state.LastEventID = evt.ID
+	}
+	if _, err := decodeJSON(evt.Data); err == nil {
+		if c.state.Events == nil {
+			c.state.Events = make(map[string]json.RawMessage)
+		}
+		c.state.Events[evt.Event] = append(json.RawMessage(nil), evt.Data...)
+	} else {
+		delete(c.state.Events, evt.Event)
+	}
+	return evt, nil
+}
+
+// State returns the latest full data of the events named name.
+func (c *StateCache) State(name string) ([]byte, bool) {
+	data, ok := c.state.Events[name]
+	return data, ok
+}
+
+// LastEventID returns the ID of the last event received.
+func (c *StateCache) LastEventID() string { return c.state.LastEventID }
+
+// SnapshotVersion returns the version of the snapshot the cache's state is based on.
+func (c *StateCache) SnapshotVersion() string { return c.state.SnapshotVersion }
+
+// FileStateStore is a StateStore saving state as JSON in the file at the path it names.
+// The file is replaced atomically, so a consumer that exits while saving keeps the previous state.
+type FileStateStore string
+
+// Load implements StateStore.
+func (f FileStateStore) Load(context.Context) (CachedState, error) {
+	var state CachedState
+
+	data, err := os.ReadFile(string(f))
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
+
+// Save implements StateStore.
+func (f FileStateStore) Save(_ context.Context, state CachedState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(string(f)), filepath.Base(string(f))+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), string(f))
+}
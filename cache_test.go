@@ -0,0 +1,131 @@
+package sse
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStateCache(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("resumes delta decoding from a restarted consumer's saved state", func(t *testing.T) {
+		t.Parallel()
+
+		store := FileStateStore(filepath.Join(t.TempDir(), "state.json"))
+		enc := newDeltaEncoder(0)
+
+		cache := NewStateCache(store)
+		if err := cache.Restore(ctx); err != nil {
+			t.Fatal(err)
+		}
+		for i, payload := range []string{`{"count":1,"name":"widget"}`, `{"count":2,"name":"widget"}`} {
+			evt := Event{Event: "state", ID: string(rune('1' + i)), Data: []byte(payload)}
+			enc.encode(&evt, time.Now())
+			if _, err := cache.Decode(evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		cache.Connected(&http.Response{Header: http.Header{"Etag": {`W/"v1"`}}})
+		if err := cache.Save(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		restarted := NewStateCache(store)
+		if err := restarted.Restore(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if id := restarted.LastEventID(); id != "2" {
+			t.Errorf("expected last event ID %q, but got %q", "2", id)
+		}
+		if version := restarted.SnapshotVersion(); version != "v1" {
+			t.Errorf("expected snapshot version %q, but got %q", "v1", version)
+		}
+
+		evt := Event{Event: "state", ID: "3", Data: []byte(`{"count":3,"name":"widget"}`)}
+		enc.encode(&evt, time.Now())
+		if !bytes.HasPrefix(evt.Data, []byte(DeltaPrefix)) {
+			t.Fatalf("expected a delta, but got %q", evt.Data)
+		}
+
+		evt, err := restarted.Decode(evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := `{"count":3,"name":"widget"}`; string(evt.Data) != expected {
+			t.Errorf("expected %s, but got %s", expected, evt.Data)
+		}
+		if data, _ := restarted.State("state"); string(data) != `{"count":3,"name":"widget"}` {
+			t.Errorf("expected the cached state to be updated, but got %s", data)
+		}
+	})
+
+	t.Run("sets resume headers", func(t *testing.T) {
+		t.Parallel()
+
+		cache := NewStateCache(FileStateStore(filepath.Join(t.TempDir(), "state.json")))
+
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		cache.ResumeRequest(req)
+		if len(req.Header) != 0 {
+			t.Errorf("expected no headers without state, but got %v", req.Header)
+		}
+
+		cache.Decode(Event{ID: "7", Data: []byte("hello")})
+		cache.Connected(&http.Response{Header: http.Header{"Etag": {`"abc"`}}})
+		cache.ResumeRequest(req)
+		if id := req.Header.Get("Last-Event-ID"); id != "7" {
+			t.Errorf("expected Last-Event-ID %q, but got %q", "7", id)
+		}
+		if tag := req.Header.Get("If-None-Match"); tag != `"abc"` {
+			t.Errorf("expected If-None-Match %q, but got %q", `"abc"`, tag)
+		}
+
+		cache.Decode(Event{ResetID: true})
+		req, _ = http.NewRequest(http.MethodGet, "http://example.com", nil)
+		cache.ResumeRequest(req)
+		if id, ok := req.Header["Last-Event-Id"]; ok {
+			t.Errorf("expected no Last-Event-ID after a reset, but got %q", id)
+		}
+	})
+
+	t.Run("forgets events whose data is not JSON", func(t *testing.T) {
+		t.Parallel()
+
+		cache := NewStateCache(FileStateStore(filepath.Join(t.TempDir(), "state.json")))
+		cache.Decode(Event{Event: "state", Data: []byte(`{"a":1}`)})
+		cache.Decode(Event{Event: "state", Data: []byte("not json")})
+		if data, ok := cache.State("state"); ok {
+			t.Errorf("expected no cached state, but got %s", data)
+		}
+	})
+
+	t.Run("reports deltas without cached state", func(t *testing.T) {
+		t.Parallel()
+
+		cache := NewStateCache(FileStateStore(filepath.Join(t.TempDir(), "state.json")))
+		_, err := cache.Decode(Event{Event: "state", Data: []byte(DeltaPrefix + `{"a":1}`)})
+		if !errors.Is(err, ErrMissingSnapshot) {
+			t.Errorf("expected %v, but got %v", ErrMissingSnapshot, err)
+		}
+	})
+
+	t.Run("reports corrupt state files", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "state.json")
+		if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := NewStateCache(FileStateStore(path)).Restore(ctx); err == nil {
+			t.Error("expected an error, but got none")
+		}
+	})
+}
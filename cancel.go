@@ -0,0 +1,62 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// ErrJobCanceled is the cause of the cancellation of the context of a stream's producers by Handler.Cancel.
+var ErrJobCanceled = errors.New("sse: job canceled by client")
+
+// Cancel cancels the job producing the events of the stream h is serving with the given ID, e.g. one whose
+// Progress a client is watching: the context of the stream's producers (see EventStream.Go) is canceled,
+// with ErrJobCanceled as its cause, so that they can report it, e.g. with Progress.Fail, before returning.
+// The stream ends once they have returned, as usual.
+// It returns ErrStreamNotFound if there is no such stream.
+func (h *Handler) Cancel(id string) error {
+	stream, ok := h.Get(id)
+	if !ok {
+		return ErrStreamNotFound
+	}
+	stream.producers.cancelJob(stream)
+	return nil
+}
+
+// CancelHandler returns an http.Handler that cancels the job of the stream named by the StreamParam
+// query parameter, with Cancel, for DELETE and POST requests, such as those made by NewCancelRequest,
+// so that a client can abort the job whose progress it is watching. It responds with 204 No Content,
+// or 404 Not Found if there is no such stream.
+//
+// Stream IDs are random, so only the client of a stream, or those it shares its ID with, can cancel its job;
+// the endpoint should still be authorized as the stream's is, e.g. by the same middleware.
+func (h *Handler) CancelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete && r.Method != http.MethodPost {
+			w.Header().Set("Allow", "DELETE, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := h.Cancel(r.URL.Query().Get(StreamParam)); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// NewCancelRequest returns a request to the CancelHandler at cancelURL, canceling the job of the stream
+// with the given ID, e.g. a ProgressUpdate's StreamID.
+func NewCancelRequest(ctx context.Context, cancelURL, streamID string) (*http.Request, error) {
+	u, err := url.Parse(cancelURL)
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	q.Set(StreamParam, streamID)
+	u.RawQuery = q.Encode()
+	return http.NewRequestWithContext(ctx, http.MethodDelete, u.String(), nil)
+}
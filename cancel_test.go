@@ -0,0 +1,143 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerCancel(t *testing.T) {
+	t.Parallel()
+
+	// serve serves h's streams at /, and its CancelHandler at /cancel.
+	serve := func(t *testing.T, h *Handler) *httptest.Server {
+		mux := http.NewServeMux()
+		mux.Handle("/", h)
+		mux.Handle("/cancel", h.CancelHandler())
+		srv := httptest.NewServer(mux)
+		t.Cleanup(srv.Close)
+		return srv
+	}
+
+	cancel := func(t *testing.T, srv *httptest.Server, id string) *http.Response {
+		t.Helper()
+
+		req, err := NewCancelRequest(context.Background(), srv.URL+"/cancel", id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("cancels the job whose progress a client is watching", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			p := NewProgress(stream)
+			stream.Go(func(ctx context.Context) error {
+				if err := p.Stage("export", "exporting"); err != nil {
+					return err
+				}
+				<-ctx.Done()
+				return p.Fail(context.Cause(ctx))
+			})
+			return nil
+		})
+		srv := serve(t, h)
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		r := bufio.NewReader(resp.Body)
+		var update ProgressUpdate
+		for update.StreamID == "" {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data:"); ok {
+				if update, _, err = DecodeProgress(Event{Event: ProgressEvent, Data: []byte(data)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		if resp := cancel(t, srv, update.StreamID); resp.StatusCode != http.StatusNoContent {
+			t.Errorf("expected status %d, but got %d", http.StatusNoContent, resp.StatusCode)
+		}
+
+		rest, _ := io.ReadAll(r)
+		if !strings.Contains(string(rest), "event:"+ProgressFailedEvent+"\n") || !strings.Contains(string(rest), ErrJobCanceled.Error()) {
+			t.Errorf("expected the job to report that it was canceled, but got %q", rest)
+		}
+	})
+
+	t.Run("ends streams whose producers fail with the context's error", func(t *testing.T) {
+		t.Parallel()
+
+		ids := make(chan string, 1)
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			ids <- stream.ID()
+			stream.Go(func(ctx context.Context) error {
+				if err := stream.Send(Event{Data: []byte("working")}); err != nil {
+					return err
+				}
+				<-ctx.Done()
+				return ctx.Err()
+			})
+			return nil
+		})
+		srv := serve(t, h)
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		// the stream is registered once its first event is flushed
+		r := bufio.NewReader(resp.Body)
+		if line, _ := r.ReadString('\n'); line != "data:working\n" {
+			t.Fatalf("expected the first event, but got %q", line)
+		}
+
+		if resp := cancel(t, srv, <-ids); resp.StatusCode != http.StatusNoContent {
+			t.Errorf("expected status %d, but got %d", http.StatusNoContent, resp.StatusCode)
+		}
+		body, _ := io.ReadAll(r)
+		evt := NewStreamError(ReasonCanceled, "").Event()
+		if expected := "\nevent:" + evt.Event + "\ndata:" + string(evt.Data) + "\n\n"; string(body) != expected {
+			t.Errorf("expected %q, but got %q", expected, body)
+		}
+	})
+
+	t.Run("rejects unknown streams and other methods", func(t *testing.T) {
+		t.Parallel()
+
+		srv := serve(t, NewHandler(nil))
+		if resp := cancel(t, srv, "unknown"); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected status %d, but got %d", http.StatusNotFound, resp.StatusCode)
+		}
+
+		resp, err := srv.Client().Get(srv.URL + "/cancel?" + StreamParam + "=unknown")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("expected status %d, but got %d", http.StatusMethodNotAllowed, resp.StatusCode)
+		}
+	})
+}
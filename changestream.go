@@ -0,0 +1,138 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// ChangeStream is a cursor over a database's change stream, such as a MongoDB collection's.
+//
+// To keep this module free of dependencies, database drivers are adapted to it, e.g. a *mongo.ChangeStream
+// from go.mongodb.org/mongo-driver, whose change documents are encoded as relaxed extended JSON:
+//
+//	type mongoChanges struct{ *mongo.ChangeStream }
+//
+//	func (s mongoChanges) Current() ([]byte, string, error) {
+//	    doc, err := bson.MarshalExtJSON(s.ChangeStream.Current, false, false)
+//	    return doc, s.ResumeToken().Lookup("_data").StringValue(), err
+//	}
+type ChangeStream interface {
+	// Next waits for the next change, and reports whether there is one. It returns false once ctx is done,
+	// the change stream fails, see Err, or it ends, e.g. because the collection was dropped.
+	Next(ctx context.Context) bool
+
+	// Current returns the current change document, as JSON, and the resume token following it.
+	Current() (doc []byte, resumeToken string, err error)
+
+	// Err returns the error that made Next return false, if any.
+	Err() error
+
+	// Close closes the change stream.
+	Close(ctx context.Context) error
+}
+
+// ChangeStreamOpener opens a change stream, resuming after the change with resumeToken if it is not empty.
+//
+//	func(ctx context.Context, resumeToken string) (sse.ChangeStream, error) {
+//	    opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+//	    if resumeToken != "" {
+//	        opts.SetResumeAfter(bson.M{"_data": resumeToken})
+//	    }
+//	    cs, err := coll.Watch(ctx, mongo.Pipeline{}, opts)
+//	    return mongoChanges{cs}, err
+//	}
+type ChangeStreamOpener func(ctx context.Context, resumeToken string) (ChangeStream, error)
+
+// Change is a change document, as produced by a MongoDB change stream.
+type Change struct {
+	// OperationType is the kind of change, e.g. "insert", "update", "replace", "delete", or "invalidate".
+	OperationType string `json:"operationType"`
+
+	// Namespace is the database and collection that changed.
+	Namespace struct {
+		DB   string `json:"db"`
+		Coll string `json:"coll"`
+	} `json:"ns"`
+
+	// DocumentKey identifies the changed document, e.g. {"_id": ...}.
+	DocumentKey json.RawMessage `json:"documentKey,omitempty"`
+
+	// FullDocument is the changed document, if the change stream includes it. Deletes do not.
+	FullDocument json.RawMessage `json:"fullDocument,omitempty"`
+
+	// UpdateDescription describes the fields an update changed.
+	UpdateDescription json.RawMessage `json:"updateDescription,omitempty"`
+
+	// ResumeToken is the change stream's resume token following the change.
+	ResumeToken string `json:"-"`
+
+	// Raw is the change document in full.
+	Raw json.RawMessage `json:"-"`
+}
+
+// ChangeEvent is the default mapping of Changes to events, used when ChangeStreamSource is given none:
+// the event is named after the operation type, and its data is the full document, or the document key
+// if the change does not include it.
+func ChangeEvent(change Change) (Event, bool) {
+	data := change.FullDocument
+	if len(data) == 0 || string(data) == "null" {
+		data = change.DocumentKey
+	}
+	return Event{Event: change.OperationType, Data: data}, true
+}
+
+// ChangeStreamSource returns an EventSource producing an event for each change of the change stream opened
+// by open, resuming after resumeToken if it is not empty, e.g. a client's Last-Event-ID (see FromChangeStream).
+// mapChange maps each change to an event, or reports false to skip it; if it is nil, ChangeEvent is used.
+// Each event's ID is set to the change's resume token, so that clients resume from the change they last received.
+//
+// The source finishes once the change stream ends, e.g. after an "invalidate" change. If the change stream
+// cannot be resumed, e.g. because resumeToken is too old, open's error is returned.
+func ChangeStreamSource(open ChangeStreamOpener, resumeToken string, mapChange func(Change) (Event, bool)) EventSource {
+	if mapChange == nil {
+		mapChange = ChangeEvent
+	}
+
+	return EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+		cs, err := open(ctx, resumeToken)
+		if err != nil {
+			return err
+		}
+		defer cs.Close(context.WithoutCancel(ctx))
+
+		for cs.Next(ctx) {
+			doc, token, err := cs.Current()
+			if err != nil {
+				return err
+			}
+
+			change := Change{ResumeToken: token, Raw: doc}
+			if err := json.Unmarshal(doc, &change); err != nil {
+				return fmt.Errorf("sse: malformed change document: %w", err)
+			}
+
+			evt, ok := mapChange(change)
+			if !ok {
+				continue
+			}
+			evt.ID = token
+			if err := send(evt); err != nil {
+				return err
+			}
+		}
+
+		if err := cs.Err(); err != nil {
+			return err
+		}
+		return ctx.Err()
+	})
+}
+
+// FromChangeStream returns a NewEventStreamHandler that streams the changes of the change stream opened by open
+// to each client, as FromSource does with a ChangeStreamSource resuming after the client's Last-Event-ID.
+func FromChangeStream(open ChangeStreamOpener, mapChange func(Change) (Event, bool)) NewEventStreamHandler {
+	return func(stream EventStream, lastEventID string) error {
+		return FromSource(ChangeStreamSource(open, lastEventID, mapChange))(stream, lastEventID)
+	}
+}
@@ -0,0 +1,132 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// fakeChangeStream is a ChangeStream over a fixed list of change documents, whose resume tokens are their indices.
+type fakeChangeStream struct {
+	docs   []string
+	next   int
+	err    error
+	closed bool
+}
+
+func (s *fakeChangeStream) Next(ctx context.Context) bool {
+	if ctx.Err() != nil || s.next >= len(s.docs) {
+		return false
+	}
+	s.next++
+	return true
+}
+
+func (s *fakeChangeStream) Current() ([]byte, string, error) {
+	return []byte(s.docs[s.next-1]), strconv.Itoa(s.next - 1), nil
+}
+
+func (s *fakeChangeStream) Err() error                  { return s.err }
+func (s *fakeChangeStream) Close(context.Context) error { s.closed = true; return nil }
+
+func TestChangeStreamSource(t *testing.T) {
+	t.Parallel()
+
+	docs := []string{
+		`{"operationType":"insert","ns":{"db":"shop","coll":"orders"},"documentKey":{"_id":1},"fullDocument":{"_id":1,"total":5}}`,
+		`{"operationType":"update","ns":{"db":"shop","coll":"orders"},"documentKey":{"_id":1},"fullDocument":{"_id":1,"total":7}}`,
+		`{"operationType":"delete","ns":{"db":"shop","coll":"orders"},"documentKey":{"_id":1}}`,
+	}
+
+	// open resumes after the change whose index is resumeToken
+	open := func(streams *[]*fakeChangeStream) ChangeStreamOpener {
+		return func(ctx context.Context, resumeToken string) (ChangeStream, error) {
+			cs := &fakeChangeStream{docs: docs}
+			if resumeToken != "" {
+				i, err := strconv.Atoi(resumeToken)
+				if err != nil {
+					return nil, errors.New("unknown resume token")
+				}
+				cs.next = i + 1
+			}
+			if streams != nil {
+				*streams = append(*streams, cs)
+			}
+			return cs, nil
+		}
+	}
+
+	t.Run("maps changes to events, with resume tokens as IDs", func(t *testing.T) {
+		t.Parallel()
+
+		var streams []*fakeChangeStream
+		events := collectEvents(t, ChangeStreamSource(open(&streams), "", nil))
+		expected := []Event{
+			{Event: "insert", ID: "0", Data: []byte(`{"_id":1,"total":5}`)},
+			{Event: "update", ID: "1", Data: []byte(`{"_id":1,"total":7}`)},
+			{Event: "delete", ID: "2", Data: []byte(`{"_id":1}`)},
+		}
+		if len(events) != len(expected) {
+			t.Fatalf("expected %d events, but got %d", len(expected), len(events))
+		}
+		for i, evt := range events {
+			if evt.Event != expected[i].Event || evt.ID != expected[i].ID || string(evt.Data) != string(expected[i].Data) {
+				t.Errorf("expected event %d to be %+v, but got %+v", i, expected[i], evt)
+			}
+		}
+		if len(streams) != 1 || !streams[0].closed {
+			t.Error("expected the change stream to be closed")
+		}
+	})
+
+	t.Run("skips changes mapped to no event", func(t *testing.T) {
+		t.Parallel()
+
+		events := collectEvents(t, ChangeStreamSource(open(nil), "", func(change Change) (Event, bool) {
+			if change.OperationType == "delete" {
+				return Event{}, false
+			}
+			return Event{Event: change.Namespace.Coll, Data: change.Raw}, true
+		}))
+		if len(events) != 2 || events[0].Event != "orders" || string(events[0].Data) != docs[0] {
+			t.Errorf("expected the mapped insert and update, but got %+v", events)
+		}
+	})
+
+	t.Run("reports change stream errors", func(t *testing.T) {
+		t.Parallel()
+
+		failed := errors.New("cursor failed")
+		src := ChangeStreamSource(func(ctx context.Context, resumeToken string) (ChangeStream, error) {
+			return &fakeChangeStream{err: failed}, nil
+		}, "", nil)
+		if err := src.Stream(context.Background(), func(Event) error { return nil }); !errors.Is(err, failed) {
+			t.Errorf("expected %v, but got %v", failed, err)
+		}
+	})
+
+	t.Run("resumes from clients' Last-Event-ID", func(t *testing.T) {
+		t.Parallel()
+
+		srv := httptest.NewServer(NewHandler(FromChangeStream(open(nil), nil)))
+		t.Cleanup(srv.Close)
+
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		req.Header.Set("Last-Event-ID", "0")
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		expected := "event:update\ndata:{\"_id\":1,\"total\":7}\nid:1\n\nevent:delete\ndata:{\"_id\":1}\nid:2\n\n"
+		if string(body) != expected {
+			t.Errorf("expected %q, but got %q", expected, body)
+		}
+	})
+}
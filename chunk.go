@@ -0,0 +1,197 @@
+package sse
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ChunkEvent is the event name of the events an oversized Event is split into.
+// See Handler.ChunkSize.
+//
+// The first data line of a chunk event is a header of the form:
+//
+//	<index> <count> <sha256> <event>
+//
+// where index is the 0-based position of the chunk, count is the total number of chunks,
+// sha256 is the hex encoded SHA-256 sum of the complete data, and event is the name of
+// the original Event (possibly empty). The remaining data is the chunk's part of the original data.
+//
+// The original ID is only sent with the last chunk, so that a client which is disconnected
+// part way through a sequence does not skip the event upon reconnecting.
+const ChunkEvent = "sse-chunk"
+
+var (
+	// ErrChunkSequence is returned by Reassembler.Add if a chunk is received out of order.
+	ErrChunkSequence = errors.New("sse: chunk received out of sequence")
+
+	// ErrChunkIntegrity is returned by Reassembler.Add if reassembled data does not match its checksum.
+	ErrChunkIntegrity = errors.New("sse: reassembled chunks failed integrity check")
+)
+
+// splitEvent splits evt into chunk events with at most size bytes of evt.Data each.
+// Splits are moved back to UTF-8 character boundaries when possible.
+// Line breaks in evt.Data are split, and summed, as the line feeds clients receive them as, see appendEvent,
+// so that a carriage return and line feed split between chunks are not received as two line breaks.
+func splitEvent(evt Event, size int) []Event {
+	evt.Data = normalizeLineBreaks(evt.Data)
+
+	var parts [][]byte
+	for data := evt.Data; len(data) > 0; {
+		n := size
+		if n >= len(data) {
+			n = len(data)
+		} else {
+			for i := n; i > n-utf8.UTFMax && i > 0; i-- {
+				if utf8.RuneStart(data[i]) {
+					n = i
+					break
+				}
+			}
+		}
+
+		parts = append(parts, data[:n])
+		data = data[n:]
+	}
+
+	sum := sha256.Sum256(evt.Data)
+	header := " " + strconv.Itoa(len(parts)) + " " + hex.EncodeToString(sum[:]) + " " + evt.Event + "\n"
+
+	chunks := make([]Event, len(parts))
+	for i, part := range parts {
+		data := make([]byte, 0, len(header)+len(part)+3)
+		data = strconv.AppendInt(data, int64(i), 10)
+		data = append(data, header...)
+		data = append(data, part...)
+
+		chunks[i] = Event{
+			Event: ChunkEvent,
+			Data:  data,
+		}
+	}
+
+	chunks[0].Retry = evt.Retry
+	chunks[0].Comment = evt.Comment
+	chunks[len(chunks)-1].ID = evt.ID
+	chunks[len(chunks)-1].ResetID = evt.ResetID
+	return chunks
+}
+
+// normalizeLineBreaks returns data with its carriage returns, and carriage return and line feed pairs,
+// replaced by line feeds. data is returned as is if it has no carriage returns.
+func normalizeLineBreaks(data []byte) []byte {
+	if bytes.IndexByte(data, '\r') < 0 {
+		return data
+	}
+
+	normalized := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] != '\r' {
+			normalized = append(normalized, data[i])
+			continue
+		}
+		normalized = append(normalized, '\n')
+		if i+1 < len(data) && data[i+1] == '\n' {
+			i++
+		}
+	}
+	return normalized
+}
+
+// Reassembler reassembles events that were split into chunk events by a Handler.
+// Received events should be passed to Add in the order they were received.
+// The zero value is ready to use.
+type Reassembler struct {
+	next  int
+	count int
+	sum   string
+	event string
+	retry time.Duration
+	data  bytes.Buffer
+}
+
+// Add adds a received event to the Reassembler.
+// If evt is not a chunk event, it is returned as is, and ok is true.
+// If evt is the last chunk of a sequence, the reassembled Event is returned, and ok is true.
+// Otherwise, ok is false, and the chunk is held until the sequence is complete.
+//
+// If a chunk is malformed, received out of sequence, or the reassembled data does not match
+// its checksum, an error is returned, and the current sequence is discarded.
+func (r *Reassembler) Add(evt Event) (_ Event, ok bool, err error) {
+	if evt.Event != ChunkEvent {
+		return evt, true, nil
+	}
+
+	defer func() {
+		if err != nil {
+			r.Reset()
+		}
+	}()
+
+	i := bytes.IndexByte(evt.Data, '\n')
+	if i < 0 {
+		return Event{}, false, errors.New("sse: malformed chunk: missing header")
+	}
+
+	fields := strings.SplitN(string(evt.Data[:i]), " ", 4)
+	if len(fields) != 4 {
+		return Event{}, false, fmt.Errorf("sse: malformed chunk header %q", evt.Data[:i])
+	}
+
+	index, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return Event{}, false, fmt.Errorf("sse: malformed chunk index: %w", err)
+	}
+
+	count, err := strconv.Atoi(fields[1])
+	if err != nil || count < 1 {
+		return Event{}, false, fmt.Errorf("sse: malformed chunk count %q", fields[1])
+	}
+
+	if index == 0 {
+		r.Reset()
+		r.count = count
+		r.sum = fields[2]
+		r.event = fields[3]
+		r.retry = evt.Retry
+	} else if index != r.next || count != r.count || fields[2] != r.sum {
+		return Event{}, false, ErrChunkSequence
+	}
+
+	r.data.Write(evt.Data[i+1:])
+	r.next++
+
+	if r.next < r.count {
+		return Event{}, false, nil
+	}
+
+	sum := sha256.Sum256(r.data.Bytes())
+	if hex.EncodeToString(sum[:]) != r.sum {
+		return Event{}, false, ErrChunkIntegrity
+	}
+
+	out := Event{
+		Event: r.event,
+		Data:  append([]byte(nil), r.data.Bytes()...),
+		ID:    evt.ID,
+		Retry: r.retry,
+	}
+	r.Reset()
+	return out, true, nil
+}
+
+// Reset discards any partially reassembled event.
+func (r *Reassembler) Reset() {
+	r.next = 0
+	r.count = 0
+	r.sum = ""
+	r.event = ""
+	r.retry = 0
+	r.data.Reset()
+}
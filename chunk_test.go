@@ -0,0 +1,208 @@
+package sse
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChunking(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round trips", func(t *testing.T) {
+		t.Parallel()
+
+		evt := Event{
+			Event: "large",
+			Data:  []byte(strings.Repeat("héllo\nwörld ", 20)),
+			ID:    "42",
+			Retry: time.Second,
+		}
+
+		chunks := splitEvent(evt, 16)
+		if len(chunks) < 2 {
+			t.Fatalf("expected multiple chunks, but got %d", len(chunks))
+		}
+
+		for i, chunk := range chunks[:len(chunks)-1] {
+			if chunk.ID != "" {
+				t.Errorf("expected chunk %d to not have an ID, but got %q", i, chunk.ID)
+			}
+		}
+
+		var r Reassembler
+		for i, chunk := range chunks {
+			out, ok, err := r.Add(chunk)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if i < len(chunks)-1 {
+				if ok {
+					t.Fatalf("expected chunk %d to be held", i)
+				}
+				continue
+			}
+
+			if !ok {
+				t.Fatal("expected last chunk to complete the event")
+			}
+
+			if out.Event != evt.Event || out.ID != evt.ID || out.Retry != evt.Retry || !bytes.Equal(out.Data, evt.Data) {
+				t.Errorf("expected reassembled event %+v, but got %+v", evt, out)
+			}
+		}
+	})
+
+	t.Run("round trips carriage returns through a Decoder", func(t *testing.T) {
+		t.Parallel()
+
+		for _, data := range []string{strings.Repeat("a\r\n", 20), strings.Repeat("ab\rc", 10), "abcdefg\r\nhij\r"} {
+			var buf bytes.Buffer
+			for _, chunk := range splitEvent(Event{Event: "large", Data: []byte(data)}, 8) {
+				if err := NewEncoder(&buf).WriteEvent(chunk); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var (
+				r   Reassembler
+				out Event
+				ok  bool
+			)
+			dec := NewDecoder(&buf)
+			for !ok {
+				evt, err := dec.Decode()
+				if err != nil {
+					t.Fatalf("%q: %v", data, err)
+				}
+				if out, ok, err = r.Add(evt); err != nil {
+					t.Fatalf("%q: %v", data, err)
+				}
+			}
+
+			expected := strings.ReplaceAll(strings.ReplaceAll(data, "\r\n", "\n"), "\r", "\n")
+			if string(out.Data) != expected {
+				t.Errorf("%q: expected reassembled data %q, but got %q", data, expected, out.Data)
+			}
+		}
+	})
+
+	t.Run("passes through other events", func(t *testing.T) {
+		t.Parallel()
+
+		var r Reassembler
+		evt := Event{Event: "small", Data: []byte("hi")}
+		out, ok, err := r.Add(evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || out.Event != evt.Event || !bytes.Equal(out.Data, evt.Data) {
+			t.Errorf("expected event to be passed through, but got %+v (ok = %v)", out, ok)
+		}
+	})
+
+	t.Run("detects corruption", func(t *testing.T) {
+		t.Parallel()
+
+		chunks := splitEvent(Event{Data: []byte(strings.Repeat("x", 64))}, 16)
+		chunks[1].Data[len(chunks[1].Data)-1] = 'y'
+
+		var r Reassembler
+		var err error
+		for _, chunk := range chunks {
+			if _, _, err = r.Add(chunk); err != nil {
+				break
+			}
+		}
+
+		if !errors.Is(err, ErrChunkIntegrity) {
+			t.Errorf("expected ErrChunkIntegrity, but got %v", err)
+		}
+	})
+
+	t.Run("detects missing chunks", func(t *testing.T) {
+		t.Parallel()
+
+		chunks := splitEvent(Event{Data: []byte(strings.Repeat("x", 64))}, 16)
+
+		var r Reassembler
+		if _, _, err := r.Add(chunks[0]); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := r.Add(chunks[2]); !errors.Is(err, ErrChunkSequence) {
+			t.Errorf("expected ErrChunkSequence, but got %v", err)
+		}
+	})
+
+	t.Run("Handler splits large events", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			go func() {
+				stream.Send(Event{Event: "small", Data: []byte("short")})
+				stream.Send(Event{Event: "large", Data: []byte(strings.Repeat("x", 100))})
+				stream.Close()
+			}()
+			return nil
+		})
+		h.ChunkSize = 32
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		client := srv.Client()
+		resp, err := client.Get(srv.URL + "?" + ExtensionsParam + "=" + string(ExtChunk))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal("failed to read response body:", err)
+		}
+
+		if !bytes.Contains(body, []byte("event:small\ndata:short\n\n")) {
+			t.Errorf("expected small event to be sent as is, but was not found: %q", body)
+		}
+
+		if n := bytes.Count(body, []byte("event:"+ChunkEvent+"\n")); n != 4 {
+			t.Errorf("expected 4 chunk events, but got %d: %q", n, body)
+		}
+	})
+
+	t.Run("Handler does not split events with a DataReader", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			go func() {
+				stream.Send(Event{Data: []byte(strings.Repeat("x", 100)), DataReader: strings.NewReader("READER")})
+				stream.Close()
+			}()
+			return nil
+		})
+		h.ChunkSize = 32
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		resp, err := srv.Client().Get(srv.URL + "?" + ExtensionsParam + "=" + string(ExtChunk))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal("failed to read response body:", err)
+		}
+
+		expected := "data:" + strings.Repeat("x", 100) + "\ndata:READER\n\n"
+		if string(body) != expected {
+			t.Errorf("expected event to be sent as is %q, but got %q", expected, body)
+		}
+	})
+}
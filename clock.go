@@ -0,0 +1,129 @@
+package sse
+
+import (
+	"encoding/json"
+	"math"
+	"sync"
+	"time"
+)
+
+// ReceivedEvent is an event stamped by a ReceiveClock with when it was received.
+type ReceivedEvent struct {
+	Event
+
+	// Received is when the event was received. It has a monotonic clock reading, so that the intervals
+	// between events are measured correctly even if the wall clock is adjusted.
+	Received time.Time
+
+	// Sent is when the server sent the event, according to the ReceiveClock's Timestamp, if known.
+	Sent time.Time
+
+	// Latency is how long the event took to be received after it was sent, if Sent is known,
+	// corrected for the estimated clock skew if the ReceiveClock's CorrectSkew is set.
+	Latency time.Duration
+}
+
+// ReceiveClock stamps the events a client receives with when they were received, and, for events carrying
+// the time they were sent, e.g. in a field of their data (see JSONTimestamp), measures their latency.
+// The zero value only stamps events. It is safe for concurrent use.
+type ReceiveClock struct {
+	// Timestamp, if not nil, returns the time evt was sent at, as stamped by the server, or the zero time
+	// if it was not.
+	Timestamp func(evt Event) time.Time
+
+	// CorrectSkew enables correcting latencies for the difference between the server's clock and the
+	// client's, which is estimated as the smallest difference between an event's Sent and Received times
+	// seen so far. Latencies are then relative to the fastest event, rather than absolute, so they are
+	// best used to spot slow events, or trends.
+	CorrectSkew bool
+
+	// OnReceive, if not nil, is called with each event stamped, e.g. to record its latency in a metric.
+	OnReceive func(evt ReceivedEvent)
+
+	mu   sync.Mutex
+	skew time.Duration
+	seen bool
+}
+
+// Receive stamps evt as received now.
+func (c *ReceiveClock) Receive(evt Event) ReceivedEvent {
+	received := ReceivedEvent{Event: evt, Received: time.Now()}
+
+	if c.Timestamp != nil {
+		if sent := c.Timestamp(evt); !sent.IsZero() {
+			received.Sent = sent
+			// Sent has no monotonic clock reading, so this compares the wall clocks of the server and client
+			received.Latency = received.Received.Sub(sent)
+			if c.CorrectSkew {
+				received.Latency -= c.observe(received.Latency)
+			}
+		}
+	}
+
+	if c.OnReceive != nil {
+		c.OnReceive(received)
+	}
+	return received
+}
+
+// Skew returns the estimated difference between the client's clock and the server's, see CorrectSkew,
+// and whether any events carrying the time they were sent have been received to estimate it from.
+func (c *ReceiveClock) Skew() (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.skew, c.seen
+}
+
+// observe records the uncorrected latency of an event, and returns the estimated skew.
+func (c *ReceiveClock) observe(latency time.Duration) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.seen || latency < c.skew {
+		c.skew, c.seen = latency, true
+	}
+	return c.skew
+}
+
+// JSONTimestamp returns a function for ReceiveClock.Timestamp, or Join.Timestamp, that reads the time an event
+// was sent from the field of its JSON data named field, either as an RFC 3339 string, or as a number of
+// milliseconds since the Unix epoch. It returns the zero time if the field is missing, or malformed.
+func JSONTimestamp(field string) func(evt Event) time.Time {
+	return func(evt Event) time.Time {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(evt.Data, &fields) != nil {
+			return time.Time{}
+		}
+
+		raw, ok := fields[field]
+		if !ok {
+			return time.Time{}
+		}
+
+		var t time.Time
+		if json.Unmarshal(raw, &t) == nil {
+			return t
+		}
+
+		var ms float64
+		if json.Unmarshal(raw, &ms) == nil && ms > 0 && ms < math.MaxInt64/float64(time.Millisecond) {
+			return time.UnixMilli(int64(ms))
+		}
+		return time.Time{}
+	}
+}
+
+// Heartbeat returns a Handler.KeepAlivePayload sending events named name, rather than comments, whose data
+// is the time they are sent, as JSON, so that clients can tell the connection is alive, and measure its latency
+// with a ReceiveClock using JSONTimestamp("time"):
+//
+//	{"time":"2024-05-01T12:00:00.123456789Z"}
+func Heartbeat(name string) func() Event {
+	return func() Event {
+		data, _ := json.Marshal(struct {
+			Time time.Time `json:"time"`
+		}{time.Now().UTC()})
+		return Event{Event: name, Data: data}
+	}
+}
@@ -0,0 +1,103 @@
+package sse
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestReceiveClock(t *testing.T) {
+	t.Parallel()
+
+	t.Run("stamps events", func(t *testing.T) {
+		t.Parallel()
+
+		var clock ReceiveClock
+		before := time.Now()
+		evt := clock.Receive(Event{Data: []byte("hello")})
+		if evt.Received.Before(before) || string(evt.Data) != "hello" {
+			t.Errorf("expected the event to be stamped now, but got %+v", evt)
+		}
+		if !evt.Sent.IsZero() || evt.Latency != 0 {
+			t.Errorf("expected no latency without a timestamp, but got %v", evt.Latency)
+		}
+	})
+
+	t.Run("measures latency", func(t *testing.T) {
+		t.Parallel()
+
+		var reported []time.Duration
+		clock := ReceiveClock{
+			Timestamp: JSONTimestamp("sent_at"),
+			OnReceive: func(evt ReceivedEvent) { reported = append(reported, evt.Latency) },
+		}
+
+		sent := time.Now().Add(-time.Second)
+		evt := clock.Receive(Event{Data: []byte(fmt.Sprintf(`{"sent_at":%d}`, sent.UnixMilli()))})
+		if evt.Latency < time.Second-time.Millisecond || evt.Latency > 2*time.Second {
+			t.Errorf("expected a latency of about 1s, but got %v", evt.Latency)
+		}
+		if len(reported) != 1 || reported[0] != evt.Latency {
+			t.Errorf("expected OnReceive to be called with the latency, but got %v", reported)
+		}
+	})
+
+	t.Run("corrects for clock skew", func(t *testing.T) {
+		t.Parallel()
+
+		// the server's clock is an hour behind the client's
+		clock := ReceiveClock{Timestamp: JSONTimestamp("sent_at"), CorrectSkew: true}
+		send := func(latency time.Duration) ReceivedEvent {
+			sent := time.Now().Add(-time.Hour - latency).UTC()
+			return clock.Receive(Event{Data: []byte(`{"sent_at":"` + sent.Format(time.RFC3339Nano) + `"}`)})
+		}
+
+		if evt := send(10 * time.Millisecond); evt.Latency != 0 {
+			t.Errorf("expected the first event to set the baseline, but got %v", evt.Latency)
+		}
+		if evt := send(510 * time.Millisecond); evt.Latency < 450*time.Millisecond || evt.Latency > 550*time.Millisecond {
+			t.Errorf("expected a latency of about 500ms over the baseline, but got %v", evt.Latency)
+		}
+		if skew, ok := clock.Skew(); !ok || skew < time.Hour || skew > time.Hour+time.Second {
+			t.Errorf("expected a skew of about an hour, but got %v, %v", skew, ok)
+		}
+	})
+}
+
+func TestJSONTimestamp(t *testing.T) {
+	t.Parallel()
+
+	ts := JSONTimestamp("at")
+	expected := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	for _, tt := range []struct {
+		data  string
+		valid bool
+	}{
+		{`{"at":"2024-01-02T15:04:05Z"}`, true},
+		{fmt.Sprintf(`{"at":%d}`, expected.UnixMilli()), true},
+		{`{"other":1}`, false},
+		{`{"at":"yesterday"}`, false},
+		{`not json`, false},
+	} {
+		got := ts(Event{Data: []byte(tt.data)})
+		if tt.valid && !got.Equal(expected) {
+			t.Errorf("%s: expected %v, but got %v", tt.data, expected, got)
+		}
+		if !tt.valid && !got.IsZero() {
+			t.Errorf("%s: expected the zero time, but got %v", tt.data, got)
+		}
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	before := time.Now()
+	evt := Heartbeat("heartbeat")()
+	if evt.Event != "heartbeat" {
+		t.Errorf("expected event %q, but got %q", "heartbeat", evt.Event)
+	}
+	if sent := JSONTimestamp("time")(evt); sent.Before(before) || sent.After(time.Now()) {
+		t.Errorf("expected the heartbeat to carry the time it was sent, but got %v from %s", sent, evt.Data)
+	}
+}
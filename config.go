@@ -0,0 +1,232 @@
+package sse
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the settings of a Handler that can be loaded from a service's configuration,
+// i.e. those that are not functions or interfaces.
+// Start from DefaultConfig, and override it with FromEnv, or json.Unmarshal, before calling Apply:
+//
+//	cfg := sse.DefaultConfig()
+//	if err := cfg.FromEnv("SSE_"); err != nil {
+//	    return err
+//	}
+//	cfg.Apply(handler)
+//
+// Durations are given as strings understood by time.ParseDuration, e.g. "15s", or as a number of nanoseconds.
+type Config struct {
+	KeepAlive             time.Duration `json:"keep_alive"`
+	Park                  bool          `json:"park"`
+	ChunkSize             int           `json:"chunk_size"`
+	GzipThreshold         int           `json:"gzip_threshold"`
+	DuplicateWindow       time.Duration `json:"duplicate_window"`
+	DeltaEncoding         bool          `json:"delta_encoding"`
+	DeltaSnapshotInterval time.Duration `json:"delta_snapshot_interval"`
+	ReplayBatchSize       int           `json:"replay_batch_size"`
+	ReplayBatchDelay      time.Duration `json:"replay_batch_delay"`
+	ReplayLimit           int           `json:"replay_limit"`
+	ReplayOnlyMaxAge      time.Duration `json:"replay_only_max_age"`
+	CatchUpURL            string        `json:"catch_up_url"`
+	BrowserCompat         bool          `json:"browser_compat"`
+	WriteTimeout          time.Duration `json:"write_timeout"`
+	DefaultRetry          time.Duration `json:"default_retry"`
+	Padding               int           `json:"padding"`
+	ShutdownRetry         time.Duration `json:"shutdown_retry"`
+	MaxConnectionAge      time.Duration `json:"max_connection_age"`
+	MaxConnections        int           `json:"max_connections"`
+	MaxConnectionsRetry   time.Duration `json:"max_connections_retry"`
+	TarpitDelay           time.Duration `json:"tarpit_delay"`
+	LatencyBudget         time.Duration `json:"latency_budget"`
+	DisconnectLagging     bool          `json:"disconnect_lagging"`
+	StrictEvents          bool          `json:"strict_events"`
+	FieldSpace            bool          `json:"field_space"`
+	FlushBatchBytes       int           `json:"flush_batch_bytes"`
+	FlushBatchEvents      int           `json:"flush_batch_events"`
+
+	// BufferSize is the buffer size of each EventStream's events channel, see NewHandlerBuffered.
+	// Changing it with Handler.UpdateConfig only affects connections made afterwards.
+	BufferSize uint `json:"buffer_size"`
+
+	// MemoryLimit enables giving the Handler a MemoryAllocator with this limit when not 0.
+	// If the Handler already has a MemoryAllocator, its limit is changed instead, so that the memory
+	// already reserved by existing connections stays accounted for.
+	MemoryLimit int64 `json:"memory_limit"`
+}
+
+// DefaultConfig returns a Config with defaults suitable for most services: a keep-alive every
+// 15 seconds, so proxies do not close idle connections, and a write timeout of 30 seconds,
+// so clients that stop reading are disconnected.
+func DefaultConfig() Config {
+	return Config{
+		KeepAlive:       15 * time.Second,
+		ReplayBatchSize: DefaultReplayBatchSize,
+		WriteTimeout:    30 * time.Second,
+	}
+}
+
+// Apply sets h's fields according to c.
+// It must not be called once h is serving connections, except through Handler.UpdateConfig:
+//
+//	handler.UpdateConfig(cfg.Apply)
+func (c Config) Apply(h *Handler) {
+	h.KeepAlive = c.KeepAlive
+	h.chanBufSize = c.BufferSize
+	h.Park = c.Park
+	h.ChunkSize = c.ChunkSize
+	h.GzipThreshold = c.GzipThreshold
+	h.DuplicateWindow = c.DuplicateWindow
+	h.DeltaEncoding = c.DeltaEncoding
+	h.DeltaSnapshotInterval = c.DeltaSnapshotInterval
+	h.ReplayBatchSize = c.ReplayBatchSize
+	h.ReplayBatchDelay = c.ReplayBatchDelay
+	h.ReplayLimit = c.ReplayLimit
+	h.ReplayOnlyMaxAge = c.ReplayOnlyMaxAge
+	h.CatchUpURL = c.CatchUpURL
+	h.BrowserCompat = c.BrowserCompat
+	h.WriteTimeout = c.WriteTimeout
+	h.DefaultRetry = c.DefaultRetry
+	h.Padding = c.Padding
+	h.ShutdownRetry = c.ShutdownRetry
+	h.MaxConnectionAge = c.MaxConnectionAge
+	h.MaxConnections = c.MaxConnections
+	h.MaxConnectionsRetry = c.MaxConnectionsRetry
+	h.TarpitDelay = c.TarpitDelay
+	h.LatencyBudget = c.LatencyBudget
+	h.DisconnectLagging = c.DisconnectLagging
+	h.StrictEvents = c.StrictEvents
+	h.FieldSpace = c.FieldSpace
+	h.FlushBatchBytes = c.FlushBatchBytes
+	h.FlushBatchEvents = c.FlushBatchEvents
+
+	if c.MemoryLimit != 0 {
+		if a, ok := h.Allocator.(*MemoryAllocator); ok {
+			a.SetLimit(c.MemoryLimit)
+		} else {
+			h.Allocator = NewMemoryAllocator(c.MemoryLimit)
+		}
+	}
+}
+
+// FromEnv overrides c with the environment variables that are set, named by prefix followed by
+// the upper case form of each field's JSON key, e.g. "SSE_KEEP_ALIVE" for a prefix of "SSE_".
+func (c *Config) FromEnv(prefix string) error {
+	for _, f := range c.fields() {
+		name := prefix + strings.ToUpper(f.key)
+		if value, ok := os.LookupEnv(name); ok {
+			if err := f.set(value); err != nil {
+				return fmt.Errorf("sse: invalid %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON overrides c with the keys present in the JSON object in data.
+// Unknown keys are rejected, so that misspelled settings are not silently ignored.
+func (c *Config) UnmarshalJSON(data []byte) error {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	fields := make(map[string]configField)
+	for _, f := range c.fields() {
+		fields[f.key] = f
+	}
+
+	for key, raw := range values {
+		f, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("sse: unknown configuration key %q", key)
+		}
+
+		value := string(raw)
+		if strings.HasPrefix(value, `"`) {
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return fmt.Errorf("sse: invalid %s: %w", key, err)
+			}
+		}
+
+		if err := f.set(value); err != nil {
+			return fmt.Errorf("sse: invalid %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// configField is a setting of a Config, identified by its JSON key.
+type configField struct {
+	key string
+	ptr interface{}
+}
+
+func (c *Config) fields() []configField {
+	return []configField{
+		{"keep_alive", &c.KeepAlive},
+		{"buffer_size", &c.BufferSize},
+		{"park", &c.Park},
+		{"chunk_size", &c.ChunkSize},
+		{"gzip_threshold", &c.GzipThreshold},
+		{"duplicate_window", &c.DuplicateWindow},
+		{"delta_encoding", &c.DeltaEncoding},
+		{"delta_snapshot_interval", &c.DeltaSnapshotInterval},
+		{"replay_batch_size", &c.ReplayBatchSize},
+		{"replay_batch_delay", &c.ReplayBatchDelay},
+		{"replay_limit", &c.ReplayLimit},
+		{"replay_only_max_age", &c.ReplayOnlyMaxAge},
+		{"catch_up_url", &c.CatchUpURL},
+		{"browser_compat", &c.BrowserCompat},
+		{"write_timeout", &c.WriteTimeout},
+		{"default_retry", &c.DefaultRetry},
+		{"padding", &c.Padding},
+		{"shutdown_retry", &c.ShutdownRetry},
+		{"max_connection_age", &c.MaxConnectionAge},
+		{"max_connections", &c.MaxConnections},
+		{"max_connections_retry", &c.MaxConnectionsRetry},
+		{"tarpit_delay", &c.TarpitDelay},
+		{"latency_budget", &c.LatencyBudget},
+		{"disconnect_lagging", &c.DisconnectLagging},
+		{"strict_events", &c.StrictEvents},
+		{"field_space", &c.FieldSpace},
+		{"flush_batch_bytes", &c.FlushBatchBytes},
+		{"flush_batch_events", &c.FlushBatchEvents},
+		{"memory_limit", &c.MemoryLimit},
+	}
+}
+
+// set parses value into the field.
+func (f configField) set(value string) error {
+	var err error
+	switch ptr := f.ptr.(type) {
+	case *time.Duration:
+		if ns, nerr := strconv.ParseInt(value, 10, 64); nerr == nil {
+			*ptr = time.Duration(ns)
+		} else {
+			*ptr, err = time.ParseDuration(value)
+		}
+
+	case *int:
+		*ptr, err = strconv.Atoi(value)
+
+	case *int64:
+		*ptr, err = strconv.ParseInt(value, 10, 64)
+
+	case *uint:
+		var n uint64
+		n, err = strconv.ParseUint(value, 10, 0)
+		*ptr = uint(n)
+
+	case *bool:
+		*ptr, err = strconv.ParseBool(value)
+
+	case *string:
+		*ptr = value
+	}
+	return err
+}
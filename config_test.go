@@ -0,0 +1,147 @@
+package sse
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestConfig(t *testing.T) {
+	t.Parallel()
+
+	t.Run("loads JSON", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := DefaultConfig()
+		err := json.Unmarshal([]byte(`{
+			"keep_alive": "5s",
+			"write_timeout": 1000000,
+			"chunk_size": 1024,
+			"park": true,
+			"catch_up_url": "/events/missed"
+		}`), &cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cfg.KeepAlive != 5*time.Second {
+			t.Errorf("expected keep_alive 5s, but got %v", cfg.KeepAlive)
+		}
+		if cfg.WriteTimeout != time.Millisecond {
+			t.Errorf("expected write_timeout 1ms, but got %v", cfg.WriteTimeout)
+		}
+		if cfg.ChunkSize != 1024 || !cfg.Park || cfg.CatchUpURL != "/events/missed" {
+			t.Errorf("expected settings to be loaded, but got %+v", cfg)
+		}
+		if cfg.ReplayBatchSize != DefaultReplayBatchSize {
+			t.Errorf("expected missing settings to keep their defaults, but got replay_batch_size %d", cfg.ReplayBatchSize)
+		}
+	})
+
+	t.Run("round trips JSON", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := DefaultConfig()
+		cfg.Park = true
+		cfg.MemoryLimit = 1 << 20
+
+		data, err := json.Marshal(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var out Config
+		if err := json.Unmarshal(data, &out); err != nil {
+			t.Fatal(err)
+		}
+		if out != cfg {
+			t.Errorf("expected %+v, but got %+v", cfg, out)
+		}
+	})
+
+	t.Run("rejects invalid JSON settings", func(t *testing.T) {
+		t.Parallel()
+
+		for _, data := range []string{
+			`{"keepalive": "5s"}`,
+			`{"keep_alive": "soon"}`,
+			`{"chunk_size": "large"}`,
+			`{"park": 1.5}`,
+		} {
+			var cfg Config
+			if err := json.Unmarshal([]byte(data), &cfg); err == nil {
+				t.Errorf("%s: expected an error, but got %+v", data, cfg)
+			}
+		}
+	})
+
+	t.Run("applies to Handler", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := DefaultConfig()
+		cfg.BufferSize = 8
+		cfg.MemoryLimit = 100
+
+		h := NewHandler(nil)
+		cfg.Apply(h)
+
+		if h.KeepAlive != cfg.KeepAlive || h.WriteTimeout != cfg.WriteTimeout || h.chanBufSize != 8 {
+			t.Errorf("expected Handler to be configured, but got %+v", h)
+		}
+		if a, ok := h.Allocator.(*MemoryAllocator); !ok || a.Limit() != 100 {
+			t.Errorf("expected a MemoryAllocator with limit 100, but got %v", h.Allocator)
+		}
+	})
+
+	t.Run("updates Handler", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := DefaultConfig()
+		cfg.BufferSize = 8
+		cfg.MemoryLimit = 100
+
+		h := NewHandler(nil)
+		cfg.Apply(h)
+		alloc := h.Allocator.(*MemoryAllocator)
+		alloc.Reserve(60)
+
+		cfg.KeepAlive = time.Minute
+		cfg.BufferSize = 16
+		cfg.MemoryLimit = 200
+		h.UpdateConfig(cfg.Apply)
+
+		current, _ := h.load()
+		if current.KeepAlive != time.Minute || current.chanBufSize != 16 {
+			t.Errorf("expected Handler to be reconfigured, but got %+v", current)
+		}
+		if current.Allocator != alloc {
+			t.Errorf("expected MemoryAllocator to be kept, but got %v", current.Allocator)
+		}
+		if alloc.Limit() != 200 || alloc.InUse() != 60 {
+			t.Errorf("expected limit 200 with 60 bytes in use, but got limit %d with %d in use", alloc.Limit(), alloc.InUse())
+		}
+	})
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("TEST_SSE_KEEP_ALIVE", "1m")
+	t.Setenv("TEST_SSE_BROWSER_COMPAT", "true")
+	t.Setenv("TEST_SSE_MEMORY_LIMIT", "4096")
+
+	cfg := DefaultConfig()
+	if err := cfg.FromEnv("TEST_SSE_"); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.KeepAlive != time.Minute || !cfg.BrowserCompat || cfg.MemoryLimit != 4096 {
+		t.Errorf("expected settings to be loaded, but got %+v", cfg)
+	}
+	if cfg.WriteTimeout != DefaultConfig().WriteTimeout {
+		t.Errorf("expected unset settings to keep their defaults, but got write timeout %v", cfg.WriteTimeout)
+	}
+
+	t.Setenv("TEST_SSE_REPLAY_LIMIT", "many")
+	if err := cfg.FromEnv("TEST_SSE_"); err == nil {
+		t.Error("expected an error for an invalid setting")
+	}
+}
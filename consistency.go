@@ -0,0 +1,190 @@
+package sse
+
+import (
+	"cmp"
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Sequencer is a Broker that assigns the events published with another an ID, before publishing them,
+// so that a publisher learns the ID of its event synchronously, see PublishID, e.g. to return it from the
+// request that made a change, for the client that made it to wait for with an Observer, so that it can
+// read its own writes from its stream:
+//
+//	id, err := seq.PublishID(ctx, "orders/42", evt)
+//	...
+//	w.Header().Set("Event-ID", id)
+//
+// and on the client:
+//
+//	observer.Wait(ctx, resp.Header.Get("Event-ID"))
+//
+// The IDs it assigns are decimal numbers that increase with each event, ordered by CompareSequenceIDs,
+// starting from the time in nanoseconds, so that they still increase once the process is restarted.
+// Events published with an ID keep it. Subscribing is left to the other Broker.
+type Sequencer struct {
+	// Broker is the Broker events are published with, and subscribed to.
+	Broker Broker
+
+	mu   sync.Mutex
+	last uint64
+}
+
+// Publish publishes evt to topic with Broker, with an ID assigned if it has none, as by PublishID.
+func (s *Sequencer) Publish(ctx context.Context, topic string, evt Event) error {
+	_, err := s.PublishID(ctx, topic, evt)
+	return err
+}
+
+// PublishID publishes evt to topic with Broker, with an ID assigned if it has none, and returns its ID.
+func (s *Sequencer) PublishID(ctx context.Context, topic string, evt Event) (string, error) {
+	if evt.ID == "" && !evt.ResetID {
+		evt.ID = s.next()
+	}
+
+	if err := s.Broker.Publish(ctx, topic, evt); err != nil {
+		return "", err
+	}
+	return evt.ID, nil
+}
+
+// Subscribe subscribes to topic with Broker.
+func (s *Sequencer) Subscribe(topic, lastEventID string) EventSource {
+	return s.Broker.Subscribe(topic, lastEventID)
+}
+
+func (s *Sequencer) next() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.last++
+	if now := uint64(time.Now().UnixNano()); now > s.last {
+		s.last = now
+	}
+	return strconv.FormatUint(s.last, 10)
+}
+
+// CompareSequenceIDs compares the IDs a and b assigned by a Sequencer, returning -1 if a is before b,
+// 0 if they are equal, and +1 if a is after b. IDs that are not decimal numbers are compared as strings.
+func CompareSequenceIDs(a, b string) int {
+	x, errA := strconv.ParseUint(a, 10, 64)
+	y, errB := strconv.ParseUint(b, 10, 64)
+	if errA != nil || errB != nil {
+		return cmp.Compare(a, b)
+	}
+	return cmp.Compare(x, y)
+}
+
+// DefaultObserverHistory is the default Observer.History.
+const DefaultObserverHistory = 1024
+
+// Observer records the IDs of the events a client receives, so that it can wait to receive a particular one,
+// e.g. that of the change it just made, see Sequencer. Each event the client receives should be passed to
+// Observe. The zero Observer is ready to use, and it is safe for concurrent use.
+type Observer struct {
+	// Compare, if not nil, orders event IDs, e.g. CompareSequenceIDs, so that Wait returns once the event
+	// with the ID, or a later one, has been received, since the event itself may have been filtered out,
+	// or superseded. Otherwise, Wait returns once the event with the ID itself has been received.
+	Compare func(a, b string) int
+
+	// History is the number of the latest IDs received that are recorded, for Wait to return for without
+	// waiting, if Compare is nil. If 0, DefaultObserverHistory is used.
+	History int
+
+	mu      sync.Mutex
+	last    string
+	latest  string // by Compare
+	seen    map[string]struct{}
+	order   []string
+	waiters map[chan struct{}]string
+}
+
+// Observe records that evt has been received, and wakes the calls to Wait waiting for it.
+func (o *Observer) Observe(evt Event) {
+	if evt.ID == "" {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.last = evt.ID
+	if o.Compare != nil {
+		if o.latest == "" || o.Compare(evt.ID, o.latest) > 0 {
+			o.latest = evt.ID
+		}
+	} else {
+		history := o.History
+		if history <= 0 {
+			history = DefaultObserverHistory
+		}
+		if o.seen == nil {
+			o.seen = make(map[string]struct{})
+		}
+		if _, ok := o.seen[evt.ID]; !ok {
+			o.seen[evt.ID] = struct{}{}
+			o.order = append(o.order, evt.ID)
+		}
+		for len(o.order) > history {
+			delete(o.seen, o.order[0])
+			o.order = o.order[1:]
+		}
+	}
+
+	for wake, id := range o.waiters {
+		if o.observed(id) {
+			close(wake)
+			delete(o.waiters, wake)
+		}
+	}
+}
+
+// observed reports whether the event with id, or if Compare is set, a later one, has been received.
+// o.mu must be held.
+func (o *Observer) observed(id string) bool {
+	if o.Compare != nil {
+		return o.latest != "" && o.Compare(o.latest, id) >= 0
+	}
+	_, ok := o.seen[id]
+	return ok
+}
+
+// Wait blocks until the event with id, or if Compare is set, a later one, has been received, and returns nil,
+// or until ctx is done, and returns its error. It returns nil immediately if id is empty.
+func (o *Observer) Wait(ctx context.Context, id string) error {
+	if id == "" {
+		return nil
+	}
+
+	o.mu.Lock()
+	if o.observed(id) {
+		o.mu.Unlock()
+		return nil
+	}
+	if o.waiters == nil {
+		o.waiters = make(map[chan struct{}]string)
+	}
+	wake := make(chan struct{})
+	o.waiters[wake] = id
+	o.mu.Unlock()
+
+	select {
+	case <-wake:
+		return nil
+	case <-ctx.Done():
+		o.mu.Lock()
+		delete(o.waiters, wake)
+		o.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// LastEventID returns the ID of the last event received with an ID.
+func (o *Observer) LastEventID() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.last
+}
@@ -0,0 +1,153 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSequencer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("assigns increasing IDs", func(t *testing.T) {
+		t.Parallel()
+
+		seq := &Sequencer{Broker: &Bus{}}
+		var last string
+		for i := 0; i < 100; i++ {
+			id, err := seq.PublishID(context.Background(), "orders", Event{Data: []byte(strconv.Itoa(i))})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if last != "" && CompareSequenceIDs(id, last) <= 0 {
+				t.Fatalf("expected %q to be after %q", id, last)
+			}
+			last = id
+		}
+	})
+
+	t.Run("keeps IDs that are set", func(t *testing.T) {
+		t.Parallel()
+
+		seq := &Sequencer{Broker: &Bus{}}
+		if id, err := seq.PublishID(context.Background(), "orders", Event{ID: "mine"}); err != nil || id != "mine" {
+			t.Errorf("expected %q, but got %q (%v)", "mine", id, err)
+		}
+		if id, err := seq.PublishID(context.Background(), "orders", Event{ResetID: true}); err != nil || id != "" {
+			t.Errorf("expected no ID, but got %q (%v)", id, err)
+		}
+	})
+
+	t.Run("reads its own writes", func(t *testing.T) {
+		t.Parallel()
+
+		bus := &Bus{}
+		seq := &Sequencer{Broker: bus}
+		var observer Observer
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go seq.Subscribe("orders", "").Stream(ctx, func(evt Event) error {
+			observer.Observe(evt)
+			return nil
+		})
+		for bus.Subscribers("orders") == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		id, err := seq.PublishID(context.Background(), "orders", Event{Data: []byte("created")})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		waitCtx, waitCancel := context.WithTimeout(context.Background(), time.Second)
+		defer waitCancel()
+		if err := observer.Wait(waitCtx, id); err != nil {
+			t.Errorf("expected the event to be observed, but got %v", err)
+		}
+		if observer.LastEventID() != id {
+			t.Errorf("expected last event ID %q, but got %q", id, observer.LastEventID())
+		}
+	})
+}
+
+func TestCompareSequenceIDs(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"9", "10", -1},
+		{"10", "10", 0},
+		{"11", "10", 1},
+		{"b", "a", 1},
+		{"10", "a", -1},
+	} {
+		if actual := CompareSequenceIDs(tc.a, tc.b); actual != tc.expected {
+			t.Errorf("expected CompareSequenceIDs(%q, %q) to be %d, but got %d", tc.a, tc.b, tc.expected, actual)
+		}
+	}
+}
+
+func TestObserver(t *testing.T) {
+	t.Parallel()
+
+	t.Run("waits for the event with the ID", func(t *testing.T) {
+		t.Parallel()
+
+		observer := Observer{History: 2}
+		done := make(chan error, 1)
+		go func() { done <- observer.Wait(context.Background(), "b") }()
+
+		observer.Observe(Event{ID: "a"})
+		select {
+		case err := <-done:
+			t.Fatalf("expected Wait to block for another ID, but got %v", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		observer.Observe(Event{ID: "b"})
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("expected no error, but got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected Wait to return once the ID was observed")
+		}
+
+		observer.Observe(Event{ID: "c"})
+		if err := observer.Wait(context.Background(), "b"); err != nil {
+			t.Errorf("expected an ID observed already to return immediately, but got %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := observer.Wait(ctx, "a"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected IDs beyond the history to be waited for, but got %v", err)
+		}
+	})
+
+	t.Run("returns for later IDs with Compare", func(t *testing.T) {
+		t.Parallel()
+
+		observer := Observer{Compare: CompareSequenceIDs}
+		observer.Observe(Event{ID: "12"})
+		observer.Observe(Event{ID: "11"})
+
+		for _, id := range []string{"10", "12"} {
+			if err := observer.Wait(context.Background(), id); err != nil {
+				t.Errorf("expected %q to be observed, but got %v", id, err)
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := observer.Wait(ctx, "13"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected to wait for a later ID, but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package sse
+
+import (
+	"context"
+)
+
+// mergedContext is a context.Context with the values of two contexts, preferring those of the first,
+// which is canceled once either is done. See mergeContext.
+type mergedContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c mergedContext) Value(key interface{}) interface{} {
+	if v := c.Context.Value(key); v != nil {
+		return v
+	}
+	return c.values.Value(key)
+}
+
+// mergeContext returns a context.Context derived from ctx, that also has the values of base,
+// and is canceled once base is done, with base's cause.
+// cancel must be called once the context is no longer used, to stop watching base.
+func mergeContext(ctx, base context.Context) (_ context.Context, cancel context.CancelFunc) {
+	ctx, cancelCause := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(base, func() { cancelCause(context.Cause(base)) })
+
+	return mergedContext{Context: ctx, values: base}, func() {
+		stop()
+		cancelCause(context.Canceled)
+	}
+}
@@ -0,0 +1,73 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type contextKey string
+
+func TestMergeContext(t *testing.T) {
+	t.Parallel()
+
+	t.Run("has values of both", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := context.WithValue(context.Background(), contextKey("shared"), "request")
+		ctx = context.WithValue(ctx, contextKey("request"), "request")
+		base := context.WithValue(context.Background(), contextKey("shared"), "base")
+		base = context.WithValue(base, contextKey("base"), "base")
+
+		merged, cancel := mergeContext(ctx, base)
+		defer cancel()
+
+		for key, expected := range map[contextKey]string{"shared": "request", "request": "request", "base": "base"} {
+			if v := merged.Value(key); v != expected {
+				t.Errorf("expected %s to be %q, but got %v", key, expected, v)
+			}
+		}
+	})
+
+	t.Run("is canceled by either", func(t *testing.T) {
+		t.Parallel()
+
+		errShutdown := errors.New("shutting down")
+
+		ctx, cancelCtx := context.WithCancel(context.Background())
+		base, cancelBase := context.WithCancelCause(context.Background())
+		merged, cancel := mergeContext(ctx, base)
+		defer cancel()
+
+		cancelBase(errShutdown)
+		<-merged.Done()
+		if cause := context.Cause(merged); cause != errShutdown {
+			t.Errorf("expected base's cause, but got %v", cause)
+		}
+
+		base, cancelBase = context.WithCancelCause(context.Background())
+		defer cancelBase(nil)
+		merged, cancel = mergeContext(ctx, base)
+		defer cancel()
+
+		cancelCtx()
+		<-merged.Done()
+		if err := merged.Err(); err != context.Canceled {
+			t.Errorf("expected context.Canceled, but got %v", err)
+		}
+	})
+
+	t.Run("derived contexts are canceled", func(t *testing.T) {
+		t.Parallel()
+
+		base, cancelBase := context.WithCancel(context.Background())
+		merged, cancel := mergeContext(context.Background(), base)
+		defer cancel()
+
+		child, cancelChild := context.WithCancel(merged)
+		defer cancelChild()
+
+		cancelBase()
+		<-child.Done()
+	})
+}
@@ -0,0 +1,116 @@
+package sse
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// EnumeratedEvent is the event name of the event a Cursor sends once its enumeration is complete,
+// before its live events, so that clients know they have received every item. It has no data.
+const EnumeratedEvent = "sse-enumerated"
+
+// CursorIDPrefix is the prefix of the IDs of the events a Cursor sends while enumerating, and of its
+// EnumeratedEvent, which encode where to resume from.
+const CursorIDPrefix = "sse-enum:"
+
+// Cursor streams a large enumeration, such as the result of a query, followed by live updates, e.g. every
+// existing order, then each new order, resumably: each enumerated event's ID is a checkpoint, so that a client
+// which reconnects during the enumeration resumes it from the last item it received, and one which reconnects
+// once it is complete only receives live events from where it left off.
+//
+// The live position is captured before the enumeration starts, so that no live event produced while enumerating
+// is missed, though it may repeat a change to an item that was enumerated after it was made.
+type Cursor struct {
+	// Position, if not nil, returns the current position of the live events, e.g. the ID of the latest event,
+	// which Live resumes from once the enumeration is complete.
+	Position func(ctx context.Context) (string, error)
+
+	// Enumerate calls send for each item after checkpoint, or from the first if checkpoint is empty,
+	// with the event for the item, and the checkpoint following it, until there are no more items,
+	// ctx is done, or send returns an error, which should be returned.
+	Enumerate func(ctx context.Context, checkpoint string, send func(evt Event, checkpoint string) error) error
+
+	// Live, if not nil, returns an EventSource producing the live events after position, as returned by Position,
+	// or after the event with ID position, when a client resumes from a live event. If nil, the stream ends
+	// once the enumeration is complete.
+	Live func(position string) EventSource
+}
+
+// Source returns an EventSource streaming c from lastEventID, a client's Last-Event-ID: enumerating from
+// the start if it is empty, resuming the enumeration if it is a checkpoint, or the live events otherwise.
+// The events enumerated have their IDs replaced by their checkpoints.
+func (c Cursor) Source(lastEventID string) EventSource {
+	return EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+		position, checkpoint, enumerating, done := lastEventID, "", lastEventID == "", false
+		if strings.HasPrefix(lastEventID, CursorIDPrefix) {
+			var err error
+			if position, checkpoint, done, err = parseCursorID(lastEventID); err != nil {
+				return err
+			}
+			enumerating = !done
+		}
+
+		if lastEventID == "" && c.Position != nil {
+			var err error
+			if position, err = c.Position(ctx); err != nil {
+				return err
+			}
+		}
+
+		if enumerating {
+			err := c.Enumerate(ctx, checkpoint, func(evt Event, checkpoint string) error {
+				evt.ID = cursorID(position, &checkpoint)
+				return send(evt)
+			})
+			if err != nil {
+				return err
+			}
+			if err := send(Event{Event: EnumeratedEvent, ID: cursorID(position, nil)}); err != nil {
+				return err
+			}
+		}
+
+		if c.Live == nil {
+			return nil
+		}
+		return c.Live(position).Stream(ctx, send)
+	})
+}
+
+// Handler returns a NewEventStreamHandler streaming c to each client from its Last-Event-ID, see Source.
+func (c Cursor) Handler() NewEventStreamHandler {
+	return func(stream EventStream, lastEventID string) error {
+		return FromSource(c.Source(lastEventID))(stream, lastEventID)
+	}
+}
+
+// cursorID returns the ID of an event enumerated with the live position, and the checkpoint following it,
+// or of the EnumeratedEvent if checkpoint is nil.
+func cursorID(position string, checkpoint *string) string {
+	id := CursorIDPrefix + url.QueryEscape(position)
+	if checkpoint != nil {
+		id += ":" + url.QueryEscape(*checkpoint)
+	}
+	return id
+}
+
+// parseCursorID parses an ID returned by cursorID; done is true for the EnumeratedEvent's.
+func parseCursorID(id string) (position, checkpoint string, done bool, err error) {
+	parts := strings.Split(strings.TrimPrefix(id, CursorIDPrefix), ":")
+	if len(parts) > 2 {
+		return "", "", false, fmt.Errorf("sse: malformed cursor ID %q", id)
+	}
+
+	if position, err = url.QueryUnescape(parts[0]); err != nil {
+		return "", "", false, fmt.Errorf("sse: malformed cursor ID %q: %w", id, err)
+	}
+	if len(parts) == 1 {
+		return position, "", true, nil
+	}
+	if checkpoint, err = url.QueryUnescape(parts[1]); err != nil {
+		return "", "", false, fmt.Errorf("sse: malformed cursor ID %q: %w", id, err)
+	}
+	return position, checkpoint, false, nil
+}
@@ -0,0 +1,102 @@
+package sse
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	t.Parallel()
+
+	items := []string{"a", "b", "c"}
+	live := []Event{{Data: []byte("d"), ID: "11"}, {Data: []byte("e"), ID: "12"}}
+
+	cursor := Cursor{
+		Position: func(ctx context.Context) (string, error) { return "10", nil },
+		Enumerate: func(ctx context.Context, checkpoint string, send func(Event, string) error) error {
+			start := 0
+			if checkpoint != "" {
+				start, _ = strconv.Atoi(checkpoint)
+			}
+			for i := start; i < len(items); i++ {
+				if err := send(Event{Data: []byte(items[i])}, strconv.Itoa(i+1)); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Live: func(position string) EventSource {
+			after, _ := strconv.Atoi(position)
+			return EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+				for _, evt := range live {
+					if id, _ := strconv.Atoi(evt.ID); id > after {
+						if err := send(evt); err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			})
+		},
+	}
+
+	data := func(events []Event) []string {
+		var out []string
+		for _, evt := range events {
+			out = append(out, evt.Event+string(evt.Data))
+		}
+		return out
+	}
+
+	t.Run("enumerates, then streams live events", func(t *testing.T) {
+		t.Parallel()
+
+		events := collectEvents(t, cursor.Source(""))
+		if expected := []string{"a", "b", "c", EnumeratedEvent, "d", "e"}; !reflect.DeepEqual(data(events), expected) {
+			t.Errorf("expected %v, but got %v", expected, data(events))
+		}
+		for _, evt := range events[:4] {
+			position, _, _, err := parseCursorID(evt.ID)
+			if err != nil || position != "10" {
+				t.Errorf("expected %q to be a cursor ID at position 10, but got %q, %v", evt.ID, position, err)
+			}
+		}
+	})
+
+	t.Run("resumes enumerating from checkpoints", func(t *testing.T) {
+		t.Parallel()
+
+		events := collectEvents(t, cursor.Source(""))
+		resumed := collectEvents(t, cursor.Source(events[0].ID))
+		if expected := []string{"b", "c", EnumeratedEvent, "d", "e"}; !reflect.DeepEqual(data(resumed), expected) {
+			t.Errorf("expected %v, but got %v", expected, data(resumed))
+		}
+	})
+
+	t.Run("resumes live events once enumerated", func(t *testing.T) {
+		t.Parallel()
+
+		events := collectEvents(t, cursor.Source(""))
+		if resumed := collectEvents(t, cursor.Source(events[3].ID)); !reflect.DeepEqual(data(resumed), []string{"d", "e"}) {
+			t.Errorf("expected only live events, but got %v", data(resumed))
+		}
+		if resumed := collectEvents(t, cursor.Source("11")); !reflect.DeepEqual(data(resumed), []string{"e"}) {
+			t.Errorf("expected the live events after 11, but got %v", data(resumed))
+		}
+	})
+
+	t.Run("escapes positions and checkpoints", func(t *testing.T) {
+		t.Parallel()
+
+		checkpoint := "a:b%c"
+		position, parsed, done, err := parseCursorID(cursorID("x:y", &checkpoint))
+		if err != nil || position != "x:y" || parsed != checkpoint || done {
+			t.Errorf("expected position %q and checkpoint %q, but got %q, %q, %v, %v", "x:y", checkpoint, position, parsed, done, err)
+		}
+		if _, _, _, err := parseCursorID(CursorIDPrefix + "a:b:c"); err == nil {
+			t.Error("expected malformed cursor IDs to be rejected")
+		}
+	})
+}
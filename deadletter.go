@@ -0,0 +1,199 @@
+package sse
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reasons an event is dead-lettered, see DeadLetter.
+const (
+	// DropOverflow is the reason for events dropped by EventStream.TrySend because the stream was not ready
+	// for them.
+	DropOverflow = "overflow"
+
+	// DropMemoryLimit is the reason for events that were not queued because holding them would exceed the
+	// Handler's Allocator's limit.
+	DropMemoryLimit = "memory_limit"
+
+	// DropInvalid is the reason for events that were not sent because they failed ValidateEvent.
+	DropInvalid = "invalid"
+)
+
+// DeadLetter is an event that could not be delivered to a client, see Handler.DeadLetter.
+type DeadLetter struct {
+	// StreamID is the ID of the stream the event was sent on, see EventStream.ID.
+	StreamID string
+
+	// Topic is the topic the stream was streaming, if it was served by Mux.HandleTopic, or a SubscriptionHandler
+	// subscribed to a single topic. Otherwise, it is empty.
+	Topic string
+
+	// Event is the event, as it was sent. If it has a DataReader, it has not been read, or closed.
+	Event Event
+
+	// Reason is why the event was dropped, e.g. DropOverflow.
+	Reason string
+
+	// Err is the error the event was dropped with, if any.
+	Err error
+
+	// At is when the event was dropped.
+	At time.Time
+}
+
+// DefaultDeadLetterCapacity is the default DeadLetterQueue.Capacity.
+const DefaultDeadLetterCapacity = 1000
+
+// DeadLetterQueue holds dead letters for later inspection, and re-publishing with Replay, e.g.:
+//
+//	var letters sse.DeadLetterQueue
+//	h := mux.HandleTopic("/orders/{id}/events", "orders/{id}", broker, sse.WithDeadLetter(letters.Add))
+//
+// It holds at most Capacity letters, dropping the oldest once it is full. The zero DeadLetterQueue is
+// ready to use, and it is safe for concurrent use. Its Capacity must not be modified once it is in use.
+type DeadLetterQueue struct {
+	// Capacity is the most letters held. If 0, DefaultDeadLetterCapacity is used.
+	Capacity int
+
+	mu      sync.Mutex
+	letters []DeadLetter
+	evicted uint64
+}
+
+// Add adds dl to the queue; it can be used as Handler.DeadLetter.
+func (q *DeadLetterQueue) Add(dl DeadLetter) {
+	capacity := q.Capacity
+	if capacity <= 0 {
+		capacity = DefaultDeadLetterCapacity
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.letters) >= capacity {
+		n := len(q.letters) - capacity + 1
+		q.letters = append(q.letters[:0], q.letters[n:]...)
+		q.evicted += uint64(n)
+	}
+	q.letters = append(q.letters, dl)
+}
+
+// Letters returns the letters held, oldest first.
+func (q *DeadLetterQueue) Letters() []DeadLetter {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return append([]DeadLetter(nil), q.letters...)
+}
+
+// Evicted returns the number of letters dropped because the queue was full.
+func (q *DeadLetterQueue) Evicted() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.evicted
+}
+
+// Replay re-publishes the letters held to their topics with b, oldest first, and removes them from the queue.
+// edit, if not nil, is called with each letter first, and may change its Topic or Event, e.g. to fix an
+// invalid event, or report false to leave the letter in the queue, e.g. to only replay those of one topic.
+// Letters without a Topic are left in the queue.
+//
+// Replay stops at the first letter that fails to be published, leaving it and those after it in the queue,
+// and returns the error. It returns the number of letters that were published.
+func (q *DeadLetterQueue) Replay(ctx context.Context, b Broker, edit func(dl *DeadLetter) bool) (int, error) {
+	q.mu.Lock()
+	letters := q.letters
+	q.letters = nil
+	q.mu.Unlock()
+
+	var (
+		kept      []DeadLetter
+		published int
+		err       error
+	)
+	for i, dl := range letters {
+		if edit != nil && !edit(&dl) || dl.Topic == "" {
+			kept = append(kept, letters[i])
+			continue
+		}
+
+		if err = b.Publish(ctx, dl.Topic, dl.Event); err != nil {
+			kept = append(kept, letters[i:]...)
+			break
+		}
+		published++
+	}
+
+	// letters added while replaying go after those that were kept
+	q.mu.Lock()
+	q.letters = append(kept, q.letters...)
+	q.mu.Unlock()
+
+	return published, err
+}
+
+// deadLetterStats counts the events dead-lettered by a Handler, by reason.
+type deadLetterStats struct {
+	overflow    atomic.Uint64
+	memoryLimit atomic.Uint64
+	invalid     atomic.Uint64
+}
+
+func (s *deadLetterStats) count(reason string) {
+	switch reason {
+	case DropOverflow:
+		s.overflow.Add(1)
+	case DropMemoryLimit:
+		s.memoryLimit.Add(1)
+	case DropInvalid:
+		s.invalid.Add(1)
+	}
+}
+
+func (s *deadLetterStats) snapshot() map[string]uint64 {
+	return map[string]uint64{
+		DropOverflow:    s.overflow.Load(),
+		DropMemoryLimit: s.memoryLimit.Load(),
+		DropInvalid:     s.invalid.Load(),
+	}
+}
+
+// deadLetter counts evt, which could not be delivered on the stream with the ID streamID, streaming topic,
+// for reason, and passes it to h.DeadLetter, if set.
+func (h *Handler) deadLetter(streamID, topic string, evt Event, reason string, err error) {
+	h.stats.deadLetters.count(reason)
+	if h.DeadLetter != nil {
+		h.DeadLetter(DeadLetter{
+			StreamID: streamID,
+			Topic:    topic,
+			Event:    evt,
+			Reason:   reason,
+			Err:      err,
+			At:       time.Now(),
+		})
+	}
+}
+
+// deadLetter reports e, which could not be queued on the stream for reason, see Handler.DeadLetter.
+func (s EventStream) deadLetter(e Event, reason string, err error) {
+	if s.h != nil {
+		s.h.deadLetter(s.id, s.state.getTopic(), e, reason, err)
+	}
+}
+
+// strict returns the error from ValidateEvent for e, reporting it as a DropInvalid dead letter,
+// if the Handler has StrictEvents set.
+func (s EventStream) strict(e Event) error {
+	if s.h == nil || !s.h.StrictEvents {
+		return nil
+	}
+
+	err := ValidateEvent(e)
+	if err != nil {
+		s.deadLetter(e, DropInvalid, err)
+	}
+	return err
+}
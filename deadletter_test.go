@@ -0,0 +1,225 @@
+package sse
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDeadLetter(t *testing.T) {
+	t.Parallel()
+
+	serve := func(t *testing.T, h *Handler) string {
+		t.Helper()
+
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	t.Run("reports events dropped by TrySend", func(t *testing.T) {
+		t.Parallel()
+
+		letters := make(chan DeadLetter, 1)
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			// nothing is receiving from the stream yet
+			if stream.TrySend(Event{Data: []byte("dropped")}) {
+				t.Error("expected TrySend to drop the event")
+			}
+			stream.Go(func(ctx context.Context) error {
+				return stream.Send(Event{Data: []byte("sent")})
+			})
+			return nil
+		}, WithDeadLetter(func(dl DeadLetter) { letters <- dl }))
+
+		if body, expected := serve(t, h), "data:sent\n\n"; body != expected {
+			t.Errorf("expected %q, but got %q", expected, body)
+		}
+
+		dl := <-letters
+		if dl.Reason != DropOverflow || string(dl.Event.Data) != "dropped" || dl.StreamID == "" || dl.At.IsZero() {
+			t.Errorf("expected the dropped event with reason %q, but got %+v", DropOverflow, dl)
+		}
+		if n := h.Stats().DeadLetters[DropOverflow]; n != 1 {
+			t.Errorf("expected 1 dead letter for %q, but got %d", DropOverflow, n)
+		}
+	})
+
+	t.Run("reports events exceeding the memory limit", func(t *testing.T) {
+		t.Parallel()
+
+		letters := make(chan DeadLetter, 1)
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			stream.Go(func(ctx context.Context) error {
+				return stream.Send(Event{Data: make([]byte, 100)})
+			})
+			return nil
+		}, WithAllocator(NewMemoryAllocator(10)), WithDeadLetter(func(dl DeadLetter) { letters <- dl }))
+		serve(t, h)
+
+		dl := <-letters
+		if dl.Reason != DropMemoryLimit || !errors.Is(dl.Err, ErrMemoryLimit) {
+			t.Errorf("expected a dead letter with reason %q, and ErrMemoryLimit, but got %+v", DropMemoryLimit, dl)
+		}
+	})
+
+	t.Run("reports invalid events instead of sending them", func(t *testing.T) {
+		t.Parallel()
+
+		letters := make(chan DeadLetter, 1)
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			stream.Go(func(ctx context.Context) error {
+				if err := stream.Send(Event{Event: "forged\ndata:x", Data: []byte("x")}); err != nil {
+					return err
+				}
+				return stream.Send(Event{Data: []byte("valid")})
+			})
+			return nil
+		}, WithDeadLetter(func(dl DeadLetter) { letters <- dl }))
+
+		if body, expected := serve(t, h), "data:valid\n\n"; body != expected {
+			t.Errorf("expected %q, but got %q", expected, body)
+		}
+
+		dl := <-letters
+		if dl.Reason != DropInvalid || !errors.Is(dl.Err, ErrInvalidEvent) {
+			t.Errorf("expected a dead letter with reason %q, and ErrInvalidEvent, but got %+v", DropInvalid, dl)
+		}
+		if stats := h.Stats(); stats.DeadLetters[DropInvalid] != 1 || stats.DeadLetters[DropOverflow] != 0 {
+			t.Errorf("expected 1 dead letter for %q, but got %v", DropInvalid, stats.DeadLetters)
+		}
+	})
+
+	t.Run("counts dead letters without a hook", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			stream.TrySend(Event{Data: []byte("dropped")})
+			stream.Go(func(ctx context.Context) error { return nil })
+			return nil
+		})
+		serve(t, h)
+
+		if n := h.Stats().DeadLetters[DropOverflow]; n != 1 {
+			t.Errorf("expected 1 dead letter for %q, but got %d", DropOverflow, n)
+		}
+	})
+}
+
+// letterBroker is a Broker delivering an invalid event to the subscribers of each topic, and recording
+// the events published to it.
+type letterBroker struct {
+	published chan Event
+	err       error
+}
+
+func (b letterBroker) Publish(ctx context.Context, topic string, evt Event) error {
+	if b.err != nil {
+		return b.err
+	}
+	evt.ID = topic
+	b.published <- evt
+	return nil
+}
+
+func (letterBroker) Subscribe(topic, lastEventID string) EventSource {
+	return EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+		return send(Event{Event: "invalid\n" + topic})
+	})
+}
+
+func TestDeadLetterQueue(t *testing.T) {
+	t.Parallel()
+
+	t.Run("records the topic of topic streams", func(t *testing.T) {
+		t.Parallel()
+
+		var letters DeadLetterQueue
+		mux := NewMux()
+		mux.HandleTopic("/orders/{id}/events", "orders/{id}", letterBroker{}, WithDeadLetter(letters.Add))
+		srv := httptest.NewServer(mux)
+		t.Cleanup(srv.Close)
+
+		resp, err := srv.Client().Get(srv.URL + "/orders/123/events")
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		dls := letters.Letters()
+		if len(dls) != 1 || dls[0].Topic != "orders/123" || dls[0].Reason != DropInvalid {
+			t.Errorf("expected an invalid event of topic %q, but got %+v", "orders/123", dls)
+		}
+	})
+
+	t.Run("evicts the oldest letters once full", func(t *testing.T) {
+		t.Parallel()
+
+		letters := DeadLetterQueue{Capacity: 2}
+		for _, id := range []string{"1", "2", "3"} {
+			letters.Add(DeadLetter{Event: Event{ID: id}})
+		}
+
+		dls := letters.Letters()
+		if len(dls) != 2 || dls[0].Event.ID != "2" || dls[1].Event.ID != "3" {
+			t.Errorf("expected the 2 newest letters, but got %+v", dls)
+		}
+		if n := letters.Evicted(); n != 1 {
+			t.Errorf("expected 1 eviction, but got %d", n)
+		}
+	})
+
+	t.Run("replays letters to their topics", func(t *testing.T) {
+		t.Parallel()
+
+		var letters DeadLetterQueue
+		letters.Add(DeadLetter{Topic: "orders/1", Event: Event{Data: []byte("a\rb")}})
+		letters.Add(DeadLetter{Topic: "orders/2", Event: Event{Data: []byte("skipped")}})
+		letters.Add(DeadLetter{Event: Event{Data: []byte("no topic")}})
+
+		broker := letterBroker{published: make(chan Event, 3)}
+		n, err := letters.Replay(context.Background(), broker, func(dl *DeadLetter) bool {
+			dl.Event.Data = bytes.ReplaceAll(dl.Event.Data, []byte("\r"), []byte("\n"))
+			return dl.Topic != "orders/2"
+		})
+		if err != nil || n != 1 {
+			t.Fatalf("expected 1 letter to be replayed, but got %d (%v)", n, err)
+		}
+
+		if evt := <-broker.published; evt.ID != "orders/1" || string(evt.Data) != "a\nb" {
+			t.Errorf("expected the edited event to be published to %q, but got %+v", "orders/1", evt)
+		}
+		if dls := letters.Letters(); len(dls) != 2 || dls[0].Topic != "orders/2" || dls[1].Topic != "" {
+			t.Errorf("expected the skipped letters to be kept, but got %+v", dls)
+		}
+	})
+
+	t.Run("keeps letters that fail to be published", func(t *testing.T) {
+		t.Parallel()
+
+		var letters DeadLetterQueue
+		letters.Add(DeadLetter{Topic: "orders/1"})
+		letters.Add(DeadLetter{Topic: "orders/2"})
+
+		failure := errors.New("unavailable")
+		n, err := letters.Replay(context.Background(), letterBroker{err: failure}, nil)
+		if n != 0 || !errors.Is(err, failure) {
+			t.Errorf("expected %v, but got %d letters replayed (%v)", failure, n, err)
+		}
+		if dls := letters.Letters(); len(dls) != 2 {
+			t.Errorf("expected the letters to be kept, but got %+v", dls)
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package sse
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidDebezium is returned (wrapped) by DecodeDebezium when a message is not a Debezium change event.
+var ErrInvalidDebezium = errors.New("sse: invalid Debezium change event")
+
+// debeziumOps names Debezium's operation codes.
+var debeziumOps = map[string]string{
+	"c": "create",
+	"u": "update",
+	"d": "delete",
+	"r": "read",
+	"t": "truncate",
+}
+
+// DebeziumChange is a compact form of a Debezium change event, for clients that only need to know what changed,
+// and how it is now, rather than the full envelope:
+//
+//	{"op":"update","key":{"id":1001},"after":{"id":1001,"email":"annek@example.com"}}
+type DebeziumChange struct {
+	// Op is the kind of change: "create", "update", "delete", "truncate",
+	// or "read", for rows read by an initial snapshot.
+	Op string `json:"op"`
+
+	// Key is the changed row's key, from the Kafka message's key.
+	Key json.RawMessage `json:"key,omitempty"`
+
+	// After is the row's state after the change, which deletes have none of.
+	After json.RawMessage `json:"after,omitempty"`
+
+	// Table is the table the row is in, from the change event's source metadata, if any.
+	Table string `json:"-"`
+}
+
+// debeziumPayload is the part of a Debezium change event that DecodeDebezium uses.
+type debeziumPayload struct {
+	Op     string          `json:"op"`
+	After  json.RawMessage `json:"after"`
+	Source struct {
+		Table string `json:"table"`
+	} `json:"source"`
+}
+
+// DecodeDebezium decodes the key and value of a Kafka message produced by a Debezium connector, with the JSON
+// converter, with or without schemas. It returns false for tombstones, the messages with no value that follow
+// deletes, so that Kafka can compact them, which carry no change.
+func DecodeDebezium(key, value []byte) (DebeziumChange, bool, error) {
+	value = unwrapDebeziumSchema(value)
+	if len(value) == 0 || string(value) == "null" {
+		return DebeziumChange{}, false, nil
+	}
+
+	var payload debeziumPayload
+	if err := json.Unmarshal(value, &payload); err != nil {
+		return DebeziumChange{}, false, fmt.Errorf("%w: %v", ErrInvalidDebezium, err)
+	}
+
+	op, ok := debeziumOps[payload.Op]
+	if !ok {
+		return DebeziumChange{}, false, fmt.Errorf("%w: unknown operation %q", ErrInvalidDebezium, payload.Op)
+	}
+
+	change := DebeziumChange{Op: op, Table: payload.Source.Table}
+	if key = unwrapDebeziumSchema(key); len(key) > 0 && string(key) != "null" {
+		change.Key = key
+	}
+	if len(payload.After) > 0 && string(payload.After) != "null" {
+		change.After = payload.After
+	}
+	return change, true, nil
+}
+
+// unwrapDebeziumSchema returns the payload of data, if it is enveloped with its schema.
+func unwrapDebeziumSchema(data []byte) []byte {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return data
+	}
+
+	var envelope struct {
+		Schema  json.RawMessage `json:"schema"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	if json.Unmarshal(data, &envelope) != nil || envelope.Schema == nil || envelope.Payload == nil {
+		return data
+	}
+	return bytes.TrimSpace(envelope.Payload)
+}
+
+// Event returns an event named after c's Op, with c as its data, as JSON.
+// Its ID is left for the caller to set, e.g. to the Kafka message's offset, which is what a client resumes from.
+func (c DebeziumChange) Event() Event {
+	data, _ := json.Marshal(c)
+	return Event{Event: c.Op, Data: data}
+}
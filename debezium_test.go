@@ -0,0 +1,77 @@
+package sse
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecodeDebezium(t *testing.T) {
+	t.Parallel()
+
+	const update = `{"before":{"id":1001,"email":"anne@example.com"},"after":{"id":1001,"email":"annek@example.com"},` +
+		`"source":{"connector":"postgresql","db":"inventory","table":"customers"},"op":"u","ts_ms":1700000000000}`
+
+	for _, tt := range []struct {
+		name       string
+		key, value string
+		expected   string
+	}{
+		{
+			name:     "without schemas",
+			key:      `{"id":1001}`,
+			value:    update,
+			expected: `{"op":"update","key":{"id":1001},"after":{"id":1001,"email":"annek@example.com"}}`,
+		},
+		{
+			name:     "with schemas",
+			key:      `{"schema":{"type":"struct"},"payload":{"id":1001}}`,
+			value:    `{"schema":{"type":"struct"},"payload":` + update + `}`,
+			expected: `{"op":"update","key":{"id":1001},"after":{"id":1001,"email":"annek@example.com"}}`,
+		},
+		{
+			name:     "deletes",
+			key:      `{"id":1001}`,
+			value:    `{"before":{"id":1001},"after":null,"source":{"table":"customers"},"op":"d"}`,
+			expected: `{"op":"delete","key":{"id":1001}}`,
+		},
+		{
+			name:     "snapshot reads",
+			value:    `{"after":{"id":1},"source":{"table":"customers"},"op":"r"}`,
+			expected: `{"op":"read","after":{"id":1}}`,
+		},
+	} {
+		change, ok, err := DecodeDebezium([]byte(tt.key), []byte(tt.value))
+		if err != nil || !ok {
+			t.Errorf("%s: expected a change, but got %v, %v", tt.name, ok, err)
+			continue
+		}
+		if change.Table != "customers" {
+			t.Errorf("%s: expected table %q, but got %q", tt.name, "customers", change.Table)
+		}
+
+		evt := change.Event()
+		if evt.Event != change.Op || string(evt.Data) != tt.expected {
+			t.Errorf("%s: expected event %q with data %s, but got %q with %s", tt.name, change.Op, tt.expected, evt.Event, evt.Data)
+		}
+	}
+
+	t.Run("skips tombstones", func(t *testing.T) {
+		t.Parallel()
+
+		for _, value := range []string{"", "null"} {
+			if _, ok, err := DecodeDebezium([]byte(`{"id":1001}`), []byte(value)); ok || err != nil {
+				t.Errorf("%q: expected a tombstone, but got %v, %v", value, ok, err)
+			}
+		}
+	})
+
+	t.Run("rejects other messages", func(t *testing.T) {
+		t.Parallel()
+
+		for _, value := range []string{"not json", `{"op":"x"}`, `{"after":{}}`} {
+			if _, _, err := DecodeDebezium(nil, []byte(value)); !errors.Is(err, ErrInvalidDebezium) {
+				t.Errorf("%q: expected %v, but got %v", value, ErrInvalidDebezium, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,230 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Decoder reads events from an io.Reader in the text/event-stream format, as an EventSource parses them
+// (https://html.spec.whatwg.org/multipage/server-sent-events.html#event-stream-interpretation), e.g. for
+// a client, a proxy, or tests. Input is read as it arrives, so partial reads from a network connection
+// are assembled into events as they complete.
+//
+// As by an EventSource, comments and unknown fields are ignored, ids containing a NUL character, and
+// retries that are not a number of milliseconds, are ignored, and events without data are not returned,
+// though their id and retry still take effect, see LastEventID and Retry.
+type Decoder struct {
+	r *bufio.Reader
+
+	line    []byte
+	skipLF  bool
+	started bool
+
+	lastEventID string
+	retry       time.Duration
+}
+
+// NewDecoder returns a *Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode returns the next event. Its ID is the id it was sent with, if any, and its ResetID is set if
+// it was sent with an empty id; Retry is set if it was sent with a retry. Its Data does not include
+// the newline following its last data line.
+// Once the input ends, Decode returns io.EOF, discarding an event that was not completed;
+// errors reading from the io.Reader are returned as they are.
+func (d *Decoder) Decode() (Event, error) {
+	var (
+		evt     Event
+		data    []byte
+		hasData bool
+	)
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			return Event{}, err
+		}
+
+		if len(line) == 0 {
+			if !hasData {
+				evt = Event{}
+				continue
+			}
+			evt.Data = data[:len(data)-1]
+			return evt, nil
+		}
+
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value := splitField(line)
+		switch string(field) {
+		case "event":
+			evt.Event = string(value)
+
+		case "data":
+			data = append(data, value...)
+			data = append(data, '\n')
+			hasData = true
+
+		case "id":
+			if bytes.IndexByte(value, 0) >= 0 {
+				continue
+			}
+			evt.ID = string(value)
+			evt.ResetID = len(value) == 0
+			d.lastEventID = evt.ID
+
+		case "retry":
+			ms, err := strconv.ParseUint(string(value), 10, 63)
+			if err != nil || ms > uint64(math.MaxInt64/time.Millisecond) {
+				continue
+			}
+			evt.Retry = time.Duration(ms) * time.Millisecond
+			d.retry = evt.Retry
+		}
+	}
+}
+
+// UnmarshalText parses text, a single event block, as written by MarshalText, into e, replacing its fields:
+// comment lines are parsed into its Comment, and a retry into its Retry. The blank line ending the block
+// may be left out. Unknown fields are ignored, as by an EventSource.
+// The returned error wraps ErrInvalidEvent if text has more than one event, or an id or retry that an
+// EventSource would ignore.
+func (e *Event) UnmarshalText(text []byte) error {
+	*e = Event{}
+
+	var (
+		d        = NewDecoder(bytes.NewReader(append(text[:len(text):len(text)], '\n')))
+		comments []string
+		data     []byte
+		hasData  bool
+		ended    bool
+	)
+	for {
+		line, err := d.readLine()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		if len(line) == 0 {
+			ended = true
+			continue
+		}
+		if ended {
+			return fmt.Errorf("%w: text has more than one event", ErrInvalidEvent)
+		}
+
+		if line[0] == ':' {
+			comment := line[1:]
+			if len(comment) > 0 && comment[0] == ' ' {
+				comment = comment[1:]
+			}
+			comments = append(comments, string(comment))
+			continue
+		}
+
+		field, value := splitField(line)
+		switch string(field) {
+		case "event":
+			e.Event = string(value)
+
+		case "data":
+			data = append(data, value...)
+			data = append(data, '\n')
+			hasData = true
+
+		case "id":
+			if bytes.IndexByte(value, 0) >= 0 {
+				return fmt.Errorf("%w: ID contains a NUL character", ErrInvalidEvent)
+			}
+			e.ID = string(value)
+			e.ResetID = len(value) == 0
+
+		case "retry":
+			ms, err := strconv.ParseUint(string(value), 10, 63)
+			if err != nil || ms > uint64(math.MaxInt64/time.Millisecond) {
+				return fmt.Errorf("%w: invalid retry %q", ErrInvalidEvent, value)
+			}
+			e.Retry = time.Duration(ms) * time.Millisecond
+		}
+	}
+
+	if hasData {
+		e.Data = data[:len(data)-1]
+	}
+	e.Comment = strings.Join(comments, "\n")
+	return nil
+}
+
+// splitField splits line into the name of its field and its value, without the space following the colon.
+func splitField(line []byte) (field, value []byte) {
+	i := bytes.IndexByte(line, ':')
+	if i < 0 {
+		return line, nil
+	}
+
+	field, value = line[:i], line[i+1:]
+	if len(value) > 0 && value[0] == ' ' {
+		value = value[1:]
+	}
+	return field, value
+}
+
+// LastEventID returns the ID of the last event whose id was read, which an EventSource would send as
+// the Last-Event-ID header when reconnecting. An empty id resets it.
+func (d *Decoder) LastEventID() string { return d.lastEventID }
+
+// Retry returns the last reconnection delay read, or 0 if none has been.
+func (d *Decoder) Retry() time.Duration { return d.retry }
+
+// readLine returns the next line, without its line ending, which is a CRLF, an LF, or a CR.
+// The line is only valid until the next call. A line not completed by the end of the input is discarded.
+func (d *Decoder) readLine() ([]byte, error) {
+	if !d.started {
+		d.started = true
+		if bom, err := d.r.Peek(3); err == nil && string(bom) == "\xef\xbb\xbf" {
+			d.r.Discard(3)
+		}
+	}
+
+	d.line = d.line[:0]
+	for {
+		n := d.r.Buffered()
+		if n == 0 {
+			n = 1
+		}
+		buf, err := d.r.Peek(n)
+		if len(buf) == 0 {
+			return nil, err
+		}
+
+		if d.skipLF {
+			d.skipLF = false
+			if buf[0] == '\n' {
+				d.r.Discard(1)
+				continue
+			}
+		}
+
+		if i := bytes.IndexAny(buf, "\r\n"); i >= 0 {
+			d.line = append(d.line, buf[:i]...)
+			d.skipLF = buf[i] == '\r'
+			d.r.Discard(i + 1)
+			return d.line, nil
+		}
+
+		d.line = append(d.line, buf...)
+		d.r.Discard(len(buf))
+	}
+}
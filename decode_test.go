@@ -0,0 +1,187 @@
+package sse
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestDecoder(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []Event
+	}{
+		{"empty", "", nil},
+		{"data", "data:hello\n\n", []Event{{Data: []byte("hello")}}},
+		{"leading space", "data: hello\ndata:  two\n\n", []Event{{Data: []byte("hello\n two")}}},
+		{
+			"all fields",
+			"event:greeting\ndata:a\ndata:b\nid:1\nretry:1500\n\n",
+			[]Event{{Event: "greeting", Data: []byte("a\nb"), ID: "1", Retry: 1500 * time.Millisecond}},
+		},
+		{"comments and unknown fields", ": keep-alive\n\nfoo:bar\ndata:x\n:comment\n\n", []Event{{Data: []byte("x")}}},
+		{"field without colon", "data\n\n", []Event{{Data: []byte{}}}},
+		{"CRLF and CR line endings", "data:a\r\ndata:b\r\r\ndata:c\r\r", []Event{{Data: []byte("a\nb")}, {Data: []byte("c")}}},
+		{"byte order mark", "\xef\xbb\xbfdata:x\n\n", []Event{{Data: []byte("x")}}},
+		{"event without data", "event:ping\nid:7\n\ndata:x\n\n", []Event{{Data: []byte("x")}}},
+		{"reset ID", "id\ndata:x\n\n", []Event{{Data: []byte("x"), ResetID: true}}},
+		{"ID containing NUL", "id:1\x002\ndata:x\n\n", []Event{{Data: []byte("x")}}},
+		{"invalid retry", "retry:1.5\nretry:-1\nretry:\ndata:x\n\n", []Event{{Data: []byte("x")}}},
+		{"incomplete event", "data:a\n\ndata:b\n", []Event{{Data: []byte("a")}}},
+		{"incomplete line", "data:a\n\ndata", []Event{{Data: []byte("a")}}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			for _, r := range []io.Reader{strings.NewReader(tt.input), iotest.OneByteReader(strings.NewReader(tt.input))} {
+				dec := NewDecoder(r)
+				var events []Event
+				for {
+					evt, err := dec.Decode()
+					if errors.Is(err, io.EOF) {
+						break
+					}
+					if err != nil {
+						t.Fatal(err)
+					}
+					events = append(events, evt)
+				}
+
+				if !reflect.DeepEqual(events, tt.expected) {
+					t.Errorf("expected %+v, but got %+v", tt.expected, events)
+				}
+			}
+		})
+	}
+
+	t.Run("tracks the last event ID and retry", func(t *testing.T) {
+		t.Parallel()
+
+		dec := NewDecoder(strings.NewReader("id:1\nretry:2000\ndata:a\n\ndata:b\n\nid:2\n\n"))
+		if _, err := dec.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		evt, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if evt.ID != "" || dec.LastEventID() != "1" || dec.Retry() != 2*time.Second {
+			t.Errorf("expected last event ID %q, and retry 2s, but got %q, %v, and event %+v",
+				"1", dec.LastEventID(), dec.Retry(), evt)
+		}
+
+		if _, err := dec.Decode(); !errors.Is(err, io.EOF) {
+			t.Errorf("expected io.EOF, but got %v", err)
+		}
+		if dec.LastEventID() != "2" {
+			t.Errorf("expected the ID of an event without data to take effect, but got %q", dec.LastEventID())
+		}
+	})
+
+	t.Run("decodes what Encoder writes", func(t *testing.T) {
+		t.Parallel()
+
+		events := []Event{
+			{Event: "greeting", Data: []byte("line one\nline two"), ID: "1"},
+			{Data: []byte(" leading space"), Retry: time.Second},
+			{Data: []byte("x"), ResetID: true},
+		}
+
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.WriteComment("hello")
+		for _, evt := range events {
+			enc.WriteEvent(evt)
+		}
+
+		enc.WriteEvent(Event{Data: []byte("a\r\nb\rc")})
+		events = append(events, Event{Data: []byte("a\nb\nc")})
+
+		dec := NewDecoder(&buf)
+		for _, expected := range events {
+			evt, err := dec.Decode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(evt, expected) {
+				t.Errorf("expected %+v, but got %+v", expected, evt)
+			}
+		}
+	})
+}
+
+func TestEventUnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round-trips MarshalText", func(t *testing.T) {
+		t.Parallel()
+
+		for _, expected := range []Event{
+			{},
+			{Comment: "recorded\nfixture", Event: "greeting", Data: []byte(" line one\nline two\n"), ID: "1", Retry: 1500 * time.Millisecond},
+			{Data: []byte("x"), ResetID: true},
+			{Comment: "keep-alive"},
+		} {
+			text, err := expected.MarshalText()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var evt Event
+			if err := evt.UnmarshalText(text); err != nil {
+				t.Fatalf("expected no error for %q, but got %v", text, err)
+			}
+			if !reflect.DeepEqual(expected, evt) {
+				t.Errorf("expected %+v, but got %+v", expected, evt)
+			}
+		}
+	})
+
+	tests := []struct {
+		name     string
+		text     string
+		expected Event
+	}{
+		{"without the blank line", "data:x\nid:2", Event{Data: []byte("x"), ID: "2"}},
+		{"CRLF line endings", "event:a\r\ndata:x\r\n\r\n", Event{Event: "a", Data: []byte("x")}},
+		{"unknown fields", "foo:bar\ndata:x\n\n", Event{Data: []byte("x")}},
+		{"empty comment", ":\n\n", Event{}},
+		{"trailing blank lines", "data:x\n\n\n", Event{Data: []byte("x")}},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			evt := Event{Event: "stale", ID: "stale"}
+			if err := evt.UnmarshalText([]byte(test.text)); err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if !reflect.DeepEqual(test.expected, evt) {
+				t.Errorf("expected %+v, but got %+v", test.expected, evt)
+			}
+		})
+	}
+
+	t.Run("rejects invalid blocks", func(t *testing.T) {
+		t.Parallel()
+
+		for _, text := range []string{"data:a\n\ndata:b\n\n", "id:1\x002\n\n", "retry:1.5\n\n"} {
+			var evt Event
+			if err := evt.UnmarshalText([]byte(text)); !errors.Is(err, ErrInvalidEvent) {
+				t.Errorf("expected ErrInvalidEvent for %q, but got %v", text, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package sse
+
+import (
+	"crypto/sha256"
+	"time"
+)
+
+// duplicateFilter tracks the data last sent for each event name on a connection.
+// See Handler.DuplicateWindow.
+type duplicateFilter struct {
+	window time.Duration
+	last   map[string]sentData
+}
+
+type sentData struct {
+	id  string
+	sum [sha256.Size]byte
+	at  time.Time
+}
+
+func newDuplicateFilter(window time.Duration) *duplicateFilter {
+	return &duplicateFilter{
+		window: window,
+		last:   make(map[string]sentData),
+	}
+}
+
+// isDuplicate reports whether evt has the same name, ID, and data as the last event sent
+// with that name, less than the window ago. If not, evt is recorded as sent at now.
+// Events with a new ID are never duplicates, so that the client's Last-Event-ID keeps advancing.
+func (f *duplicateFilter) isDuplicate(evt *Event, now time.Time) bool {
+	if len(evt.Data) == 0 || evt.DataReader != nil {
+		return false
+	}
+
+	sum := sha256.Sum256(evt.Data)
+	if prev, ok := f.last[evt.Event]; ok && prev.id == evt.ID && prev.sum == sum && now.Sub(prev.at) < f.window {
+		return true
+	}
+
+	f.last[evt.Event] = sentData{id: evt.ID, sum: sum, at: now}
+	return false
+}
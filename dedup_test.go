@@ -0,0 +1,76 @@
+package sse
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDuplicateFilter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("suppresses within window", func(t *testing.T) {
+		t.Parallel()
+
+		f := newDuplicateFilter(time.Minute)
+		now := time.Now()
+
+		steps := []struct {
+			evt       Event
+			at        time.Time
+			duplicate bool
+		}{
+			{Event{Event: "state", Data: []byte("a")}, now, false},
+			{Event{Event: "state", Data: []byte("a")}, now.Add(time.Second), true},
+			{Event{Event: "state", Data: []byte("a"), ID: "2"}, now.Add(time.Second), false},
+			{Event{Event: "state", Data: []byte("a"), ID: "2"}, now.Add(time.Second), true},
+			{Event{Event: "other", Data: []byte("a")}, now.Add(time.Second), false},
+			{Event{Event: "state", Data: []byte("b")}, now.Add(2 * time.Second), false},
+			{Event{Event: "state", Data: []byte("a")}, now.Add(3 * time.Second), false},
+			{Event{Event: "state", Data: []byte("a")}, now.Add(3*time.Second + time.Minute), false},
+			{Event{ID: "3"}, now.Add(4 * time.Second), false},
+			{Event{ID: "3"}, now.Add(4 * time.Second), false},
+		}
+
+		for i, step := range steps {
+			if dup := f.isDuplicate(&step.evt, step.at); dup != step.duplicate {
+				t.Errorf("step %d: expected duplicate = %v, but got %v", i, step.duplicate, dup)
+			}
+		}
+	})
+
+	t.Run("Handler skips duplicates", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			go func() {
+				stream.Send(Event{Event: "state", Data: []byte("same")})
+				stream.Send(Event{Event: "state", Data: []byte("same")})
+				stream.Send(Event{Event: "state", Data: []byte("changed")})
+				stream.Close()
+			}()
+			return nil
+		})
+		h.DuplicateWindow = time.Minute
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal("failed to read response body:", err)
+		}
+
+		expected := "event:state\ndata:same\n\nevent:state\ndata:changed\n\n"
+		if !bytes.Equal(body, []byte(expected)) {
+			t.Errorf("expected response body %q, but got %q", expected, body)
+		}
+	})
+}
@@ -0,0 +1,230 @@
+package sse
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+)
+
+// DeltaPrefix marks the data of an Event as a JSON Merge Patch (RFC 7396) to be applied to
+// the data of the previous event with the same name. See Handler.DeltaEncoding.
+const DeltaPrefix = "merge-patch:"
+
+// ErrMissingSnapshot is returned by DeltaDecoder.Decode if a delta is received for an event name
+// that no full payload has been received for.
+//
+// Each connection starts with full payloads, so a client can recover from this, or any other
+// failure to apply a delta, by reconnecting.
+var ErrMissingSnapshot = errors.New("sse: received delta without a snapshot to apply it to")
+
+// deltaEncoder replaces the data of events with merge patches against the previous event
+// with the same name on a connection.
+type deltaEncoder struct {
+	snapshotInterval time.Duration
+	last             map[string]deltaState
+}
+
+type deltaState struct {
+	doc        interface{}
+	snapshotAt time.Time
+}
+
+func newDeltaEncoder(snapshotInterval time.Duration) *deltaEncoder {
+	return &deltaEncoder{
+		snapshotInterval: snapshotInterval,
+		last:             make(map[string]deltaState),
+	}
+}
+
+// encode replaces evt.Data with a merge patch against the last event sent with the same name,
+// if both are JSON objects, and the patch is smaller than evt.Data.
+// If the last full payload with the same name was sent at least snapshotInterval before now,
+// evt is sent in full instead.
+func (d *deltaEncoder) encode(evt *Event, now time.Time) {
+	if len(evt.Data) == 0 || evt.DataReader != nil {
+		return
+	}
+
+	next, err := decodeJSON(evt.Data)
+	if err != nil {
+		delete(d.last, evt.Event)
+		return
+	}
+
+	prev, hasPrev := d.last[evt.Event]
+	if !hasPrev || (d.snapshotInterval > 0 && now.Sub(prev.snapshotAt) >= d.snapshotInterval) {
+		d.last[evt.Event] = deltaState{doc: next, snapshotAt: now}
+		return
+	}
+	d.last[evt.Event] = deltaState{doc: next, snapshotAt: prev.snapshotAt}
+
+	patch, ok := createMergePatch(prev.doc, next)
+	if !ok {
+		d.last[evt.Event] = deltaState{doc: next, snapshotAt: now}
+		return
+	}
+
+	encoded, err := json.Marshal(patch)
+	if err != nil || len(DeltaPrefix)+len(encoded) >= len(evt.Data) {
+		d.last[evt.Event] = deltaState{doc: next, snapshotAt: now}
+		return
+	}
+
+	evt.Data = append([]byte(DeltaPrefix), encoded...)
+}
+
+// DeltaDecoder restores the full data of events delta encoded by a Handler.
+// Received events should be passed to Decode in the order they were received.
+// The zero value is ready to use.
+type DeltaDecoder struct {
+	state map[string]interface{}
+}
+
+// Decode returns evt with its data restored in full, if it was a delta.
+// Otherwise, evt is returned as is, and its data is retained to apply later deltas to.
+// Note that restored data is re-encoded, so it may differ in formatting and key order from
+// the data originally sent, though it is equivalent JSON.
+func (d *DeltaDecoder) Decode(evt Event) (Event, error) {
+	if d.state == nil {
+		d.state = make(map[string]interface{})
+	}
+
+	if !bytes.HasPrefix(evt.Data, []byte(DeltaPrefix)) {
+		if doc, err := decodeJSON(evt.Data); err == nil {
+			d.state[evt.Event] = doc
+		} else {
+			delete(d.state, evt.Event)
+		}
+		return evt, nil
+	}
+
+	prev, ok := d.state[evt.Event]
+	if !ok {
+		return Event{}, ErrMissingSnapshot
+	}
+
+	patch, err := decodeJSON(evt.Data[len(DeltaPrefix):])
+	if err != nil {
+		return Event{}, err
+	}
+
+	next := applyMergePatch(prev, patch)
+	data, err := json.Marshal(next)
+	if err != nil {
+		return Event{}, err
+	}
+
+	d.state[evt.Event] = next
+	evt.Data = data
+	return evt, nil
+}
+
+// Reset discards all retained data.
+func (d *DeltaDecoder) Reset() {
+	d.state = nil
+}
+
+func decodeJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("sse: unexpected data after JSON value")
+	}
+	return doc, nil
+}
+
+// createMergePatch returns a merge patch that transforms prev into next.
+// ok is false if prev and next are not both JSON objects, or if next cannot be
+// represented as a patch, i.e. it contains null object members.
+func createMergePatch(prev, next interface{}) (_ interface{}, ok bool) {
+	prevObj, ok := prev.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	nextObj, ok := next.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	patch := make(map[string]interface{})
+	for key := range prevObj {
+		if _, ok := nextObj[key]; !ok {
+			patch[key] = nil
+		}
+	}
+
+	for key, nextVal := range nextObj {
+		prevVal, ok := prevObj[key]
+		if ok && reflect.DeepEqual(prevVal, nextVal) {
+			continue
+		}
+
+		if ok {
+			if sub, ok := createMergePatch(prevVal, nextVal); ok {
+				patch[key] = sub
+				continue
+			}
+		}
+
+		if hasNullMember(nextVal) {
+			return nil, false
+		}
+		patch[key] = nextVal
+	}
+
+	return patch, true
+}
+
+// hasNullMember reports whether v is null, or is an object with null members at any depth.
+func hasNullMember(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	for _, member := range obj {
+		if hasNullMember(member) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyMergePatch applies patch to target as described by RFC 7396.
+func applyMergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = make(map[string]interface{})
+	} else {
+		copied := make(map[string]interface{}, len(targetObj))
+		for key, val := range targetObj {
+			copied[key] = val
+		}
+		targetObj = copied
+	}
+
+	for key, val := range patchObj {
+		if val == nil {
+			delete(targetObj, key)
+		} else {
+			targetObj[key] = applyMergePatch(targetObj[key], val)
+		}
+	}
+	return targetObj
+}
@@ -0,0 +1,167 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeltaEncoding(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round trips", func(t *testing.T) {
+		t.Parallel()
+
+		payloads := []string{
+			`{"name":"widget","count":1,"tags":["a","b"],"dims":{"w":10,"h":20},"price":12345678901234567890}`,
+			`{"name":"widget","count":2,"tags":["a","b"],"dims":{"w":10,"h":20},"price":12345678901234567890}`,
+			`{"name":"widget","count":2,"tags":["a"],"dims":{"w":10},"price":12345678901234567890}`,
+			`{"name":"widget","count":2,"tags":["a",null],"dims":{"w":10},"price":12345678901234567890}`,
+			`{"name":"widget","count":2,"tags":["a"],"dims":{"w":null},"price":12345678901234567890}`,
+			`[1,2,3]`,
+			`not json`,
+			`{"name":"gadget"}`,
+		}
+
+		enc := newDeltaEncoder(0)
+		var dec DeltaDecoder
+		sentDelta := false
+
+		for i, payload := range payloads {
+			evt := Event{Event: "state", Data: []byte(payload)}
+			enc.encode(&evt, time.Now())
+			if bytes.HasPrefix(evt.Data, []byte(DeltaPrefix)) {
+				sentDelta = true
+			}
+
+			out, err := dec.Decode(evt)
+			if err != nil {
+				t.Fatalf("payload %d: %v", i, err)
+			}
+
+			if !jsonEqual(out.Data, []byte(payload)) {
+				t.Errorf("payload %d: expected %s, but got %s (sent %s)", i, payload, out.Data, evt.Data)
+			}
+		}
+
+		if !sentDelta {
+			t.Error("expected at least one delta to be sent")
+		}
+	})
+
+	t.Run("tracks event names separately", func(t *testing.T) {
+		t.Parallel()
+
+		enc := newDeltaEncoder(0)
+		long := `{"a":1,"b":"` + strings.Repeat("x", 64) + `"}`
+
+		first := Event{Event: "one", Data: []byte(long)}
+		enc.encode(&first, time.Now())
+		second := Event{Event: "two", Data: []byte(long)}
+		enc.encode(&second, time.Now())
+
+		if string(second.Data) != long {
+			t.Errorf("expected first event named 'two' to be sent in full, but got %s", second.Data)
+		}
+	})
+
+	t.Run("sends periodic snapshots", func(t *testing.T) {
+		t.Parallel()
+
+		enc := newDeltaEncoder(time.Minute)
+		now := time.Now()
+		description := strings.Repeat("x", 64)
+
+		steps := []struct {
+			at    time.Time
+			delta bool
+		}{
+			{now, false},
+			{now.Add(time.Second), true},
+			{now.Add(59 * time.Second), true},
+			{now.Add(time.Minute), false},
+			{now.Add(time.Minute + time.Second), true},
+		}
+
+		for i, step := range steps {
+			evt := Event{Data: []byte(`{"count":` + strconv.Itoa(i) + `,"description":"` + description + `"}`)}
+			enc.encode(&evt, step.at)
+
+			if delta := bytes.HasPrefix(evt.Data, []byte(DeltaPrefix)); delta != step.delta {
+				t.Errorf("step %d: expected delta = %v, but got %s", i, step.delta, evt.Data)
+			}
+		}
+	})
+
+	t.Run("requires a snapshot", func(t *testing.T) {
+		t.Parallel()
+
+		var dec DeltaDecoder
+		_, err := dec.Decode(Event{Data: []byte(DeltaPrefix + `{"a":1}`)})
+		if !errors.Is(err, ErrMissingSnapshot) {
+			t.Errorf("expected ErrMissingSnapshot, but got %v", err)
+		}
+	})
+
+	t.Run("Handler sends deltas when requested", func(t *testing.T) {
+		t.Parallel()
+
+		first := `{"count":1,"description":"` + strings.Repeat("x", 64) + `"}`
+		second := `{"count":2,"description":"` + strings.Repeat("x", 64) + `"}`
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			go func() {
+				stream.Send(Event{Data: []byte(first)})
+				stream.Send(Event{Data: []byte(second)})
+				stream.Close()
+			}()
+			return nil
+		})
+		h.DeltaEncoding = true
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		for _, tc := range []struct {
+			name   string
+			query  string
+			second string
+		}{
+			{"without param", "", second},
+			{"with param", "?" + ExtensionsParam + "=" + string(ExtDelta), DeltaPrefix + `{"count":2}`},
+		} {
+			resp, err := srv.Client().Get(srv.URL + tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var lines []string
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				if line := scanner.Text(); line != "" {
+					lines = append(lines, strings.TrimPrefix(line, "data:"))
+				}
+			}
+			resp.Body.Close()
+
+			expected := []string{first, tc.second}
+			if !reflect.DeepEqual(lines, expected) {
+				t.Errorf("%s: expected data %q, but got %q", tc.name, expected, lines)
+			}
+		}
+	})
+}
+
+func jsonEqual(a, b []byte) bool {
+	var x, y interface{}
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return bytes.Equal(a, b)
+	}
+	return reflect.DeepEqual(x, y)
+}
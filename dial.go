@@ -0,0 +1,34 @@
+package sse
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// DialFunc dials the connections an *http.Client makes requests over, as an http.Transport's DialContext,
+// e.g. a net.Dialer's DialContext method, or that of a SOCKS proxy's dialer.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// NewStreamClient returns an *http.Client for consuming event streams, e.g. with NewResponseDecoder, with
+// the connections dialed by dial, e.g. to reach servers over unix domain sockets (see DialUnix), or through
+// a proxy, as in sidecar and test setups. If dial is nil, connections are dialed as by http.DefaultTransport.
+//
+// Its transport is otherwise a clone of http.DefaultTransport, and the client has no Timeout, which would
+// end streams; requests are limited by their contexts instead.
+func NewStreamClient(dial DialFunc) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if dial != nil {
+		transport.DialContext = dial
+	}
+	return &http.Client{Transport: transport}
+}
+
+// DialUnix returns a DialFunc dialing the unix domain socket at path for every address, so that requests to
+// any host, e.g. "http://unix/events", are made to the server listening on it.
+func DialUnix(path string) DialFunc {
+	var dialer net.Dialer
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "unix", path)
+	}
+}
@@ -0,0 +1,74 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNewStreamClient(t *testing.T) {
+	t.Parallel()
+
+	t.Run("streams over a unix domain socket", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			stream.Go(func(ctx context.Context) error {
+				return stream.Send(Event{Data: []byte("hello"), ID: "1"})
+			})
+			return nil
+		})
+
+		path := filepath.Join(t.TempDir(), "sse.sock")
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			t.Skipf("unix domain sockets are not supported: %v", err)
+		}
+		srv := &http.Server{Handler: h}
+		go srv.Serve(l)
+		t.Cleanup(func() { srv.Close() })
+
+		resp, err := NewStreamClient(DialUnix(path)).Get("http://unix/events")
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		defer resp.Body.Close()
+
+		d, err := NewResponseDecoder(resp)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		evt, err := d.Decode()
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if expected := (Event{Data: []byte("hello"), ID: "1"}); !reflect.DeepEqual(expected, evt) {
+			t.Errorf("expected %+v, but got %+v", expected, evt)
+		}
+	})
+
+	t.Run("dials with dial", func(t *testing.T) {
+		t.Parallel()
+
+		errDial := errors.New("dial")
+		var addrs []string
+		client := NewStreamClient(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			addrs = append(addrs, addr)
+			return nil, errDial
+		})
+
+		if _, err := client.Get("http://example.com/events"); !errors.Is(err, errDial) {
+			t.Errorf("expected the dial error, but got %v", err)
+		}
+		if expected := []string{"example.com:80"}; !reflect.DeepEqual(expected, addrs) {
+			t.Errorf("expected %v to be dialed, but got %v", expected, addrs)
+		}
+		if client.Timeout != 0 {
+			t.Errorf("expected no timeout, but got %v", client.Timeout)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package sse
+
+import (
+	"encoding/json"
+)
+
+// StreamErrorEvent is the event name of the terminal event sent by EventStream.CloseWithError,
+// and by a Handler when its NewEventStreamHandler fails, or an EventStream exceeds its memory limit.
+// Its data is a JSON object with the fields of a StreamError:
+//
+//	{"code":"quota_exceeded","message":"too many connections","retry":false}
+//
+// Receivers can parse it with ParseStreamError.
+const StreamErrorEvent = "sse-stream-error"
+
+// Reason codes for a StreamError. Applications may define their own.
+const (
+	ReasonServerError   = "server_error"
+	ReasonQuotaExceeded = "quota_exceeded"
+	ReasonAuthExpired   = "auth_expired"
+	ReasonShutdown      = "shutdown"
+	ReasonSlowClient    = "slow_client"
+	ReasonCanceled      = "canceled"
+	ReasonMaxAge        = "max_age"
+)
+
+// StreamError describes why a server ended a stream, and whether the client should reconnect.
+type StreamError struct {
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+
+	// Retry reports whether the client should reconnect, or give up.
+	Retry bool `json:"retry"`
+}
+
+// NewStreamError returns a *StreamError with code and message.
+// Retry is true for ReasonServerError, ReasonShutdown, ReasonSlowClient, and ReasonMaxAge, and false otherwise.
+func NewStreamError(code, message string) *StreamError {
+	return &StreamError{
+		Code:    code,
+		Message: message,
+		Retry:   code == ReasonServerError || code == ReasonShutdown || code == ReasonSlowClient || code == ReasonMaxAge,
+	}
+}
+
+func (e *StreamError) Error() string {
+	if e.Message == "" {
+		return "sse: stream ended: " + e.Code
+	}
+	return "sse: stream ended: " + e.Code + ": " + e.Message
+}
+
+// Event returns the StreamErrorEvent describing e.
+func (e *StreamError) Event() Event {
+	data, _ := json.Marshal(e)
+	return Event{
+		Event: StreamErrorEvent,
+		Data:  data,
+	}
+}
+
+// memoryLimitError returns the StreamError that ends a stream when queueing an event exceeds
+// the Handler's Allocator's limit. The limit is shared with other connections, so the client
+// may try again once memory has been released.
+func memoryLimitError() *StreamError {
+	err := NewStreamError(ReasonQuotaExceeded, ErrMemoryLimit.Error())
+	err.Retry = true
+	return err
+}
+
+// ParseStreamError returns the *StreamError described by evt, if it is a StreamErrorEvent.
+// If evt's data is malformed, a retryable ReasonServerError is returned.
+func ParseStreamError(evt Event) (_ *StreamError, ok bool) {
+	if evt.Event != StreamErrorEvent {
+		return nil, false
+	}
+
+	var e StreamError
+	if err := json.Unmarshal(evt.Data, &e); err != nil || e.Code == "" {
+		return NewStreamError(ReasonServerError, "malformed stream error"), true
+	}
+	return &e, true
+}
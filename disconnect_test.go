@@ -0,0 +1,169 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamError(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round trips", func(t *testing.T) {
+		t.Parallel()
+
+		for _, tt := range []struct {
+			code  string
+			retry bool
+		}{
+			{ReasonServerError, true},
+			{ReasonShutdown, true},
+			{ReasonQuotaExceeded, false},
+			{ReasonAuthExpired, false},
+		} {
+			sent := NewStreamError(tt.code, "details")
+			received, ok := ParseStreamError(sent.Event())
+			if !ok {
+				t.Fatalf("%s: expected event to be a stream error", tt.code)
+			}
+
+			if *received != *sent {
+				t.Errorf("%s: expected %+v, but got %+v", tt.code, sent, received)
+			}
+
+			if received.Retry != tt.retry {
+				t.Errorf("%s: expected retry = %v, but got %v", tt.code, tt.retry, received.Retry)
+			}
+
+			var err error = received
+			var target *StreamError
+			if !errors.As(err, &target) {
+				t.Errorf("%s: expected errors.As to find *StreamError", tt.code)
+			}
+		}
+	})
+
+	t.Run("ignores other events", func(t *testing.T) {
+		t.Parallel()
+
+		if _, ok := ParseStreamError(Event{Event: "message", Data: []byte(`{"code":"shutdown"}`)}); ok {
+			t.Error("expected event to not be a stream error")
+		}
+	})
+
+	t.Run("handles malformed data", func(t *testing.T) {
+		t.Parallel()
+
+		e, ok := ParseStreamError(Event{Event: StreamErrorEvent, Data: []byte("oops")})
+		if !ok || e.Code != ReasonServerError || !e.Retry {
+			t.Errorf("expected retryable server error, but got %+v (ok = %v)", e, ok)
+		}
+	})
+
+	t.Run("CloseWithError sends terminal event", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			go stream.CloseWithError(NewStreamError(ReasonAuthExpired, "token expired"))
+			return nil
+		})
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		var evt Event
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if name := strings.TrimPrefix(line, "event:"); name != line {
+				evt.Event = name
+			} else if data := strings.TrimPrefix(line, "data:"); data != line {
+				evt.Data = []byte(data)
+			}
+		}
+
+		e, ok := ParseStreamError(evt)
+		if !ok {
+			t.Fatalf("expected a stream error event, but got %+v", evt)
+		}
+		if e.Code != ReasonAuthExpired || e.Message != "token expired" || e.Retry {
+			t.Errorf("expected non-retryable auth_expired error, but got %+v", e)
+		}
+	})
+
+	t.Run("CloseWithError returns once client disconnects", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		stream := EventStream{
+			ctx:    ctx,
+			events: make(chan queuedEvent),
+			queue:  newQueueAccount(DefaultAllocator),
+			closer: newStreamCloser(),
+		}
+
+		done := make(chan error, 1)
+		go func() { done <- stream.CloseWithError(NewStreamError(ReasonShutdown, "")) }()
+
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("expected context.Canceled, but got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("expected CloseWithError to return")
+		}
+	})
+
+	t.Run("Handler sends terminal event when NewEventStreamHandler fails", func(t *testing.T) {
+		t.Parallel()
+
+		for _, tt := range []struct {
+			err    error
+			status int
+			code   string
+		}{
+			{errors.New("database unavailable"), http.StatusInternalServerError, ReasonServerError},
+			{fmt.Errorf("rejected: %w", NewStreamError(ReasonAuthExpired, "token expired")), http.StatusOK, ReasonAuthExpired},
+		} {
+			h := NewHandler(func(stream EventStream, lastEventID string) error { return tt.err })
+			srv := httptest.NewServer(h)
+
+			resp, err := srv.Client().Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			srv.Close()
+			if err != nil {
+				t.Fatal("failed to read response body:", err)
+			}
+
+			if resp.StatusCode != tt.status {
+				t.Errorf("%v: expected status %d, but got %d", tt.err, tt.status, resp.StatusCode)
+			}
+
+			expected := "event:" + StreamErrorEvent + "\ndata:{\"code\":\"" + tt.code + "\""
+			if !strings.HasPrefix(string(body), expected) {
+				t.Errorf("%v: expected %q, but got %q", tt.err, expected, body)
+			}
+			if strings.Contains(string(body), "database") {
+				t.Errorf("%v: expected error details to not be sent, but got %q", tt.err, body)
+			}
+		}
+	})
+}
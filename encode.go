@@ -0,0 +1,388 @@
+package sse
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidEvent is returned (wrapped) by ValidateEvent if an Event cannot be sent correctly.
+var ErrInvalidEvent = errors.New("sse: invalid event")
+
+// EncodeEvent writes evt to w in the text/event-stream format, as sent by a Handler.
+// Nothing is written if evt is empty. Each line break in its data, a CRLF, an LF, or a CR, starts a new
+// data line, so that clients receive them all as LFs.
+// If evt has a DataReader, it is consumed (and closed, if it is an io.Closer).
+// evt is not validated; see ValidateEvent.
+func EncodeEvent(w io.Writer, evt Event) error {
+	return NewEncoder(w).WriteEvent(evt)
+}
+
+// Encoder writes events to an io.Writer in the text/event-stream format, as sent by a Handler, e.g. for
+// writing fixtures, proxies, or servers not built on net/http. Each event is written with a single Write,
+// unless it has a DataReader, so that w may be flushed between them.
+// An Encoder reuses its buffer between events; it is not safe for concurrent use.
+type Encoder struct {
+	// FieldSpace, if set, writes a space after the colon of each field, e.g. "data: hello", as some tools
+	// reading streams expect, rather than the compact form, e.g. "data:hello". Clients remove the space,
+	// so events are received the same either way. See Handler.FieldSpace.
+	FieldSpace bool
+
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+// NewEncoder returns an *Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// WriteEvent writes evt. Nothing is written if evt is empty.
+// If evt has a DataReader, it is consumed (and closed, if it is an io.Closer).
+// evt is not validated; see ValidateEvent.
+func (e *Encoder) WriteEvent(evt Event) error {
+	e.buf.Reset()
+	return writeEvent(e.w, &e.buf, &evt, e.FieldSpace)
+}
+
+// WriteComment writes text as comment lines, one per line of text, which clients ignore, see Event.Comment.
+// An empty text is written as an empty comment line, e.g. for a keep-alive.
+func (e *Encoder) WriteComment(text string) error {
+	if text == "" {
+		_, err := io.WriteString(e.w, ":\n\n")
+		return err
+	}
+	return e.WriteEvent(Event{Comment: text})
+}
+
+// WriteRetry writes a retry field, telling the client how long to wait before reconnecting,
+// which must be at least a millisecond.
+func (e *Encoder) WriteRetry(retry time.Duration) error {
+	if retry < time.Millisecond {
+		return fmt.Errorf("%w: retry is less than a millisecond", ErrInvalidEvent)
+	}
+	return e.WriteEvent(Event{Retry: retry})
+}
+
+// errDataReader is returned by Event.MarshalText for an Event with a DataReader.
+var errDataReader = errors.New("sse: event with a DataReader cannot be marshaled")
+
+// MarshalText returns e in the text/event-stream format, exactly as a Handler sends it, e.g. for logging,
+// storing, or hashing events. An empty Event is marshaled as no bytes.
+// It returns an error if e is not valid (see ValidateEvent), or has a DataReader, which would be consumed.
+func (e Event) MarshalText() ([]byte, error) {
+	if e.DataReader != nil {
+		return nil, errDataReader
+	}
+	if err := ValidateEvent(e); err != nil {
+		return nil, err
+	}
+	return e.AppendTo(nil), nil
+}
+
+// AppendTo appends e in the text/event-stream format, exactly as a Handler sends it, to dst and returns
+// the extended slice, so that a buffer can be reused between events. Nothing is appended if e is empty.
+// e is not validated; see ValidateEvent. Its DataReader, if any, is ignored.
+func (e Event) AppendTo(dst []byte) []byte {
+	e.DataReader = nil
+
+	buf := bytes.NewBuffer(dst)
+	appendEvent(nil, buf, &e, false)
+	return buf.Bytes()
+}
+
+// ValidateEvent reports whether evt would be received by a client as it was sent.
+// The returned error wraps ErrInvalidEvent if it would not be, due to:
+//   - a line break or NUL character in the Event or ID
+//   - an ID along with ResetID
+//   - a negative Retry, or a positive Retry of less than a millisecond
+//   - an empty tag, or a tag containing a comma, line break, or NUL character
+//   - a carriage return or NUL character in the Comment
+//   - a TraceParent that is not a valid W3C traceparent
+func ValidateEvent(evt Event) error {
+	if strings.ContainsAny(evt.Event, "\r\n\x00") {
+		return fmt.Errorf("%w: event name contains a line break or NUL character", ErrInvalidEvent)
+	}
+
+	if strings.ContainsAny(evt.ID, "\r\n\x00") {
+		return fmt.Errorf("%w: ID contains a line break or NUL character", ErrInvalidEvent)
+	}
+
+	if evt.ResetID && evt.ID != "" {
+		return fmt.Errorf("%w: ID is set along with ResetID", ErrInvalidEvent)
+	}
+
+	if evt.Retry < 0 {
+		return fmt.Errorf("%w: retry is negative", ErrInvalidEvent)
+	}
+
+	if evt.Retry > 0 && evt.Retry < time.Millisecond {
+		return fmt.Errorf("%w: retry is less than a millisecond", ErrInvalidEvent)
+	}
+
+	for _, tag := range evt.Tags {
+		if tag == "" || strings.ContainsAny(tag, ",\r\n\x00") {
+			return fmt.Errorf("%w: invalid tag %q", ErrInvalidEvent, tag)
+		}
+	}
+
+	if len(evt.Variants) > 0 && evt.Experiment == "" {
+		return fmt.Errorf("%w: variants are set without an experiment", ErrInvalidEvent)
+	}
+
+	if strings.ContainsAny(evt.Comment, "\r\x00") {
+		return fmt.Errorf("%w: comment contains a carriage return or NUL character", ErrInvalidEvent)
+	}
+
+	if evt.TraceParent != "" && !validTraceParent(evt.TraceParent) {
+		return fmt.Errorf("%w: invalid traceparent %q", ErrInvalidEvent, evt.TraceParent)
+	}
+
+	return nil
+}
+
+// SanitizeEvent returns evt without what would keep a client from receiving it as it was sent (see
+// ValidateEvent), so that an event built from untrusted input cannot forge fields or events:
+//   - line breaks and NUL characters are removed from the Event and ID
+//   - carriage returns in the Comment start new comment lines, as line feeds do, and NUL characters are removed
+//   - invalid tags are removed
+//   - an invalid TraceParent is removed
+//
+// Handlers send events as by SanitizeEvent, unless they have a DeadLetter hook, or StrictEvents set.
+func SanitizeEvent(evt Event) Event {
+	sanitizeEvent(&evt)
+	return evt
+}
+
+func sanitizeEvent(evt *Event) {
+	evt.Event = removeBytes(evt.Event, "\r\n\x00")
+	evt.ID = removeBytes(evt.ID, "\r\n\x00")
+
+	if strings.ContainsAny(evt.Comment, "\r\x00") {
+		comment := strings.ReplaceAll(evt.Comment, "\r\n", "\n")
+		comment = strings.ReplaceAll(comment, "\r", "\n")
+		evt.Comment = removeBytes(comment, "\x00")
+	}
+
+	if evt.TraceParent != "" && !validTraceParent(evt.TraceParent) {
+		evt.TraceParent = ""
+	}
+
+	for i, tag := range evt.Tags {
+		if tag != "" && !strings.ContainsAny(tag, ",\r\n\x00") {
+			continue
+		}
+
+		// copied, since the tags may be shared with other events
+		tags := append([]string(nil), evt.Tags[:i]...)
+		for _, tag := range evt.Tags[i+1:] {
+			if tag != "" && !strings.ContainsAny(tag, ",\r\n\x00") {
+				tags = append(tags, tag)
+			}
+		}
+		evt.Tags = tags
+		break
+	}
+}
+
+// removeBytes returns s without any of the bytes in chars.
+func removeBytes(s, chars string) string {
+	if !strings.ContainsAny(s, chars) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(chars, s[i]) < 0 {
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// readChunkSize is the size of the chunks read from an Event's DataReader.
+const readChunkSize = 4096
+
+// writeEvent encodes evt into buf, and writes it to w.
+// Nothing is written if evt is empty.
+// buf may also be written to w before the event is complete, if evt has a DataReader.
+func writeEvent(w io.Writer, buf *bytes.Buffer, evt *Event, space bool) error {
+	if err := appendEvent(w, buf, evt, space); err != nil || buf.Len() == 0 {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// appendEvent encodes evt onto the end of buf. Nothing is appended if evt is empty.
+// Line breaks in its data, of any kind, are encoded as separate data lines.
+// If space is set, fields are written with a space after their colon, see Encoder.FieldSpace.
+// If evt has a DataReader, buf is written to w, and reset, whenever it grows past readChunkSize,
+// so that the contents of buf from earlier events are written first.
+func appendEvent(w io.Writer, buf *bytes.Buffer, evt *Event, space bool) error {
+	start := buf.Len()
+	wrote := false
+
+	for comment := evt.Comment; len(comment) != 0; {
+		line := comment
+		if i := strings.IndexByte(comment, '\n'); i >= 0 {
+			line, comment = comment[:i], comment[i+1:]
+		} else {
+			comment = ""
+		}
+
+		buf.WriteString(": ")
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+
+	if len(evt.Event) != 0 {
+		writeField(buf, "event:", evt.Event[0], space)
+		buf.WriteString(evt.Event)
+		buf.WriteByte('\n')
+	}
+
+	for data := evt.Data; len(evt.Data) != 0; {
+		if len(data) > 0 {
+			writeField(buf, "data:", data[0], space)
+		} else {
+			writeField(buf, "data:", 0, space)
+		}
+
+		i := bytes.IndexAny(data, "\r\n")
+		if i < 0 {
+			buf.Write(data)
+			buf.WriteByte('\n')
+			break
+		}
+
+		buf.Write(data[:i])
+		buf.WriteByte('\n')
+		if data[i] == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+			i++
+		}
+		data = data[i+1:]
+	}
+
+	if evt.DataReader != nil {
+		n, err := writeDataReader(w, buf, evt.DataReader, space)
+		if closer, ok := evt.DataReader.(io.Closer); ok {
+			closer.Close()
+		}
+		if err != nil {
+			return err
+		}
+		wrote = n > 0
+	}
+
+	switch {
+	case evt.ResetID:
+		buf.WriteString("id\n")
+	case len(evt.ID) != 0:
+		writeField(buf, "id:", evt.ID[0], space)
+		buf.WriteString(evt.ID)
+		buf.WriteByte('\n')
+	}
+
+	if evt.Retry > 0 {
+		var retry [20]byte
+		writeField(buf, "retry:", 0, space)
+		buf.Write(strconv.AppendInt(retry[:0], evt.Retry.Milliseconds(), 10))
+		buf.WriteByte('\n')
+	}
+
+	if !wrote && buf.Len() == start {
+		return nil
+	}
+
+	buf.WriteByte('\n')
+	return nil
+}
+
+// writeField writes the name of a field, followed by a space if space is set, or if first, the first byte
+// of its value, is a space, which clients would otherwise strip from the value.
+func writeField(buf *bytes.Buffer, name string, first byte, space bool) {
+	buf.WriteString(name)
+	if space || first == ' ' {
+		buf.WriteByte(' ')
+	}
+}
+
+// writeDataReader encodes the contents of r into buf as data lines, writing buf to w
+// whenever it grows past readChunkSize. It returns the number of bytes read from r.
+func writeDataReader(w io.Writer, buf *bytes.Buffer, r io.Reader, space bool) (int64, error) {
+	var (
+		chunk       = make([]byte, readChunkSize)
+		total       int64
+		atLineStart = true
+		skipLF      bool
+	)
+
+	for {
+		n, err := r.Read(chunk)
+		total += int64(n)
+
+		for data := chunk[:n]; len(data) > 0; {
+			// the LF of a CRLF split between reads
+			if skipLF {
+				skipLF = false
+				if data[0] == '\n' {
+					data = data[1:]
+					continue
+				}
+			}
+
+			if atLineStart {
+				writeField(buf, "data:", data[0], space)
+			}
+
+			i := bytes.IndexAny(data, "\r\n")
+			if i < 0 {
+				buf.Write(data)
+				atLineStart = false
+				break
+			}
+
+			buf.Write(data[:i])
+			buf.WriteByte('\n')
+			if data[i] == '\r' {
+				if i+1 == len(data) {
+					skipLF = true
+				} else if data[i+1] == '\n' {
+					i++
+				}
+			}
+			data = data[i+1:]
+			atLineStart = true
+		}
+
+		if buf.Len() >= readChunkSize {
+			if _, err := w.Write(buf.Bytes()); err != nil {
+				return total, err
+			}
+			buf.Reset()
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+
+	if total > 0 {
+		// match the encoding of Data: a trailing newline results in a trailing empty data line
+		if atLineStart {
+			writeField(buf, "data:", 0, space)
+		}
+		buf.WriteByte('\n')
+	}
+
+	return total, nil
+}
@@ -0,0 +1,452 @@
+package sse
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestEncodeEvent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		evt      Event
+		expected string
+	}{
+		{
+			name:     "empty",
+			evt:      Event{},
+			expected: "",
+		},
+		{
+			name: "all fields",
+			evt: Event{
+				Event: "hello",
+				Data:  []byte("line one\nline two"),
+				ID:    "1",
+				Retry: 1500 * time.Millisecond,
+			},
+			expected: "event:hello\ndata:line one\ndata:line two\nid:1\nretry:1500\n\n",
+		},
+		{
+			name:     "leading spaces",
+			evt:      Event{Event: " hello", Data: []byte(" a\n  b\nc")},
+			expected: "event:  hello\ndata:  a\ndata:   b\ndata:c\n\n",
+		},
+		{
+			name:     "leading spaces from a DataReader",
+			evt:      Event{DataReader: strings.NewReader(" a\n b")},
+			expected: "data:  a\ndata:  b\n\n",
+		},
+		{
+			name:     "CRLF and CR line breaks",
+			evt:      Event{Data: []byte("a\r\nb\rc\n\r\nd\r")},
+			expected: "data:a\ndata:b\ndata:c\ndata:\ndata:d\ndata:\n\n",
+		},
+		{
+			name:     "CRLF split between reads of a DataReader",
+			evt:      Event{DataReader: iotest.OneByteReader(strings.NewReader("a\r\nb\rc\r"))},
+			expected: "data:a\ndata:b\ndata:c\ndata:\n\n",
+		},
+		{
+			name:     "reset ID",
+			evt:      Event{ResetID: true},
+			expected: "id\n\n",
+		},
+		{
+			name:     "ID of a space",
+			evt:      Event{ID: " "},
+			expected: "id:  \n\n",
+		},
+		{
+			name:     "data and reader",
+			evt:      Event{Data: []byte("first"), DataReader: strings.NewReader("second\n")},
+			expected: "data:first\ndata:second\ndata:\n\n",
+		},
+		{
+			name:     "comment",
+			evt:      Event{Comment: "heartbeat\nfrom node-1"},
+			expected: ": heartbeat\n: from node-1\n\n",
+		},
+		{
+			name:     "comment and fields",
+			evt:      Event{Comment: "debug marker", Data: []byte("x"), ID: "2"},
+			expected: ": debug marker\ndata:x\nid:2\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			if err := EncodeEvent(&buf, tt.evt); err != nil {
+				t.Fatal(err)
+			}
+
+			if buf.String() != tt.expected {
+				t.Errorf("expected %q, but got %q", tt.expected, buf.String())
+			}
+		})
+	}
+}
+
+// TestEncodeEventAllocs is not parallel, since testing.AllocsPerRun cannot be used by parallel tests.
+func TestEncodeEventAllocs(t *testing.T) {
+	for _, evt := range []Event{
+		{Data: []byte("42")},
+		{Event: "update", Data: []byte(`{"value":42}`), ID: "1234", Retry: time.Second},
+		{Data: []byte("line one\r\nline two\nline three")},
+	} {
+		enc := NewEncoder(io.Discard)
+		enc.WriteEvent(evt) // grows the Encoder's buffer
+
+		if allocs := testing.AllocsPerRun(100, func() { enc.WriteEvent(evt) }); allocs != 0 {
+			t.Errorf("expected no allocations encoding %q, but got %v", evt.Data, allocs)
+		}
+
+		dst := evt.AppendTo(nil)
+		if allocs := testing.AllocsPerRun(100, func() { evt.AppendTo(dst[:0]) }); allocs != 0 {
+			t.Errorf("expected no allocations appending %q, but got %v", evt.Data, allocs)
+		}
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	t.Parallel()
+
+	t.Run("writes events, comments, and retries", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		for _, err := range []error{
+			enc.WriteRetry(2 * time.Second),
+			enc.WriteComment("fixture\nfor tests"),
+			enc.WriteEvent(Event{Event: "hello", Data: []byte("world"), ID: "1"}),
+			enc.WriteComment(""),
+			enc.WriteEvent(Event{Data: []byte("second")}),
+		} {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		expected := "retry:2000\n\n: fixture\n: for tests\n\nevent:hello\ndata:world\nid:1\n\n:\n\ndata:second\n\n"
+		if buf.String() != expected {
+			t.Errorf("expected %q, but got %q", expected, buf.String())
+		}
+	})
+
+	t.Run("writes a space after field names", func(t *testing.T) {
+		t.Parallel()
+
+		events := []Event{
+			{Event: "hello", Data: []byte("a\n b\n"), ID: "1", Retry: 1500 * time.Millisecond},
+			{DataReader: strings.NewReader(" c\n"), ResetID: true},
+		}
+
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.FieldSpace = true
+		for _, evt := range events {
+			if err := enc.WriteEvent(evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		expected := "event: hello\ndata: a\ndata:  b\ndata: \nid: 1\nretry: 1500\n\ndata:  c\ndata: \nid\n\n"
+		if buf.String() != expected {
+			t.Errorf("expected %q, but got %q", expected, buf.String())
+		}
+
+		dec := NewDecoder(&buf)
+		for _, expected := range []Event{
+			{Event: "hello", Data: []byte("a\n b\n"), ID: "1", Retry: 1500 * time.Millisecond},
+			{Data: []byte(" c\n"), ResetID: true},
+		} {
+			evt, err := dec.Decode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(expected, evt) {
+				t.Errorf("expected %+v, but got %+v", expected, evt)
+			}
+		}
+	})
+
+	t.Run("writes each event at once", func(t *testing.T) {
+		t.Parallel()
+
+		var w writeCounter
+		enc := NewEncoder(&w)
+		enc.WriteEvent(Event{Event: "hello", Data: []byte("a\nb\nc"), ID: "1"})
+		enc.WriteEvent(Event{Data: []byte("d")})
+		if w.writes != 2 {
+			t.Errorf("expected 2 writes, but got %d", w.writes)
+		}
+	})
+
+	t.Run("rejects retries of less than a millisecond", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).WriteRetry(0); !errors.Is(err, ErrInvalidEvent) {
+			t.Errorf("expected ErrInvalidEvent, but got %v", err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("expected nothing to be written, but got %q", buf.String())
+		}
+	})
+}
+
+func TestEventMarshalText(t *testing.T) {
+	t.Parallel()
+
+	t.Run("matches EncodeEvent", func(t *testing.T) {
+		t.Parallel()
+
+		for _, evt := range []Event{
+			{},
+			{Comment: "note", Event: "hello", Data: []byte(" line one\nline two\n"), ID: "1", Retry: 1500 * time.Millisecond},
+			{Data: []byte("x"), ResetID: true},
+		} {
+			var buf bytes.Buffer
+			if err := EncodeEvent(&buf, evt); err != nil {
+				t.Fatal(err)
+			}
+
+			text, err := evt.MarshalText()
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if string(text) != buf.String() {
+				t.Errorf("expected %q, but got %q", buf.String(), text)
+			}
+		}
+	})
+
+	t.Run("AppendTo appends", func(t *testing.T) {
+		t.Parallel()
+
+		dst := make([]byte, 0, 64)
+		dst = Event{Data: []byte("a")}.AppendTo(dst)
+		dst = Event{Data: []byte("b"), ID: "2"}.AppendTo(dst)
+
+		expected := "data:a\n\ndata:b\nid:2\n\n"
+		if string(dst) != expected {
+			t.Errorf("expected %q, but got %q", expected, dst)
+		}
+	})
+
+	t.Run("rejects invalid events", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := (Event{Event: "a\nb"}).MarshalText(); !errors.Is(err, ErrInvalidEvent) {
+			t.Errorf("expected ErrInvalidEvent, but got %v", err)
+		}
+		if _, err := (Event{DataReader: strings.NewReader("x")}).MarshalText(); err == nil {
+			t.Error("expected an error for a DataReader, but got none")
+		}
+	})
+}
+
+// writeCounter counts the calls to its Write method.
+type writeCounter struct {
+	writes int
+}
+
+func (w *writeCounter) Write(p []byte) (int, error) {
+	w.writes++
+	return len(p), nil
+}
+
+func TestValidateEvent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		evt   Event
+		valid bool
+	}{
+		{"empty", Event{}, true},
+		{"all fields", Event{Event: "hello", Data: []byte("a\nb"), ID: "1", Retry: time.Second}, true},
+		{"reset ID", Event{ResetID: true}, true},
+		{"ID of a space", Event{ID: " "}, true},
+		{"ID and reset ID", Event{ID: "1", ResetID: true}, false},
+		{"newline in name", Event{Event: "hello\ndata:forged"}, false},
+		{"carriage return in name", Event{Event: "hello\r"}, false},
+		{"NUL in name", Event{Event: "hello\x00"}, false},
+		{"newline in ID", Event{ID: "1\nretry:1"}, false},
+		{"NUL in ID", Event{ID: "1\x00"}, false},
+		{"carriage return in data", Event{Data: []byte("a\r\nb")}, true},
+		{"negative retry", Event{Retry: -time.Second}, false},
+		{"sub-millisecond retry", Event{Retry: time.Microsecond}, false},
+		{"tags", Event{Tags: []string{"urgent", "billing"}}, true},
+		{"empty tag", Event{Tags: []string{""}}, false},
+		{"comma in tag", Event{Tags: []string{"a,b"}}, false},
+		{"newline in tag", Event{Tags: []string{"a\nb"}}, false},
+		{"multi-line comment", Event{Comment: "a\nb"}, true},
+		{"carriage return in comment", Event{Comment: "a\r\nb"}, false},
+		{"NUL in comment", Event{Comment: "a\x00"}, false},
+		{"variants", Event{Experiment: "banner", Variants: map[string][]byte{"b": []byte("b")}}, true},
+		{"variants without experiment", Event{Variants: map[string][]byte{"b": []byte("b")}}, false},
+		{"carriage return in variant", Event{Experiment: "banner", Variants: map[string][]byte{"b": []byte("\r")}}, true},
+		{"traceparent", Event{TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, true},
+		{"invalid traceparent", Event{TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736\ndata:forged"}, false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := ValidateEvent(tt.evt)
+			if tt.valid && err != nil {
+				t.Errorf("expected event to be valid, but got %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidEvent) {
+				t.Errorf("expected ErrInvalidEvent, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestSanitizeEvent(t *testing.T) {
+	t.Parallel()
+
+	tags := []string{"a", "b,c", "", "d"}
+	tests := []struct {
+		name     string
+		evt      Event
+		expected Event
+	}{
+		{"valid", Event{Event: "e", Data: []byte("a\r\nb"), ID: "1", Tags: []string{"a"}}, Event{Event: "e", Data: []byte("a\r\nb"), ID: "1", Tags: []string{"a"}}},
+		{"line breaks in name and ID", Event{Event: "e\ndata:forged", ID: "1\r\n\nid:2\x00"}, Event{Event: "edata:forged", ID: "1id:2"}},
+		{"carriage returns in comment", Event{Comment: "a\r\nb\rdata:forged\x00"}, Event{Comment: "a\nb\ndata:forged"}},
+		{"invalid tags", Event{Tags: tags}, Event{Tags: []string{"a", "d"}}},
+		{"invalid traceparent", Event{TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736\ndata:forged"}, Event{}},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual := SanitizeEvent(test.evt)
+			if !reflect.DeepEqual(test.expected, actual) {
+				t.Errorf("expected %+v, but got %+v", test.expected, actual)
+			}
+			if err := ValidateEvent(actual); err != nil {
+				t.Errorf("expected a valid event, but got %v", err)
+			}
+		})
+	}
+
+	if !reflect.DeepEqual(tags, []string{"a", "b,c", "", "d"}) {
+		t.Errorf("expected the tags not to be modified, but got %q", tags)
+	}
+}
+
+func TestStrictEvents(t *testing.T) {
+	t.Parallel()
+
+	serve := func(t *testing.T, h *Handler) string {
+		t.Helper()
+
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	forged := Event{Event: "message\ndata:forged", Data: []byte("x"), ID: "1\nretry:1"}
+
+	t.Run("sanitizes events by default", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			stream.Go(func(ctx context.Context) error {
+				if err := stream.Send(forged); err != nil {
+					return err
+				}
+				return stream.Send(Event{Comment: "note\rdata:forged"})
+			})
+			return nil
+		})
+
+		expected := "event:messagedata:forged\ndata:x\nid:1retry:1\n\n: note\n: data:forged\n\n"
+		if body := serve(t, h); body != expected {
+			t.Errorf("expected %q, but got %q", expected, body)
+		}
+	})
+
+	t.Run("returns errors for invalid events", func(t *testing.T) {
+		t.Parallel()
+
+		letters := make(chan DeadLetter, 2)
+		errs := make(chan error, 1)
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			stream.Go(func(ctx context.Context) error {
+				errs <- stream.Send(forged)
+				if stream.TrySend(forged) {
+					t.Error("expected TrySend not to queue an invalid event")
+				}
+				return stream.Send(Event{Data: []byte("valid")})
+			})
+			return nil
+		}, WithDeadLetter(func(dl DeadLetter) { letters <- dl }))
+		h.StrictEvents = true
+
+		if body, expected := serve(t, h), "data:valid\n\n"; body != expected {
+			t.Errorf("expected %q, but got %q", expected, body)
+		}
+		if err := <-errs; !errors.Is(err, ErrInvalidEvent) {
+			t.Errorf("expected ErrInvalidEvent, but got %v", err)
+		}
+		if stats := h.Stats(); stats.DeadLetters[DropInvalid] != 2 {
+			t.Errorf("expected 2 dead letters for %q, but got %v", DropInvalid, stats.DeadLetters)
+		}
+	})
+}
+
+func TestHandlerFieldSpace(t *testing.T) {
+	t.Parallel()
+
+	h := NewHandler(func(stream EventStream, lastEventID string) error {
+		stream.Go(func(ctx context.Context) error {
+			return stream.Send(Event{Event: "hello", Data: []byte("world"), ID: "1"})
+		})
+		return nil
+	})
+	h.FieldSpace = true
+
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if expected := "event: hello\ndata: world\nid: 1\n\n"; string(body) != expected {
+		t.Errorf("expected %q, but got %q", expected, body)
+	}
+}
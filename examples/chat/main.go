@@ -0,0 +1,117 @@
+//go:build examples
+
+// Command chat is a chat room: messages posted to /messages are sent to every client connected to /events.
+// Clients that reconnect are sent the messages they missed.
+//
+//	curl -N localhost:8080/events
+//	curl -d '{"name": "gopher", "text": "hello"}' localhost:8080/messages
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	sse "github.com/dabbertorres/go-server-sent-events"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	history := flag.Int("history", 100, "number of messages kept for clients that reconnect")
+	flag.Parse()
+
+	log.Fatal(http.ListenAndServe(*addr, newRoom(*history).routes()))
+}
+
+type message struct {
+	Name string `json:"name"`
+	Text string `json:"text"`
+}
+
+// room broadcasts messages to its members.
+type room struct {
+	history *sse.ReplayBuffer
+
+	mu      sync.Mutex
+	lastID  int
+	members map[chan sse.Event]struct{}
+}
+
+func newRoom(historySize int) *room {
+	return &room{
+		history: sse.NewReplayBuffer(historySize),
+		members: make(map[chan sse.Event]struct{}),
+	}
+}
+
+func (rm *room) routes() http.Handler {
+	events := sse.NewHandler(rm.join)
+	events.KeepAlive = 15 * time.Second
+	events.Replay = rm.history
+
+	mux := http.NewServeMux()
+	mux.Handle("/events", events)
+	mux.HandleFunc("/messages", rm.post)
+	return mux
+}
+
+// join adds a client to the room until it disconnects, greeting it with the number of members.
+func (rm *room) join(stream sse.EventStream, lastEventID string) error {
+	events := make(chan sse.Event, 16)
+
+	rm.mu.Lock()
+	rm.members[events] = struct{}{}
+	events <- sse.Event{Event: "welcome", Data: []byte(strconv.Itoa(len(rm.members)))}
+	rm.mu.Unlock()
+
+	go func() {
+		<-stream.Context().Done()
+
+		rm.mu.Lock()
+		delete(rm.members, events)
+		rm.mu.Unlock()
+	}()
+
+	return sse.FromSource(sse.ChanSource(events))(stream, lastEventID)
+}
+
+func (rm *room) post(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method must be POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var msg message
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil || msg.Text == "" {
+		http.Error(w, "Body must be a message", http.StatusBadRequest)
+		return
+	}
+
+	data, _ := json.Marshal(msg)
+	rm.broadcast(sse.Event{Event: "message", Data: data})
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (rm *room) broadcast(evt sse.Event) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	rm.lastID++
+	evt.ID = strconv.Itoa(rm.lastID)
+	rm.history.Add(evt)
+
+	for member := range rm.members {
+		select {
+		case member <- evt:
+		default:
+			// The member is not keeping up, so disconnect it: it reconnects with the ID of the
+			// last message it received, and is sent what it missed from the history.
+			close(member)
+			delete(rm.members, member)
+		}
+	}
+}
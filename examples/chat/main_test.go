@@ -0,0 +1,114 @@
+//go:build examples
+
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sse "github.com/dabbertorres/go-server-sent-events"
+)
+
+func TestRoom(t *testing.T) {
+	srv := httptest.NewServer(newRoom(10).routes())
+	// registered first, so that it runs after the streams opened by connect are closed
+	t.Cleanup(srv.Close)
+
+	alice := connect(t, srv, "")
+	if evt := readEvent(t, alice); evt["event"] != "welcome" || evt["data"] != "1" {
+		t.Fatalf("expected welcome with 1 member, but got %v", evt)
+	}
+
+	post(t, srv, `{"name": "alice", "text": "first"}`)
+	post(t, srv, `{"name": "alice", "text": "second"}`)
+
+	for _, expected := range []string{"first", "second"} {
+		if evt := readEvent(t, alice); !strings.Contains(evt["data"], expected) {
+			t.Errorf("expected message %q, but got %v", expected, evt)
+		}
+	}
+
+	bob := connect(t, srv, "1")
+	if evt := readEvent(t, bob); evt["id"] != "2" || !strings.Contains(evt["data"], "second") {
+		t.Errorf("expected missed message to be replayed, but got %v", evt)
+	}
+	if evt := readEvent(t, bob); evt["event"] != "welcome" || evt["data"] != "2" {
+		t.Errorf("expected welcome with 2 members, but got %v", evt)
+	}
+}
+
+func TestRoomDisconnectsSlowMembers(t *testing.T) {
+	rm := newRoom(10)
+	member := make(chan sse.Event, 1)
+	rm.members[member] = struct{}{}
+
+	rm.broadcast(sse.Event{Data: []byte("first")})
+	rm.broadcast(sse.Event{Data: []byte("second")})
+
+	if evt := <-member; string(evt.Data) != "first" {
+		t.Errorf("expected first message, but got %q", evt.Data)
+	}
+	if _, ok := <-member; ok {
+		t.Error("expected slow member to be disconnected")
+	}
+	if len(rm.members) != 0 {
+		t.Errorf("expected slow member to be removed, but %d members remain", len(rm.members))
+	}
+}
+
+func connect(t *testing.T, srv *httptest.Server, lastEventID string) *bufio.Reader {
+	t.Helper()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events", nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return bufio.NewReader(resp.Body)
+}
+
+func post(t *testing.T, srv *httptest.Server, body string) {
+	t.Helper()
+
+	resp, err := srv.Client().Post(srv.URL+"/messages", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected status %d, but got %d", http.StatusAccepted, resp.StatusCode)
+	}
+}
+
+// readEvent reads the fields of the next event, skipping comments.
+func readEvent(t *testing.T, r *bufio.Reader) map[string]string {
+	t.Helper()
+
+	evt := make(map[string]string)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal("failed to read event:", err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && len(evt) > 0:
+			return evt
+		case line == "", strings.HasPrefix(line, ":"):
+			continue
+		}
+
+		name, value, _ := strings.Cut(line, ":")
+		evt[name] = value
+	}
+}
@@ -0,0 +1,122 @@
+//go:build examples
+
+// Command dashboard streams live metrics to clients connected to /metrics.
+// Each client is sent the latest sample whenever one is taken, skipping any it was too slow to
+// receive, while clients that reconnect are sent every sample they missed.
+//
+//	curl -N localhost:8080/metrics
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	sse "github.com/dabbertorres/go-server-sent-events"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	interval := flag.Duration("interval", time.Second, "how often metrics are sampled")
+	flag.Parse()
+
+	d := newDashboard(300)
+	go func() {
+		for now := range time.Tick(*interval) {
+			d.record(sampleRuntime(now))
+		}
+	}()
+
+	log.Fatal(http.ListenAndServe(*addr, d.routes()))
+}
+
+type sample struct {
+	Time       time.Time `json:"time"`
+	Goroutines int       `json:"goroutines"`
+	HeapBytes  uint64    `json:"heap_bytes"`
+}
+
+func sampleRuntime(now time.Time) sample {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	return sample{
+		Time:       now,
+		Goroutines: runtime.NumGoroutine(),
+		HeapBytes:  mem.HeapAlloc,
+	}
+}
+
+// dashboard holds the latest sample, and the history used to replay missed samples.
+type dashboard struct {
+	history *sse.ReplayBuffer
+
+	mu      sync.Mutex
+	lastID  int
+	latest  sse.Event
+	updated chan struct{}
+}
+
+func newDashboard(historySize int) *dashboard {
+	return &dashboard{
+		history: sse.NewReplayBuffer(historySize),
+		updated: make(chan struct{}),
+	}
+}
+
+func (d *dashboard) routes() http.Handler {
+	metrics := sse.NewHandler(sse.FromSource(sse.EventSourceFunc(d.stream)))
+	metrics.KeepAlive = 15 * time.Second
+	metrics.Replay = d.history
+	metrics.DeltaEncoding = true
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	return mux
+}
+
+// record makes s the latest sample, and wakes every client waiting for it.
+func (d *dashboard) record(s sample) {
+	data, _ := json.Marshal(s)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.lastID++
+	d.latest = sse.Event{Event: "metrics", ID: strconv.Itoa(d.lastID), Data: data}
+	d.history.Add(d.latest)
+
+	close(d.updated)
+	d.updated = make(chan struct{})
+}
+
+func (d *dashboard) current() (sse.Event, <-chan struct{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.latest, d.updated
+}
+
+// stream sends the latest sample to a client each time one is recorded.
+func (d *dashboard) stream(ctx context.Context, send func(sse.Event) error) error {
+	for {
+		evt, updated := d.current()
+		if evt.ID != "" {
+			if err := send(evt); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-updated:
+		}
+	}
+}
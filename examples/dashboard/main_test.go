@@ -0,0 +1,81 @@
+//go:build examples
+
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDashboard(t *testing.T) {
+	d := newDashboard(10)
+	start := time.Now()
+	for i := 1; i <= 3; i++ {
+		d.record(sample{Time: start, Goroutines: i})
+	}
+
+	srv := httptest.NewServer(d.routes())
+	// registered first, so that it runs after the streams opened by connect are closed
+	t.Cleanup(srv.Close)
+
+	live := connect(t, srv, "")
+	if evt := readEvent(t, live); evt["id"] != "3" {
+		t.Errorf("expected latest sample, but got %v", evt)
+	}
+
+	resumed := connect(t, srv, "1")
+	for _, expected := range []string{"2", "3"} {
+		if evt := readEvent(t, resumed); evt["id"] != expected {
+			t.Errorf("expected missed sample %s to be replayed, but got %v", expected, evt)
+		}
+	}
+
+	d.record(sample{Time: start, Goroutines: 4})
+	if evt := readEvent(t, live); evt["id"] != "4" || !strings.Contains(evt["data"], `"goroutines":4`) {
+		t.Errorf("expected new sample, but got %v", evt)
+	}
+}
+
+func connect(t *testing.T, srv *httptest.Server, lastEventID string) *bufio.Reader {
+	t.Helper()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/metrics", nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return bufio.NewReader(resp.Body)
+}
+
+// readEvent reads the fields of the next event, skipping comments.
+func readEvent(t *testing.T, r *bufio.Reader) map[string]string {
+	t.Helper()
+
+	evt := make(map[string]string)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal("failed to read event:", err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && len(evt) > 0:
+			return evt
+		case line == "", strings.HasPrefix(line, ":"):
+			continue
+		}
+
+		name, value, _ := strings.Cut(line, ":")
+		evt[name] = value
+	}
+}
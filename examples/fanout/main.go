@@ -0,0 +1,155 @@
+//go:build examples
+
+// Command fanout consumes messages from a Kafka topic once, and streams them to every client connected
+// to /events, using each message's offset as its event ID, so clients that reconnect are sent the
+// messages they missed. Clients that cannot keep up are disconnected, and catch up when they reconnect.
+//
+// To keep this module free of dependencies, the messages come from any Consumer; the one used by main
+// generates messages. A Kafka client can be adapted to it, e.g. a *kafka.Reader from
+// github.com/segmentio/kafka-go:
+//
+//	func (c kafkaConsumer) Fetch(ctx context.Context) (Message, error) {
+//	    m, err := c.reader.ReadMessage(ctx)
+//	    return Message{Topic: m.Topic, Offset: m.Offset, Value: m.Value}, err
+//	}
+//
+//	curl -N localhost:8080/events
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	sse "github.com/dabbertorres/go-server-sent-events"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	flag.Parse()
+
+	f := newFanout(1000)
+	go func() {
+		if err := f.consume(context.Background(), &generator{topic: "orders", interval: time.Second}); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	log.Fatal(http.ListenAndServe(*addr, f.routes()))
+}
+
+// Message is a message consumed from a single partition of a topic.
+type Message struct {
+	Topic  string
+	Offset int64
+	Value  []byte
+}
+
+// Consumer reads messages from a topic, in order.
+type Consumer interface {
+	Fetch(ctx context.Context) (Message, error)
+}
+
+// generator is a Consumer that generates a message every interval.
+type generator struct {
+	topic    string
+	interval time.Duration
+	offset   int64
+}
+
+func (g *generator) Fetch(ctx context.Context) (Message, error) {
+	select {
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	case <-time.After(g.interval):
+	}
+
+	g.offset++
+	return Message{
+		Topic:  g.topic,
+		Offset: g.offset,
+		Value:  []byte(fmt.Sprintf(`{"order": %d}`, g.offset)),
+	}, nil
+}
+
+// fanout sends each consumed message to all of its subscribers.
+type fanout struct {
+	history *sse.ReplayBuffer
+
+	mu          sync.Mutex
+	subscribers map[chan sse.Event]struct{}
+}
+
+func newFanout(historySize int) *fanout {
+	return &fanout{
+		history:     sse.NewReplayBuffer(historySize),
+		subscribers: make(map[chan sse.Event]struct{}),
+	}
+}
+
+func (f *fanout) routes() http.Handler {
+	events := sse.NewHandlerBuffered(f.subscribe, 64)
+	events.KeepAlive = 15 * time.Second
+	events.Replay = f.history
+
+	mux := http.NewServeMux()
+	mux.Handle("/events", events)
+	return mux
+}
+
+// consume fans out the messages read from c, until ctx is done, or c fails.
+func (f *fanout) consume(ctx context.Context, c Consumer) error {
+	for {
+		msg, err := c.Fetch(ctx)
+		if err != nil {
+			return err
+		}
+
+		f.publish(sse.Event{
+			Event: msg.Topic,
+			ID:    strconv.FormatInt(msg.Offset, 10),
+			Data:  msg.Value,
+		})
+	}
+}
+
+func (f *fanout) publish(evt sse.Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.history.Add(evt)
+
+	for sub := range f.subscribers {
+		select {
+		case sub <- evt:
+		default:
+			// The subscriber is not keeping up, so disconnect it rather than holding up the others:
+			// it reconnects with the offset of the last message it received, and is sent what it missed.
+			close(sub)
+			delete(f.subscribers, sub)
+		}
+	}
+}
+
+func (f *fanout) subscribe(stream sse.EventStream, lastEventID string) error {
+	events := make(chan sse.Event, 64)
+
+	f.mu.Lock()
+	f.subscribers[events] = struct{}{}
+	f.mu.Unlock()
+
+	go func() {
+		<-stream.Context().Done()
+
+		f.mu.Lock()
+		delete(f.subscribers, events)
+		f.mu.Unlock()
+	}()
+
+	return sse.FromSource(sse.ChanSource(events))(stream, lastEventID)
+}
@@ -0,0 +1,135 @@
+//go:build examples
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	sse "github.com/dabbertorres/go-server-sent-events"
+)
+
+// chanConsumer is a Consumer that reads messages from a channel.
+type chanConsumer chan Message
+
+func (c chanConsumer) Fetch(ctx context.Context) (Message, error) {
+	select {
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	case msg := <-c:
+		return msg, nil
+	}
+}
+
+func TestFanout(t *testing.T) {
+	f := newFanout(10)
+	srv := httptest.NewServer(f.routes())
+	// registered first, so that it runs after the streams opened by connect are closed
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	messages := make(chanConsumer)
+	go f.consume(ctx, messages)
+
+	// the response starts with the first event, so connect while it is sent
+	connected := make(chan *bufio.Reader)
+	go func() { connected <- connect(t, srv, "") }()
+	waitForSubscribers(t, f, 1)
+
+	for offset := int64(1); offset <= 3; offset++ {
+		messages <- Message{Topic: "orders", Offset: offset, Value: []byte("order")}
+	}
+
+	live := <-connected
+	for _, expected := range []string{"1", "2", "3"} {
+		if evt := readEvent(t, live); evt["id"] != expected || evt["event"] != "orders" {
+			t.Errorf("expected message %s, but got %v", expected, evt)
+		}
+	}
+
+	resumed := connect(t, srv, "1")
+	for _, expected := range []string{"2", "3"} {
+		if evt := readEvent(t, resumed); evt["id"] != expected {
+			t.Errorf("expected missed message %s to be replayed, but got %v", expected, evt)
+		}
+	}
+}
+
+func TestFanoutDisconnectsSlowSubscribers(t *testing.T) {
+	f := newFanout(10)
+	sub := make(chan sse.Event, 1)
+	f.subscribers[sub] = struct{}{}
+
+	f.publish(sse.Event{ID: "1"})
+	f.publish(sse.Event{ID: "2"})
+
+	if evt := <-sub; evt.ID != "1" {
+		t.Errorf("expected first message, but got %q", evt.ID)
+	}
+	if _, ok := <-sub; ok {
+		t.Error("expected slow subscriber to be disconnected")
+	}
+}
+
+func waitForSubscribers(t *testing.T, f *fanout, n int) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		f.mu.Lock()
+		subscribed := len(f.subscribers)
+		f.mu.Unlock()
+
+		if subscribed == n {
+			return
+		}
+	}
+	t.Fatalf("expected %d subscribers", n)
+}
+
+func connect(t *testing.T, srv *httptest.Server, lastEventID string) *bufio.Reader {
+	t.Helper()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events", nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Error(err)
+		return bufio.NewReader(strings.NewReader(""))
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return bufio.NewReader(resp.Body)
+}
+
+// readEvent reads the fields of the next event, skipping comments.
+func readEvent(t *testing.T, r *bufio.Reader) map[string]string {
+	t.Helper()
+
+	evt := make(map[string]string)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal("failed to read event:", err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && len(evt) > 0:
+			return evt
+		case line == "", strings.HasPrefix(line, ":"):
+			continue
+		}
+
+		name, value, _ := strings.Cut(line, ":")
+		evt[name] = value
+	}
+}
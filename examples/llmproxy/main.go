@@ -0,0 +1,128 @@
+//go:build examples
+
+// Command llmproxy streams the tokens generated by a language model to clients connected to /complete,
+// as "token" events followed by a "done" event.
+//
+// The upstream model server is sent the prompt as JSON, and is expected to reply with a stream of
+// data lines holding {"token": "..."} objects, ending with "data: [DONE]", as most completion APIs do.
+// The upstream request is canceled as soon as the client disconnects, so abandoned completions stop
+// consuming tokens.
+//
+//	curl -N 'localhost:8080/complete?prompt=hello'
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	sse "github.com/dabbertorres/go-server-sent-events"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	upstream := flag.String("upstream", "http://localhost:9090/v1/completions", "URL of the model server")
+	flag.Parse()
+
+	p := &proxy{upstream: *upstream, client: http.DefaultClient}
+	log.Fatal(http.ListenAndServe(*addr, p.routes()))
+}
+
+type proxy struct {
+	upstream string
+	client   *http.Client
+}
+
+type promptKey struct{}
+
+func (p *proxy) routes() http.Handler {
+	complete := sse.NewHandler(p.complete)
+	complete.KeepAlive = 15 * time.Second
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/complete", func(w http.ResponseWriter, r *http.Request) {
+		prompt := r.URL.Query().Get("prompt")
+		if prompt == "" {
+			http.Error(w, "A prompt is required", http.StatusBadRequest)
+			return
+		}
+
+		// the stream's context is the request's, so the prompt can be passed along with it
+		complete.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), promptKey{}, prompt)))
+	})
+	return mux
+}
+
+func (p *proxy) complete(stream sse.EventStream, lastEventID string) error {
+	prompt := stream.Context().Value(promptKey{}).(string)
+
+	go func() {
+		if err := p.relay(stream.Context(), prompt, stream.Send); err != nil {
+			if stream.Context().Err() != nil {
+				return
+			}
+			stream.CloseWithError(sse.NewStreamError(sse.ReasonServerError, err.Error()))
+			return
+		}
+		stream.Close()
+	}()
+	return nil
+}
+
+// relay sends each token generated for prompt by the upstream server, followed by a "done" event.
+func (p *proxy) relay(ctx context.Context, prompt string, send func(sse.Event) error) error {
+	body, _ := json.Marshal(map[string]interface{}{"prompt": prompt, "stream": true})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.upstream, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("upstream responded with %s", resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			return send(sse.Event{Event: "done"})
+		}
+
+		var chunk struct {
+			Token string `json:"token"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("invalid chunk from upstream: %w", err)
+		}
+
+		if err := send(sse.Event{Event: "token", Data: []byte(chunk.Token)}); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("upstream ended the stream early")
+}
@@ -0,0 +1,83 @@
+//go:build examples
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sse "github.com/dabbertorres/go-server-sent-events"
+)
+
+func TestProxy(t *testing.T) {
+	model := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Prompt string `json:"prompt"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Prompt == "fail" {
+			http.Error(w, "bad prompt", http.StatusBadRequest)
+			return
+		}
+
+		for _, token := range strings.Fields(req.Prompt) {
+			fmt.Fprintf(w, "data: {\"token\": %q}\n\n", token)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer model.Close()
+
+	p := &proxy{upstream: model.URL, client: model.Client()}
+	srv := httptest.NewServer(p.routes())
+	defer srv.Close()
+
+	t.Run("relays tokens", func(t *testing.T) {
+		body := get(t, srv.URL+"/complete?prompt=hello+there")
+
+		expected := "event:token\ndata:hello\n\nevent:token\ndata:there\n\nevent:done\n\n"
+		if body != expected {
+			t.Errorf("expected %q, but got %q", expected, body)
+		}
+	})
+
+	t.Run("reports upstream errors", func(t *testing.T) {
+		body := get(t, srv.URL+"/complete?prompt=fail")
+
+		if !strings.HasPrefix(body, "event:"+sse.StreamErrorEvent+"\n") || !strings.Contains(body, sse.ReasonServerError) {
+			t.Errorf("expected a stream error, but got %q", body)
+		}
+	})
+
+	t.Run("requires a prompt", func(t *testing.T) {
+		resp, err := srv.Client().Get(srv.URL + "/complete")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status %d, but got %d", http.StatusBadRequest, resp.StatusCode)
+		}
+	})
+}
+
+func get(t *testing.T, url string) string {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal("failed to read response body:", err)
+	}
+	return string(body)
+}
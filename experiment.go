@@ -0,0 +1,55 @@
+package sse
+
+import (
+	"hash/fnv"
+)
+
+// CohortFunc assigns the client identified by principal (see Handler.Principal) to a cohort of experiment,
+// e.g. "control" or "treatment", to decide which of an event's Variants it is sent, see Handler.Cohort.
+// An empty cohort sends the event's Data.
+//
+// It should assign a principal to the same cohort each time, so that a client sees the same variant
+// of an experiment across events and reconnects; see HashCohorts.
+type CohortFunc func(principal, experiment string) string
+
+// HashCohorts returns a CohortFunc assigning principals to cohorts evenly, by a hash of the experiment
+// and principal, so that each principal stays in the same cohort of an experiment, without any state,
+// but may be in different cohorts of different experiments.
+// Clients with an empty principal are assigned no cohort, so that anonymous clients are not all
+// put in the same one.
+func HashCohorts(cohorts ...string) CohortFunc {
+	return func(principal, experiment string) string {
+		if principal == "" || len(cohorts) == 0 {
+			return ""
+		}
+
+		h := fnv.New64a()
+		h.Write([]byte(experiment))
+		h.Write([]byte{0})
+		h.Write([]byte(principal))
+		return cohorts[h.Sum64()%uint64(len(cohorts))]
+	}
+}
+
+// variant returns the data of the variant of evt the client is sent, see Event.Variants.
+// The connection's cohort of each experiment is assigned once, so that it does not change during
+// the connection, even if Handler.Cohort's assignments do.
+func (c *conn) variant(evt *Event) []byte {
+	if c.h.Cohort == nil {
+		return evt.Data
+	}
+
+	cohort, ok := c.cohorts[evt.Experiment]
+	if !ok {
+		cohort = c.h.Cohort(c.principal, evt.Experiment)
+		if c.cohorts == nil {
+			c.cohorts = make(map[string]string)
+		}
+		c.cohorts[evt.Experiment] = cohort
+	}
+
+	if data, ok := evt.Variants[cohort]; ok && cohort != "" {
+		return data
+	}
+	return evt.Data
+}
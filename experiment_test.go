@@ -0,0 +1,110 @@
+package sse
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCohort(t *testing.T) {
+	t.Parallel()
+
+	events := func(stream EventStream, lastEventID string) error {
+		stream.Go(func(ctx context.Context) error {
+			for _, evt := range []Event{
+				{Data: []byte("old banner"), Experiment: "banner", Variants: map[string][]byte{"new": []byte("new banner")}},
+				{Data: []byte("everyone")},
+				{Data: []byte("old footer"), Experiment: "banner", Variants: map[string][]byte{"new": []byte("new footer")}},
+			} {
+				if err := stream.Send(evt); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		return nil
+	}
+
+	get := func(t *testing.T, srv *httptest.Server, user string) string {
+		t.Helper()
+
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		req.Header.Set("X-User", user)
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	principal := func(r *http.Request) string { return r.Header.Get("X-User") }
+
+	t.Run("sends each client its cohort's variant", func(t *testing.T) {
+		t.Parallel()
+
+		var assignments atomic.Int32
+		h := NewHandler(events, WithPrincipal(principal), WithCohort(func(principal, experiment string) string {
+			assignments.Add(1)
+			if principal == "alice" && experiment == "banner" {
+				return "new"
+			}
+			return "control"
+		}))
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+
+		if body, expected := get(t, srv, "alice"), "data:new banner\n\ndata:everyone\n\ndata:new footer\n\n"; body != expected {
+			t.Errorf("expected %q, but got %q", expected, body)
+		}
+		if body, expected := get(t, srv, "bob"), "data:old banner\n\ndata:everyone\n\ndata:old footer\n\n"; body != expected {
+			t.Errorf("expected %q, but got %q", expected, body)
+		}
+
+		if n := assignments.Load(); n != 2 {
+			t.Errorf("expected 1 assignment per connection, but got %d", n)
+		}
+	})
+
+	t.Run("sends data without a Cohort", func(t *testing.T) {
+		t.Parallel()
+
+		srv := httptest.NewServer(NewHandler(events, WithPrincipal(principal)))
+		t.Cleanup(srv.Close)
+
+		if body, expected := get(t, srv, "alice"), "data:old banner\n\ndata:everyone\n\ndata:old footer\n\n"; body != expected {
+			t.Errorf("expected %q, but got %q", expected, body)
+		}
+	})
+}
+
+func TestHashCohorts(t *testing.T) {
+	t.Parallel()
+
+	cohort := HashCohorts("control", "treatment")
+
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		principal := "user-" + string(rune('a'+i%26)) + string(rune('a'+i/26))
+		c := cohort(principal, "banner")
+		if again := cohort(principal, "banner"); again != c {
+			t.Fatalf("expected %q to stay in cohort %q, but got %q", principal, c, again)
+		}
+		counts[c]++
+	}
+
+	for _, c := range []string{"control", "treatment"} {
+		if counts[c] < 400 {
+			t.Errorf("expected about half of the principals in cohort %q, but got %d", c, counts[c])
+		}
+	}
+
+	if c := cohort("", "banner"); c != "" {
+		t.Errorf("expected no cohort for an empty principal, but got %q", c)
+	}
+}
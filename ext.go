@@ -0,0 +1,110 @@
+package sse
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Extension is a protocol extension which a Handler only uses with clients that support it.
+// Clients advertise the extensions they support with the ExtensionsParam query parameter,
+// either as a comma separated list, or by repeating the parameter.
+// The Handler enables those that it is also configured for, and lists them in the
+// ExtensionsHeader response header.
+// Plain EventSource clients advertise none, so they only ever receive standard events.
+type Extension string
+
+const (
+	// ExtGzip enables compressing large event data. See Handler.GzipThreshold.
+	ExtGzip Extension = "gzip"
+
+	// ExtChunk enables splitting large events into chunks. See Handler.ChunkSize.
+	ExtChunk Extension = "chunk"
+
+	// ExtDelta enables delta encoding of JSON event data. See Handler.DeltaEncoding.
+	ExtDelta Extension = "delta"
+
+	// ExtTags enables sending the tags of events. See Event.Tags.
+	// It is always supported, unless Handler.BrowserCompat is set.
+	ExtTags Extension = "tags"
+
+	// ExtTrace enables sending the traceparent of events. See Event.TraceParent.
+	// It is always supported, unless Handler.BrowserCompat is set.
+	ExtTrace Extension = "trace"
+)
+
+// ErrExtensionRequired is reported (wrapped) to Handler.OnWarning when an event relies on an extension
+// that the client does not support.
+var ErrExtensionRequired = errors.New("sse: extension required")
+
+// ExtensionsParam is the query parameter clients advertise the extensions they support with.
+const ExtensionsParam = "sse-ext"
+
+// ExtensionsHeader is the response header a Handler lists the extensions enabled for a connection in.
+const ExtensionsHeader = "Sse-Extensions"
+
+// AdvertiseExtensions adds exts to the ExtensionsParam query parameter of u.
+func AdvertiseExtensions(u *url.URL, exts ...Extension) {
+	names := make([]string, len(exts))
+	for i, ext := range exts {
+		names[i] = string(ext)
+	}
+
+	q := u.Query()
+	q.Add(ExtensionsParam, strings.Join(names, ","))
+	u.RawQuery = q.Encode()
+}
+
+// extensionSet is a set of extensions.
+type extensionSet map[Extension]bool
+
+// requestedExtensions returns the extensions advertised by r.
+func requestedExtensions(r *http.Request) extensionSet {
+	exts := make(extensionSet)
+	for _, value := range r.URL.Query()[ExtensionsParam] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				exts[Extension(name)] = true
+			}
+		}
+	}
+	return exts
+}
+
+// supportedExtensions returns the extensions h is configured to use.
+func (h *Handler) supportedExtensions() extensionSet {
+	exts := extensionSet{ExtTags: true, ExtTrace: true}
+	if h.GzipThreshold > 0 {
+		exts[ExtGzip] = true
+	}
+	if h.ChunkSize > 0 {
+		exts[ExtChunk] = true
+	}
+	if h.DeltaEncoding {
+		exts[ExtDelta] = true
+	}
+	return exts
+}
+
+// intersect returns the extensions in both s and other.
+func (s extensionSet) intersect(other extensionSet) extensionSet {
+	out := make(extensionSet)
+	for ext := range s {
+		if other[ext] {
+			out[ext] = true
+		}
+	}
+	return out
+}
+
+// String returns the extensions in s as a sorted, comma separated list.
+func (s extensionSet) String() string {
+	names := make([]string, 0, len(s))
+	for ext := range s {
+		names = append(names, string(ext))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,127 @@
+package sse
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestExtensions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("parses advertised extensions", func(t *testing.T) {
+		t.Parallel()
+
+		u, _ := url.Parse("http://example.com/events?topic=a")
+		AdvertiseExtensions(u, ExtGzip, ExtDelta)
+		AdvertiseExtensions(u, "unknown")
+
+		if topic := u.Query().Get("topic"); topic != "a" {
+			t.Errorf("expected existing query parameters to be kept, but got topic=%q", topic)
+		}
+
+		r, _ := http.NewRequest(http.MethodGet, u.String(), nil)
+		exts := requestedExtensions(r)
+		if expected := "delta,gzip,unknown"; exts.String() != expected {
+			t.Errorf("expected extensions %q, but got %q", expected, exts.String())
+		}
+	})
+
+	t.Run("Handler enables mutual extensions", func(t *testing.T) {
+		t.Parallel()
+
+		large := strings.Repeat("x", 100)
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			go func() {
+				stream.Send(Event{Data: []byte(large)})
+				stream.Close()
+			}()
+			return nil
+		})
+		h.ChunkSize = 32
+		h.DeltaEncoding = true
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		for _, tt := range []struct {
+			name     string
+			query    string
+			header   string
+			chunking bool
+		}{
+			{"plain client", "", "", false},
+			{"unsupported extensions", "?" + ExtensionsParam + "=gzip,unknown", "", false},
+			{"mutual extensions", "?" + ExtensionsParam + "=gzip,chunk,delta", "chunk,delta", true},
+			{"tags", "?" + ExtensionsParam + "=tags", "tags", false},
+		} {
+			resp, err := srv.Client().Get(srv.URL + tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatal("failed to read response body:", err)
+			}
+
+			if header := resp.Header.Get(ExtensionsHeader); header != tt.header {
+				t.Errorf("%s: expected %s header %q, but got %q", tt.name, ExtensionsHeader, tt.header, header)
+			}
+
+			if chunked := strings.Contains(string(body), ChunkEvent); chunked != tt.chunking {
+				t.Errorf("%s: expected chunking = %v, but got %q", tt.name, tt.chunking, body)
+			}
+		}
+	})
+
+	t.Run("BrowserCompat disables extensions", func(t *testing.T) {
+		t.Parallel()
+
+		large := strings.Repeat("x", 100)
+		warnings := make(chan error, 1)
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			go func() {
+				stream.Send(Event{Data: []byte(large)})
+				stream.Close()
+			}()
+			return nil
+		})
+		h.ChunkSize = 32
+		h.GzipThreshold = 32
+		h.BrowserCompat = true
+		h.OnWarning = func(connID string, err error) { warnings <- err }
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		resp, err := srv.Client().Get(srv.URL + "?" + ExtensionsParam + "=gzip,chunk")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal("failed to read response body:", err)
+		}
+
+		if header := resp.Header.Get(ExtensionsHeader); header != "" {
+			t.Errorf("expected no %s header, but got %q", ExtensionsHeader, header)
+		}
+
+		if expected := "data:" + large + "\n\n"; string(body) != expected {
+			t.Errorf("expected response body %q, but got %q", expected, body)
+		}
+
+		select {
+		case err := <-warnings:
+			if !errors.Is(err, ErrExtensionRequired) {
+				t.Errorf("expected warning to wrap ErrExtensionRequired, but got %v", err)
+			}
+		default:
+			t.Error("expected a warning about the unchunked event")
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package sse
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrNoReconnect, when returned (wrapped) by a NewEventStreamHandler, makes the Handler respond with
+// 204 No Content, which tells an EventSource that the stream is finished, so that it stops reconnecting.
+var ErrNoReconnect = errors.New("sse: stream finished, do not reconnect")
+
+// FinishedID is the ID of the last event sent by EventStream.CloseNoReconnect. A Handler responds to requests
+// with it as their Last-Event-ID with 204 No Content, without calling the NewEventStreamHandler.
+const FinishedID = "sse-finished"
+
+// CloseNoReconnect closes the EventStream permanently: it sends an event with the ID FinishedID, and closes the
+// stream, so that when the client reconnects, as an EventSource does once the connection ends, the Handler
+// responds with 204 No Content, which stops it reconnecting, e.g. once the job whose progress it is watching
+// has finished. The client must not be sent events with other IDs after FinishedID.
+// Errors are returned as by Send and Close.
+func (s EventStream) CloseNoReconnect() error {
+	err := s.Send(Event{ID: FinishedID})
+	if closeErr := s.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// rejectFinished responds with 204 No Content to a request resuming a stream closed by CloseNoReconnect,
+// and reports whether it did.
+func rejectFinished(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("Last-Event-ID") != FinishedID {
+		return false
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
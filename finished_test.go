@@ -0,0 +1,77 @@
+package sse
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCloseNoReconnect(t *testing.T) {
+	t.Parallel()
+
+	t.Run("answers reconnects with 204", func(t *testing.T) {
+		t.Parallel()
+
+		var calls atomic.Int32
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			calls.Add(1)
+			stream.Go(func(ctx context.Context) error {
+				stream.Send(Event{ID: "1", Data: []byte("done")})
+				return stream.CloseNoReconnect()
+			})
+			return nil
+		})
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if expected := "data:done\nid:1\n\nid:" + FinishedID + "\n\n"; string(body) != expected {
+			t.Errorf("expected %q, but got %q", expected, body)
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		req.Header.Set("Last-Event-ID", FinishedID)
+		resp, err = srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("expected status %d, but got %d", http.StatusNoContent, resp.StatusCode)
+		}
+		if n := calls.Load(); n != 1 {
+			t.Errorf("expected the NewEventStreamHandler to be called once, but got %d", n)
+		}
+	})
+
+	t.Run("answers 204 for ErrNoReconnect", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			return fmt.Errorf("subscription %s expired: %w", lastEventID, ErrNoReconnect)
+		})
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("expected status %d, but got %d", http.StatusNoContent, resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package sse
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultFlagCacheTTL is the default Handler.FlagCacheTTL.
+const DefaultFlagCacheTTL = 30 * time.Second
+
+// FlagEvaluator decides whether the feature flags events are behind are enabled for a client, see Event.Flag,
+// e.g. an adapter for a service's feature flag provider.
+type FlagEvaluator interface {
+	// Enabled reports whether flag is enabled for principal, the client identified by Handler.Principal.
+	// ctx is the stream's Context.
+	Enabled(ctx context.Context, principal, flag string) (bool, error)
+}
+
+// FlagEvaluatorFunc is a function implementing FlagEvaluator.
+type FlagEvaluatorFunc func(ctx context.Context, principal, flag string) (bool, error)
+
+// Enabled implements FlagEvaluator.
+func (f FlagEvaluatorFunc) Enabled(ctx context.Context, principal, flag string) (bool, error) {
+	return f(ctx, principal, flag)
+}
+
+// flagCache holds the results of a Handler's FlagEvaluator, shared by its connections, so that an event sent
+// to many clients of the same principal is only evaluated once per FlagCacheTTL.
+// The zero value is ready to use.
+type flagCache struct {
+	mu        sync.Mutex
+	entries   map[flagKey]flagEntry
+	lastSweep time.Time
+}
+
+type flagKey struct {
+	principal string
+	flag      string
+}
+
+type flagEntry struct {
+	enabled bool
+	expires time.Time
+}
+
+// get returns the cached result for key, if it has not expired by now.
+func (c *flagCache) get(key flagKey, now time.Time) (enabled, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || !now.Before(entry.expires) {
+		return false, false
+	}
+	return entry.enabled, true
+}
+
+// put caches the result for key for ttl, and forgets those that have expired, at most once per ttl.
+func (c *flagCache) put(key flagKey, enabled bool, now time.Time, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[flagKey]flagEntry)
+	}
+	if now.Sub(c.lastSweep) >= ttl {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastSweep = now
+	}
+	c.entries[key] = flagEntry{enabled: enabled, expires: now.Add(ttl)}
+}
+
+// flagEnabled reports whether the event behind flag may be sent to the client, see Event.Flag.
+// Events behind a flag are not sent if the Handler has no Flags, or evaluating the flag fails.
+func (c *conn) flagEnabled(flag string) bool {
+	h := c.h
+	if h.Flags == nil {
+		return false
+	}
+
+	ttl := h.FlagCacheTTL
+	if ttl == 0 {
+		ttl = DefaultFlagCacheTTL
+	}
+
+	key := flagKey{principal: c.principal, flag: flag}
+	now := time.Now()
+	if ttl > 0 && h.flagCache != nil {
+		if enabled, ok := h.flagCache.get(key, now); ok {
+			return enabled
+		}
+	}
+
+	enabled, err := h.Flags.Enabled(c.ctx, c.principal, flag)
+	if err != nil {
+		c.log(slog.LevelWarn, "feature flag evaluation failed", err)
+		return false
+	}
+
+	if ttl > 0 && h.flagCache != nil {
+		h.flagCache.put(key, enabled, now, ttl)
+	}
+	return enabled
+}
@@ -0,0 +1,132 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlags(t *testing.T) {
+	t.Parallel()
+
+	events := func(stream EventStream, lastEventID string) error {
+		stream.Go(func(ctx context.Context) error {
+			for _, evt := range []Event{
+				{Data: []byte("beta 1"), Flag: "beta"},
+				{Data: []byte("everyone")},
+				{Data: []byte("beta 2"), Flag: "beta"},
+			} {
+				if err := stream.Send(evt); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		return nil
+	}
+
+	get := func(t *testing.T, srv *httptest.Server, user string) string {
+		t.Helper()
+
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		req.Header.Set("X-User", user)
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	principal := func(r *http.Request) string { return r.Header.Get("X-User") }
+
+	t.Run("only sends events to clients the flag is enabled for", func(t *testing.T) {
+		t.Parallel()
+
+		var evaluations atomic.Int32
+		h := NewHandler(events, WithPrincipal(principal), WithFlags(FlagEvaluatorFunc(
+			func(ctx context.Context, principal, flag string) (bool, error) {
+				evaluations.Add(1)
+				return principal == "alice" && flag == "beta", nil
+			})))
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+
+		if body, expected := get(t, srv, "alice"), "data:beta 1\n\ndata:everyone\n\ndata:beta 2\n\n"; body != expected {
+			t.Errorf("expected %q, but got %q", expected, body)
+		}
+		if body, expected := get(t, srv, "bob"), "data:everyone\n\n"; body != expected {
+			t.Errorf("expected %q, but got %q", expected, body)
+		}
+		get(t, srv, "alice")
+
+		if n := evaluations.Load(); n != 2 {
+			t.Errorf("expected 1 evaluation per principal, but got %d", n)
+		}
+	})
+
+	t.Run("evaluates flags each time without caching", func(t *testing.T) {
+		t.Parallel()
+
+		var evaluations atomic.Int32
+		h := NewHandler(events, WithPrincipal(principal), WithFlags(FlagEvaluatorFunc(
+			func(ctx context.Context, principal, flag string) (bool, error) {
+				evaluations.Add(1)
+				return true, nil
+			})))
+		h.FlagCacheTTL = -1
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+
+		get(t, srv, "alice")
+		if n := evaluations.Load(); n != 2 {
+			t.Errorf("expected 2 evaluations, but got %d", n)
+		}
+	})
+
+	t.Run("does not send events behind flags that cannot be evaluated", func(t *testing.T) {
+		t.Parallel()
+
+		for _, flags := range []FlagEvaluator{
+			nil,
+			FlagEvaluatorFunc(func(ctx context.Context, principal, flag string) (bool, error) {
+				return true, errors.New("flag service unavailable")
+			}),
+		} {
+			srv := httptest.NewServer(NewHandler(events, WithFlags(flags)))
+			if body, expected := get(t, srv, "alice"), "data:everyone\n\n"; body != expected {
+				t.Errorf("expected %q, but got %q", expected, body)
+			}
+			srv.Close()
+		}
+	})
+
+	t.Run("caches results until they expire", func(t *testing.T) {
+		t.Parallel()
+
+		var cache flagCache
+		now := time.Now()
+		key := flagKey{principal: "alice", flag: "beta"}
+		cache.put(key, true, now, time.Minute)
+		cache.put(flagKey{principal: "bob", flag: "beta"}, false, now, time.Minute)
+
+		if enabled, ok := cache.get(key, now.Add(time.Second)); !ok || !enabled {
+			t.Errorf("expected a cached result, but got %v, %v", enabled, ok)
+		}
+		if _, ok := cache.get(key, now.Add(time.Minute)); ok {
+			t.Error("expected the result to expire")
+		}
+
+		cache.put(flagKey{principal: "carol", flag: "beta"}, true, now.Add(time.Minute), time.Minute)
+		if len(cache.entries) != 1 {
+			t.Errorf("expected expired results to be forgotten, but got %d", len(cache.entries))
+		}
+	})
+}
@@ -1,3 +1,3 @@
 module github.com/dabbertorres/go-server-sent-events
 
-go 1.17
+go 1.21
@@ -0,0 +1,63 @@
+package sse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"io"
+	"sync"
+)
+
+// GzipPrefix marks the data of an Event as gzip compressed, and base64 encoded.
+// See Handler.GzipThreshold.
+const GzipPrefix = "gzip+base64:"
+
+// ErrNotGzip is returned by DecodeGzip if data does not start with GzipPrefix.
+var ErrNotGzip = errors.New("sse: data is not gzip compressed")
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(nil) },
+}
+
+// compressData returns data gzip compressed and base64 encoded, prefixed by GzipPrefix.
+// If that would not be smaller than data, data is returned as is.
+func compressData(data []byte) []byte {
+	var compressed bytes.Buffer
+	compressed.WriteString(GzipPrefix)
+
+	enc := base64.NewEncoder(base64.StdEncoding, &compressed)
+	zw := gzipWriterPool.Get().(*gzip.Writer)
+	zw.Reset(enc)
+	zw.Write(data)
+	zw.Close()
+	enc.Close()
+	gzipWriterPool.Put(zw)
+
+	if compressed.Len() >= len(data) {
+		return data
+	}
+	return compressed.Bytes()
+}
+
+// IsGzip reports whether data was compressed by a Handler. See Handler.GzipThreshold.
+func IsGzip(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(GzipPrefix))
+}
+
+// DecodeGzip returns the original data of an Event compressed by a Handler.
+// If data does not start with GzipPrefix, ErrNotGzip is returned.
+func DecodeGzip(data []byte) ([]byte, error) {
+	if !IsGzip(data) {
+		return nil, ErrNotGzip
+	}
+
+	dec := base64.NewDecoder(base64.StdEncoding, bytes.NewReader(data[len(GzipPrefix):]))
+	zr, err := gzip.NewReader(dec)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	return io.ReadAll(zr)
+}
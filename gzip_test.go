@@ -0,0 +1,136 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGzip(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round trips", func(t *testing.T) {
+		t.Parallel()
+
+		data := []byte(strings.Repeat("compressible\n", 100))
+		compressed := compressData(data)
+
+		if !IsGzip(compressed) {
+			t.Fatalf("expected data to be compressed, but got %q", compressed)
+		}
+
+		decoded, err := DecodeGzip(compressed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(decoded, data) {
+			t.Errorf("expected decoded data %q, but got %q", data, decoded)
+		}
+	})
+
+	t.Run("keeps incompressible data", func(t *testing.T) {
+		t.Parallel()
+
+		data := []byte("tiny")
+		if out := compressData(data); !bytes.Equal(out, data) {
+			t.Errorf("expected data to be left as is, but got %q", out)
+		}
+	})
+
+	t.Run("rejects uncompressed data", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := DecodeGzip([]byte("plain")); !errors.Is(err, ErrNotGzip) {
+			t.Errorf("expected ErrNotGzip, but got %v", err)
+		}
+	})
+
+	t.Run("Handler compresses when requested", func(t *testing.T) {
+		t.Parallel()
+
+		data := strings.Repeat("compressible ", 100)
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			go func() {
+				stream.Send(Event{Data: []byte("short")})
+				stream.Send(Event{Data: []byte(data)})
+				stream.Close()
+			}()
+			return nil
+		})
+		h.GzipThreshold = 64
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		for _, tc := range []struct {
+			name     string
+			query    string
+			expected bool
+		}{
+			{"without param", "", false},
+			{"with param", "?" + ExtensionsParam + "=" + string(ExtGzip), true},
+		} {
+			resp, err := srv.Client().Get(srv.URL + tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var lines []string
+			scanner := bufio.NewScanner(resp.Body)
+			scanner.Buffer(nil, 1<<20)
+			for scanner.Scan() {
+				if line := scanner.Text(); line != "" {
+					lines = append(lines, strings.TrimPrefix(line, "data:"))
+				}
+			}
+			resp.Body.Close()
+
+			if len(lines) != 2 {
+				t.Fatalf("%s: expected 2 data lines, but got %q", tc.name, lines)
+			}
+
+			if lines[0] != "short" {
+				t.Errorf("%s: expected small event to be sent as is, but got %q", tc.name, lines[0])
+			}
+
+			if compressed := IsGzip([]byte(lines[1])); compressed != tc.expected {
+				t.Errorf("%s: expected compressed = %v, but got %q", tc.name, tc.expected, lines[1])
+			}
+		}
+	})
+
+	t.Run("Handler does not compress events with a DataReader", func(t *testing.T) {
+		t.Parallel()
+
+		data := strings.Repeat("compressible ", 100)
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			go func() {
+				stream.Send(Event{Data: []byte(data), DataReader: strings.NewReader("READER")})
+				stream.Close()
+			}()
+			return nil
+		})
+		h.GzipThreshold = 64
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		resp, err := srv.Client().Get(srv.URL + "?" + ExtensionsParam + "=" + string(ExtGzip))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal("failed to read response body:", err)
+		}
+
+		if expected := "data:" + data + "\ndata:READER\n\n"; string(body) != expected {
+			t.Errorf("expected event to be sent as is %q, but got %q", expected, body)
+		}
+	})
+}
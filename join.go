@@ -0,0 +1,297 @@
+package sse
+
+import (
+	"container/heap"
+	"context"
+	"time"
+)
+
+// JoinOrder defines the order in which a Join delivers the events of its sources.
+// Each source's events are always delivered in the order it produces them, except with OrderTimestamp.
+type JoinOrder int
+
+const (
+	// OrderFair delivers the events of each source in order, with sources taking turns, as with Combine,
+	// so that a busy source cannot starve the rest.
+	OrderFair JoinOrder = iota
+
+	// OrderArrival delivers events in the order they are produced, regardless of their source,
+	// so a busy source may delay the others.
+	OrderArrival
+
+	// OrderTimestamp delivers events in the order of their Join.Timestamp, holding each back for
+	// up to Join.ReorderWindow, so that events from other sources with earlier timestamps that
+	// arrive in the meantime can be delivered first.
+	OrderTimestamp
+)
+
+// DefaultReorderWindow is the default Join.ReorderWindow.
+const DefaultReorderWindow = 100 * time.Millisecond
+
+// DefaultReorderLimit is the default Join.ReorderLimit.
+const DefaultReorderLimit = 1024
+
+// Join merges the events of several sources, e.g. the topics of a subscription, into one stream,
+// with defined ordering. The zero value delivers events as Combine does.
+type Join struct {
+	Order JoinOrder
+
+	// Timestamp returns the time evt happened at, for OrderTimestamp.
+	// If nil, events are ordered by when they arrive, as with OrderArrival.
+	Timestamp func(evt Event) time.Time
+
+	// ReorderWindow is how long OrderTimestamp holds each event back for.
+	// The default is DefaultReorderWindow.
+	ReorderWindow time.Duration
+
+	// ReorderLimit is the maximum number of events OrderTimestamp holds back; once it is reached,
+	// the earliest is delivered without waiting. The default is DefaultReorderLimit.
+	ReorderLimit int
+}
+
+// Sources returns an EventSource that runs each of sources concurrently, and produces their events
+// in the order defined by j.Order.
+// The joined source finishes once all sources have finished, after delivering any events held back.
+// If any source returns an error, or send fails, the others are canceled, and the first error is returned.
+func (j Join) Sources(sources ...EventSource) EventSource {
+	switch j.Order {
+	case OrderArrival:
+		return EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+			return runJoin(ctx, sources, len(sources), func(evt Event) error { return send(evt) }, nil)
+		})
+
+	case OrderTimestamp:
+		return EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+			r := newReorderBuffer(j, send)
+			return runJoin(ctx, sources, 0, func(evt Event) error {
+				now := time.Now()
+				r.add(evt, now)
+				return r.deliver(now)
+			}, r)
+		})
+
+	default:
+		return Combine(sources...)
+	}
+}
+
+// runJoin runs sources concurrently, passing their events to add, until all have finished, or there is an error.
+// The events are passed through a channel with capacity buffered.
+// If r is not nil, its events are delivered when due, and once all sources have finished.
+func runJoin(ctx context.Context, sources []EventSource, buffered int, add func(Event) error, r *reorderBuffer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events := make(chan Event, buffered)
+	errs := make(chan error, len(sources))
+
+	for _, src := range sources {
+		go func(src EventSource) {
+			errs <- src.Stream(ctx, func(evt Event) error {
+				select {
+				case events <- evt:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+		}(src)
+	}
+
+	var timer *time.Timer
+	var due <-chan time.Time
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	var firstErr error
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	for remaining := len(sources); remaining > 0; {
+		if r != nil && firstErr == nil {
+			if d, ok := r.nextDue(time.Now()); ok {
+				if timer == nil {
+					timer = time.NewTimer(d)
+				} else {
+					timer.Reset(d)
+				}
+				due = timer.C
+			} else {
+				due = nil
+			}
+		}
+
+		select {
+		case evt := <-events:
+			if firstErr == nil {
+				if err := add(evt); err != nil {
+					fail(err)
+				}
+			}
+
+		case now := <-due:
+			if err := r.deliver(now); err != nil {
+				fail(err)
+			}
+
+		case err := <-errs:
+			remaining--
+			if err != nil {
+				fail(err)
+			}
+		}
+	}
+
+	// the sources have finished, but their last events may still be buffered
+	for n := len(events); n > 0 && firstErr == nil; n-- {
+		if err := add(<-events); err != nil {
+			fail(err)
+		}
+	}
+
+	if r != nil && firstErr == nil {
+		return r.flush()
+	}
+	return firstErr
+}
+
+// reorderBuffer holds events back for OrderTimestamp.
+type reorderBuffer struct {
+	window time.Duration
+	limit  int
+	stamp  func(Event) time.Time
+	send   func(Event) error
+
+	byTime    reorderHeap
+	byArrival []*reorderItem
+	added     uint64
+}
+
+type reorderItem struct {
+	evt       Event
+	at        time.Time
+	arrived   time.Time
+	seq       uint64 // orders events that arrived at the same time
+	delivered bool
+	index     int
+}
+
+func newReorderBuffer(j Join, send func(Event) error) *reorderBuffer {
+	r := &reorderBuffer{
+		window: j.ReorderWindow,
+		limit:  j.ReorderLimit,
+		stamp:  j.Timestamp,
+		send:   send,
+	}
+	if r.window <= 0 {
+		r.window = DefaultReorderWindow
+	}
+	if r.limit <= 0 {
+		r.limit = DefaultReorderLimit
+	}
+	return r
+}
+
+func (r *reorderBuffer) add(evt Event, now time.Time) {
+	at := now
+	if r.stamp != nil {
+		at = r.stamp(evt)
+	}
+	r.added++
+	item := &reorderItem{evt: evt, at: at, arrived: now, seq: r.added}
+	heap.Push(&r.byTime, item)
+	r.byArrival = append(r.byArrival, item)
+}
+
+// nextDue returns how long until the event that arrived first has been held back for the window.
+func (r *reorderBuffer) nextDue(now time.Time) (time.Duration, bool) {
+	r.skipDelivered()
+	if len(r.byArrival) == 0 {
+		return 0, false
+	}
+	return r.byArrival[0].arrived.Add(r.window).Sub(now), true
+}
+
+// deliver sends the events that have been held back for the window, along with those with earlier
+// timestamps, and the earliest events beyond the limit, in order.
+func (r *reorderBuffer) deliver(now time.Time) error {
+	for {
+		r.skipDelivered()
+		if len(r.byArrival) == 0 {
+			return nil
+		}
+
+		oldest := r.byArrival[0]
+		if len(r.byTime) <= r.limit && now.Sub(oldest.arrived) < r.window {
+			return nil
+		}
+
+		// deliver everything up to the oldest event, or just the earliest if over the limit
+		for len(r.byTime) > 0 {
+			item := heap.Pop(&r.byTime).(*reorderItem)
+			item.delivered = true
+			if err := r.send(item.evt); err != nil {
+				return err
+			}
+			if item == oldest || len(r.byTime) == r.limit {
+				break
+			}
+		}
+	}
+}
+
+// flush sends all of the events held back, in order.
+func (r *reorderBuffer) flush() error {
+	for len(r.byTime) > 0 {
+		if err := r.send(heap.Pop(&r.byTime).(*reorderItem).evt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *reorderBuffer) skipDelivered() {
+	for len(r.byArrival) > 0 && r.byArrival[0].delivered {
+		r.byArrival[0] = nil
+		r.byArrival = r.byArrival[1:]
+	}
+}
+
+// reorderHeap implements heap.Interface, ordering events by their timestamp, then by the order they arrived in.
+type reorderHeap []*reorderItem
+
+func (h reorderHeap) Len() int { return len(h) }
+
+func (h reorderHeap) Less(i, j int) bool {
+	if h[i].at.Equal(h[j].at) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].at.Before(h[j].at)
+}
+
+func (h reorderHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *reorderHeap) Push(x interface{}) {
+	item := x.(*reorderItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *reorderHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
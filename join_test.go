@@ -0,0 +1,204 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// idTimestamp uses each event's ID as its timestamp, in seconds.
+func idTimestamp(evt Event) time.Time {
+	n, _ := strconv.Atoi(evt.ID)
+	return time.Unix(int64(n), 0)
+}
+
+func eventIDs(events []Event) string {
+	ids := make([]string, len(events))
+	for i, evt := range events {
+		ids[i] = evt.ID
+	}
+	return strings.Join(ids, " ")
+}
+
+func TestJoin(t *testing.T) {
+	t.Parallel()
+
+	t.Run("arrival order keeps each source's order", func(t *testing.T) {
+		t.Parallel()
+
+		a := make(chan Event, 3)
+		a <- Event{Event: "a", ID: "1"}
+		a <- Event{Event: "a", ID: "2"}
+		a <- Event{Event: "a", ID: "3"}
+		close(a)
+		b := make(chan Event, 2)
+		b <- Event{Event: "b", ID: "1"}
+		b <- Event{Event: "b", ID: "2"}
+		close(b)
+
+		bySource := map[string][]Event{}
+		join := Join{Order: OrderArrival}
+		err := join.Sources(ChanSource(a), ChanSource(b)).Stream(context.Background(), func(evt Event) error {
+			bySource[evt.Event] = append(bySource[evt.Event], evt)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ids := eventIDs(bySource["a"]); ids != "1 2 3" {
+			t.Errorf("expected a's events %q, but got %q", "1 2 3", ids)
+		}
+		if ids := eventIDs(bySource["b"]); ids != "1 2" {
+			t.Errorf("expected b's events %q, but got %q", "1 2", ids)
+		}
+	})
+
+	t.Run("timestamp order reorders events within the window", func(t *testing.T) {
+		t.Parallel()
+
+		sent := make(chan struct{})
+		late := EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+			if err := send(Event{ID: "2"}); err != nil {
+				return err
+			}
+			close(sent)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		early := EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+			<-sent
+			if err := send(Event{ID: "1"}); err != nil {
+				return err
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var received []Event
+		start := time.Now()
+		join := Join{Order: OrderTimestamp, Timestamp: idTimestamp, ReorderWindow: 50 * time.Millisecond}
+		err := join.Sources(late, early).Stream(ctx, func(evt Event) error {
+			received = append(received, evt)
+			if len(received) == 2 {
+				cancel()
+			}
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, but got %v", err)
+		}
+
+		if ids := eventIDs(received); ids != "1 2" {
+			t.Errorf("expected events %q, but got %q", "1 2", ids)
+		}
+		if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+			t.Errorf("expected events to be held back for the window, but they were delivered after %s", elapsed)
+		}
+	})
+
+	t.Run("timestamp order delivers the earliest over the limit", func(t *testing.T) {
+		t.Parallel()
+
+		src := EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+			for _, id := range []string{"3", "1"} {
+				if err := send(Event{ID: id}); err != nil {
+					return err
+				}
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var received []Event
+		join := Join{Order: OrderTimestamp, Timestamp: idTimestamp, ReorderWindow: time.Hour, ReorderLimit: 1}
+		join.Sources(src).Stream(ctx, func(evt Event) error {
+			received = append(received, evt)
+			cancel()
+			return nil
+		})
+
+		if ids := eventIDs(received); ids != "1" {
+			t.Errorf("expected events %q, but got %q", "1", ids)
+		}
+	})
+
+	t.Run("timestamp order flushes once sources finish", func(t *testing.T) {
+		t.Parallel()
+
+		a := make(chan Event, 2)
+		a <- Event{ID: "4"}
+		a <- Event{ID: "2"}
+		close(a)
+		b := make(chan Event, 2)
+		b <- Event{ID: "3"}
+		b <- Event{ID: "1"}
+		close(b)
+
+		var received []Event
+		join := Join{Order: OrderTimestamp, Timestamp: idTimestamp, ReorderWindow: time.Hour}
+		err := join.Sources(ChanSource(a), ChanSource(b)).Stream(context.Background(), func(evt Event) error {
+			received = append(received, evt)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ids := eventIDs(received); ids != "1 2 3 4" {
+			t.Errorf("expected events %q, but got %q", "1 2 3 4", ids)
+		}
+	})
+
+	t.Run("timestamp order without a Timestamp orders by arrival", func(t *testing.T) {
+		t.Parallel()
+
+		a := make(chan Event, 3)
+		a <- Event{ID: "4"}
+		a <- Event{ID: "2"}
+		a <- Event{ID: "3"}
+		close(a)
+
+		var received []Event
+		join := Join{Order: OrderTimestamp}
+		err := join.Sources(ChanSource(a)).Stream(context.Background(), func(evt Event) error {
+			received = append(received, evt)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ids := eventIDs(received); ids != "4 2 3" {
+			t.Errorf("expected events %q, but got %q", "4 2 3", ids)
+		}
+	})
+
+	t.Run("cancels the other sources on error", func(t *testing.T) {
+		t.Parallel()
+
+		fail := errors.New("failed")
+		for _, order := range []JoinOrder{OrderFair, OrderArrival, OrderTimestamp} {
+			failing := EventSourceFunc(func(context.Context, func(Event) error) error { return fail })
+			blocking := EventSourceFunc(func(ctx context.Context, _ func(Event) error) error {
+				<-ctx.Done()
+				return ctx.Err()
+			})
+
+			join := Join{Order: order, Timestamp: idTimestamp}
+			err := join.Sources(blocking, failing).Stream(context.Background(), func(Event) error { return nil })
+			if !errors.Is(err, fail) {
+				t.Errorf("expected order %d to return the source's error, but got %v", order, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// EventDecoder reads events from a stream, see Decoder and JSONLinesDecoder.
+type EventDecoder interface {
+	// Decode returns the next event, or io.EOF once the stream ends.
+	Decode() (Event, error)
+
+	// LastEventID returns the ID to resume the stream after, as the Last-Event-ID header.
+	LastEventID() string
+}
+
+// JSONLinesContentType is the content type of streams served as JSON lines, see JSONLinesDecoder.
+const JSONLinesContentType = "application/x-ndjson"
+
+// JSONLinesDecoder reads events from an io.Reader of JSON lines (newline-delimited JSON), as served in place
+// of an event stream by servers falling back to it for clients that cannot consume one, one event per line:
+//
+//	{"event":"greeting","data":"hello","id":"1","retry":3000}
+//	{"data":{"total":42}}
+//
+// Each field is optional, as in an event stream. A data field that is a JSON string is the event's data;
+// any other JSON value is, as it is encoded, so that JSON payloads decode to the same data as those of an
+// event stream. Empty lines are skipped.
+type JSONLinesDecoder struct {
+	r           *bufio.Reader
+	lastEventID string
+}
+
+// NewJSONLinesDecoder returns a *JSONLinesDecoder reading from r.
+func NewJSONLinesDecoder(r io.Reader) *JSONLinesDecoder {
+	return &JSONLinesDecoder{r: bufio.NewReader(r)}
+}
+
+// jsonLine is a line decoded by a JSONLinesDecoder.
+type jsonLine struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+	ID    *string         `json:"id"`
+	Retry int64           `json:"retry"`
+}
+
+// Decode returns the next event. Its ResetID is set if it has an empty id.
+// Once the input ends, Decode returns io.EOF, discarding a line that was not completed.
+// A line that is not a JSON object of an event's fields is returned as an error, after which Decode may
+// be called again for the next line.
+func (d *JSONLinesDecoder) Decode() (Event, error) {
+	for {
+		line, err := d.r.ReadBytes('\n')
+		if err != nil {
+			return Event{}, err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var l jsonLine
+		if err := json.Unmarshal(line, &l); err != nil {
+			return Event{}, fmt.Errorf("sse: malformed JSON line: %w", err)
+		}
+
+		evt := Event{Event: l.Event}
+		if len(l.Data) > 0 && string(l.Data) != "null" {
+			var s string
+			if json.Unmarshal(l.Data, &s) == nil {
+				evt.Data = []byte(s)
+			} else {
+				evt.Data = l.Data
+			}
+		}
+		if l.ID != nil {
+			evt.ID = *l.ID
+			evt.ResetID = *l.ID == ""
+			d.lastEventID = *l.ID
+		}
+		if l.Retry > 0 {
+			evt.Retry = time.Duration(l.Retry) * time.Millisecond
+		}
+		return evt, nil
+	}
+}
+
+// LastEventID returns the ID of the last event with an id, which an empty id resets.
+func (d *JSONLinesDecoder) LastEventID() string { return d.lastEventID }
+
+// NewResponseDecoder returns the EventDecoder for resp's body by its Content-Type: a *Decoder for an event
+// stream, or a *JSONLinesDecoder for JSON lines, so that a client receives the same events either way.
+// Other content types are returned as an error.
+func NewResponseDecoder(resp *http.Response) (EventDecoder, error) {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("sse: invalid content type %q: %w", resp.Header.Get("Content-Type"), err)
+	}
+
+	switch mediaType {
+	case "text/event-stream":
+		return NewDecoder(resp.Body), nil
+	case JSONLinesContentType, "application/jsonl", "application/json-lines":
+		return NewJSONLinesDecoder(resp.Body), nil
+	default:
+		return nil, fmt.Errorf("sse: unsupported content type %q", mediaType)
+	}
+}
@@ -0,0 +1,131 @@
+package sse
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONLinesDecoder(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []Event
+	}{
+		{"empty", "", nil},
+		{"data", `{"data":"hello"}` + "\n", []Event{{Data: []byte("hello")}}},
+		{
+			"all fields",
+			`{"event":"greeting","data":"a\nb","id":"1","retry":1500}` + "\n",
+			[]Event{{Event: "greeting", Data: []byte("a\nb"), ID: "1", Retry: 1500 * time.Millisecond}},
+		},
+		{"JSON data", `{"data":{"total":42}}` + "\n", []Event{{Data: []byte(`{"total":42}`)}}},
+		{"empty lines", "\n" + `{"data":"x"}` + "\r\n\n", []Event{{Data: []byte("x")}}},
+		{"reset ID", `{"data":"x","id":""}` + "\n", []Event{{Data: []byte("x"), ResetID: true}}},
+		{"incomplete line", `{"data":"a"}` + "\n" + `{"data":"b"}`, []Event{{Data: []byte("a")}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			d := NewJSONLinesDecoder(strings.NewReader(test.input))
+			var actual []Event
+			for {
+				evt, err := d.Decode()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+				actual = append(actual, evt)
+			}
+
+			if !reflect.DeepEqual(test.expected, actual) {
+				t.Errorf("expected %+v, but got %+v", test.expected, actual)
+			}
+		})
+	}
+
+	t.Run("malformed line", func(t *testing.T) {
+		t.Parallel()
+
+		d := NewJSONLinesDecoder(strings.NewReader("data:x\n" + `{"data":"y","id":"2"}` + "\n"))
+		if _, err := d.Decode(); err == nil {
+			t.Fatal("expected an error, but got none")
+		}
+
+		evt, err := d.Decode()
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if string(evt.Data) != "y" {
+			t.Errorf("expected %q, but got %q", "y", evt.Data)
+		}
+		if d.LastEventID() != "2" {
+			t.Errorf("expected last event ID %q, but got %q", "2", d.LastEventID())
+		}
+	})
+}
+
+func TestNewResponseDecoder(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/event-stream", "event:greeting\ndata:hello\nid:1\n\n"},
+		{"text/event-stream; charset=utf-8", "event:greeting\ndata:hello\nid:1\n\n"},
+		{JSONLinesContentType, `{"event":"greeting","data":"hello","id":"1"}` + "\n"},
+	}
+
+	expected := Event{Event: "greeting", Data: []byte("hello"), ID: "1"}
+	for _, test := range tests {
+		test := test
+		t.Run(test.contentType, func(t *testing.T) {
+			t.Parallel()
+
+			resp := &http.Response{
+				Header: http.Header{"Content-Type": {test.contentType}},
+				Body:   io.NopCloser(strings.NewReader(test.body)),
+			}
+			d, err := NewResponseDecoder(resp)
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			evt, err := d.Decode()
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if !reflect.DeepEqual(expected, evt) {
+				t.Errorf("expected %+v, but got %+v", expected, evt)
+			}
+			if d.LastEventID() != "1" {
+				t.Errorf("expected last event ID %q, but got %q", "1", d.LastEventID())
+			}
+			if _, err := d.Decode(); !errors.Is(err, io.EOF) {
+				t.Errorf("expected %v, but got %v", io.EOF, err)
+			}
+		})
+	}
+
+	t.Run("unsupported", func(t *testing.T) {
+		t.Parallel()
+
+		for _, contentType := range []string{"", "text/html"} {
+			resp := &http.Response{Header: http.Header{"Content-Type": {contentType}}, Body: http.NoBody}
+			if _, err := NewResponseDecoder(resp); err == nil {
+				t.Errorf("expected an error for %q, but got none", contentType)
+			}
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package sse
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// acquireConnection counts a new connection against h.MaxConnections, and reports whether it may be served;
+// if not, it has been rejected. Connections that are counted must be released by releaseConnection.
+func (h *Handler) acquireConnection(w http.ResponseWriter, r *http.Request) bool {
+	open := h.stats.open.Add(1)
+	if h.MaxConnections <= 0 || open <= int64(h.MaxConnections) {
+		return true
+	}
+	h.stats.open.Add(-1)
+
+	h.stats.rejected.Add(1)
+	if h.OnMaxConnections != nil {
+		h.OnMaxConnections(r)
+	}
+
+	err := NewHTTPError(http.StatusServiceUnavailable, "too many connections")
+	if retry := h.MaxConnectionsRetry; retry > 0 {
+		err.Header = http.Header{"Retry-After": {strconv.Itoa(int((retry + time.Second - 1) / time.Second))}}
+	}
+	err.write(w)
+	return false
+}
+
+// releaseConnection records that a connection counted by acquireConnection has ended.
+func (h *Handler) releaseConnection() {
+	h.stats.open.Add(-1)
+}
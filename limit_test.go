@@ -0,0 +1,74 @@
+package sse
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaxConnections(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	var rejections atomic.Int32
+	h := NewHandler(func(stream EventStream, lastEventID string) error {
+		stream.Go(func(ctx context.Context) error {
+			if err := stream.Send(Event{Data: []byte("connected")}); err != nil {
+				return err
+			}
+			<-release
+			return nil
+		})
+		return nil
+	}, WithMaxConnections(1), WithOnMaxConnections(func(r *http.Request) { rejections.Add(1) }))
+	h.MaxConnectionsRetry = 1500 * time.Millisecond
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	first, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Body.Close()
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, but got %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+	if retry := resp.Header.Get("Retry-After"); retry != "2" {
+		t.Errorf("expected Retry-After %q, but got %q", "2", retry)
+	}
+	if n := rejections.Load(); n != 1 {
+		t.Errorf("expected OnMaxConnections to be called once, but got %d", n)
+	}
+	if stats := h.Stats(); stats.Rejected != 1 {
+		t.Errorf("expected 1 rejection in the Handler's stats, but got %d", stats.Rejected)
+	}
+
+	close(release)
+	io.ReadAll(first.Body)
+
+	deadline := time.Now().Add(time.Second)
+	for h.stats.open.Load() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	resp, err = srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected a connection once the first one ended, but got status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,107 @@
+package sse
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// LocalizeFunc returns data, the data of an event, localized for locale, e.g. by translating the messages
+// it carries. locale is as returned by Handler.Locale, and may be empty.
+type LocalizeFunc func(locale string, data []byte) ([]byte, error)
+
+// Localizer localizes the data of the events carrying messages for people, such as notifications, for each
+// client, by the event's name, see Handler.Localizer. Events whose name has no LocalizeFunc registered are
+// sent as they are. The zero Localizer is ready to use, and it is safe for concurrent use.
+type Localizer struct {
+	mu     sync.RWMutex
+	events map[string]LocalizeFunc
+}
+
+// Register localizes the data of the events named event with localize, replacing any LocalizeFunc already
+// registered for it. Events with no name are registered as "message", the name clients see them as.
+func (l *Localizer) Register(event string, localize LocalizeFunc) {
+	if event == "" {
+		event = "message"
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.events == nil {
+		l.events = make(map[string]LocalizeFunc)
+	}
+	l.events[event] = localize
+}
+
+func (l *Localizer) lookup(event string) LocalizeFunc {
+	if event == "" {
+		event = "message"
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.events[event]
+}
+
+// LocalizeNotice returns a LocalizeFunc for NoticeEvents, translating the Message of their Notice with
+// translate, e.g.:
+//
+//	localizer.Register(sse.NoticeEvent, sse.LocalizeNotice(catalog.Translate))
+func LocalizeNotice(translate func(locale, message string) string) LocalizeFunc {
+	return func(locale string, data []byte) ([]byte, error) {
+		n, _, err := DecodeNotice(Event{Event: NoticeEvent, Data: data})
+		if err != nil {
+			return nil, err
+		}
+
+		n.Message = translate(locale, n.Message)
+		return n.Event().Data, nil
+	}
+}
+
+// PreferredLocale returns the language tag r's client prefers most, by its Accept-Language header,
+// e.g. "en-GB", or an empty string if it has none. It is the default Handler.Locale.
+func PreferredLocale(r *http.Request) string {
+	var (
+		locale  string
+		quality float64
+	)
+	for _, header := range r.Header.Values("Accept-Language") {
+		for _, element := range strings.Split(header, ",") {
+			params := strings.Split(element, ";")
+			tag := strings.TrimSpace(params[0])
+			if tag == "" || tag == "*" {
+				continue
+			}
+
+			q, ok := acceptQuality(params[1:])
+			if !ok || q <= quality {
+				continue
+			}
+			locale, quality = tag, q
+		}
+	}
+	return locale
+}
+
+// localize localizes the data of evt for the connection's locale, if its name is registered with the
+// Handler's Localizer. Events with a DataReader are sent as they are. If localizing fails, the event is
+// sent as it is, and the error is logged.
+func (c *conn) localize(evt *Event) {
+	localize := c.h.Localizer.lookup(evt.Event)
+	if localize == nil || evt.DataReader != nil {
+		return
+	}
+
+	data, err := localize(c.locale, evt.Data)
+	if err != nil {
+		err = fmt.Errorf("event %q for locale %q: %w", evt.Event, c.locale, err)
+		c.log(slog.LevelWarn, "localizing event failed", err)
+		return
+	}
+	evt.Data = data
+}
@@ -0,0 +1,148 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLocalizer(t *testing.T) {
+	t.Parallel()
+
+	events := func(stream EventStream, lastEventID string) error {
+		stream.Go(func(ctx context.Context) error {
+			for _, evt := range []Event{
+				{Event: "greeting", Data: []byte("hello")},
+				{Data: []byte("hello")},
+				Notice{Message: "maintenance"}.Event(),
+			} {
+				if err := stream.Send(evt); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		return nil
+	}
+
+	translations := map[string]string{"hello": "bonjour", "maintenance": "maintenance prévue"}
+	translate := func(locale, message string) string {
+		if locale != "fr" {
+			return message
+		}
+		return translations[message]
+	}
+
+	var localizer Localizer
+	localizer.Register("greeting", func(locale string, data []byte) ([]byte, error) {
+		return []byte(translate(locale, string(data))), nil
+	})
+	localizer.Register(NoticeEvent, LocalizeNotice(translate))
+
+	get := func(t *testing.T, srv *httptest.Server, language string) string {
+		t.Helper()
+
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		req.Header.Set("Accept-Language", language)
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	t.Run("localizes registered events by the preferred locale", func(t *testing.T) {
+		t.Parallel()
+
+		srv := httptest.NewServer(NewHandler(events, WithLocalizer(&localizer)))
+		t.Cleanup(srv.Close)
+
+		expected := "event:greeting\ndata:bonjour\n\ndata:hello\n\n" +
+			"event:" + NoticeEvent + "\ndata:{\"message\":\"maintenance prévue\"}\n\n"
+		if body := get(t, srv, "en;q=0.5, fr"); body != expected {
+			t.Errorf("expected %q, but got %q", expected, body)
+		}
+
+		expected = "event:greeting\ndata:hello\n\ndata:hello\n\n" +
+			"event:" + NoticeEvent + "\ndata:{\"message\":\"maintenance\"}\n\n"
+		if body := get(t, srv, "en"); body != expected {
+			t.Errorf("expected %q, but got %q", expected, body)
+		}
+	})
+
+	t.Run("uses Locale", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(events, WithLocalizer(&localizer), WithLocale(func(r *http.Request) string {
+			return r.URL.Query().Get("lang")
+		}))
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"?lang=fr", nil)
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if expected := "event:greeting\ndata:bonjour\n\n"; !strings.HasPrefix(string(body), expected) {
+			t.Errorf("expected %q to start with %q", body, expected)
+		}
+	})
+
+	t.Run("sends events as they are if localizing fails", func(t *testing.T) {
+		t.Parallel()
+
+		var failing Localizer
+		failing.Register("greeting", func(locale string, data []byte) ([]byte, error) {
+			return nil, errors.New("no catalog")
+		})
+		srv := httptest.NewServer(NewHandler(events, WithLocalizer(&failing)))
+		t.Cleanup(srv.Close)
+
+		if body, expected := get(t, srv, "fr"), "event:greeting\ndata:hello\n\n"; !strings.HasPrefix(body, expected) {
+			t.Errorf("expected %q to start with %q", body, expected)
+		}
+	})
+}
+
+func TestPreferredLocale(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{"", ""},
+		{"fr", "fr"},
+		{"en-GB, en;q=0.8", "en-GB"},
+		{"en;q=0.5, de;q=0.9, fr;q=0.7", "de"},
+		{"*, fr;q=0.1", "fr"},
+		{"fr;q=0, en;q=0.1", "en"},
+		{"fr;q=bad", ""},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.header, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("Accept-Language", tt.header)
+			}
+			if locale := PreferredLocale(r); locale != tt.expected {
+				t.Errorf("expected %q, but got %q", tt.expected, locale)
+			}
+		})
+	}
+}
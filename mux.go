@@ -0,0 +1,132 @@
+package sse
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Mux routes requests to event stream Handlers by their URL path, e.g. for serving one Handler per
+// kind of resource, whose path identifies the resource:
+//
+//	mux := sse.NewMux()
+//	mux.HandleTopic("/orders/{id}/events", "orders/{id}", broker)
+//
+// Patterns are paths, whose segments may be parameters, named in braces, which match any one segment.
+// The value of each parameter is available to the NewEventStreamHandler through PathParam.
+// Routes are matched in the order they are registered. Requests that match none are answered with a 404.
+type Mux struct {
+	routes []muxRoute
+}
+
+type muxRoute struct {
+	pattern []string
+	handler http.Handler
+}
+
+// NewMux returns a *Mux without any routes.
+func NewMux() *Mux {
+	return &Mux{}
+}
+
+// Handle registers h for requests whose path matches pattern.
+func (m *Mux) Handle(pattern string, h http.Handler) {
+	m.routes = append(m.routes, muxRoute{
+		pattern: splitPath(pattern),
+		handler: h,
+	})
+}
+
+// HandleFunc registers a Handler calling newEventStream for requests whose path matches pattern,
+// configured by opts, and returns it.
+func (m *Mux) HandleFunc(pattern string, newEventStream NewEventStreamHandler, opts ...Option) *Handler {
+	h := NewHandler(newEventStream, opts...)
+	m.Handle(pattern, h)
+	return h
+}
+
+// HandleTopic registers a Handler streaming the events b delivers for topic, for requests whose path
+// matches pattern, configured by opts, and returns it. The parameters of pattern are replaced in topic
+// by their values, so that each resource has its own topic.
+func (m *Mux) HandleTopic(pattern, topic string, b Broker, opts ...Option) *Handler {
+	return m.HandleFunc(pattern, func(stream EventStream, lastEventID string) error {
+		params, _ := stream.Context().Value(pathParamsKey{}).(map[string]string)
+		topic := expandTopic(topic, params)
+		stream.state.setTopic(topic)
+		src := b.Subscribe(topic, lastEventID)
+		return FromSource(src)(stream, lastEventID)
+	}, opts...)
+}
+
+// ServeHTTP serves r with the Handler of the first route matching its path.
+func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := splitPath(r.URL.Path)
+	for _, route := range m.routes {
+		if params, ok := matchPath(route.pattern, path); ok {
+			if len(params) > 0 {
+				r = r.WithContext(context.WithValue(r.Context(), pathParamsKey{}, params))
+			}
+			route.handler.ServeHTTP(w, r)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+type pathParamsKey struct{}
+
+// PathParam returns the value of the parameter called name in the pattern of the Mux route that matched
+// the request ctx belongs to, e.g. EventStream.Context, or "" if there is none.
+func PathParam(ctx context.Context, name string) string {
+	params, _ := ctx.Value(pathParamsKey{}).(map[string]string)
+	return params[name]
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// matchPath reports whether path matches pattern, and returns the values of its parameters.
+func matchPath(pattern, path []string) (map[string]string, bool) {
+	if len(pattern) != len(path) {
+		return nil, false
+	}
+
+	var params map[string]string
+	for i, segment := range pattern {
+		name, ok := paramName(segment)
+		if !ok {
+			if segment != path[i] {
+				return nil, false
+			}
+			continue
+		}
+
+		if path[i] == "" {
+			return nil, false
+		}
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params[name] = path[i]
+	}
+	return params, true
+}
+
+func paramName(segment string) (string, bool) {
+	if len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+// expandTopic replaces the parameters in topic with their values.
+func expandTopic(topic string, params map[string]string) string {
+	segments := strings.Split(topic, "/")
+	for i, segment := range segments {
+		if name, ok := paramName(segment); ok {
+			segments[i] = params[name]
+		}
+	}
+	return strings.Join(segments, "/")
+}
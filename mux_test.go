@@ -0,0 +1,68 @@
+package sse
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// topicBroker is a Broker that produces a single event naming the topic subscribed to.
+type topicBroker struct{}
+
+func (topicBroker) Publish(ctx context.Context, topic string, evt Event) error { return nil }
+
+func (topicBroker) Subscribe(topic, lastEventID string) EventSource {
+	return EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+		return send(Event{Event: topic, ID: lastEventID})
+	})
+}
+
+func TestMux(t *testing.T) {
+	t.Parallel()
+
+	mux := NewMux()
+	mux.HandleTopic("/orders/{id}/events", "orders/{id}", topicBroker{})
+	mux.HandleFunc("/users/{user}/{feed}", func(stream EventStream, lastEventID string) error {
+		stream.Go(func(ctx context.Context) error {
+			return stream.Send(Event{Data: []byte(PathParam(ctx, "user") + " " + PathParam(ctx, "feed"))})
+		})
+		return nil
+	})
+	mux.Handle("/health", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		path     string
+		status   int
+		expected string
+	}{
+		{"/orders/42/events", http.StatusOK, "event:orders/42\nid:7\n\n"},
+		{"/users/ana/activity", http.StatusOK, "data:ana activity\n\n"},
+		{"/health", http.StatusOK, "ok"},
+		{"/orders//events", http.StatusNotFound, ""},
+		{"/orders/42", http.StatusNotFound, ""},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+tc.path, nil)
+		req.Header.Set("Last-Event-ID", "7")
+
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s: expected status %d, but got %d", tc.path, tc.status, resp.StatusCode)
+		}
+		if tc.expected != "" && string(body) != tc.expected {
+			t.Errorf("%s: expected response body %q, but got %q", tc.path, tc.expected, body)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NoticeEvent is the event name of the events carrying a Notice.
+const NoticeEvent = "sse-notice"
+
+// DefaultNoticeTimeout is the default NoticeHandler.Timeout.
+const DefaultNoticeTimeout = 5 * time.Second
+
+// maxNoticeSize is the maximum size of a request to a NoticeHandler, in bytes.
+const maxNoticeSize = 64 << 10
+
+// Notice is an operational notice for the people using a service, e.g. of upcoming maintenance,
+// sent as the data of a NoticeEvent, as JSON:
+//
+//	{"message":"maintenance in 5 minutes","level":"warning"}
+//
+// Clients decode it with DecodeNotice.
+type Notice struct {
+	// Message is the notice, for people.
+	Message string `json:"message"`
+
+	// Level is how important the notice is, e.g. "info", or "warning".
+	Level string `json:"level,omitempty"`
+}
+
+// Event returns the NoticeEvent carrying n.
+func (n Notice) Event() Event {
+	data, _ := json.Marshal(n)
+	return Event{Event: NoticeEvent, Data: data}
+}
+
+// DecodeNotice returns the Notice carried by evt, and reports whether evt is a NoticeEvent.
+func DecodeNotice(evt Event) (Notice, bool, error) {
+	var n Notice
+	if evt.Event != NoticeEvent {
+		return n, false, nil
+	}
+
+	if err := json.Unmarshal(evt.Data, &n); err != nil {
+		return n, true, fmt.Errorf("sse: malformed notice: %w", err)
+	}
+	return n, true, nil
+}
+
+// Broadcast sends e to every stream h is serving, as by EventStream.SendContext, and returns the number
+// of streams it was sent to. Streams that have not queued e by the time ctx is done are skipped,
+// so that a client that is not keeping up does not hold up the others.
+func (h *Handler) Broadcast(ctx context.Context, e Event) int {
+	var (
+		sent atomic.Int64
+		wg   sync.WaitGroup
+	)
+	for _, stream := range h.Streams() {
+		stream := stream
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if stream.SendContext(ctx, e) == nil {
+				sent.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	return int(sent.Load())
+}
+
+// NoticeHandler is an admin endpoint broadcasting Notices to clients without changes to the application,
+// e.g. by an operator before maintenance. A notice is POSTed to it as JSON, with an optional topic:
+//
+//	{"message":"maintenance in 5 minutes","level":"warning","topic":"orders/123"}
+//
+// A notice without a topic is broadcast to every stream of Handlers, see Handler.Broadcast, and answered
+// with the number of streams it was sent to, as {"sent":42}. A notice with a topic is published to it with
+// Broker, and answered with 204 No Content.
+type NoticeHandler struct {
+	// Authorize authorizes each request, e.g. by checking an operator's credentials, and rejects it by returning
+	// an error: with the error's status if it is an *HTTPError, and with a 403 otherwise.
+	// If nil, every request is rejected.
+	Authorize func(r *http.Request) error
+
+	// Handlers are the Handlers whose streams notices without a topic are broadcast to.
+	Handlers []*Handler
+
+	// Broker, if not nil, is the Broker notices with a topic are published to. Otherwise, they are rejected.
+	Broker Broker
+
+	// Timeout is how long each stream is given to queue a notice, see Handler.Broadcast.
+	// The default is DefaultNoticeTimeout.
+	Timeout time.Duration
+}
+
+// noticeRequest is the body of a request to a NoticeHandler.
+type noticeRequest struct {
+	Notice
+	Topic string `json:"topic,omitempty"`
+}
+
+// ServeHTTP authorizes r, and broadcasts or publishes the Notice it POSTs.
+func (h *NoticeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.authorize(r); err != nil {
+		var httpErr *HTTPError
+		if !errors.As(err, &httpErr) {
+			httpErr = NewHTTPError(http.StatusForbidden, "")
+		}
+		httpErr.write(w)
+		return
+	}
+
+	var req noticeRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxNoticeSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "sse: malformed notice: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Message == "" {
+		http.Error(w, "sse: notice has no message", http.StatusBadRequest)
+		return
+	}
+
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = DefaultNoticeTimeout
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	evt := req.Notice.Event()
+	if req.Topic != "" {
+		if h.Broker == nil {
+			http.Error(w, "sse: notices cannot be published to topics", http.StatusBadRequest)
+			return
+		}
+		if err := h.Broker.Publish(ctx, req.Topic, evt); err != nil {
+			http.Error(w, "sse: failed to publish notice: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	sent := 0
+	for _, handler := range h.Handlers {
+		sent += handler.Broadcast(ctx, evt)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Sent int `json:"sent"`
+	}{sent})
+}
+
+func (h *NoticeHandler) authorize(r *http.Request) error {
+	if h.Authorize == nil {
+		return errors.New("sse: notices are not authorized")
+	}
+	return h.Authorize(r)
+}
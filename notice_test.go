@@ -0,0 +1,182 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// noticeBroker is a Broker recording the events published to it.
+type noticeBroker struct {
+	published chan Event
+	err       error
+}
+
+func (b noticeBroker) Publish(ctx context.Context, topic string, evt Event) error {
+	if b.err != nil {
+		return b.err
+	}
+	evt.ID = topic
+	b.published <- evt
+	return nil
+}
+
+func (noticeBroker) Subscribe(topic, lastEventID string) EventSource { return nil }
+
+func TestNotice(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round trips", func(t *testing.T) {
+		t.Parallel()
+
+		evt := Notice{Message: "maintenance in 5 minutes", Level: "warning"}.Event()
+		if expected := `{"message":"maintenance in 5 minutes","level":"warning"}`; evt.Event != NoticeEvent || string(evt.Data) != expected {
+			t.Errorf("expected %s event %s, but got %s event %s", NoticeEvent, expected, evt.Event, evt.Data)
+		}
+
+		n, ok, err := DecodeNotice(evt)
+		if !ok || err != nil || n.Message != "maintenance in 5 minutes" || n.Level != "warning" {
+			t.Errorf("expected the notice, but got %+v, %v, %v", n, ok, err)
+		}
+		if _, ok, _ := DecodeNotice(Event{Data: evt.Data}); ok {
+			t.Error("expected other events not to be notices")
+		}
+	})
+
+	t.Run("broadcasts to every stream", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			return nil
+		}, WithDefaultRetry(time.Second))
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+		t.Cleanup(func() {
+			for _, stream := range h.Streams() {
+				stream.Close()
+			}
+		})
+
+		lines := make(chan string, 8)
+		for i := 0; i < 2; i++ {
+			resp, err := srv.Client().Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			go func() {
+				defer resp.Body.Close()
+				scanner := bufio.NewScanner(resp.Body)
+				for scanner.Scan() {
+					if strings.HasPrefix(scanner.Text(), "data:") {
+						lines <- scanner.Text()
+					}
+				}
+			}()
+		}
+
+		notices := &NoticeHandler{
+			Authorize: func(r *http.Request) error {
+				if r.Header.Get("Authorization") != "Bearer operator" {
+					return NewHTTPError(http.StatusUnauthorized, "")
+				}
+				return nil
+			},
+			Handlers: []*Handler{h},
+		}
+		admin := httptest.NewServer(notices)
+		t.Cleanup(admin.Close)
+
+		post := func(auth, body string) (int, string) {
+			req, _ := http.NewRequest(http.MethodPost, admin.URL, strings.NewReader(body))
+			req.Header.Set("Authorization", auth)
+			resp, err := admin.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			var sb strings.Builder
+			bufio.NewReader(resp.Body).WriteTo(&sb)
+			return resp.StatusCode, sb.String()
+		}
+
+		if status, _ := post("Bearer intruder", `{"message":"hi"}`); status != http.StatusUnauthorized {
+			t.Errorf("expected status %d, but got %d", http.StatusUnauthorized, status)
+		}
+
+		status, body := post("Bearer operator", `{"message":"maintenance in 5 minutes"}`)
+		if status != http.StatusOK || body != "{\"sent\":2}\n" {
+			t.Errorf("expected the notice to be sent to 2 streams, but got %d: %q", status, body)
+		}
+		for i := 0; i < 2; i++ {
+			select {
+			case line := <-lines:
+				if expected := `data:{"message":"maintenance in 5 minutes"}`; line != expected {
+					t.Errorf("expected %q, but got %q", expected, line)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("expected each stream to receive the notice")
+			}
+		}
+	})
+
+	t.Run("publishes to topics", func(t *testing.T) {
+		t.Parallel()
+
+		broker := noticeBroker{published: make(chan Event, 1)}
+		notices := &NoticeHandler{
+			Authorize: func(r *http.Request) error { return nil },
+			Broker:    broker,
+		}
+
+		w := httptest.NewRecorder()
+		notices.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"message":"hi","topic":"orders/1"}`)))
+		if w.Code != http.StatusNoContent {
+			t.Errorf("expected status %d, but got %d", http.StatusNoContent, w.Code)
+		}
+
+		evt := <-broker.published
+		if evt.ID != "orders/1" || evt.Event != NoticeEvent || string(evt.Data) != `{"message":"hi"}` {
+			t.Errorf("expected the notice to be published to orders/1, but got %+v", evt)
+		}
+
+		notices.Broker = noticeBroker{err: errors.New("queue unavailable")}
+		w = httptest.NewRecorder()
+		notices.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"message":"hi","topic":"orders/1"}`)))
+		if w.Code != http.StatusBadGateway {
+			t.Errorf("expected status %d, but got %d", http.StatusBadGateway, w.Code)
+		}
+	})
+
+	t.Run("rejects invalid requests", func(t *testing.T) {
+		t.Parallel()
+
+		for _, tt := range []struct {
+			name      string
+			method    string
+			body      string
+			authorize func(r *http.Request) error
+			status    int
+		}{
+			{"without Authorize", http.MethodPost, `{"message":"hi"}`, nil, http.StatusForbidden},
+			{"not authorized", http.MethodPost, `{"message":"hi"}`, func(*http.Request) error { return errors.New("no") }, http.StatusForbidden},
+			{"GET", http.MethodGet, "", func(*http.Request) error { return nil }, http.StatusMethodNotAllowed},
+			{"malformed", http.MethodPost, `{"message":`, func(*http.Request) error { return nil }, http.StatusBadRequest},
+			{"unknown field", http.MethodPost, `{"msg":"hi"}`, func(*http.Request) error { return nil }, http.StatusBadRequest},
+			{"no message", http.MethodPost, `{"level":"info"}`, func(*http.Request) error { return nil }, http.StatusBadRequest},
+			{"topic without Broker", http.MethodPost, `{"message":"hi","topic":"a"}`, func(*http.Request) error { return nil }, http.StatusBadRequest},
+		} {
+			w := httptest.NewRecorder()
+			notices := &NoticeHandler{Authorize: tt.authorize}
+			notices.ServeHTTP(w, httptest.NewRequest(tt.method, "/", strings.NewReader(tt.body)))
+			if w.Code != tt.status {
+				t.Errorf("%s: expected status %d, but got %d", tt.name, tt.status, w.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package sse
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Option configures a Handler when it is created by NewHandler, so that it needs no further configuration
+// once it is serving connections:
+//
+//	h := sse.NewHandler(stream,
+//	    sse.WithConfig(cfg),
+//	    sse.WithReplay(history),
+//	    sse.WithLogger(logger),
+//	)
+//
+// Each Option sets the Handler field of the same name; see the Handler's fields for details.
+// Options can also be applied to a Handler that is serving connections with Handler.UpdateConfig.
+type Option func(h *Handler)
+
+// WithConfig sets the Handler's fields according to cfg, see Config.Apply.
+// Options after it override cfg's settings.
+func WithConfig(cfg Config) Option { return cfg.Apply }
+
+// WithKeepAlive sets the interval between keep-alives, see Handler.KeepAlive.
+func WithKeepAlive(interval time.Duration) Option {
+	return func(h *Handler) { h.KeepAlive = interval }
+}
+
+// WithKeepAlivePayload sets what is sent as each keep-alive, see Handler.KeepAlivePayload.
+func WithKeepAlivePayload(payload func() Event) Option {
+	return func(h *Handler) { h.KeepAlivePayload = payload }
+}
+
+// WithBufferSize sets the buffer size of each EventStream's events channel, see NewHandlerBuffered.
+func WithBufferSize(size uint) Option {
+	return func(h *Handler) { h.chanBufSize = size }
+}
+
+// WithLogger sets the Logger for the errors the Handler handles itself, see Handler.Logger.
+func WithLogger(l *slog.Logger) Option {
+	return func(h *Handler) { h.Logger = l }
+}
+
+// WithAllocator sets the Allocator for the memory used by events, see Handler.Allocator.
+func WithAllocator(a Allocator) Option {
+	return func(h *Handler) { h.Allocator = a }
+}
+
+// WithReplay sets the source of past events to replay to clients that reconnect, see Handler.Replay.
+func WithReplay(src ReplaySource) Option {
+	return func(h *Handler) { h.Replay = src }
+}
+
+// WithSnapshot sets the source of the state sent to clients when they connect, see Handler.Snapshot.
+func WithSnapshot(src SnapshotSource) Option {
+	return func(h *Handler) { h.Snapshot = src }
+}
+
+// WithBaseContext sets the context each EventStream's Context is derived from, see Handler.BaseContext.
+func WithBaseContext(base func(r *http.Request) context.Context) Option {
+	return func(h *Handler) { h.BaseContext = base }
+}
+
+// WithBeforeStream sets the hook called before a stream starts, see Handler.BeforeStream.
+func WithBeforeStream(fn func(w http.ResponseWriter, r *http.Request) error) Option {
+	return func(h *Handler) { h.BeforeStream = fn }
+}
+
+// WithOnConnect sets the hook called when a connection is accepted, see Handler.OnConnect.
+func WithOnConnect(fn func(connID string, r *http.Request)) Option {
+	return func(h *Handler) { h.OnConnect = fn }
+}
+
+// WithOnDisconnect sets the hook called when a connection ends, see Handler.OnDisconnect.
+func WithOnDisconnect(fn func(connID string)) Option {
+	return func(h *Handler) { h.OnDisconnect = fn }
+}
+
+// WithDefaultRetry sets the reconnection delay sent to each client when it connects, see Handler.DefaultRetry.
+func WithDefaultRetry(retry time.Duration) Option {
+	return func(h *Handler) { h.DefaultRetry = retry }
+}
+
+// WithPadding sets the size of the comment sent to each client when it connects, see Handler.Padding.
+func WithPadding(size int) Option {
+	return func(h *Handler) { h.Padding = size }
+}
+
+// WithAccept sets how requests are checked to accept the stream, see Handler.Accept.
+func WithAccept(accept func(r *http.Request) bool) Option {
+	return func(h *Handler) { h.Accept = accept }
+}
+
+// WithACL sets the networks requests are served from, see Handler.ACL.
+func WithACL(acl *NetworkACL) Option {
+	return func(h *Handler) { h.ACL = acl }
+}
+
+// WithScreen sets the hook that screens each request before it is served, see Handler.Screen.
+func WithScreen(fn func(fp Fingerprint) ScreenDecision) Option {
+	return func(h *Handler) { h.Screen = fn }
+}
+
+// WithFlags sets the evaluator of the feature flags events are behind, see Handler.Flags.
+func WithFlags(flags FlagEvaluator) Option {
+	return func(h *Handler) { h.Flags = flags }
+}
+
+// WithPrincipal sets the function identifying the principal of each request, see Handler.Principal.
+func WithPrincipal(principal func(r *http.Request) string) Option {
+	return func(h *Handler) { h.Principal = principal }
+}
+
+// WithCohort sets the function assigning clients to the cohorts of experiments, see Handler.Cohort.
+func WithCohort(cohort CohortFunc) Option {
+	return func(h *Handler) { h.Cohort = cohort }
+}
+
+// WithLocalizer sets the Localizer of the events sent to each client, see Handler.Localizer.
+func WithLocalizer(localizer *Localizer) Option {
+	return func(h *Handler) { h.Localizer = localizer }
+}
+
+// WithLocale sets the function returning the locale of each request, see Handler.Locale.
+func WithLocale(locale func(r *http.Request) string) Option {
+	return func(h *Handler) { h.Locale = locale }
+}
+
+// WithMaxConnectionAge sets how long connections are served for before they are ended, see Handler.MaxConnectionAge.
+func WithMaxConnectionAge(age time.Duration) Option {
+	return func(h *Handler) { h.MaxConnectionAge = age }
+}
+
+// WithMaxConnections sets the most connections served at once, see Handler.MaxConnections.
+func WithMaxConnections(max int) Option {
+	return func(h *Handler) { h.MaxConnections = max }
+}
+
+// WithOnMaxConnections sets the hook called with each request rejected by MaxConnections,
+// see Handler.OnMaxConnections.
+func WithOnMaxConnections(fn func(r *http.Request)) Option {
+	return func(h *Handler) { h.OnMaxConnections = fn }
+}
+
+// WithDeadLetter sets the hook called with each event that could not be delivered, see Handler.DeadLetter.
+func WithDeadLetter(fn func(dl DeadLetter)) Option {
+	return func(h *Handler) { h.DeadLetter = fn }
+}
+
+// WithReconnectLimiter sets the limiter for clients that reconnect excessively, see Handler.ReconnectLimiter.
+func WithReconnectLimiter(l *ReconnectLimiter) Option {
+	return func(h *Handler) { h.ReconnectLimiter = l }
+}
+
+// WithOnSlowClient sets the hook called when a write to a client times out, see Handler.OnSlowClient.
+func WithOnSlowClient(fn func(connID string)) Option {
+	return func(h *Handler) { h.OnSlowClient = fn }
+}
+
+// WithOnWarning sets the hook called for events a client may not understand, see Handler.OnWarning.
+func WithOnWarning(fn func(connID string, err error)) Option {
+	return func(h *Handler) { h.OnWarning = fn }
+}
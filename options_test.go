@@ -0,0 +1,98 @@
+package sse
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("configure Handler", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := DefaultConfig()
+		cfg.ChunkSize = 1024
+		replay := NewReplayBuffer(10)
+
+		h := NewHandler(nil,
+			WithConfig(cfg),
+			WithKeepAlive(time.Minute),
+			WithBufferSize(64),
+			WithReplay(replay),
+		)
+
+		if h.KeepAlive != time.Minute || h.ChunkSize != 1024 || h.WriteTimeout != cfg.WriteTimeout {
+			t.Errorf("expected options to be applied in order, but got %+v", h)
+		}
+		if h.chanBufSize != 64 || h.Replay != replay {
+			t.Errorf("expected buffer size and replay to be set, but got %+v", h)
+		}
+	})
+
+	t.Run("apply updates", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(nil, WithKeepAlive(time.Minute))
+		h.UpdateConfig(WithKeepAlive(time.Second))
+
+		if cfg, _ := h.load(); cfg.KeepAlive != time.Second {
+			t.Errorf("expected keep-alive to be updated, but got %v", cfg.KeepAlive)
+		}
+	})
+
+	t.Run("Handler logs errors", func(t *testing.T) {
+		t.Parallel()
+
+		var logs bytes.Buffer
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			return errors.New("database unavailable")
+		}, WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if !strings.Contains(logs.String(), "database unavailable") || !strings.Contains(logs.String(), "conn_id=") {
+			t.Errorf("expected error to be logged, but got %q", logs.String())
+		}
+	})
+
+	t.Run("Handler logs warnings without OnWarning", func(t *testing.T) {
+		t.Parallel()
+
+		var logs bytes.Buffer
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			stream.Go(func(ctx context.Context) error {
+				return stream.Send(Event{Data: bytes.Repeat([]byte("a"), 100)})
+			})
+			return nil
+		}, WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+		h.ChunkSize = 10
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if !strings.Contains(logs.String(), "level=WARN") || !strings.Contains(logs.String(), "extension required") {
+			t.Errorf("expected warning to be logged, but got %q", logs.String())
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package sse
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+)
+
+// errPanic is wrapped by the errors panics are recovered as.
+var errPanic = errors.New("sse: panic")
+
+// recovered reports a panic with value, recovered from while serving the connection, to the Handler's OnPanic hook,
+// or logs it if there is none, and returns it as an error.
+func (c *conn) recovered(value interface{}) error {
+	stack := debug.Stack()
+	if c.h.OnPanic != nil {
+		c.h.OnPanic(c.id, value, stack)
+	} else if c.h.Logger != nil {
+		c.h.Logger.LogAttrs(c.ctx, slog.LevelError, "recovered from panic",
+			slog.String("conn_id", c.id), slog.Any("panic", value), slog.String("stack", string(stack)))
+	}
+	return fmt.Errorf("%w: %v", errPanic, value)
+}
+
+// callHandler calls the Handler's NewEventStreamHandler, returning a panic in it as an error.
+func (c *conn) callHandler(stream EventStream, lastEventID string) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = c.recovered(v)
+		}
+	}()
+	return c.h.handler(stream, lastEventID)
+}
@@ -0,0 +1,121 @@
+package sse
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// panicReader panics when it is read.
+type panicReader struct{}
+
+func (panicReader) Read([]byte) (int, error) { panic("read failed") }
+
+func TestHandlerPanics(t *testing.T) {
+	t.Parallel()
+
+	serverError := "event:" + StreamErrorEvent + "\ndata:{\"code\":\"" + ReasonServerError + "\""
+
+	for _, tt := range []struct {
+		name     string
+		handler  NewEventStreamHandler
+		status   int
+		terminal bool
+	}{
+		{"in the NewEventStreamHandler", func(stream EventStream, lastEventID string) error {
+			panic("handler failed")
+		}, http.StatusInternalServerError, true},
+
+		{"in a producer", func(stream EventStream, lastEventID string) error {
+			stream.Go(func(ctx context.Context) error { panic("producer failed") })
+			return nil
+		}, http.StatusOK, true},
+
+		{"in the event loop", func(stream EventStream, lastEventID string) error {
+			stream.Go(func(ctx context.Context) error {
+				return stream.Send(Event{DataReader: panicReader{}})
+			})
+			return nil
+		}, http.StatusOK, false},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			panics := make(chan interface{}, 1)
+			disconnected := make(chan struct{})
+			h := NewHandler(tt.handler)
+			h.OnPanic = func(connID string, value interface{}, stack []byte) {
+				if connID == "" || len(stack) == 0 {
+					t.Errorf("expected connection ID and stack, but got %q and %q", connID, stack)
+				}
+				panics <- value
+			}
+			h.OnDisconnect = func(string) { close(disconnected) }
+			srv := httptest.NewServer(h)
+			defer srv.Close()
+
+			resp, err := srv.Client().Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Errorf("expected status %d, but got %d", tt.status, resp.StatusCode)
+			}
+			if terminal := strings.HasPrefix(string(body), serverError); terminal != tt.terminal {
+				t.Errorf("expected terminal event = %v, but got %q", tt.terminal, body)
+			}
+
+			select {
+			case value := <-panics:
+				if !strings.Contains(value.(string), "failed") {
+					t.Errorf("expected the panic's value, but got %v", value)
+				}
+			case <-time.After(5 * time.Second):
+				t.Error("expected OnPanic to be called")
+			}
+
+			if tt.status == http.StatusOK {
+				select {
+				case <-disconnected:
+				case <-time.After(5 * time.Second):
+					t.Error("expected OnDisconnect to be called")
+				}
+			}
+		})
+	}
+
+	t.Run("logs panics without OnPanic", func(t *testing.T) {
+		t.Parallel()
+
+		var logs bytes.Buffer
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			panic("handler failed")
+		}, WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if !strings.Contains(logs.String(), "panic=\"handler failed\"") || !strings.Contains(logs.String(), "stack=") {
+			t.Errorf("expected panic to be logged with its stack, but got %q", logs.String())
+		}
+		if strings.Contains(logs.String(), "stream handler failed") {
+			t.Errorf("expected panic to be logged once, but got %q", logs.String())
+		}
+	})
+}
@@ -0,0 +1,384 @@
+package sse
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// parkingLot schedules the keep-alives of a Handler's parked connections using a single timer shared
+// by all of them, rather than a ticker per connection. See Handler.Park.
+// The zero value is ready to use.
+type parkingLot struct {
+	mu     sync.Mutex
+	timer  *time.Timer
+	spaces parkingQueue
+}
+
+// parkingSpace is a connection registered with a parkingLot.
+// Each time a keep-alive is due, onDue is called if set; otherwise the time is sent on wake,
+// unless one is already pending.
+type parkingSpace struct {
+	interval time.Duration
+	due      time.Time
+	onDue    func()
+	wake     chan time.Time
+	index    int
+
+	// lastWrite, if not nil, is when the connection was last written to, in Unix nanoseconds;
+	// a keep-alive is only due once it has been idle for interval.
+	lastWrite *atomic.Int64
+}
+
+// park registers a connection that is due a keep-alive each time it has been idle for interval, according
+// to lastWrite if it is not nil, or every interval otherwise.
+// onDue is called without holding the parkingLot's lock, so it may park or leave; it must not block.
+func (l *parkingLot) park(interval time.Duration, lastWrite *atomic.Int64, onDue func()) *parkingSpace {
+	p := &parkingSpace{
+		interval:  interval,
+		due:       time.Now().Add(interval),
+		onDue:     onDue,
+		lastWrite: lastWrite,
+	}
+	if onDue == nil {
+		p.wake = make(chan time.Time, 1)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	heap.Push(&l.spaces, p)
+	if p.index == 0 {
+		l.schedule()
+	}
+	return p
+}
+
+// leave unregisters a connection once it has ended.
+func (l *parkingLot) leave(p *parkingSpace) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if p.index >= 0 {
+		heap.Remove(&l.spaces, p.index)
+	}
+	if len(l.spaces) == 0 && l.timer != nil {
+		l.timer.Stop()
+	}
+}
+
+// wakeDue wakes each connection whose keep-alive is due, and schedules the timer for the next one.
+// The lock is only held to find the connections that are due, and they are woken after it is released,
+// so that parking and leaving are not held up while many connections are woken.
+func (l *parkingLot) wakeDue() {
+	now := time.Now()
+
+	l.mu.Lock()
+	var due []*parkingSpace
+	for len(l.spaces) > 0 && !l.spaces[0].due.After(now) {
+		p := l.spaces[0]
+		if idle := p.idleUntil(); idle.After(now) {
+			// written to since it was scheduled
+			p.due = idle
+			heap.Fix(&l.spaces, 0)
+			continue
+		}
+		due = append(due, p)
+
+		p.due = now.Add(p.interval)
+		heap.Fix(&l.spaces, 0)
+	}
+	l.schedule()
+	l.mu.Unlock()
+
+	for _, p := range due {
+		if p.onDue != nil {
+			p.onDue()
+			continue
+		}
+
+		select {
+		case p.wake <- now:
+		default:
+		}
+	}
+}
+
+// idleUntil returns when p will have been idle for its interval, or the zero time if it does not track writes.
+func (p *parkingSpace) idleUntil() time.Time {
+	if p.lastWrite == nil {
+		return time.Time{}
+	}
+	return time.Unix(0, p.lastWrite.Load()).Add(p.interval)
+}
+
+// schedule sets the timer to fire when the next keep-alive is due.
+// l.mu must be held.
+func (l *parkingLot) schedule() {
+	if len(l.spaces) == 0 {
+		return
+	}
+
+	d := time.Until(l.spaces[0].due)
+	if l.timer == nil {
+		l.timer = time.AfterFunc(d, l.wakeDue)
+	} else {
+		l.timer.Reset(d)
+	}
+}
+
+// parkingQueue implements heap.Interface, ordering parkingSpaces by when they are next due.
+type parkingQueue []*parkingSpace
+
+func (q parkingQueue) Len() int           { return len(q) }
+func (q parkingQueue) Less(i, j int) bool { return q[i].due.Before(q[j].due) }
+
+func (q parkingQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *parkingQueue) Push(x interface{}) {
+	p := x.(*parkingSpace)
+	p.index = len(*q)
+	*q = append(*q, p)
+}
+
+func (q *parkingQueue) Pop() interface{} {
+	old := *q
+	p := old[len(old)-1]
+	old[len(old)-1] = nil
+	p.index = -1
+	*q = old[:len(old)-1]
+	return p
+}
+
+// waker wakes a parked connection when an event is queued on its EventStream, or it is closed.
+// It does nothing until the connection is parked.
+type waker struct {
+	fn atomic.Pointer[func()]
+}
+
+func (w *waker) wake() {
+	if w == nil {
+		return
+	}
+	if fn := w.fn.Load(); fn != nil {
+		(*fn)()
+	}
+}
+
+// canPark reports whether the connection for r can be parked, which requires taking it over from
+// net/http's server (see http.Hijacker), so only HTTP/1.x connections can be.
+func canPark(w http.ResponseWriter, r *http.Request) bool {
+	return r.ProtoMajor == 1 && unwrapsTo(w, func(w http.ResponseWriter) bool {
+		_, ok := w.(http.Hijacker)
+		return ok
+	})
+}
+
+// Wake states of a parkedConn.
+const (
+	parkedIdle int32 = iota
+	parkedRunning
+	parkedPending // woken again while running
+)
+
+// parkedConn is a connection that has been taken over from net/http's server, so that no goroutine is
+// needed while it is idle: one is started each time it is woken, to send what is due, and then exits.
+type parkedConn struct {
+	c       *conn
+	stream  EventStream
+	root    *Handler
+	changed <-chan struct{}
+	netConn net.Conn
+	cancel  func()
+	space   *parkingSpace
+
+	// stopWake stops waking p once its EventStream's Context is done.
+	stopWake func() bool
+
+	state        atomic.Int32
+	keepAliveDue atomic.Bool
+}
+
+// hijack takes the connection over from w, and writes the response's status line and headers.
+// The response's body is delimited by closing the connection.
+func (c *conn) hijack(w http.ResponseWriter, r *http.Request) (net.Conn, error) {
+	netConn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	header := w.Header().Clone()
+	header.Set("Connection", "close")
+
+	fmt.Fprintf(rw, "HTTP/%d.%d %03d %s\r\n", r.ProtoMajor, r.ProtoMinor, http.StatusOK, http.StatusText(http.StatusOK))
+	header.Write(rw)
+	rw.WriteString("\r\n")
+
+	c.w = rw.Writer
+	c.rc = hijackedConn{netConn, rw.Writer}
+	return netConn, nil
+}
+
+// hijackedConn flushes the writes to a connection taken over from net/http's server.
+type hijackedConn struct {
+	net.Conn
+	w *bufio.Writer
+}
+
+func (h hijackedConn) Flush() error { return h.w.Flush() }
+
+// park registers p with its Handler's parkingLot, and starts waking it when events are queued;
+// anything queued before it was parked is sent right away.
+func (p *parkedConn) park(interval time.Duration) {
+	// wakes are held off until the first run, which starts once p is registered
+	p.state.Store(parkedRunning)
+	p.space = p.c.h.parking.park(interval, &p.c.lastWrite, p.dueKeepAlive)
+
+	wake := p.wake
+	p.stream.waker.fn.Store(&wake)
+	p.stopWake = context.AfterFunc(p.c.ctx, p.wake)
+	go p.run()
+}
+
+// dueKeepAlive is called by the parkingLot when a keep-alive is due.
+func (p *parkedConn) dueKeepAlive() {
+	p.keepAliveDue.Store(true)
+	p.wake()
+}
+
+// wake starts a goroutine to run p, unless one is already running, in which case it runs p again.
+func (p *parkedConn) wake() {
+	for {
+		switch p.state.Load() {
+		case parkedIdle:
+			if p.state.CompareAndSwap(parkedIdle, parkedRunning) {
+				go p.run()
+				return
+			}
+		case parkedRunning:
+			if p.state.CompareAndSwap(parkedRunning, parkedPending) {
+				return
+			}
+		case parkedPending:
+			return
+		}
+	}
+}
+
+func (p *parkedConn) run() {
+	defer func() {
+		if v := recover(); v != nil {
+			p.c.recovered(v)
+			p.end()
+		}
+	}()
+
+	for {
+		// a wake from here on runs p again
+		p.state.Store(parkedRunning)
+		if !p.step() {
+			p.end()
+			return
+		}
+		if p.state.CompareAndSwap(parkedRunning, parkedIdle) {
+			return
+		}
+	}
+}
+
+// step sends everything that is due, without waiting for more.
+// It returns false if the connection should be closed.
+func (p *parkedConn) step() bool {
+	c, stream := p.c, &p.stream
+
+	if c.ctx.Err() != nil {
+		if c.shuttingDown() {
+			c.sendShutdown(stream)
+		} else if c.expired() {
+			c.sendExpired(stream)
+		}
+		return false
+	}
+
+	select {
+	case <-stream.queue.exceeded:
+		// deliver what was queued before the limit was reached, then tell the client why the stream ended
+		if c.sendQueued(stream) {
+			c.writeStreamError(memoryLimitError())
+		}
+		return false
+	default:
+	}
+
+	select {
+	case <-p.changed:
+		c.h, p.changed = p.root.load()
+		if !stream.settings.hasKeepAlive && c.h.KeepAlive != p.space.interval && c.h.KeepAlive > 0 {
+			c.h.parking.leave(p.space)
+			p.space = c.h.parking.park(c.h.KeepAlive, &c.lastWrite, p.dueKeepAlive)
+		}
+	default:
+	}
+
+	maxBytes, maxEvents := c.flushBatch()
+	for drained, batched := false, 0; !drained; {
+		select {
+		case evt, ok := <-stream.events:
+			if !ok {
+				if c.shuttingDown() {
+					c.sendShutdown(stream)
+				} else if c.expired() {
+					c.sendExpired(stream)
+				} else {
+					c.flush()
+				}
+				return false
+			}
+			if !c.sendEvent(stream, evt) {
+				return false
+			}
+			if batched++; c.buf.Len() >= maxBytes || batched == maxEvents {
+				if !c.flush() {
+					return false
+				}
+				batched = 0
+			}
+
+		default:
+			drained = true
+		}
+	}
+
+	if p.keepAliveDue.Swap(false) && !c.writeKeepAlive() {
+		return false
+	}
+
+	if c.buf.Len() > 0 {
+		return c.flush()
+	}
+	return true
+}
+
+// end closes the connection, and releases everything held by it.
+func (p *parkedConn) end() {
+	p.stream.waker.fn.Store(nil)
+	if p.stopWake != nil {
+		p.stopWake()
+	}
+	if p.space != nil {
+		p.c.h.parking.leave(p.space)
+	}
+	p.cancel()
+	p.netConn.Close()
+	p.c.end(&p.stream)
+}
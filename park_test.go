@@ -0,0 +1,179 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParking(t *testing.T) {
+	t.Parallel()
+
+	t.Run("wakes connections when due", func(t *testing.T) {
+		t.Parallel()
+
+		var lot parkingLot
+		fast := lot.park(20*time.Millisecond, nil, nil)
+		slow := lot.park(time.Hour, nil, nil)
+		defer lot.leave(slow)
+
+		for i := 0; i < 3; i++ {
+			select {
+			case <-fast.wake:
+			case <-time.After(time.Second):
+				t.Fatalf("expected wake %d", i)
+			}
+		}
+
+		select {
+		case <-slow.wake:
+			t.Error("expected connection to not be woken before it is due")
+		default:
+		}
+
+		lot.leave(fast)
+		if fast.index != -1 || len(lot.spaces) != 1 {
+			t.Errorf("expected connection to be unregistered, but %d remain", len(lot.spaces))
+		}
+	})
+
+	t.Run("Handler sends keep-alives to parked connections", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			go func() {
+				<-time.After(250 * time.Millisecond)
+				stream.Send(Event{Data: []byte("wake")})
+				stream.Close()
+			}()
+			return nil
+		})
+		h.KeepAlive = 50 * time.Millisecond
+		h.Park = true
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		bodies := make(chan []byte, 3)
+		for i := 0; i < cap(bodies); i++ {
+			go func() {
+				resp, err := srv.Client().Get(srv.URL)
+				if err != nil {
+					bodies <- nil
+					return
+				}
+				defer resp.Body.Close()
+
+				body, _ := io.ReadAll(resp.Body)
+				bodies <- body
+			}()
+		}
+
+		for i := 0; i < cap(bodies); i++ {
+			body := <-bodies
+			if !bytes.Contains(body, []byte(": keep-alive\n\n")) {
+				t.Errorf("expected keep-alives, but got %q", body)
+			}
+			if !bytes.HasSuffix(body, []byte("data:wake\n\n")) {
+				t.Errorf("expected event, but got %q", body)
+			}
+		}
+	})
+
+	t.Run("Handler ends parked connections once BaseContext is done", func(t *testing.T) {
+		t.Parallel()
+
+		base, cancelBase := context.WithCancel(context.Background())
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			return nil
+		})
+		h.KeepAlive = time.Hour
+		h.Park = true
+		h.BaseContext = func(r *http.Request) context.Context { return base }
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		cancelBase()
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := io.ReadAll(resp.Body)
+			done <- err
+		}()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Error("expected the stream to end, but got", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("expected the connection to be closed")
+		}
+	})
+}
+
+// Not parallel, so that the goroutines of other tests are not counted.
+func TestParkedConnectionsAreIdle(t *testing.T) {
+	const clients = 10
+
+	streams := make(chan EventStream, clients)
+	h := NewHandler(func(stream EventStream, lastEventID string) error {
+		streams <- stream
+		return nil
+	})
+	h.KeepAlive = time.Hour
+	h.Park = true
+	srv := httptest.NewServer(h)
+	// registered first, so that it runs after the responses are closed
+	t.Cleanup(srv.Close)
+
+	before := serving()
+
+	bodies := make([]*bufio.Reader, clients)
+	for i := range bodies {
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		bodies[i] = bufio.NewReader(resp.Body)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); serving() > before; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected no goroutines serving parked connections, but got %d", serving()-before)
+		}
+	}
+
+	for i := 0; i < clients; i++ {
+		stream := <-streams
+		if err := stream.Send(Event{Data: []byte("wake")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, body := range bodies {
+		line, err := body.ReadString('\n')
+		if err != nil || line != "data:wake\n" {
+			t.Errorf("expected event to be sent to parked connection, but got %q (%v)", line, err)
+		}
+	}
+}
+
+// serving counts the goroutines serving a request, or sending to a parked connection.
+func serving() int {
+	buf := make([]byte, 1<<20)
+	stacks := string(buf[:runtime.Stack(buf, true)])
+	return strings.Count(stacks, "(*Handler).ServeHTTP") + strings.Count(stacks, "(*parkedConn).run")
+}
@@ -0,0 +1,164 @@
+package sse
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultPingInterval is how often a Pinger pings when its Interval is 0.
+const DefaultPingInterval = 15 * time.Second
+
+// PingHandler returns an http.Handler for clients to measure their round-trip time to h with, e.g. with a Pinger,
+// since an event stream only goes one way. It responds to GET and HEAD requests with 204 No Content, or with
+// 404 Not Found if they name a stream with the StreamParam query parameter that h is not serving, so that clients
+// can also tell whether the server still has their stream, e.g. when it has been silent for a while.
+func (h *Handler) PingHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		if id := r.URL.Query().Get(StreamParam); id != "" {
+			if _, ok := h.Get(id); !ok {
+				http.Error(w, ErrStreamNotFound.Error(), http.StatusNotFound)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// PingResult is the result of a ping by a Pinger.
+type PingResult struct {
+	// At is when the ping was sent.
+	At time.Time
+
+	// RTT is the ping's round-trip time.
+	RTT time.Duration
+
+	// SmoothedRTT is the moving average of the round-trip times of the pings that succeeded so far,
+	// weighted towards the latest, as TCP's is.
+	SmoothedRTT time.Duration
+
+	// Err is why the ping failed, if it did. It wraps ErrStreamNotFound if the server is no longer serving
+	// the Pinger's stream.
+	Err error
+}
+
+// Pinger measures a client's round-trip time to a Handler's PingHandler, periodically, to report along with
+// the health of its stream, e.g. with the latencies measured by a ReceiveClock.
+type Pinger struct {
+	// URL is the URL of the PingHandler.
+	URL string
+
+	// StreamID, if not empty, is the ID of the client's stream, to check that the server is still serving it.
+	StreamID string
+
+	// Client is used to make the pings. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Interval is how often Run pings. If 0, DefaultPingInterval is used.
+	Interval time.Duration
+
+	// OnPing, if not nil, is called with the result of each ping.
+	OnPing func(result PingResult)
+
+	mu   sync.Mutex
+	last PingResult
+}
+
+// Ping pings the PingHandler once, and returns the result.
+func (p *Pinger) Ping(ctx context.Context) PingResult {
+	result := PingResult{At: time.Now()}
+	result.Err = p.ping(ctx)
+	result.RTT = time.Since(result.At)
+
+	p.mu.Lock()
+	switch {
+	case result.Err != nil:
+		result.SmoothedRTT = p.last.SmoothedRTT
+	case p.last.SmoothedRTT == 0:
+		result.SmoothedRTT = result.RTT
+	default:
+		result.SmoothedRTT = (7*p.last.SmoothedRTT + result.RTT) / 8
+	}
+	p.last = result
+	p.mu.Unlock()
+
+	if p.OnPing != nil {
+		p.OnPing(result)
+	}
+	return result
+}
+
+func (p *Pinger) ping(ctx context.Context) error {
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return err
+	}
+	if p.StreamID != "" {
+		q := u.Query()
+		q.Set(StreamParam, p.StreamID)
+		u.RawQuery = q.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrStreamNotFound, p.StreamID)
+	default:
+		return fmt.Errorf("sse: ping failed: %s", resp.Status)
+	}
+}
+
+// Run pings the PingHandler every Interval, until ctx is done, and returns ctx's error.
+func (p *Pinger) Run(ctx context.Context) error {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = DefaultPingInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.Ping(ctx)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Last returns the result of the latest ping.
+func (p *Pinger) Last() PingResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.last
+}
@@ -0,0 +1,85 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPing(t *testing.T) {
+	t.Parallel()
+
+	ids := make(chan string, 1)
+	release := make(chan struct{})
+	h := NewHandler(func(stream EventStream, lastEventID string) error {
+		ids <- stream.ID()
+		stream.Go(func(ctx context.Context) error {
+			if err := stream.Send(Event{Data: []byte("hello")}); err != nil {
+				return err
+			}
+			<-release
+			return nil
+		})
+		return nil
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("/", h)
+	mux.Handle("/ping", h.PingHandler())
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if line, _ := bufio.NewReader(resp.Body).ReadString('\n'); line != "data:hello\n" {
+		t.Fatalf("expected the event, but got %q", line)
+	}
+
+	var results []PingResult
+	p := &Pinger{
+		URL:      srv.URL + "/ping",
+		StreamID: <-ids,
+		Client:   srv.Client(),
+		Interval: time.Millisecond,
+		OnPing:   func(result PingResult) { results = append(results, result) },
+	}
+
+	first := p.Ping(context.Background())
+	if first.Err != nil || first.RTT <= 0 || first.SmoothedRTT != first.RTT {
+		t.Errorf("expected a successful ping, seeding the smoothed RTT, but got %+v", first)
+	}
+	second := p.Ping(context.Background())
+	if expected := (7*first.RTT + second.RTT) / 8; second.Err != nil || second.SmoothedRTT != expected {
+		t.Errorf("expected a smoothed RTT of %v, but got %+v", expected, second)
+	}
+
+	close(release)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if result := p.Ping(context.Background()); errors.Is(result.Err, ErrStreamNotFound) {
+			if result.SmoothedRTT != second.SmoothedRTT {
+				t.Errorf("expected failed pings not to change the smoothed RTT, but got %v", result.SmoothedRTT)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected pings to report that the stream ended")
+		}
+	}
+	if last := p.Last(); !errors.Is(last.Err, ErrStreamNotFound) || len(results) < 3 {
+		t.Errorf("expected every ping to be reported, ending with the failure, but got %d, %+v", len(results), last)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	p.StreamID = ""
+	if err := p.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected Run to return once its context is done, but got %v", err)
+	}
+}
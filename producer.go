@@ -0,0 +1,123 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// producerGroup runs the producers started by EventStream.Go, and closes the stream once they have all finished.
+// The Handler holds a place in the group while the NewEventStreamHandler runs, so that the stream is not closed
+// while it may still start producers.
+type producerGroup struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	active  int
+	started bool
+	err     error
+
+	// recovered reports a panic in a producer, and returns it as an error.
+	recovered func(value interface{}) error
+}
+
+// Go runs fn in a new goroutine to produce events for the stream, until it returns, or ctx is done.
+// ctx is derived from the EventStream's Context, and is also canceled once any producer started by Go
+// returns an error, like golang.org/x/sync/errgroup.
+//
+// Once all of the producers have returned, the stream is closed: if any of them failed, the first error is
+// sent to the client as with CloseWithError if it is a *StreamError, and as a ReasonServerError otherwise.
+// A producer that panics fails with a ReasonServerError, after the panic is reported to Handler.OnPanic.
+// ctx is also canceled by Handler.Cancel, with ErrJobCanceled as its cause; failing with ctx's error then
+// sends a ReasonCanceled StreamError instead.
+// Producers must not close the stream themselves.
+// Go must be called before the NewEventStreamHandler returns, or by a producer that has not yet returned.
+func (s EventStream) Go(fn func(ctx context.Context) error) {
+	g := s.producers
+
+	g.mu.Lock()
+	ctx := g.context(s)
+	g.started = true
+	g.active++
+	g.mu.Unlock()
+
+	go func() {
+		var err error
+		defer func() {
+			if v := recover(); v != nil {
+				err = g.recover(v)
+			}
+			g.done(s, err)
+		}()
+		err = fn(ctx)
+	}()
+}
+
+// context returns the producers' context, creating it if needed. g.mu must be held.
+func (g *producerGroup) context(s EventStream) context.Context {
+	if g.ctx == nil {
+		g.ctx, g.cancel = context.WithCancelCause(s.ctx)
+	}
+	return g.ctx
+}
+
+// cancelJob cancels the producers' context with ErrJobCanceled, see Handler.Cancel.
+func (g *producerGroup) cancelJob(s EventStream) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.context(s)
+	g.cancel(ErrJobCanceled)
+}
+
+// recover returns a panic in a producer with value as an error.
+func (g *producerGroup) recover(value interface{}) error {
+	if g.recovered != nil {
+		return g.recovered(value)
+	}
+	return fmt.Errorf("%w: %v", errPanic, value)
+}
+
+// hold keeps s from being closed until release is called, even if no producers are running.
+func (g *producerGroup) hold() {
+	g.mu.Lock()
+	g.active++
+	g.mu.Unlock()
+}
+
+func (g *producerGroup) release(s EventStream) { g.done(s, nil) }
+
+// done records that a producer finished with err, and closes s if it was the last.
+func (g *producerGroup) done(s EventStream, err error) {
+	g.mu.Lock()
+	if err != nil && g.err == nil {
+		g.err = err
+		g.cancel(nil)
+	}
+	g.active--
+	finished := g.active == 0 && g.started
+	err = g.err
+	g.mu.Unlock()
+
+	if !finished {
+		return
+	}
+	g.cancel(nil)
+
+	// there is no one left to tell if the client has gone
+	if err == nil || s.ctx.Err() != nil {
+		s.Close()
+		return
+	}
+
+	var streamErr *StreamError
+	switch {
+	case errors.As(err, &streamErr):
+	case errors.Is(err, context.Canceled) && errors.Is(context.Cause(g.ctx), ErrJobCanceled):
+		streamErr = NewStreamError(ReasonCanceled, "")
+	default:
+		streamErr = NewStreamError(ReasonServerError, "")
+	}
+	s.CloseWithError(streamErr)
+}
@@ -0,0 +1,115 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventStreamGo(t *testing.T) {
+	t.Parallel()
+
+	serve := func(t *testing.T, h *Handler) string {
+		t.Helper()
+
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal("failed to read response body:", err)
+		}
+		return string(body)
+	}
+
+	t.Run("closes stream once producers finish", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			for _, name := range []string{"one", "two"} {
+				name := name
+				stream.Go(func(ctx context.Context) error {
+					<-time.After(10 * time.Millisecond)
+					return stream.Send(Event{Data: []byte(name)})
+				})
+			}
+			return nil
+		})
+
+		body := serve(t, h)
+		if !strings.Contains(body, "data:one\n\n") || !strings.Contains(body, "data:two\n\n") {
+			t.Errorf("expected events from both producers, but got %q", body)
+		}
+	})
+
+	t.Run("sends first error and cancels others", func(t *testing.T) {
+		t.Parallel()
+
+		canceled := make(chan error, 1)
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			stream.Go(func(ctx context.Context) error {
+				<-ctx.Done()
+				canceled <- ctx.Err()
+				return ctx.Err()
+			})
+			stream.Go(func(ctx context.Context) error {
+				return NewStreamError(ReasonAuthExpired, "token expired")
+			})
+			return nil
+		})
+
+		body := serve(t, h)
+		evt := NewStreamError(ReasonAuthExpired, "token expired").Event()
+		if expected := "event:" + evt.Event + "\ndata:" + string(evt.Data) + "\n\n"; body != expected {
+			t.Errorf("expected response body %q, but got %q", expected, body)
+		}
+		if err := <-canceled; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected other producers to be canceled, but got %v", err)
+		}
+	})
+
+	t.Run("sends server error for other errors", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			stream.Go(func(ctx context.Context) error {
+				return errors.New("database unavailable")
+			})
+			return nil
+		})
+
+		body := serve(t, h)
+		if !strings.Contains(body, `"code":"server_error"`) || strings.Contains(body, "database") {
+			t.Errorf("expected server error event without details, but got %q", body)
+		}
+	})
+
+	t.Run("allows producers to start producers", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			stream.Go(func(ctx context.Context) error {
+				stream.Go(func(ctx context.Context) error {
+					<-time.After(10 * time.Millisecond)
+					return stream.Send(Event{Data: []byte("nested")})
+				})
+				return nil
+			})
+			return nil
+		})
+
+		if body := serve(t, h); body != "data:nested\n\n" {
+			t.Errorf("expected event from nested producer, but got %q", body)
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package sse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// The event names of the events sent by Progress.
+const (
+	// ProgressEvent reports a job's progress.
+	ProgressEvent = "sse-progress"
+
+	// ProgressSucceededEvent reports that a job succeeded, with its result, if any. It is the last event sent.
+	ProgressSucceededEvent = "sse-progress-succeeded"
+
+	// ProgressFailedEvent reports that a job failed, with the error. It is the last event sent.
+	ProgressFailedEvent = "sse-progress-failed"
+)
+
+// The states of a job, see ProgressUpdate.
+const (
+	ProgressRunning   = "running"
+	ProgressSucceeded = "succeeded"
+	ProgressFailed    = "failed"
+)
+
+// ErrProgressEnded is returned by Progress's methods once its job has succeeded or failed.
+var ErrProgressEnded = errors.New("sse: progress has ended")
+
+// ProgressUpdate is the data of the events sent by Progress, as JSON:
+//
+//	{"stream_id":"3f2a9c","state":"running","stage":"upload","percent":42.5,"message":"uploading part 3 of 7"}
+type ProgressUpdate struct {
+	// StreamID is the ID of the stream the job reports its progress on, with which clients can cancel the job,
+	// see Handler.CancelHandler.
+	StreamID string `json:"stream_id"`
+
+	// State is the job's state: ProgressRunning, ProgressSucceeded, or ProgressFailed.
+	State string `json:"state"`
+
+	// Stage is the name of the job's current stage, if it has stages.
+	Stage string `json:"stage,omitempty"`
+
+	// Percent is how far through the job is, from 0 to 100.
+	Percent float64 `json:"percent"`
+
+	// Message describes what the job is doing, for people.
+	Message string `json:"message,omitempty"`
+
+	// Result is the result of a job that succeeded, if any.
+	Result json.RawMessage `json:"result,omitempty"`
+
+	// Error is the error of a job that failed.
+	Error string `json:"error,omitempty"`
+}
+
+// Progress reports the progress of a long-running job on an EventStream, with events named ProgressEvent,
+// until it succeeds or fails, which is reported with a ProgressSucceededEvent or ProgressFailedEvent,
+// after which the stream is closed. Clients decode them with DecodeProgress.
+//
+// If the job is canceled, see Handler.Cancel, the context of the stream's producers is canceled; the producer
+// running the job should then report it with Fail(context.Cause(ctx)).
+//
+// It is safe for concurrent use, e.g. by workers of the same job.
+type Progress struct {
+	stream EventStream
+
+	mu     sync.Mutex
+	update ProgressUpdate
+}
+
+// NewProgress returns a *Progress sending the progress of a job on stream.
+func NewProgress(stream EventStream) *Progress {
+	return &Progress{stream: stream, update: ProgressUpdate{StreamID: stream.id, State: ProgressRunning}}
+}
+
+// Stage reports that the job has moved on to the stage named name, starting from 0 percent.
+func (p *Progress) Stage(name, message string) error {
+	return p.send(ProgressEvent, func(u *ProgressUpdate) error {
+		u.Stage, u.Percent, u.Message = name, 0, message
+		return nil
+	})
+}
+
+// Update reports how far through the job, or its current stage, is, as a percentage, from 0 to 100.
+func (p *Progress) Update(percent float64, message string) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("sse: progress of %v percent is not between 0 and 100", percent)
+	}
+
+	return p.send(ProgressEvent, func(u *ProgressUpdate) error {
+		u.Percent, u.Message = percent, message
+		return nil
+	})
+}
+
+// Succeed reports that the job succeeded, with result, as JSON, if it is not nil, and closes the stream.
+func (p *Progress) Succeed(result interface{}) error {
+	return p.end(ProgressSucceededEvent, func(u *ProgressUpdate) error {
+		u.State, u.Percent, u.Message = ProgressSucceeded, 100, ""
+		if result == nil {
+			return nil
+		}
+
+		data, err := json.Marshal(result)
+		u.Result = data
+		return err
+	})
+}
+
+// Fail reports that the job failed with err, and closes the stream.
+func (p *Progress) Fail(err error) error {
+	return p.end(ProgressFailedEvent, func(u *ProgressUpdate) error {
+		u.State, u.Message, u.Error = ProgressFailed, "", err.Error()
+		return nil
+	})
+}
+
+// end sends the last event, and closes the stream.
+func (p *Progress) end(name string, change func(u *ProgressUpdate) error) error {
+	err := p.send(name, change)
+	if errors.Is(err, ErrProgressEnded) {
+		return err
+	}
+
+	if closeErr := p.stream.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// send applies change to the job's state, and sends it as an event named name.
+func (p *Progress) send(name string, change func(u *ProgressUpdate) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.update.State != ProgressRunning {
+		return ErrProgressEnded
+	}
+
+	update := p.update
+	if err := change(&update); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	p.update = update
+	return p.stream.Send(Event{Event: name, Data: data})
+}
+
+// DecodeProgress returns the ProgressUpdate sent by a Progress as evt, and reports whether evt was sent by one,
+// so that clients can watch a job's progress alongside other events:
+//
+//	if update, ok, err := sse.DecodeProgress(evt); ok && err == nil && update.State != sse.ProgressRunning {
+//	    // the job has ended, and the stream with it
+//	}
+func DecodeProgress(evt Event) (ProgressUpdate, bool, error) {
+	var update ProgressUpdate
+	switch evt.Event {
+	case ProgressEvent, ProgressSucceededEvent, ProgressFailedEvent:
+	default:
+		return update, false, nil
+	}
+
+	if err := json.Unmarshal(evt.Data, &update); err != nil {
+		return update, true, fmt.Errorf("sse: malformed progress update: %w", err)
+	}
+	return update, true, nil
+}
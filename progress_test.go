@@ -0,0 +1,104 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	t.Parallel()
+
+	newStream := func() EventStream {
+		return EventStream{
+			ctx:    context.Background(),
+			events: make(chan queuedEvent, 8),
+			queue:  newQueueAccount(DefaultAllocator),
+			closer: newStreamCloser(),
+		}
+	}
+
+	// sent returns the updates sent on stream, once it is closed.
+	sent := func(t *testing.T, stream EventStream) []ProgressUpdate {
+		t.Helper()
+
+		var updates []ProgressUpdate
+		for evt := range stream.events {
+			update, ok, err := DecodeProgress(evt.Event)
+			if !ok || err != nil {
+				t.Fatalf("expected a progress update, but got %q: %v", evt.Data, err)
+			}
+			updates = append(updates, update)
+		}
+		return updates
+	}
+
+	t.Run("reports stages and percentages, then success", func(t *testing.T) {
+		t.Parallel()
+
+		stream := newStream()
+		p := NewProgress(stream)
+		for _, err := range []error{
+			p.Stage("upload", "uploading"),
+			p.Update(50, "halfway"),
+			p.Stage("process", ""),
+			p.Succeed(map[string]string{"url": "/exports/1"}),
+		} {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		updates := sent(t, stream)
+		if len(updates) != 4 {
+			t.Fatalf("expected 4 updates, but got %d", len(updates))
+		}
+		if u := updates[1]; u.State != ProgressRunning || u.Stage != "upload" || u.Percent != 50 || u.Message != "halfway" {
+			t.Errorf("expected the upload to be halfway, but got %+v", u)
+		}
+		if u := updates[2]; u.Stage != "process" || u.Percent != 0 {
+			t.Errorf("expected the next stage to start from 0, but got %+v", u)
+		}
+		if u := updates[3]; u.State != ProgressSucceeded || u.Percent != 100 || string(u.Result) != `{"url":"/exports/1"}` {
+			t.Errorf("expected success with the result, but got %+v", u)
+		}
+	})
+
+	t.Run("reports failure, and nothing after", func(t *testing.T) {
+		t.Parallel()
+
+		stream := newStream()
+		p := NewProgress(stream)
+		if err := p.Fail(errors.New("disk full")); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Update(10, ""); !errors.Is(err, ErrProgressEnded) {
+			t.Errorf("expected %v, but got %v", ErrProgressEnded, err)
+		}
+		if err := p.Succeed(nil); !errors.Is(err, ErrProgressEnded) {
+			t.Errorf("expected %v, but got %v", ErrProgressEnded, err)
+		}
+
+		updates := sent(t, stream)
+		if len(updates) != 1 || updates[0].State != ProgressFailed || updates[0].Error != "disk full" {
+			t.Errorf("expected only the failure, but got %+v", updates)
+		}
+	})
+
+	t.Run("rejects percentages out of range", func(t *testing.T) {
+		t.Parallel()
+
+		p := NewProgress(newStream())
+		if err := p.Update(101, ""); err == nil {
+			t.Error("expected an error, but got none")
+		}
+	})
+
+	t.Run("ignores other events", func(t *testing.T) {
+		t.Parallel()
+
+		if _, ok, _ := DecodeProgress(Event{Event: "other", Data: []byte("{}")}); ok {
+			t.Error("expected other events not to be progress updates")
+		}
+	})
+}
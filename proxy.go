@@ -0,0 +1,43 @@
+package sse
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// NewProxyHandler returns an *httputil.ReverseProxy that forwards event streams from target, e.g. for a gateway
+// in front of internal services, which may be customized further before it is used:
+//
+//   - each write from target is flushed to the client immediately, rather than buffered
+//   - request headers such as Last-Event-ID, and the extensions negotiated with target, are passed through
+//   - responses are marked with "X-Accel-Buffering: no", so that proxies such as nginx in front of the
+//     gateway do not buffer them either
+//   - the request to target is canceled once the client disconnects
+//
+// If target cannot be reached, the client is sent a retryable ReasonServerError StreamErrorEvent,
+// rather than an error status, which would stop an EventSource from reconnecting.
+func NewProxyHandler(target *url.URL) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.SetXForwarded()
+		},
+		FlushInterval: -1,
+		ModifyResponse: func(resp *http.Response) error {
+			resp.Header.Set("X-Accel-Buffering", "no")
+			return nil
+		},
+		ErrorHandler: proxyError,
+	}
+}
+
+// proxyError tells the client that the stream could not be proxied, and that it should reconnect.
+func proxyError(w http.ResponseWriter, r *http.Request, err error) {
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	EncodeEvent(w, NewStreamError(ReasonServerError, "upstream unavailable").Event())
+}
@@ -0,0 +1,93 @@
+package sse
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestProxyHandler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("forwards events as they are sent", func(t *testing.T) {
+		t.Parallel()
+
+		done := make(chan struct{})
+		ended := make(chan struct{})
+		upstream := httptest.NewServer(NewHandler(func(stream EventStream, lastEventID string) error {
+			go func() {
+				defer close(ended)
+
+				stream.Send(Event{ID: "2", Data: []byte("after " + lastEventID)})
+				select {
+				case <-done:
+				case <-stream.Context().Done():
+				}
+			}()
+			return nil
+		}))
+		defer upstream.Close()
+		defer close(done)
+
+		target, _ := url.Parse(upstream.URL)
+		proxy := httptest.NewServer(NewProxyHandler(target))
+		defer proxy.Close()
+
+		req, _ := http.NewRequest(http.MethodGet, proxy.URL, nil)
+		req.Header.Set("Last-Event-ID", "1")
+		resp, err := proxy.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.Header.Get("X-Accel-Buffering") != "no" || resp.Header.Get("Content-Type") != "text/event-stream" {
+			t.Errorf("expected event stream headers, but got %v", resp.Header)
+		}
+
+		// the upstream stream is still open, so this only succeeds if the proxy does not buffer
+		r := bufio.NewReader(resp.Body)
+		for _, expected := range []string{"data:after 1\n", "id:2\n"} {
+			if line, err := r.ReadString('\n'); err != nil || line != expected {
+				t.Errorf("expected %q, but got %q (%v)", expected, line, err)
+			}
+		}
+
+		resp.Body.Close()
+		select {
+		case <-ended:
+		case <-time.After(5 * time.Second):
+			t.Error("expected upstream stream to end once the client disconnected")
+		}
+	})
+
+	t.Run("tells clients to reconnect if upstream is unavailable", func(t *testing.T) {
+		t.Parallel()
+
+		upstream := httptest.NewServer(http.NotFoundHandler())
+		target, _ := url.Parse(upstream.URL)
+		upstream.Close()
+
+		proxy := httptest.NewServer(NewProxyHandler(target))
+		defer proxy.Close()
+
+		resp, err := proxy.Client().Get(proxy.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status %d, but got %d", http.StatusOK, resp.StatusCode)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		evt := NewStreamError(ReasonServerError, "upstream unavailable").Event()
+		if expected := "event:" + evt.Event + "\ndata:" + string(evt.Data) + "\n\n"; string(body) != expected {
+			t.Errorf("expected response body %q, but got %q", expected, body)
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package sse
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ReconnectLimiter slows down clients that reconnect excessively, e.g. in a loop caused by a bug, so that
+// they do not take up a stream each time, see Handler.ReconnectLimiter.
+// A client that connects more than Allowed times within Window is delayed, by Delay for its first
+// excess connection, doubling with each one after it, up to MaxDelay. A client's count restarts once
+// Window has passed since its first connection was counted.
+//
+// Its fields must not be modified once it is in use.
+type ReconnectLimiter struct {
+	// Allowed is the number of connections a client may make within Window without being delayed.
+	Allowed int
+
+	// Window is the period over which a client's connections are counted.
+	Window time.Duration
+
+	// Delay is how long a client's first excess connection is delayed.
+	Delay time.Duration
+
+	// MaxDelay is the longest a connection is delayed.
+	MaxDelay time.Duration
+
+	// Reject, if true, rejects excess connections straight away with 429 Too Many Requests, and a Retry-After
+	// header with the delay, rather than holding them open for the delay before serving them.
+	// Note that an EventSource does not reconnect once it receives an error response.
+	Reject bool
+
+	// Key, if not nil, returns the key a client's connections are counted by, e.g. a user ID.
+	// If nil, its Fingerprint's IP is used.
+	Key func(fp Fingerprint) string
+
+	mu        sync.Mutex
+	clients   map[string]*reconnects
+	lastSweep time.Time
+}
+
+// reconnects counts a client's connections within a ReconnectLimiter's Window.
+type reconnects struct {
+	start time.Time
+	count int
+}
+
+// NewReconnectLimiter returns a *ReconnectLimiter allowing allowed connections per client within window,
+// with a Delay of a second, and a MaxDelay of a minute.
+func NewReconnectLimiter(allowed int, window time.Duration) *ReconnectLimiter {
+	return &ReconnectLimiter{
+		Allowed:  allowed,
+		Window:   window,
+		Delay:    time.Second,
+		MaxDelay: time.Minute,
+	}
+}
+
+// record counts a connection by the client identified by key at now, and returns how long it is delayed.
+func (l *ReconnectLimiter) record(key string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.clients == nil {
+		l.clients = make(map[string]*reconnects)
+	}
+	if now.Sub(l.lastSweep) >= l.Window {
+		for k, c := range l.clients {
+			if now.Sub(c.start) >= l.Window {
+				delete(l.clients, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	c, ok := l.clients[key]
+	if !ok || now.Sub(c.start) >= l.Window {
+		c = &reconnects{start: now}
+		l.clients[key] = c
+	}
+	c.count++
+
+	excess := c.count - l.Allowed
+	if excess <= 0 {
+		return 0
+	}
+
+	delay := l.Delay
+	for i := 1; i < excess && delay < l.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > l.MaxDelay {
+		delay = l.MaxDelay
+	}
+	return delay
+}
+
+// limitReconnects applies h.ReconnectLimiter to r, and reports whether it may be served;
+// if not, it has been responded to, or its client has disconnected.
+func (h *Handler) limitReconnects(w http.ResponseWriter, r *http.Request) bool {
+	l := h.ReconnectLimiter
+	if l == nil {
+		return true
+	}
+
+	fp := NewFingerprint(r)
+	key := fp.IP
+	if l.Key != nil {
+		key = l.Key(fp)
+	}
+
+	delay := l.record(key, time.Now())
+	if delay <= 0 {
+		return true
+	}
+
+	if l.Reject {
+		h.stats.rejected.Add(1)
+		err := NewHTTPError(http.StatusTooManyRequests, "reconnecting too often")
+		err.Header = http.Header{"Retry-After": {strconv.Itoa(int((delay + time.Second - 1) / time.Second))}}
+		err.write(w)
+		return false
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	case <-h.shutdown.ctx.Done():
+		h.rejectShuttingDown(w)
+		return false
+	}
+}
@@ -0,0 +1,106 @@
+package sse
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReconnectLimiter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("delays excess connections increasingly", func(t *testing.T) {
+		t.Parallel()
+
+		l := NewReconnectLimiter(2, time.Minute)
+		l.MaxDelay = 5 * time.Second
+		now := time.Now()
+
+		var delays []time.Duration
+		for i := 0; i < 6; i++ {
+			delays = append(delays, l.record("client", now.Add(time.Duration(i)*time.Second)))
+		}
+		expected := []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+		for i := range expected {
+			if delays[i] != expected[i] {
+				t.Errorf("expected delays %v, but got %v", expected, delays)
+				break
+			}
+		}
+
+		if delay := l.record("other", now); delay != 0 {
+			t.Errorf("expected other clients not to be delayed, but got %v", delay)
+		}
+		if delay := l.record("client", now.Add(time.Minute)); delay != 0 {
+			t.Errorf("expected the count to restart after the window, but got %v", delay)
+		}
+	})
+
+	t.Run("forgets clients once their window has passed", func(t *testing.T) {
+		t.Parallel()
+
+		l := NewReconnectLimiter(1, time.Minute)
+		now := time.Now()
+		l.record("a", now)
+		l.record("b", now.Add(30*time.Second))
+		l.record("c", now.Add(time.Minute+time.Second))
+
+		if len(l.clients) != 2 {
+			t.Errorf("expected 2 clients, but got %d", len(l.clients))
+		}
+	})
+
+	stream := func(stream EventStream, lastEventID string) error {
+		return stream.Close()
+	}
+
+	get := func(t *testing.T, srv *httptest.Server) *http.Response {
+		t.Helper()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("holds excess connections", func(t *testing.T) {
+		t.Parallel()
+
+		l := NewReconnectLimiter(1, time.Minute)
+		l.Delay = 50 * time.Millisecond
+		srv := httptest.NewServer(NewHandler(stream, WithReconnectLimiter(l)))
+		t.Cleanup(srv.Close)
+
+		get(t, srv)
+		start := time.Now()
+		if resp := get(t, srv); resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status %d, but got %d", http.StatusOK, resp.StatusCode)
+		}
+		if elapsed := time.Since(start); elapsed < l.Delay {
+			t.Errorf("expected the connection to be delayed by at least %v, but got %v", l.Delay, elapsed)
+		}
+	})
+
+	t.Run("rejects excess connections", func(t *testing.T) {
+		t.Parallel()
+
+		l := NewReconnectLimiter(1, time.Minute)
+		l.Delay = 1500 * time.Millisecond
+		l.Reject = true
+		l.Key = func(fp Fingerprint) string { return fp.UserAgent }
+		srv := httptest.NewServer(NewHandler(stream, WithReconnectLimiter(l)))
+		t.Cleanup(srv.Close)
+
+		get(t, srv)
+		resp := get(t, srv)
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Errorf("expected status %d, but got %d", http.StatusTooManyRequests, resp.StatusCode)
+		}
+		if retry := resp.Header.Get("Retry-After"); retry != "2" {
+			t.Errorf("expected Retry-After %q, but got %q", "2", retry)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package sse
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrStreamNotFound is returned by Handler.SendTo when there is no stream with the given ID.
+var ErrStreamNotFound = errors.New("sse: stream not found")
+
+// StreamParam is the query parameter naming a stream, by its ID, for a Handler's CancelHandler and PingHandler.
+const StreamParam = "stream"
+
+// streamRegistry holds the streams a Handler is serving, by ID.
+// The zero value is ready to use.
+type streamRegistry struct {
+	mu      sync.RWMutex
+	streams map[string]EventStream
+}
+
+func (r *streamRegistry) add(stream EventStream) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.streams == nil {
+		r.streams = make(map[string]EventStream)
+	}
+	r.streams[stream.id] = stream
+}
+
+func (r *streamRegistry) remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.streams, id)
+}
+
+// Streams returns the streams h is serving, sorted by ID: those whose NewEventStreamHandler has accepted
+// the connection, and which have not yet ended.
+func (h *Handler) Streams() []EventStream {
+	h.streams.mu.RLock()
+	streams := make([]EventStream, 0, len(h.streams.streams))
+	for _, stream := range h.streams.streams {
+		streams = append(streams, stream)
+	}
+	h.streams.mu.RUnlock()
+
+	sort.Slice(streams, func(i, j int) bool { return streams[i].id < streams[j].id })
+	return streams
+}
+
+// Get returns the stream h is serving with the given ID (see EventStream.ID), if any,
+// e.g. to send an event to a specific client, such as a notification that its export is done.
+func (h *Handler) Get(id string) (EventStream, bool) {
+	h.streams.mu.RLock()
+	defer h.streams.mu.RUnlock()
+
+	stream, ok := h.streams.streams[id]
+	return stream, ok
+}
+
+// SendTo sends e to the stream h is serving with the given ID, as by EventStream.Send.
+// It returns ErrStreamNotFound if there is no such stream, e.g. because the client has disconnected.
+func (h *Handler) SendTo(id string, e Event) error {
+	stream, ok := h.Get(id)
+	if !ok {
+		return ErrStreamNotFound
+	}
+	return stream.Send(e)
+}
@@ -0,0 +1,111 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandlerRegistry(t *testing.T) {
+	t.Parallel()
+
+	for _, park := range []bool{false, true} {
+		park := park
+
+		t.Run("sends to streams by ID, until they end", func(t *testing.T) {
+			t.Parallel()
+
+			ids := make(chan string, 1)
+			h := NewHandler(func(stream EventStream, lastEventID string) error {
+				ids <- stream.ID()
+				return nil
+			})
+			h.KeepAlive = time.Hour
+			h.Park = park
+			srv := httptest.NewServer(h)
+			t.Cleanup(srv.Close)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			req := httptest.NewRequest("GET", srv.URL, nil).WithContext(ctx)
+			req.RequestURI = ""
+
+			connected := make(chan error, 1)
+			lines := make(chan string, 4)
+			go func() {
+				resp, err := srv.Client().Do(req)
+				connected <- err
+				if err != nil {
+					return
+				}
+				defer resp.Body.Close()
+
+				scanner := bufio.NewScanner(resp.Body)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}()
+
+			id := <-ids
+			for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+				if _, ok := h.Get(id); ok {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("park %v: expected stream %s to be registered", park, id)
+				}
+			}
+			if streams := h.Streams(); len(streams) != 1 || streams[0].ID() != id {
+				t.Errorf("park %v: expected the stream to be listed, but got %v", park, streams)
+			}
+
+			if err := h.SendTo(id, Event{Data: []byte("your export is done")}); err != nil {
+				t.Fatalf("park %v: expected SendTo to succeed, but got %v", park, err)
+			}
+			if err := <-connected; err != nil {
+				t.Fatal(err)
+			}
+			if line := <-lines; line != "data:your export is done" {
+				t.Errorf("park %v: expected the event, but got %q", park, line)
+			}
+
+			// parked connections only notice the client disconnecting when writing to them fails
+			stream, _ := h.Get(id)
+			stream.Close()
+			for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+				if _, ok := h.Get(id); !ok {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("park %v: expected stream %s to be removed once it ended", park, id)
+				}
+			}
+			if err := h.SendTo(id, Event{Data: []byte("late")}); !errors.Is(err, ErrStreamNotFound) {
+				t.Errorf("park %v: expected %v, but got %v", park, ErrStreamNotFound, err)
+			}
+		})
+	}
+
+	t.Run("does not register rejected streams", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			return NewHTTPError(403, "")
+		})
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if streams := h.Streams(); len(streams) != 0 {
+			t.Errorf("expected no streams, but got %d", len(streams))
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package sse
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// HTTPError rejects a request with an HTTP status code, when returned by a NewEventStreamHandler,
+// e.g. a 401 for a missing token, or a 429 when a client has too many streams open.
+// Unlike with a StreamError, no stream is started: the client receives a plain error response, which
+// also stops an EventSource from reconnecting.
+type HTTPError struct {
+	// Code is the HTTP status code, e.g. http.StatusForbidden.
+	Code int
+
+	// Message is the body of the response. If empty, the status' text is used.
+	Message string
+
+	// Header, if not nil, is added to the response's headers, e.g. a Retry-After, or a WWW-Authenticate.
+	Header http.Header
+}
+
+// NewHTTPError returns an *HTTPError with code and message.
+func NewHTTPError(code int, message string) *HTTPError {
+	return &HTTPError{Code: code, Message: message}
+}
+
+func (e *HTTPError) Error() string {
+	msg := "sse: request rejected: " + strconv.Itoa(e.Code) + " " + http.StatusText(e.Code)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// write responds to the client with e.
+func (e *HTTPError) write(w http.ResponseWriter) {
+	for key, values := range e.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.Code)
+	}
+	http.Error(w, msg, e.Code)
+}
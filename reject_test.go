@@ -0,0 +1,82 @@
+package sse
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPError(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Handler rejects requests with the status code", func(t *testing.T) {
+		t.Parallel()
+
+		for _, tt := range []struct {
+			err    error
+			status int
+			body   string
+		}{
+			{NewHTTPError(http.StatusUnauthorized, "missing token"), http.StatusUnauthorized, "missing token\n"},
+			{fmt.Errorf("rejected: %w", NewHTTPError(http.StatusForbidden, "")), http.StatusForbidden, "Forbidden\n"},
+			{&HTTPError{Code: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"30"}}}, http.StatusTooManyRequests, "Too Many Requests\n"},
+		} {
+			connected := false
+			h := NewHandler(func(stream EventStream, lastEventID string) error { return tt.err })
+			h.OnConnect = func(string, *http.Request) { connected = true }
+			srv := httptest.NewServer(h)
+
+			resp, err := srv.Client().Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			srv.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Errorf("%v: expected status %d, but got %d", tt.err, tt.status, resp.StatusCode)
+			}
+			if string(body) != tt.body {
+				t.Errorf("%v: expected body %q, but got %q", tt.err, tt.body, body)
+			}
+			if contentType := resp.Header.Get("Content-Type"); contentType == "text/event-stream" {
+				t.Errorf("%v: expected the response not to be an event stream", tt.err)
+			}
+			if connected {
+				t.Errorf("%v: expected OnConnect not to be called", tt.err)
+			}
+		}
+	})
+
+	t.Run("adds headers", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			return &HTTPError{Code: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"30"}}}
+		})
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if retry := resp.Header.Get("Retry-After"); retry != "30" {
+			t.Errorf("expected Retry-After %q, but got %q", "30", retry)
+		}
+	})
+
+	t.Run("formats errors", func(t *testing.T) {
+		t.Parallel()
+
+		expected := "sse: request rejected: 403 Forbidden: not a member"
+		if msg := NewHTTPError(http.StatusForbidden, "not a member").Error(); msg != expected {
+			t.Errorf("expected %q, but got %q", expected, msg)
+		}
+	})
+}
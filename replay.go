@@ -0,0 +1,318 @@
+package sse
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultReplayBatchSize is the number of events replayed at a time when Handler.ReplayBatchSize is 0.
+const DefaultReplayBatchSize = 100
+
+// CatchUpEvent is the event name of the event sent instead of replaying events, when a client is
+// too far behind. Its data is Handler.CatchUpURL, with the client's Last-Event-ID added as the
+// CatchUpParam query parameter. See Handler.ReplayLimit.
+const CatchUpEvent = "sse-catch-up"
+
+// CatchUpParam is the query parameter added to Handler.CatchUpURL in a CatchUpEvent.
+const CatchUpParam = "last-event-id"
+
+// ReplayParam is the query parameter clients request a replay-only response with, by setting it to ReplayOnly.
+// See Handler.ReplayOnlyMaxAge.
+const ReplayParam = "replay"
+
+// ReplayOnly is the value of ReplayParam requesting a replay-only response.
+const ReplayOnly = "only"
+
+// DefaultReplayOnlyMaxAge is how long caching proxies may cache a complete replay-only response
+// when Handler.ReplayOnlyMaxAge is 0.
+const DefaultReplayOnlyMaxAge = time.Hour
+
+// ErrUnknownEventID is returned by a ReplaySource if it does not know of the requested event ID,
+// e.g. because it is too old.
+var ErrUnknownEventID = errors.New("sse: unknown event ID")
+
+// ReplaySource provides past events for replaying to clients that reconnect with a Last-Event-ID.
+// See Handler.Replay.
+type ReplaySource interface {
+	// EventsSince returns up to limit events that were sent after the event with ID lastEventID,
+	// oldest first, and whether there are more events after them.
+	// All events returned must have an ID, as replaying continues from the ID of the last event returned.
+	// If lastEventID is not known, ErrUnknownEventID should be returned.
+	EventsSince(ctx context.Context, lastEventID string, limit int) (events []Event, more bool, err error)
+
+	// CountSince returns the number of events that were sent after the event with ID lastEventID.
+	// It is only used when Handler.ReplayLimit is set.
+	// If lastEventID is not known, ErrUnknownEventID should be returned.
+	CountSince(ctx context.Context, lastEventID string) (int, error)
+}
+
+// replay replays events after lastEventID from the Handler's ReplaySource.
+// It returns false if the connection should be closed.
+func (c *conn) replay(ctx context.Context, lastEventID string) bool {
+	src := c.h.Replay
+
+	if c.h.ReplayLimit > 0 {
+		n, err := src.CountSince(ctx, lastEventID)
+		if err == nil && n > c.h.ReplayLimit {
+			return c.send(c.catchUpEvent(lastEventID)) && c.flush()
+		}
+		if err != nil {
+			return c.replayFailed(ctx, lastEventID, err)
+		}
+	}
+
+	batchSize := c.h.ReplayBatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultReplayBatchSize
+	}
+
+	for {
+		events, more, err := src.EventsSince(ctx, lastEventID, batchSize)
+		if err != nil {
+			return c.replayFailed(ctx, lastEventID, err)
+		}
+
+		for _, evt := range events {
+			if !c.send(evt) {
+				return false
+			}
+		}
+		if !c.flush() {
+			return false
+		}
+
+		if !more || len(events) == 0 {
+			return true
+		}
+		lastEventID = events[len(events)-1].ID
+
+		if c.h.ReplayBatchDelay > 0 {
+			timer := time.NewTimer(c.h.ReplayBatchDelay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return false
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// replayFailed sends a CatchUpEvent if err is ErrUnknownEventID, and a CatchUpURL is configured.
+// Other errors end replaying, but leave the connection open, unless ctx is done.
+func (c *conn) replayFailed(ctx context.Context, lastEventID string, err error) bool {
+	if errors.Is(err, ErrUnknownEventID) && c.h.CatchUpURL != "" {
+		return c.send(c.catchUpEvent(lastEventID)) && c.flush()
+	}
+	return ctx.Err() == nil
+}
+
+// serveReplayOnly responds with the events after lastEventID from the Handler's ReplaySource, as a complete
+// response rather than a stream, unless the NewEventStreamHandler rejected the request with err, which is
+// responded to as it would be for a stream. See Handler.ReplayOnlyMaxAge.
+// The events are sent as they would be to a stream, e.g. only those behind a flag enabled for the client.
+func (c *conn) serveReplayOnly(w http.ResponseWriter, lastEventID string, err error) {
+	h := c.h
+
+	var httpErr *HTTPError
+	switch {
+	case errors.Is(err, ErrNoReconnect):
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case errors.As(err, &httpErr):
+		httpErr.write(w)
+		return
+	case err != nil:
+		if !errors.Is(err, errPanic) {
+			c.log(slog.LevelError, "stream handler failed", err)
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	limit := h.ReplayBatchSize
+	if limit <= 0 {
+		limit = DefaultReplayBatchSize
+	}
+
+	events, _, err := h.Replay.EventsSince(c.ctx, lastEventID, limit)
+	if errors.Is(err, ErrUnknownEventID) {
+		http.Error(w, fmt.Sprintf("Unknown event ID %q", lastEventID), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		c.log(slog.LevelError, "replay failed", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// the events are written to the body, rather than the client, to respond with its Content-Length
+	var body bytes.Buffer
+	c.w = &body
+	personal := false
+	for _, evt := range events {
+		personal = personal || evt.Flag != "" && h.Flags != nil || len(evt.Variants) > 0 && h.Cohort != nil
+		if !c.send(evt) {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+	body.Write(c.buf.Bytes())
+	c.buf.Reset()
+
+	// a full page of events after an ID never changes, unlike one that later events will be added to,
+	// though it may only be the same for the client it was sent to
+	if len(events) == limit {
+		maxAge := h.ReplayOnlyMaxAge
+		if maxAge <= 0 {
+			maxAge = DefaultReplayOnlyMaxAge
+		}
+		scope := "public"
+		if personal || h.Localizer != nil {
+			scope = "private"
+		}
+		w.Header().Set("Cache-Control", scope+", max-age="+strconv.Itoa(int(maxAge/time.Second)))
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body.Bytes())
+}
+
+func (c *conn) catchUpEvent(lastEventID string) Event {
+	sep := "?"
+	if strings.Contains(c.h.CatchUpURL, "?") {
+		sep = "&"
+	}
+
+	return Event{
+		Event: CatchUpEvent,
+		Data:  []byte(c.h.CatchUpURL + sep + CatchUpParam + "=" + url.QueryEscape(lastEventID)),
+	}
+}
+
+// ReplayBuffer is an in-memory ReplaySource, retaining a fixed number of the most recent events.
+// It is safe for concurrent use.
+type ReplayBuffer struct {
+	// Allocator, if not nil, accounts for the memory held by retained events.
+	// If it has a limit, the oldest events are evicted early to stay within it.
+	// If nil, DefaultAllocator is used. It must not be changed after the first call to Add.
+	Allocator Allocator
+
+	mu     sync.RWMutex
+	events []Event
+	start  uint64 // sequence number of the oldest retained event
+	next   uint64 // sequence number of the next event added
+	seqs   map[string]uint64
+}
+
+// NewReplayBuffer returns a *ReplayBuffer that retains up to size events.
+func NewReplayBuffer(size int) *ReplayBuffer {
+	if size < 1 {
+		size = 1
+	}
+
+	return &ReplayBuffer{
+		events: make([]Event, size),
+		seqs:   make(map[string]uint64, size),
+	}
+}
+
+// Add retains evt for replaying, evicting the oldest event if the buffer is full.
+// Events without an ID cannot be resumed from, so they are ignored.
+// evt must not have a DataReader, and its Data must not be modified afterwards.
+//
+// If retaining evt would exceed the Allocator's limit even after evicting all other events,
+// it is not retained, and ErrMemoryLimit is returned.
+func (b *ReplayBuffer) Add(evt Event) error {
+	if evt.ID == "" {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	alloc := b.allocator()
+	n := eventSize(&evt)
+	for !alloc.Reserve(n) {
+		if b.next == b.start {
+			return ErrMemoryLimit
+		}
+		b.evictOldest(alloc)
+	}
+
+	size := uint64(len(b.events))
+	if b.next-b.start == size {
+		b.evictOldest(alloc)
+	}
+
+	b.events[b.next%size] = evt
+	b.seqs[evt.ID] = b.next
+	b.next++
+	return nil
+}
+
+func (b *ReplayBuffer) evictOldest(alloc Allocator) {
+	oldest := &b.events[b.start%uint64(len(b.events))]
+	if b.seqs[oldest.ID] == b.start {
+		delete(b.seqs, oldest.ID)
+	}
+	alloc.Release(eventSize(oldest))
+	*oldest = Event{}
+	b.start++
+}
+
+func (b *ReplayBuffer) allocator() Allocator {
+	if b.Allocator != nil {
+		return b.Allocator
+	}
+	return DefaultAllocator
+}
+
+// EventsSince implements ReplaySource.
+func (b *ReplayBuffer) EventsSince(_ context.Context, lastEventID string, limit int) ([]Event, bool, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	seq, ok := b.seqs[lastEventID]
+	if !ok {
+		return nil, false, ErrUnknownEventID
+	}
+
+	from := seq + 1
+	to := b.next
+	if uint64(limit) < to-from {
+		to = from + uint64(limit)
+	}
+
+	size := uint64(len(b.events))
+	events := make([]Event, 0, to-from)
+	for i := from; i < to; i++ {
+		events = append(events, b.events[i%size])
+	}
+
+	return events, to < b.next, nil
+}
+
+// CountSince implements ReplaySource.
+func (b *ReplayBuffer) CountSince(_ context.Context, lastEventID string) (int, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	seq, ok := b.seqs[lastEventID]
+	if !ok {
+		return 0, ErrUnknownEventID
+	}
+	return int(b.next - seq - 1), nil
+}
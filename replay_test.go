@@ -0,0 +1,270 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplayBuffer(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	b := NewReplayBuffer(5)
+	for i := 1; i <= 8; i++ {
+		b.Add(Event{ID: strconv.Itoa(i), Data: []byte("event " + strconv.Itoa(i))})
+	}
+	b.Add(Event{Data: []byte("no id")})
+
+	t.Run("evicts oldest events", func(t *testing.T) {
+		t.Parallel()
+
+		if _, _, err := b.EventsSince(ctx, "3", 10); !errors.Is(err, ErrUnknownEventID) {
+			t.Errorf("expected ErrUnknownEventID, but got %v", err)
+		}
+
+		if _, err := b.CountSince(ctx, "3"); !errors.Is(err, ErrUnknownEventID) {
+			t.Errorf("expected ErrUnknownEventID, but got %v", err)
+		}
+	})
+
+	t.Run("returns events since ID", func(t *testing.T) {
+		t.Parallel()
+
+		events, more, err := b.EventsSince(ctx, "4", 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 2 || events[0].ID != "5" || events[1].ID != "6" || !more {
+			t.Errorf("expected events 5 and 6 with more, but got %+v (more = %v)", events, more)
+		}
+
+		events, more, err = b.EventsSince(ctx, "6", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 2 || events[0].ID != "7" || events[1].ID != "8" || more {
+			t.Errorf("expected events 7 and 8 without more, but got %+v (more = %v)", events, more)
+		}
+
+		events, more, err = b.EventsSince(ctx, "8", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 0 || more {
+			t.Errorf("expected no events, but got %+v (more = %v)", events, more)
+		}
+	})
+
+	t.Run("counts events since ID", func(t *testing.T) {
+		t.Parallel()
+
+		n, err := b.CountSince(ctx, "5")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 3 {
+			t.Errorf("expected 3 events, but got %d", n)
+		}
+	})
+}
+
+func TestHandlerReplay(t *testing.T) {
+	t.Parallel()
+
+	buf := NewReplayBuffer(100)
+	for i := 1; i <= 10; i++ {
+		buf.Add(Event{ID: strconv.Itoa(i), Data: []byte("replayed")})
+	}
+
+	newHandler := func() *Handler {
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			go func() {
+				stream.Send(Event{ID: "11", Data: []byte("live")})
+				stream.Close()
+			}()
+			return nil
+		})
+		h.Replay = buf
+		h.ReplayBatchSize = 3
+		h.ReplayBatchDelay = time.Millisecond
+		return h
+	}
+
+	get := func(t *testing.T, h *Handler, lastEventID string) string {
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal("failed to read response body:", err)
+		}
+		return string(body)
+	}
+
+	t.Run("replays missed events in order", func(t *testing.T) {
+		t.Parallel()
+
+		body := get(t, newHandler(), "4")
+
+		var expected strings.Builder
+		for i := 5; i <= 10; i++ {
+			expected.WriteString("data:replayed\nid:" + strconv.Itoa(i) + "\n\n")
+		}
+		expected.WriteString("data:live\nid:11\n\n")
+
+		if body != expected.String() {
+			t.Errorf("expected response body %q, but got %q", expected.String(), body)
+		}
+	})
+
+	t.Run("serves replay-only responses", func(t *testing.T) {
+		t.Parallel()
+
+		srv := httptest.NewServer(newHandler())
+		t.Cleanup(srv.Close)
+
+		for _, tt := range []struct {
+			query        string
+			status       int
+			cacheControl string
+			body         string
+		}{
+			{"?replay=only&last-event-id=4", http.StatusOK, "public, max-age=3600",
+				"data:replayed\nid:5\n\ndata:replayed\nid:6\n\ndata:replayed\nid:7\n\n"},
+			{"?replay=only&last-event-id=8", http.StatusOK, "no-cache",
+				"data:replayed\nid:9\n\ndata:replayed\nid:10\n\n"},
+			{"?replay=only&last-event-id=unknown", http.StatusNotFound, "", ""},
+		} {
+			resp, err := srv.Client().Get(srv.URL + tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatal("failed to read response body:", err)
+			}
+
+			if resp.StatusCode != tt.status {
+				t.Errorf("%s: expected status %d, but got %d", tt.query, tt.status, resp.StatusCode)
+			}
+			if tt.status != http.StatusOK {
+				continue
+			}
+
+			if cc := resp.Header.Get("Cache-Control"); cc != tt.cacheControl {
+				t.Errorf("%s: expected Cache-Control %q, but got %q", tt.query, tt.cacheControl, cc)
+			}
+			if resp.ContentLength != int64(len(tt.body)) {
+				t.Errorf("%s: expected Content-Length %d, but got %d", tt.query, len(tt.body), resp.ContentLength)
+			}
+			if string(body) != tt.body {
+				t.Errorf("%s: expected response body %q, but got %q", tt.query, tt.body, body)
+			}
+		}
+	})
+
+	t.Run("admits replay-only requests as streams", func(t *testing.T) {
+		t.Parallel()
+
+		buf := NewReplayBuffer(10)
+		buf.Add(Event{ID: "1", Data: []byte("public")})
+		buf.Add(Event{ID: "2", Data: []byte("secret"), Flag: "beta"})
+		buf.Add(Event{ID: "3", Data: []byte("public")})
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			if stream.Request().Header.Get("Authorization") == "" {
+				return NewHTTPError(http.StatusUnauthorized, "")
+			}
+			stream.Go(func(ctx context.Context) error { return stream.Send(Event{Data: []byte("live")}) })
+			return nil
+		})
+		h.Replay = buf
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+
+		for _, tt := range []struct {
+			name   string
+			auth   string
+			status int
+			body   string
+		}{
+			{"unauthorized", "", http.StatusUnauthorized, ""},
+			{"authorized", "Bearer token", http.StatusOK, "data:public\nid:3\n\n"},
+		} {
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+"?replay=only&last-event-id=1", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Errorf("%s: expected status %d, but got %d", tt.name, tt.status, resp.StatusCode)
+			}
+			if tt.status == http.StatusOK && string(body) != tt.body {
+				t.Errorf("%s: expected response body %q, but got %q", tt.name, tt.body, body)
+			}
+		}
+	})
+
+	t.Run("does not replay without Last-Event-ID", func(t *testing.T) {
+		t.Parallel()
+
+		body := get(t, newHandler(), "")
+		if expected := "data:live\nid:11\n\n"; body != expected {
+			t.Errorf("expected response body %q, but got %q", expected, body)
+		}
+	})
+
+	t.Run("directs far behind clients to catch up", func(t *testing.T) {
+		t.Parallel()
+
+		h := newHandler()
+		h.ReplayLimit = 5
+		h.CatchUpURL = "/catch-up?topic=a"
+
+		body := get(t, h, "2")
+		expected := "event:" + CatchUpEvent + "\ndata:/catch-up?topic=a&" + CatchUpParam + "=2\n\n" +
+			"data:live\nid:11\n\n"
+		if body != expected {
+			t.Errorf("expected response body %q, but got %q", expected, body)
+		}
+	})
+
+	t.Run("directs clients with unknown IDs to catch up", func(t *testing.T) {
+		t.Parallel()
+
+		h := newHandler()
+		h.CatchUpURL = "/catch-up"
+
+		body := get(t, h, "unknown")
+		expected := "event:" + CatchUpEvent + "\ndata:/catch-up?" + CatchUpParam + "=unknown\n\n" +
+			"data:live\nid:11\n\n"
+		if body != expected {
+			t.Errorf("expected response body %q, but got %q", expected, body)
+		}
+	})
+}
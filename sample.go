@@ -0,0 +1,125 @@
+package sse
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// SampleEvery returns a Transform that delivers only every nth event: the first, the (n+1)th, and so on.
+// If n is 1 or less, every event is delivered.
+func SampleEvery(n int) Transform {
+	return func(src EventSource) EventSource {
+		return EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+			var seen int
+			return src.Stream(ctx, func(evt Event) error {
+				seen++
+				if n > 1 && (seen-1)%n != 0 {
+					return nil
+				}
+				return send(evt)
+			})
+		})
+	}
+}
+
+// MinInterval returns a Transform that drops events produced less than interval after the last one delivered.
+// Use LatestWins instead to deliver the latest state at the end of each interval.
+func MinInterval(interval time.Duration) Transform {
+	return func(src EventSource) EventSource {
+		return EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+			var last time.Time
+			return src.Stream(ctx, func(evt Event) error {
+				now := time.Now()
+				if !last.IsZero() && now.Sub(last) < interval {
+					return nil
+				}
+				last = now
+				return send(evt)
+			})
+		})
+	}
+}
+
+// Reservoir returns a Transform that delivers up to size events chosen uniformly at random from those
+// produced during each interval, at the end of the interval, in the order they were produced.
+// Unlike SampleEvery, it bounds the rate of events however fast they are produced.
+// If interval is not positive, its EventSources fail with an error wrapping ErrInvalidInterval.
+func Reservoir(size int, interval time.Duration) Transform {
+	if interval <= 0 {
+		return failTransform(fmt.Errorf("%w: %s is not positive", ErrInvalidInterval, interval))
+	}
+
+	return func(src EventSource) EventSource {
+		return EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+			// each event is kept along with its position, to restore their order
+			type sample struct {
+				evt Event
+				pos int
+			}
+			var (
+				samples []sample
+				seen    int
+			)
+
+			return windowed(ctx, src, interval, send,
+				func(evt Event, _ time.Time) {
+					seen++
+					if len(samples) < size {
+						samples = append(samples, sample{evt, seen})
+					} else if i := rand.Intn(seen); i < size {
+						samples[i] = sample{evt, seen}
+					}
+				},
+				func(time.Time) []Event {
+					sort.Slice(samples, func(i, j int) bool { return samples[i].pos < samples[j].pos })
+
+					events := make([]Event, len(samples))
+					for i := range samples {
+						events[i] = samples[i].evt
+					}
+					samples, seen = samples[:0], 0
+					return events
+				})
+		})
+	}
+}
+
+// LatestWins returns a Transform that delivers, at the end of each interval, only the latest event
+// produced during it with each Event name, in the order the names were first produced.
+// This suits events that each carry the full state of something, so that earlier ones are superseded.
+// If interval is not positive, its EventSources fail with an error wrapping ErrInvalidInterval.
+func LatestWins(interval time.Duration) Transform {
+	if interval <= 0 {
+		return failTransform(fmt.Errorf("%w: %s is not positive", ErrInvalidInterval, interval))
+	}
+
+	return func(src EventSource) EventSource {
+		return EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+			var (
+				latest []Event
+				index  = make(map[string]int)
+			)
+
+			return windowed(ctx, src, interval, send,
+				func(evt Event, _ time.Time) {
+					if i, ok := index[evt.Event]; ok {
+						latest[i] = evt
+						return
+					}
+					index[evt.Event] = len(latest)
+					latest = append(latest, evt)
+				},
+				func(time.Time) []Event {
+					events := latest
+					latest = nil
+					for name := range index {
+						delete(index, name)
+					}
+					return events
+				})
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// numberedSource returns an EventSource producing n events, with IDs 1 to n, named by name(i).
+func numberedSource(name func(i int) string, n int) EventSource {
+	return EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+		for i := 1; i <= n; i++ {
+			if err := send(Event{Event: name(i), ID: strconv.Itoa(i)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func noName(int) string { return "" }
+
+func collectEvents(t *testing.T, src EventSource) []Event {
+	t.Helper()
+
+	var events []Event
+	if err := src.Stream(context.Background(), func(evt Event) error {
+		events = append(events, evt)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestSampleEvery(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		n        int
+		expected string
+	}{
+		{0, "1 2 3 4 5 6 7"},
+		{1, "1 2 3 4 5 6 7"},
+		{3, "1 4 7"},
+	} {
+		events := collectEvents(t, SampleEvery(tc.n)(numberedSource(noName, 7)))
+		if ids := eventIDs(events); ids != tc.expected {
+			t.Errorf("%d: expected events %q, but got %q", tc.n, tc.expected, ids)
+		}
+	}
+}
+
+func TestMinInterval(t *testing.T) {
+	t.Parallel()
+
+	events := collectEvents(t, MinInterval(time.Hour)(numberedSource(noName, 5)))
+	if ids := eventIDs(events); ids != "1" {
+		t.Errorf("expected events %q, but got %q", "1", ids)
+	}
+
+	events = collectEvents(t, MinInterval(0)(numberedSource(noName, 5)))
+	if ids := eventIDs(events); ids != "1 2 3 4 5" {
+		t.Errorf("expected events %q, but got %q", "1 2 3 4 5", ids)
+	}
+}
+
+func TestReservoir(t *testing.T) {
+	t.Parallel()
+
+	t.Run("samples up to size events in order", func(t *testing.T) {
+		t.Parallel()
+
+		events := collectEvents(t, Reservoir(10, time.Hour)(numberedSource(noName, 1000)))
+		if len(events) != 10 {
+			t.Fatalf("expected 10 events, but got %d", len(events))
+		}
+
+		prev := 0
+		for _, evt := range events {
+			id, _ := strconv.Atoi(evt.ID)
+			if id <= prev || id > 1000 {
+				t.Errorf("expected events in order, but got %q", eventIDs(events))
+				break
+			}
+			prev = id
+		}
+	})
+
+	t.Run("delivers every event when there are fewer than size", func(t *testing.T) {
+		t.Parallel()
+
+		events := collectEvents(t, Reservoir(10, time.Hour)(numberedSource(noName, 3)))
+		if ids := eventIDs(events); ids != "1 2 3" {
+			t.Errorf("expected events %q, but got %q", "1 2 3", ids)
+		}
+	})
+
+	t.Run("delivers samples at the end of each interval", func(t *testing.T) {
+		t.Parallel()
+
+		events := make(chan Event)
+		go func() {
+			events <- Event{ID: "1"}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var received []Event
+		Reservoir(1, 10*time.Millisecond)(ChanSource(events)).Stream(ctx, func(evt Event) error {
+			received = append(received, evt)
+			cancel()
+			return nil
+		})
+		if ids := eventIDs(received); ids != "1" {
+			t.Errorf("expected events %q, but got %q", "1", ids)
+		}
+	})
+}
+
+func TestLatestWins(t *testing.T) {
+	t.Parallel()
+
+	// events alternate between two names
+	src := numberedSource(func(i int) string { return []string{"odd", "even"}[(i+1)%2] }, 5)
+
+	events := collectEvents(t, LatestWins(time.Hour)(src))
+
+	var got []string
+	for _, evt := range events {
+		got = append(got, evt.Event+":"+evt.ID)
+	}
+	if expected := "odd:5 even:4"; strings.Join(got, " ") != expected {
+		t.Errorf("expected events %q, but got %q", expected, got)
+	}
+}
+
+func TestWindowedInvalidInterval(t *testing.T) {
+	t.Parallel()
+
+	for name, transform := range map[string]Transform{
+		"Reservoir zero":      Reservoir(3, 0),
+		"Reservoir negative":  Reservoir(3, -time.Second),
+		"LatestWins zero":     LatestWins(0),
+		"LatestWins negative": LatestWins(-time.Second),
+	} {
+		err := transform(numberedSource(noName, 3)).Stream(context.Background(), func(Event) error { return nil })
+		if !errors.Is(err, ErrInvalidInterval) {
+			t.Errorf("%s: expected ErrInvalidInterval, but got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package sse
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTarpitDelay is the default Handler.TarpitDelay.
+const DefaultTarpitDelay = 30 * time.Second
+
+// ScreenDecision is what a Handler does with a request, as decided by Handler.Screen.
+type ScreenDecision int
+
+const (
+	// ScreenAllow serves the request as usual.
+	ScreenAllow ScreenDecision = iota
+
+	// ScreenDeny rejects the request with 403 Forbidden.
+	ScreenDeny
+
+	// ScreenTarpit holds the request open without responding for Handler.TarpitDelay, and then rejects it
+	// as with ScreenDeny, so that an abusive client is slowed down, rather than reconnecting straight away.
+	ScreenTarpit
+)
+
+// Fingerprint describes the client making a request, for abuse detection, see Handler.Screen.
+type Fingerprint struct {
+	// IP is the client's IP address, from the request's RemoteAddr.
+	// Behind a reverse proxy, RemoteAddr is the proxy's, unless it is rewritten by a middleware that trusts it.
+	IP string
+
+	// UserAgent is the request's User-Agent header.
+	UserAgent string
+
+	// TLS is the state of the request's TLS connection, or nil if it was not made over TLS.
+	TLS *tls.ConnectionState
+
+	// TLSFingerprint identifies the TLS implementation of the client, from its ClientHello,
+	// if the server was configured by TLSFingerprints. Otherwise, it is empty.
+	TLSFingerprint string
+
+	// Request is the request, for anything else the hook relies on.
+	Request *http.Request
+}
+
+// NewFingerprint returns the Fingerprint of the client making r.
+func NewFingerprint(r *http.Request) Fingerprint {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+
+	fp := Fingerprint{
+		IP:        ip,
+		UserAgent: r.UserAgent(),
+		TLS:       r.TLS,
+		Request:   r,
+	}
+	if hello, ok := r.Context().Value(tlsFingerprintKey{}).(*clientHello); ok {
+		fp.TLSFingerprint = hello.fingerprint
+	}
+	return fp
+}
+
+// screen applies h.Screen to r, and reports whether it may be served; if not, it has been responded to.
+func (h *Handler) screen(w http.ResponseWriter, r *http.Request) bool {
+	if h.Screen == nil {
+		return true
+	}
+
+	switch h.Screen(NewFingerprint(r)) {
+	case ScreenDeny:
+	case ScreenTarpit:
+		h.tarpit(r)
+	default:
+		return true
+	}
+
+	h.stats.rejected.Add(1)
+	NewHTTPError(http.StatusForbidden, "").write(w)
+	return false
+}
+
+// tarpit waits for h.TarpitDelay, or until r's client disconnects, or h shuts down.
+func (h *Handler) tarpit(r *http.Request) {
+	delay := h.TarpitDelay
+	if delay <= 0 {
+		delay = DefaultTarpitDelay
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-r.Context().Done():
+	case <-h.shutdown.ctx.Done():
+	}
+}
+
+// TLSFingerprints records a fingerprint of each TLS client's ClientHello, so that it is available to
+// Handler.Screen as Fingerprint.TLSFingerprint. The zero TLSFingerprints is ready to use:
+//
+//	var fingerprints sse.TLSFingerprints
+//	fingerprints.Configure(srv)
+//	srv.ListenAndServeTLS(certFile, keyFile)
+//
+// The fingerprint is a hash of the TLS versions, cipher suites, curves, point formats, signature schemes,
+// and application protocols offered by the client, in the order it offered them, in the spirit of JA3;
+// it is not a JA3 fingerprint, since crypto/tls does not expose the ClientHello's extensions.
+type TLSFingerprints struct {
+	// handshakes holds the connections that have not completed their TLS handshake,
+	// keyed by their underlying net.Conn, as seen by tls.Config.GetConfigForClient.
+	handshakes sync.Map
+}
+
+// clientHello holds the fingerprint of a connection's ClientHello, once it has been received.
+type clientHello struct {
+	fingerprint string
+}
+
+type tlsFingerprintKey struct{}
+
+// Configure makes srv record the fingerprints of its TLS clients, wrapping its TLSConfig's GetConfigForClient,
+// and its ConnContext and ConnState hooks, which must not be replaced afterwards.
+// It must be called before srv starts serving.
+func (f *TLSFingerprints) Configure(srv *http.Server) {
+	if srv.TLSConfig == nil {
+		srv.TLSConfig = new(tls.Config)
+	}
+
+	getConfig := srv.TLSConfig.GetConfigForClient
+	srv.TLSConfig.GetConfigForClient = func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+		if hello, ok := f.handshakes.Load(info.Conn); ok {
+			hello.(*clientHello).fingerprint = fingerprintClientHello(info)
+		}
+		if getConfig != nil {
+			return getConfig(info)
+		}
+		return nil, nil
+	}
+
+	connContext := srv.ConnContext
+	srv.ConnContext = func(ctx context.Context, c net.Conn) context.Context {
+		if connContext != nil {
+			ctx = connContext(ctx, c)
+		}
+		if tc, ok := c.(*tls.Conn); ok {
+			hello := new(clientHello)
+			f.handshakes.Store(tc.NetConn(), hello)
+			ctx = context.WithValue(ctx, tlsFingerprintKey{}, hello)
+		}
+		return ctx
+	}
+
+	connState := srv.ConnState
+	srv.ConnState = func(c net.Conn, state http.ConnState) {
+		// connections only become active once their handshake is complete
+		if tc, ok := c.(*tls.Conn); ok && state != http.StateNew {
+			f.handshakes.Delete(tc.NetConn())
+		}
+		if connState != nil {
+			connState(c, state)
+		}
+	}
+}
+
+// fingerprintClientHello returns the fingerprint of info, see TLSFingerprints.
+func fingerprintClientHello(info *tls.ClientHelloInfo) string {
+	var b strings.Builder
+	writeList := func(n int, value func(i int) uint16) {
+		first := true
+		for i := 0; i < n; i++ {
+			v := value(i)
+			if isGREASE(v) {
+				continue
+			}
+			if !first {
+				b.WriteByte('-')
+			}
+			b.WriteString(strconv.Itoa(int(v)))
+			first = false
+		}
+		b.WriteByte(',')
+	}
+
+	writeList(len(info.SupportedVersions), func(i int) uint16 { return info.SupportedVersions[i] })
+	writeList(len(info.CipherSuites), func(i int) uint16 { return info.CipherSuites[i] })
+	writeList(len(info.SupportedCurves), func(i int) uint16 { return uint16(info.SupportedCurves[i]) })
+	writeList(len(info.SupportedPoints), func(i int) uint16 { return uint16(info.SupportedPoints[i]) })
+	writeList(len(info.SignatureSchemes), func(i int) uint16 { return uint16(info.SignatureSchemes[i]) })
+	b.WriteString(strings.Join(info.SupportedProtos, "-"))
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:16])
+}
+
+// isGREASE reports whether v is a GREASE value (RFC 8701), which clients offer at random,
+// and so are left out of fingerprints.
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
@@ -0,0 +1,183 @@
+package sse
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestScreen(t *testing.T) {
+	t.Parallel()
+
+	stream := func(stream EventStream, lastEventID string) error {
+		stream.Close()
+		return nil
+	}
+
+	get := func(t *testing.T, client *http.Client, url string) *http.Response {
+		t.Helper()
+
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("User-Agent", "scraper/1.0")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("allows and denies requests", func(t *testing.T) {
+		t.Parallel()
+
+		fingerprints := make(chan Fingerprint, 2)
+		h := NewHandler(stream, WithScreen(func(fp Fingerprint) ScreenDecision {
+			fingerprints <- fp
+			if fp.Request.URL.Query().Get("deny") != "" {
+				return ScreenDeny
+			}
+			return ScreenAllow
+		}))
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+
+		if resp := get(t, srv.Client(), srv.URL); resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status %d, but got %d", http.StatusOK, resp.StatusCode)
+		}
+		if resp := get(t, srv.Client(), srv.URL+"?deny=1"); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("expected status %d, but got %d", http.StatusForbidden, resp.StatusCode)
+		}
+
+		fp := <-fingerprints
+		if fp.IP != "127.0.0.1" || fp.UserAgent != "scraper/1.0" || fp.TLS != nil || fp.TLSFingerprint != "" {
+			t.Errorf("unexpected fingerprint: %+v", fp)
+		}
+		if stats := h.Stats(); stats.Connections != 1 || stats.Rejected != 1 {
+			t.Errorf("expected the denied request to be rejected, but got %d connections, and %d rejections",
+				stats.Connections, stats.Rejected)
+		}
+	})
+
+	t.Run("tarpits requests", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(stream, WithScreen(func(Fingerprint) ScreenDecision { return ScreenTarpit }))
+		h.TarpitDelay = 50 * time.Millisecond
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+
+		start := time.Now()
+		if resp := get(t, srv.Client(), srv.URL); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("expected status %d, but got %d", http.StatusForbidden, resp.StatusCode)
+		}
+		if elapsed := time.Since(start); elapsed < h.TarpitDelay {
+			t.Errorf("expected the response to be delayed by at least %v, but got %v", h.TarpitDelay, elapsed)
+		}
+	})
+
+	t.Run("releases tarpitted requests on shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		screened := make(chan struct{})
+		h := NewHandler(stream, WithScreen(func(Fingerprint) ScreenDecision {
+			close(screened)
+			return ScreenTarpit
+		}))
+		h.TarpitDelay = time.Hour
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+
+		done := make(chan *http.Response, 1)
+		go func() {
+			resp, err := srv.Client().Get(srv.URL)
+			if err != nil {
+				t.Error(err)
+				close(done)
+				return
+			}
+			resp.Body.Close()
+			done <- resp
+		}()
+
+		<-screened
+		if err := h.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case resp, ok := <-done:
+			if ok && resp.StatusCode != http.StatusForbidden {
+				t.Errorf("expected status %d, but got %d", http.StatusForbidden, resp.StatusCode)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the tarpitted request to be released")
+		}
+	})
+
+	t.Run("includes TLS fingerprints", func(t *testing.T) {
+		t.Parallel()
+
+		fingerprints := make(chan Fingerprint, 2)
+		h := NewHandler(stream, WithScreen(func(fp Fingerprint) ScreenDecision {
+			fingerprints <- fp
+			return ScreenAllow
+		}))
+
+		var tlsFingerprints TLSFingerprints
+		srv := httptest.NewUnstartedServer(h)
+		tlsFingerprints.Configure(srv.Config)
+		srv.TLS = srv.Config.TLSConfig
+		srv.StartTLS()
+		t.Cleanup(srv.Close)
+
+		for i := 0; i < 2; i++ {
+			client := srv.Client()
+			client.Transport.(*http.Transport).DisableKeepAlives = true
+			get(t, client, srv.URL)
+		}
+
+		first, second := <-fingerprints, <-fingerprints
+		if first.TLS == nil {
+			t.Error("expected the TLS connection state, but got none")
+		}
+		if len(first.TLSFingerprint) != 32 {
+			t.Errorf("expected a TLS fingerprint, but got %q", first.TLSFingerprint)
+		}
+		if first.TLSFingerprint != second.TLSFingerprint {
+			t.Errorf("expected the same client to have the same fingerprint, but got %q and %q",
+				first.TLSFingerprint, second.TLSFingerprint)
+		}
+
+		tlsFingerprints.handshakes.Range(func(key, _ interface{}) bool {
+			t.Errorf("expected completed handshakes to be forgotten, but got %v", key)
+			return true
+		})
+	})
+
+	t.Run("fingerprints ignore GREASE values", func(t *testing.T) {
+		t.Parallel()
+
+		hello := &tls.ClientHelloInfo{
+			CipherSuites:      []uint16{tls.TLS_AES_128_GCM_SHA256, tls.TLS_CHACHA20_POLY1305_SHA256},
+			SupportedCurves:   []tls.CurveID{tls.X25519},
+			SupportedVersions: []uint16{tls.VersionTLS13},
+			SupportedProtos:   []string{"h2", "http/1.1"},
+		}
+		greased := *hello
+		greased.CipherSuites = []uint16{0x1a1a, tls.TLS_AES_128_GCM_SHA256, tls.TLS_CHACHA20_POLY1305_SHA256}
+		greased.SupportedVersions = []uint16{0xfafa, tls.VersionTLS13}
+
+		if a, b := fingerprintClientHello(hello), fingerprintClientHello(&greased); a != b {
+			t.Errorf("expected the same fingerprint, but got %q and %q", a, b)
+		}
+
+		reordered := *hello
+		reordered.CipherSuites = []uint16{tls.TLS_CHACHA20_POLY1305_SHA256, tls.TLS_AES_128_GCM_SHA256}
+		if a, b := fingerprintClientHello(hello), fingerprintClientHello(&reordered); a == b {
+			t.Errorf("expected different fingerprints for different cipher suite orders, but got %q", a)
+		}
+	})
+}
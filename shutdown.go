@@ -0,0 +1,106 @@
+package sse
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// handlerShutdown signals that a Handler is shutting down. Each EventStream's Context is canceled
+// along with ctx.
+type handlerShutdown struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	stopped bool
+	active  sync.WaitGroup
+}
+
+func newHandlerShutdown() *handlerShutdown {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &handlerShutdown{ctx: ctx, cancel: cancel}
+}
+
+// enter records a new connection, or returns false if the Handler is shutting down, and it must be rejected.
+func (s *handlerShutdown) enter() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return false
+	}
+	s.active.Add(1)
+	return true
+}
+
+// leave records that a connection recorded by enter has ended.
+func (s *handlerShutdown) leave() {
+	s.active.Done()
+}
+
+// stop makes the Handler reject new connections, and ends those it is serving.
+func (s *handlerShutdown) stop() {
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+
+	s.cancel()
+}
+
+// Shutdown shuts h's streams down gracefully, as described by RegisterOnShutdown, and waits for them to end,
+// or for ctx to be done, in which case it returns ctx's error.
+// Once Shutdown is called, new connections are rejected with 503 Service Unavailable, with a Retry-After
+// header if ShutdownRetry is set.
+//
+// Shutdown does not shut down the http.Server serving h, so that it can be used when h is only one of
+// the server's handlers, e.g. before calling the server's Shutdown.
+func (h *Handler) Shutdown(ctx context.Context) error {
+	h.shutdown.stop()
+
+	drained := make(chan struct{})
+	go func() {
+		h.shutdown.active.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rejectShuttingDown responds to a request for a new stream while h is shutting down.
+func (h *Handler) rejectShuttingDown(w http.ResponseWriter) {
+	err := NewHTTPError(http.StatusServiceUnavailable, "shutting down")
+	if retry := h.shutdownRetry(); retry > 0 {
+		err.Header = http.Header{"Retry-After": {strconv.Itoa(int((retry + time.Second - 1) / time.Second))}}
+	}
+	err.write(w)
+}
+
+// RegisterOnShutdown makes srv shut down h's streams gracefully once srv.Shutdown is called,
+// rather than leaving clients to find their connections reset.
+//
+// Each EventStream's Context is canceled, so that its producers stop. The events already queued
+// are sent, followed by a ReasonShutdown StreamErrorEvent, with a reconnection delay if ShutdownRetry
+// is set, and the connection is closed, which lets srv.Shutdown complete. New connections are rejected,
+// as by Shutdown.
+func (h *Handler) RegisterOnShutdown(srv *http.Server) {
+	srv.RegisterOnShutdown(h.shutdown.stop)
+}
+
+// shutdownRetry returns the reconnection delay to send a client when shutting down. See ShutdownRetry.
+func (h *Handler) shutdownRetry() time.Duration {
+	if h.ShutdownRetry <= 0 {
+		return 0
+	}
+
+	half := h.ShutdownRetry / 2
+	return half + time.Duration(rand.Int63n(int64(h.ShutdownRetry-half)+1))
+}
@@ -0,0 +1,218 @@
+package sse
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	t.Parallel()
+
+	// producer sends an event, and waits for the stream to end.
+	producer := func(stream EventStream, lastEventID string) error {
+		stream.Go(func(ctx context.Context) error {
+			if err := stream.Send(Event{Data: []byte("before")}); err != nil {
+				return err
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		return nil
+	}
+
+	read := func(t *testing.T, resp *http.Response) string {
+		t.Helper()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal("failed to read response body:", err)
+		}
+		return string(body)
+	}
+
+	for _, park := range []bool{false, true} {
+		park := park
+
+		t.Run("RegisterOnShutdown ends streams gracefully", func(t *testing.T) {
+			t.Parallel()
+
+			h := NewHandler(producer)
+			h.KeepAlive = time.Hour
+			h.Park = park
+			h.ShutdownRetry = 4 * time.Second
+			srv := httptest.NewUnstartedServer(h)
+			h.RegisterOnShutdown(srv.Config)
+			srv.Start()
+			defer srv.Close()
+
+			resp, err := srv.Client().Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			shutdown := make(chan error, 1)
+			go func() {
+				<-time.After(50 * time.Millisecond)
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				shutdown <- srv.Config.Shutdown(ctx)
+			}()
+
+			body := read(t, resp)
+			if !strings.HasPrefix(body, "data:before\n\n") {
+				t.Errorf("park %v: expected queued events to be sent, but got %q", park, body)
+			}
+
+			evt := NewStreamError(ReasonShutdown, "").Event()
+			if !strings.Contains(body, "event:"+evt.Event+"\ndata:"+string(evt.Data)+"\nretry:") {
+				t.Errorf("park %v: expected a shutdown event with a retry delay, but got %q", park, body)
+			}
+			if err := <-shutdown; err != nil {
+				t.Errorf("park %v: expected Shutdown to complete, but got %v", park, err)
+			}
+		})
+	}
+
+	t.Run("BaseContext being done ends streams gracefully", func(t *testing.T) {
+		t.Parallel()
+
+		base, cancelBase := context.WithCancel(context.Background())
+		h := NewHandler(producer)
+		h.BaseContext = func(r *http.Request) context.Context { return base }
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		go func() {
+			<-time.After(50 * time.Millisecond)
+			cancelBase()
+		}()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		evt := NewStreamError(ReasonShutdown, "").Event()
+		expected := "data:before\n\nevent:" + evt.Event + "\ndata:" + string(evt.Data) + "\n\n"
+		if body := read(t, resp); body != expected {
+			t.Errorf("expected response body %q, but got %q", expected, body)
+		}
+	})
+
+	t.Run("spreads reconnection delays", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(nil)
+		h.ShutdownRetry = 10 * time.Second
+
+		seen := make(map[time.Duration]bool)
+		for i := 0; i < 100; i++ {
+			d := h.shutdownRetry()
+			if d < 5*time.Second || d > 10*time.Second {
+				t.Fatalf("expected a delay between 5s and 10s, but got %v", d)
+			}
+			seen[d] = true
+		}
+		if len(seen) < 2 {
+			t.Error("expected delays to vary")
+		}
+	})
+}
+
+func TestHandlerShutdown(t *testing.T) {
+	t.Parallel()
+
+	for _, park := range []bool{false, true} {
+		park := park
+
+		t.Run("ends streams, and waits for them to drain", func(t *testing.T) {
+			t.Parallel()
+
+			h := NewHandler(func(stream EventStream, lastEventID string) error {
+				stream.Go(func(ctx context.Context) error {
+					if err := stream.Send(Event{Data: []byte("before")}); err != nil {
+						return err
+					}
+					<-ctx.Done()
+					return ctx.Err()
+				})
+				return nil
+			})
+			h.KeepAlive = time.Hour
+			h.Park = park
+			h.ShutdownRetry = 4 * time.Second
+			srv := httptest.NewServer(h)
+			t.Cleanup(srv.Close)
+
+			resp, err := srv.Client().Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			shutdown := make(chan error, 1)
+			go func() {
+				<-time.After(50 * time.Millisecond)
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				shutdown <- h.Shutdown(ctx)
+			}()
+
+			body, _ := io.ReadAll(resp.Body)
+			evt := NewStreamError(ReasonShutdown, "").Event()
+			if !strings.HasPrefix(string(body), "data:before\n\nevent:"+evt.Event+"\ndata:"+string(evt.Data)+"\nretry:") {
+				t.Errorf("park %v: expected queued events, then a shutdown event, but got %q", park, body)
+			}
+			if err := <-shutdown; err != nil {
+				t.Errorf("park %v: expected Shutdown to complete, but got %v", park, err)
+			}
+
+			resp, err = srv.Client().Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("park %v: expected new connections to be rejected, but got %s", park, resp.Status)
+			}
+			if retry := resp.Header.Get("Retry-After"); retry == "" {
+				t.Errorf("park %v: expected a Retry-After header", park)
+			}
+		})
+	}
+
+	t.Run("returns once its context is done", func(t *testing.T) {
+		t.Parallel()
+
+		entered, release := make(chan struct{}), make(chan struct{})
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			// ignores the stream's context, so the stream does not end
+			close(entered)
+			<-release
+			return nil
+		})
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+		t.Cleanup(func() { close(release) })
+
+		go func() {
+			if resp, err := srv.Client().Get(srv.URL); err == nil {
+				resp.Body.Close()
+			}
+		}()
+		<-entered
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := h.Shutdown(ctx); err != context.DeadlineExceeded {
+			t.Errorf("expected %v, but got %v", context.DeadlineExceeded, err)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package sse
+
+// DefaultSlabBlockSize is the block size used by a Slab when NewSlab is given a size of 0 or less.
+const DefaultSlabBlockSize = 64 * 1024
+
+// Slab reduces allocations when creating many small events, by carving their Data out of large
+// blocks of memory, so that one allocation is shared by many events.
+//
+// A block is never reused; it is garbage collected once no event's Data refers to it any longer.
+// Payloads larger than a quarter of the block size are allocated on their own, so that a single
+// long-lived payload cannot pin a mostly unused block.
+//
+// A Slab is not safe for concurrent use; typically each producer goroutine has its own.
+type Slab struct {
+	blockSize int
+	block     []byte
+}
+
+// NewSlab returns a *Slab that allocates blocks of blockSize bytes.
+func NewSlab(blockSize int) *Slab {
+	if blockSize <= 0 {
+		blockSize = DefaultSlabBlockSize
+	}
+	return &Slab{blockSize: blockSize}
+}
+
+// Bytes returns a copy of p, allocated from the slab.
+// The copy's capacity is limited to its length, so appending to it never overwrites other data.
+func (s *Slab) Bytes(p []byte) []byte {
+	b := s.alloc(len(p))
+	copy(b, p)
+	return b
+}
+
+// String returns the bytes of str, allocated from the slab. See Bytes.
+func (s *Slab) String(str string) []byte {
+	b := s.alloc(len(str))
+	copy(b, str)
+	return b
+}
+
+// Event returns an Event named event with data copied into the slab.
+func (s *Slab) Event(event, data string) Event {
+	return Event{
+		Event: event,
+		Data:  s.String(data),
+	}
+}
+
+func (s *Slab) alloc(n int) []byte {
+	if n > s.blockSize/4 {
+		return make([]byte, n)
+	}
+
+	if cap(s.block)-len(s.block) < n {
+		s.block = make([]byte, 0, s.blockSize)
+	}
+
+	start := len(s.block)
+	s.block = s.block[:start+n]
+	return s.block[start : start+n : start+n]
+}
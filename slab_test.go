@@ -0,0 +1,77 @@
+package sse
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSlab(t *testing.T) {
+	t.Parallel()
+
+	t.Run("copies data", func(t *testing.T) {
+		t.Parallel()
+
+		s := NewSlab(64)
+		src := []byte("hello")
+		b := s.Bytes(src)
+		src[0] = 'j'
+
+		if string(b) != "hello" {
+			t.Errorf("expected slab copy to be independent of source, but got %q", b)
+		}
+	})
+
+	t.Run("shares blocks between small payloads", func(t *testing.T) {
+		t.Parallel()
+
+		s := NewSlab(64)
+		a := s.String("first")
+		b := s.String("second")
+
+		if &s.block[0] != &a[0] || &s.block[len(a)] != &b[0] {
+			t.Error("expected small payloads to be allocated from the same block")
+		}
+	})
+
+	t.Run("limits capacity", func(t *testing.T) {
+		t.Parallel()
+
+		s := NewSlab(64)
+		a := s.String("first")
+		b := s.String("second")
+		a = append(a, "!!!"...)
+
+		if string(b) != "second" {
+			t.Errorf("expected appending to one payload to not affect another, but got %q", b)
+		}
+		if string(a) != "first!!!" {
+			t.Errorf("expected appended payload, but got %q", a)
+		}
+	})
+
+	t.Run("allocates large payloads separately", func(t *testing.T) {
+		t.Parallel()
+
+		s := NewSlab(64)
+		large := bytes.Repeat([]byte("x"), 32)
+		b := s.Bytes(large)
+
+		if len(s.block) != 0 {
+			t.Errorf("expected large payload to not use the block, but %d bytes were used", len(s.block))
+		}
+		if !bytes.Equal(b, large) {
+			t.Errorf("expected %q, but got %q", large, b)
+		}
+	})
+
+	t.Run("starts new blocks when full", func(t *testing.T) {
+		t.Parallel()
+
+		s := NewSlab(16)
+		for i := 0; i < 10; i++ {
+			if b := s.String("abcd"); string(b) != "abcd" {
+				t.Fatalf("expected %q, but got %q", "abcd", b)
+			}
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package sse
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// SnapshotVersionParam is the query parameter clients that cannot set an If-None-Match header, such as
+// EventSource, present the version of the snapshot they have with. See Handler.Snapshot.
+const SnapshotVersionParam = "snapshot-version"
+
+// SnapshotSource provides the current state of what a stream is about, to send to clients when they connect.
+// See Handler.Snapshot.
+type SnapshotSource interface {
+	// Snapshot returns an event carrying the current state, and its version, which must change whenever the
+	// state does, or be empty if the state is not versioned.
+	Snapshot(ctx context.Context, r *http.Request) (evt Event, version string, err error)
+}
+
+// SnapshotFunc is an adapter to allow the use of ordinary functions as a SnapshotSource.
+type SnapshotFunc func(ctx context.Context, r *http.Request) (Event, string, error)
+
+// Snapshot calls f(ctx, r).
+func (f SnapshotFunc) Snapshot(ctx context.Context, r *http.Request) (Event, string, error) {
+	return f(ctx, r)
+}
+
+// snapshot returns the snapshot to send to the client of r, or nil if it already has the current version,
+// and sets the response's ETag header to the version.
+func (c *conn) snapshot(w http.ResponseWriter, r *http.Request) (*Event, error) {
+	evt, version, err := c.h.Snapshot.Snapshot(c.ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	if version == "" {
+		return &evt, nil
+	}
+
+	w.Header().Set("ETag", `"`+version+`"`)
+	if hasSnapshotVersion(r, version) {
+		return nil, nil
+	}
+	return &evt, nil
+}
+
+// hasSnapshotVersion reports whether the client of r presented version, with an If-None-Match header,
+// or the SnapshotVersionParam query parameter.
+func hasSnapshotVersion(r *http.Request, version string) bool {
+	if r.URL.Query().Get(SnapshotVersionParam) == version {
+		return true
+	}
+
+	for _, header := range r.Header.Values("If-None-Match") {
+		for _, tag := range strings.Split(header, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == `"`+version+`"` {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerSnapshot(t *testing.T) {
+	t.Parallel()
+
+	newHandler := func(version string, err error) *Handler {
+		return NewHandler(func(stream EventStream, lastEventID string) error {
+			stream.Go(func(ctx context.Context) error {
+				return stream.Send(Event{Data: []byte("live")})
+			})
+			return nil
+		}, WithSnapshot(SnapshotFunc(func(ctx context.Context, r *http.Request) (Event, string, error) {
+			return Event{Event: "state", Data: []byte("v" + version)}, version, err
+		})))
+	}
+
+	get := func(t *testing.T, h *Handler, query string, header http.Header) (*http.Response, string) {
+		t.Helper()
+
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+query, nil)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	t.Run("sends the snapshot first", func(t *testing.T) {
+		t.Parallel()
+
+		resp, body := get(t, newHandler("2", nil), "", nil)
+		if expected := "event:state\ndata:v2\n\ndata:live\n\n"; body != expected {
+			t.Errorf("expected %q, but got %q", expected, body)
+		}
+		if etag := resp.Header.Get("ETag"); etag != `"2"` {
+			t.Errorf("expected ETag %q, but got %q", `"2"`, etag)
+		}
+	})
+
+	t.Run("skips the snapshot for clients with the current version", func(t *testing.T) {
+		t.Parallel()
+
+		for _, tt := range []struct {
+			name   string
+			query  string
+			header http.Header
+		}{
+			{"If-None-Match", "", http.Header{"If-None-Match": {`"1", "2"`}}},
+			{"weak If-None-Match", "", http.Header{"If-None-Match": {`W/"2"`}}},
+			{"query parameter", "?" + SnapshotVersionParam + "=2", nil},
+		} {
+			_, body := get(t, newHandler("2", nil), tt.query, tt.header)
+			if expected := "data:live\n\n"; body != expected {
+				t.Errorf("%s: expected %q, but got %q", tt.name, expected, body)
+			}
+		}
+	})
+
+	t.Run("sends changed snapshots", func(t *testing.T) {
+		t.Parallel()
+
+		_, body := get(t, newHandler("3", nil), "", http.Header{"If-None-Match": {`"2"`}})
+		if !strings.HasPrefix(body, "event:state\ndata:v3\n\n") {
+			t.Errorf("expected the new snapshot, but got %q", body)
+		}
+	})
+
+	t.Run("sends unversioned snapshots to every client", func(t *testing.T) {
+		t.Parallel()
+
+		resp, body := get(t, newHandler("", nil), "", http.Header{"If-None-Match": {"*"}})
+		if !strings.HasPrefix(body, "event:state\n") {
+			t.Errorf("expected the snapshot, but got %q", body)
+		}
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			t.Errorf("expected no ETag, but got %q", etag)
+		}
+	})
+
+	t.Run("ends the stream if the snapshot fails", func(t *testing.T) {
+		t.Parallel()
+
+		_, body := get(t, newHandler("1", errors.New("state unavailable")), "", nil)
+		if expected := "event:" + StreamErrorEvent + "\ndata:{\"code\":\"" + ReasonServerError + "\""; !strings.HasPrefix(body, expected) {
+			t.Errorf("expected %q, but got %q", expected, body)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package sse
+
+import (
+	"context"
+	"time"
+)
+
+// EventSource produces events to be sent to a client.
+type EventSource interface {
+	// Stream calls send for each event produced, until there are no more events, ctx is done,
+	// or send returns an error. send blocks until the event has been accepted, and returns an
+	// error if ctx is done first; that error should be returned by Stream.
+	Stream(ctx context.Context, send func(Event) error) error
+}
+
+// EventSourceFunc is an adapter to allow the use of ordinary functions as an EventSource.
+type EventSourceFunc func(ctx context.Context, send func(Event) error) error
+
+// Stream calls f(ctx, send).
+func (f EventSourceFunc) Stream(ctx context.Context, send func(Event) error) error {
+	return f(ctx, send)
+}
+
+// ChanSource returns an EventSource that produces the events received from events, until it is closed.
+func ChanSource(events <-chan Event) EventSource {
+	return EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+
+			case evt, ok := <-events:
+				if !ok {
+					return nil
+				}
+				if err := send(evt); err != nil {
+					return err
+				}
+			}
+		}
+	})
+}
+
+// TickerSource returns an EventSource that produces the event returned by fn every interval.
+func TickerSource(interval time.Duration, fn func(time.Time) Event) EventSource {
+	return EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+
+			case now := <-ticker.C:
+				if err := send(fn(now)); err != nil {
+					return err
+				}
+			}
+		}
+	})
+}
+
+// Combine returns an EventSource that runs each of sources concurrently, and produces the events
+// they produce.
+//
+// Sources take turns: a source that has produced an event waits behind any others that are ready,
+// so a busy source cannot starve the rest.
+// The combined source finishes once all sources have finished. If any source returns an error,
+// or send fails, the others are canceled, and the first error is returned.
+func Combine(sources ...EventSource) EventSource {
+	return EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		events := make(chan Event)
+		errs := make(chan error, len(sources))
+
+		for _, src := range sources {
+			go func(src EventSource) {
+				errs <- src.Stream(ctx, func(evt Event) error {
+					select {
+					case events <- evt:
+						return nil
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				})
+			}(src)
+		}
+
+		var firstErr error
+		for remaining := len(sources); remaining > 0; {
+			select {
+			case evt := <-events:
+				if firstErr != nil {
+					continue
+				}
+				if err := send(evt); err != nil {
+					firstErr = err
+					cancel()
+				}
+
+			case err := <-errs:
+				remaining--
+				if err != nil && firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+			}
+		}
+
+		return firstErr
+	})
+}
+
+// FromSource returns a NewEventStreamHandler that streams the events produced by src to each client,
+// and closes the stream once src finishes. src is canceled when the client disconnects.
+// If src fails, the error is sent to the client, see EventStream.Go.
+func FromSource(src EventSource) NewEventStreamHandler {
+	return func(stream EventStream, lastEventID string) error {
+		stream.Go(func(ctx context.Context) error {
+			return src.Stream(ctx, func(evt Event) error {
+				return stream.send(ctx, evt)
+			})
+		})
+		return nil
+	}
+}
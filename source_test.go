@@ -0,0 +1,160 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCombine(t *testing.T) {
+	t.Parallel()
+
+	t.Run("takes turns between sources", func(t *testing.T) {
+		t.Parallel()
+
+		busy := EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+			for {
+				if err := send(Event{Event: "busy"}); err != nil {
+					return err
+				}
+			}
+		})
+
+		finite := make(chan Event)
+		go func() {
+			for i := 0; i < 10; i++ {
+				finite <- Event{Event: "finite", ID: strconv.Itoa(i)}
+			}
+		}()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var received []Event
+		Combine(busy, ChanSource(finite)).Stream(ctx, func(evt Event) error {
+			received = append(received, evt)
+			if evt.Event == "finite" && evt.ID == "9" {
+				cancel()
+			}
+			return nil
+		})
+
+		var finiteIDs []string
+		for _, evt := range received {
+			if evt.Event == "finite" {
+				finiteIDs = append(finiteIDs, evt.ID)
+			}
+		}
+
+		if expected := "0 1 2 3 4 5 6 7 8 9"; strings.Join(finiteIDs, " ") != expected {
+			t.Errorf("expected finite events %q in order, but got %q", expected, finiteIDs)
+		}
+	})
+
+	t.Run("finishes when all sources finish", func(t *testing.T) {
+		t.Parallel()
+
+		a := make(chan Event, 2)
+		a <- Event{ID: "a1"}
+		a <- Event{ID: "a2"}
+		close(a)
+		b := make(chan Event, 1)
+		b <- Event{ID: "b1"}
+		close(b)
+
+		var n int
+		err := Combine(ChanSource(a), ChanSource(b)).Stream(context.Background(), func(Event) error {
+			n++
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 3 {
+			t.Errorf("expected 3 events, but got %d", n)
+		}
+	})
+
+	t.Run("cancels others on error", func(t *testing.T) {
+		t.Parallel()
+
+		failure := errors.New("failure")
+		failing := EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+			return failure
+		})
+
+		canceled := make(chan struct{})
+		blocking := EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+			<-ctx.Done()
+			close(canceled)
+			return ctx.Err()
+		})
+
+		err := Combine(failing, blocking).Stream(context.Background(), func(Event) error { return nil })
+		if !errors.Is(err, failure) {
+			t.Errorf("expected source's error, but got %v", err)
+		}
+
+		select {
+		case <-canceled:
+		default:
+			t.Error("expected other sources to be canceled")
+		}
+	})
+}
+
+func TestTickerSource(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var n int
+	err := TickerSource(time.Millisecond, func(time.Time) Event {
+		return Event{Event: "tick"}
+	}).Stream(ctx, func(evt Event) error {
+		if n++; n == 3 {
+			cancel()
+		}
+		return nil
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, but got %v", err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 ticks, but got %d", n)
+	}
+}
+
+func TestFromSource(t *testing.T) {
+	t.Parallel()
+
+	events := make(chan Event, 2)
+	events <- Event{Data: []byte("one")}
+	events <- Event{Data: []byte("two")}
+	close(events)
+
+	srv := httptest.NewServer(NewHandler(FromSource(ChanSource(events))))
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal("failed to read response body:", err)
+	}
+
+	if expected := "data:one\n\ndata:two\n\n"; string(body) != expected {
+		t.Errorf("expected response body %q, but got %q", expected, body)
+	}
+}
@@ -4,10 +4,18 @@ package sse
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,6 +26,52 @@ type Event struct {
 	Data  []byte
 	ID    string
 	Retry time.Duration
+
+	// ResetID, if set, sends an empty ID, which resets the client's last event ID, so that it sends no
+	// Last-Event-ID header when it reconnects. ID must then be empty. See EventStream.ResetLastEventID.
+	ResetID bool
+
+	// DataReader, if set, is read in chunks while the event is being written, and its contents
+	// are sent as data lines. This avoids having to hold large payloads in memory.
+	// If Data is also set, Data is sent first, and the contents of DataReader start on a new line.
+	//
+	// A DataReader can only be consumed once, so each EventStream must be given its own;
+	// e.g. an io.SectionReader over a shared io.ReaderAt.
+	// If it implements io.Closer, it is closed after being consumed.
+	// If reading from it fails, the connection is closed without completing the event,
+	// so the client discards it.
+	DataReader io.Reader
+
+	// Tags, if not empty, are sent to clients that support ExtTags along with the event, in an envelope
+	// ahead of its data (see TagsPrefix), so that they can route events by tag, e.g. with a Router.
+	// Other clients receive the event without them.
+	// Events can also be filtered by tag on the server, see FilterTags, and TopicSubscription.Tags.
+	Tags []string
+
+	// TraceParent, if not empty, is the W3C Trace Context traceparent of the event, e.g. of the request that
+	// published it, sent to clients that support ExtTrace in an envelope ahead of its data (see TracePrefix),
+	// so that the traces of handling the event on the client continue the publisher's, see TraceContext.
+	// Other clients receive the event without it. EventStream.SendContext, and Bus.Publish, set it from their
+	// context, see ContextWithTraceParent, if it is empty.
+	TraceParent string
+
+	// Comment, if not empty, is sent as comment lines ahead of the event's fields, one per line of Comment,
+	// which clients ignore, e.g. as a debugging marker readable in a network inspector, or a keep-alive
+	// with custom text. An Event with only a Comment is sent as comment lines alone, see EventStream.SendComment.
+	Comment string
+
+	// Flag, if not empty, is the key of the feature flag the event is behind: it is only sent to clients
+	// whose principal (see Handler.Principal) the flag is enabled for, as evaluated by the Handler's Flags
+	// when the event is written to the connection. Flag itself is not sent.
+	Flag string
+
+	// Experiment, along with Variants, makes the event part of an A/B experiment: each client is sent the
+	// variant for the cohort of Experiment Handler.Cohort assigns it to, in place of Data, or Data if there is
+	// no variant for its cohort, or the Handler has no Cohort. Neither is sent.
+	Experiment string
+
+	// Variants holds alternatives to the event's Data, keyed by cohort, see Experiment.
+	Variants map[string][]byte
 }
 
 // Write is a convenience method for including data in the Event.
@@ -26,16 +80,68 @@ func (e *Event) Write(b []byte) (int, error) {
 	return len(b), nil
 }
 
+// ErrStreamClosed is returned when sending an event to an EventStream that has been closed.
+var ErrStreamClosed = errors.New("sse: stream closed")
+
 // EventStream is the main point of interaction with the API.
 // Events may be sent using the Send() method, and any additional
 // context added to the request is provided by the Context() method.
 type EventStream struct {
-	ctx    context.Context
-	events chan Event
+	id        string
+	ctx       context.Context
+	r         *http.Request
+	events    chan queuedEvent
+	queue     *queueAccount
+	settings  *streamSettings
+	waker     *waker
+	producers *producerGroup
+	closer    *streamCloser
+	ended     chan struct{}
+	lagging   *atomic.Bool
+	dropped   *atomic.Uint64
+	timeline  *timeline
+	state     *streamState
+
+	// h is the configuration of the Handler when the stream was started, for reporting dead letters.
+	h *Handler
 }
 
+// queuedEvent is an event queued on an EventStream, along with when it was sent, to measure its latency.
+type queuedEvent struct {
+	Event
+	sent time.Time
+}
+
+// streamCloser coordinates closing an EventStream with the Sends that race with it: Sends hold a read lock
+// while queueing an event, so that the events channel is only closed once none are in flight.
+type streamCloser struct {
+	mu     sync.RWMutex
+	closed bool
+
+	// closing is closed once Close is called, to fail the Sends waiting to queue an event, so that Close
+	// does not wait on them indefinitely.
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+func newStreamCloser() *streamCloser {
+	return &streamCloser{closing: make(chan struct{})}
+}
+
+// streamSettings holds the settings of an EventStream that its NewEventStreamHandler may override.
+type streamSettings struct {
+	keepAlive    time.Duration
+	hasKeepAlive bool
+}
+
+// ID returns a unique identifier for the connection the EventStream sends events on.
+// It can be used to correlate events, logs, and traces for a single client connection,
+// and to send events to it from elsewhere, with Handler.SendTo.
+func (s EventStream) ID() string { return s.id }
+
 // Context returns the context.Context attached to the *http.Request that started the
-// event stream. It can be used to check if e.g. a client canceled/closed the connection:
+// event stream, merged with the Handler's BaseContext, if any.
+// It can be used to check if e.g. a client canceled/closed the connection:
 //
 //     select {
 //     case <-stream.Context().Done():
@@ -45,190 +151,1500 @@ type EventStream struct {
 //
 func (s EventStream) Context() context.Context { return s.ctx }
 
-// Send sends an event to the client.
-func (s EventStream) Send(e Event) { s.events <- e }
+// Request returns the *http.Request that started the event stream, e.g. to read its query parameters,
+// or authorization headers, to decide what to stream; path parameters matched by a Mux are available
+// with PathParam and the EventStream's Context.
+// It must not be modified, and its Body must not be read once the NewEventStreamHandler returns.
+func (s EventStream) Request() *http.Request { return s.r }
+
+// SetKeepAlive overrides the Handler's KeepAlive interval for this EventStream; 0 disables keep-alives.
+// It must be called before the NewEventStreamHandler returns, e.g. to use a shorter interval for
+// clients behind aggressive NATs, and none for server-to-server connections.
+func (s EventStream) SetKeepAlive(interval time.Duration) {
+	s.settings.keepAlive = interval
+	s.settings.hasKeepAlive = true
+}
+
+// Send sends an event to the client. It is safe to call concurrently, including with Close.
+// If the client has disconnected, the event is dropped, and the error of the EventStream's Context is returned.
+// If the stream has been closed, or is closed before the event can be queued, ErrStreamClosed is returned.
+// If queueing the event would exceed the Handler's Allocator's limit, it is not sent, and ErrMemoryLimit is returned;
+// the events already queued are sent, followed by a ReasonQuotaExceeded StreamErrorEvent, and the stream ends.
+func (s EventStream) Send(e Event) error { return s.send(s.ctx, e) }
+
+// SendContext is like Send, but also gives up if ctx is done before the event can be queued, returning ctx's error,
+// e.g. so that a publisher shared by many streams is not held up by one that is not keeping up.
+// If e has no TraceParent, it is sent with that of ctx, if any, see ContextWithTraceParent.
+func (s EventStream) SendContext(ctx context.Context, e Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if e.TraceParent == "" {
+		e.TraceParent = TraceParentFromContext(ctx)
+	}
+	return s.send(ctx, e)
+}
+
+// TrySend is like Send, but does not block: it reports whether e was queued, and drops it otherwise,
+// e.g. if the events channel's buffer (see NewHandlerBuffered) is full, so that producers of high-frequency
+// events can shed load rather than wait for a slow client. Once the stream is closed, or its Context is done,
+// it always returns false, without counting the event as dropped.
+func (s EventStream) TrySend(e Event) bool {
+	s.closer.mu.RLock()
+	defer s.closer.mu.RUnlock()
+
+	if s.closer.closed || s.ctx.Err() != nil {
+		return false
+	}
+
+	if s.strict(e) != nil {
+		return false
+	}
+
+	n := eventSize(&e)
+	if !s.queue.reserve(n) {
+		s.deadLetter(e, DropMemoryLimit, ErrMemoryLimit)
+		return false
+	}
+
+	select {
+	case s.events <- queuedEvent{Event: e, sent: time.Now()}:
+		s.waker.wake()
+		return true
+	default:
+		s.queue.release(n)
+		s.dropped.Add(1)
+		s.deadLetter(e, DropOverflow, nil)
+		return false
+	}
+}
+
+// Dropped returns the number of events TrySend has dropped because the EventStream was not ready for them.
+func (s EventStream) Dropped() uint64 { return s.dropped.Load() }
+
+// Lagging reports whether the connection is lagging: whether the last events flushed to the client
+// took longer than the Handler's LatencyBudget to be flushed after being sent.
+func (s EventStream) Lagging() bool { return s.lagging.Load() }
+
+// SendComment sends text to the client as comment lines, which it ignores, as Send does with Event{Comment: text}.
+func (s EventStream) SendComment(text string) error { return s.Send(Event{Comment: text}) }
 
 // ResetLastEventID causes an event with an empty id to be sent to the client,
 // "...meaning no `Last-Event-ID` header will now be sent in the event of a reconnection being attempted."
-func (s EventStream) ResetLastEventID() { s.events <- Event{ID: " "} }
+func (s EventStream) ResetLastEventID() error { return s.Send(Event{ResetID: true}) }
+
+// send queues e to be sent to the client, unless ctx or the EventStream's Context is done, or the stream is closed first.
+func (s EventStream) send(ctx context.Context, e Event) error {
+	s.closer.mu.RLock()
+	defer s.closer.mu.RUnlock()
+
+	if s.closer.closed {
+		return ErrStreamClosed
+	}
+
+	if err := s.strict(e); err != nil {
+		return err
+	}
+
+	n := eventSize(&e)
+	if !s.queue.reserve(n) {
+		s.deadLetter(e, DropMemoryLimit, ErrMemoryLimit)
+		return ErrMemoryLimit
+	}
+
+	select {
+	case s.events <- queuedEvent{Event: e, sent: time.Now()}:
+		s.waker.wake()
+		return nil
+	case <-ctx.Done():
+		s.queue.release(n)
+		return ctx.Err()
+	case <-s.ctx.Done():
+		s.queue.release(n)
+		return s.ctx.Err()
+	case <-s.closer.closing:
+		s.queue.release(n)
+		return ErrStreamClosed
+	}
+}
+
+// Wait blocks until the connection the EventStream sends events on has ended, and everything sent
+// before then has been flushed to the client, or dropped if the client disconnected.
+// OnDisconnect has been called by the time it returns.
+func (s EventStream) Wait() { <-s.ended }
+
+// Done returns a channel that is closed once the connection the EventStream sends events on has ended, as Wait
+// returns, e.g. for producers to stop work once the client has gone, or the stream was closed, in a select.
+func (s EventStream) Done() <-chan struct{} { return s.ended }
 
-// Close closes the EventStream. Send() must not be called after Close() is called.
+// WaitContext is like Wait, but returns ctx's error if ctx is done before the connection has ended.
+func (s EventStream) WaitContext(ctx context.Context) error {
+	select {
+	case <-s.ended:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close closes the EventStream, once the events already queued have been sent.
+// Sends that are waiting to queue an event when it is called fail with ErrStreamClosed, as do any after it.
+// It is safe to call more than once, and concurrently with Send.
 func (s EventStream) Close() error {
-	close(s.events)
+	s.closer.closeOnce.Do(func() { close(s.closer.closing) })
+
+	s.closer.mu.Lock()
+	defer s.closer.mu.Unlock()
+
+	if !s.closer.closed {
+		s.closer.closed = true
+		close(s.events)
+		s.waker.wake()
+	}
 	return nil
 }
 
+// CloseWithError sends err to the client as a StreamErrorEvent, and closes the EventStream,
+// so that the client can tell why the stream ended, and whether it should reconnect.
+// If the client has already disconnected, the EventStream is closed, and the context's error is returned.
+// If the EventStream has already been closed, ErrStreamClosed is returned.
+func (s EventStream) CloseWithError(err *StreamError) error {
+	sendErr := s.send(s.ctx, err.Event())
+	if closeErr := s.Close(); sendErr == nil {
+		sendErr = closeErr
+	}
+	return sendErr
+}
+
 // NewEventStreamHandler is a function that is called for each new request received by a Handler.
-// Note that it MUST NOT block (for long).
+// Note that it MUST NOT block (for long); events can be produced by goroutines started with EventStream.Go.
 // The EventStream parameter is used for sending events to the client.
 // If the client included a Last-Event-ID header, its value is provided in the lastEventID parameter.
-// If the function returns an error, the Handler responds to the client with a 500.
+// If the function returns a *StreamError, it is sent to the client as a StreamErrorEvent, and the stream ends.
+// If it returns an *HTTPError, the client receives its status code, and no stream is started.
+// If it returns ErrNoReconnect, the client receives a 204, which stops an EventSource from reconnecting.
+// If it returns any other error, the Handler responds to the client with a 500, and a ReasonServerError StreamErrorEvent.
 type NewEventStreamHandler func(stream EventStream, lastEventID string) error
 
+// DefaultPadding is a Handler.Padding large enough for the proxies and EventSource polyfills that need one,
+// which typically wait for 2KB of a response.
+const DefaultPadding = 2048
+
 // Handler may be used as a http.Handler for the handling and sending of Server-Sent Events.
 type Handler struct {
-	// KeepAlive enables sending non-event data when not 0.
-	// Useful when dealing with e.g. legacy proxy servers.
+	// KeepAlive enables sending non-event data when not 0, once a connection has been idle for KeepAlive,
+	// i.e. nothing has been written to it for that long. Useful when dealing with e.g. legacy proxy servers.
 	// The spec recommends "... every 15 seconds or so."
 	// Note that is unrelated to other methods of keeping connections alive,
 	// such as the "Connection: keep-alive" header.
 	KeepAlive time.Duration
 
+	// KeepAlivePayload, if not nil, returns what is sent as each keep-alive, rather than a ": keep-alive" comment,
+	// e.g. a comment with other text, or a named heartbeat event, see Heartbeat.
+	// It is written as is, without being chunked, compressed or delta encoded, so it should be small;
+	// an empty Event sends nothing, which does not keep the connection alive.
+	KeepAlivePayload func() Event
+
+	// ChunkSize enables splitting events with more than ChunkSize bytes of Data into
+	// a sequence of ChunkEvent events when not 0, for proxies that limit the size of messages.
+	// Only clients that support the ExtChunk extension receive chunks.
+	// Clients can reassemble them with a Reassembler.
+	// Events with a DataReader are not split.
+	ChunkSize int
+
+	// GzipThreshold enables compressing events with more than GzipThreshold bytes of Data when not 0,
+	// for clients that support the ExtGzip extension.
+	// The compressed data is base64 encoded, and prefixed by GzipPrefix. Clients can decompress it with DecodeGzip.
+	// Compression happens before an event is split according to ChunkSize.
+	// Events with a DataReader are not compressed.
+	GzipThreshold int
+
+	// DuplicateWindow enables skipping events that have the same Event name and Data as the last
+	// event sent with that name on the connection, if it was sent less than DuplicateWindow ago.
+	// This is useful for sources that repeatedly emit unchanged state.
+	// Only the Event, ID, and Data fields are compared, so events with a new ID are always sent, as are
+	// events without Data, and events with a DataReader.
+	DuplicateWindow time.Duration
+
+	// DeltaEncoding enables sending events as JSON Merge Patches (RFC 7396) against the previous event
+	// with the same name, for clients that support the ExtDelta extension.
+	// The first event with each name is sent in full, as are events for which a patch would not be smaller,
+	// and events whose Data is not a JSON object.
+	// Patches are prefixed by DeltaPrefix. Clients can restore the full data with a DeltaDecoder.
+	// Each connection starts with full payloads, so clients can resynchronize by reconnecting.
+	DeltaEncoding bool
+
+	// DeltaSnapshotInterval enables periodically sending events in full when DeltaEncoding is in use,
+	// when not 0. An event is sent in full if the last full event with the same name was sent at least
+	// DeltaSnapshotInterval ago, so that clients which failed to apply a delta recover without reconnecting.
+	DeltaSnapshotInterval time.Duration
+
+	// Replay enables replaying past events to clients that reconnect with a Last-Event-ID, when not nil.
+	// Events are replayed after the NewEventStreamHandler returns, and before any events sent to the EventStream.
+	Replay ReplaySource
+
+	// ReplayBatchSize is the maximum number of events read from Replay at a time.
+	// Each batch is flushed to the client before the next is read.
+	// The default is DefaultReplayBatchSize.
+	ReplayBatchSize int
+
+	// ReplayBatchDelay is how long to wait between replaying batches, to avoid saturating the connection.
+	ReplayBatchDelay time.Duration
+
+	// ReplayLimit enables capping the number of events replayed to a client when not 0.
+	// If a client is more than ReplayLimit events behind, a CatchUpEvent directing it to CatchUpURL
+	// is sent instead of replaying events.
+	ReplayLimit int
+
+	// ReplayOnlyMaxAge is how long caching proxies, such as CDNs, may cache replay-only responses which are complete.
+	// The default is DefaultReplayOnlyMaxAge.
+	//
+	// A request with the ReplayParam query parameter set to ReplayOnly is served up to ReplayBatchSize events
+	// from Replay after the event ID given by the CatchUpParam query parameter (or its Last-Event-ID), as a response
+	// with a Content-Length, rather than a stream; e.g. "/events?replay=only&last-event-id=42", for backfilling
+	// from a CatchUpURL. Extensions are not used, so that every client receives the same response.
+	// Such requests are admitted as streams are, including by BeforeStream, and the NewEventStreamHandler, which
+	// may reject them, though nothing it sends is, and the events are sent as they are to streams, e.g. only
+	// those behind a flag enabled for the client.
+	// A response with ReplayBatchSize events is complete, since the events after an ID do not change, so it may be
+	// cached, privately if its events depend on the client; a shorter one may have events added to it, so it is not.
+	// Live streams are never cached.
+	ReplayOnlyMaxAge time.Duration
+
+	// CatchUpURL is the URL of an endpoint clients can retrieve missed events from. See ReplayLimit.
+	// If set, a CatchUpEvent is also sent when a client's Last-Event-ID is no longer known by Replay.
+	// Otherwise, no events are replayed to such clients.
+	CatchUpURL string
+
+	// BrowserCompat disables all extensions, regardless of what clients advertise, so that
+	// only standard events are sent, as understood by a plain EventSource.
+	BrowserCompat bool
+
+	// OnWarning, if not nil, is called with the connection's ID and an error wrapping ErrExtensionRequired,
+	// for each event sent to a client that does not support an extension the event relies on.
+	// E.g. an event with more than ChunkSize bytes of Data, sent to a client that does not support ExtChunk.
+	// If nil, warnings are logged to Logger.
+	OnWarning func(connID string, err error)
+
+	// Logger, if not nil, is used to log the errors the Handler handles itself, e.g. the errors
+	// returned by a NewEventStreamHandler that are not a *StreamError, along with the connection's ID.
+	Logger *slog.Logger
+
+	// LatencyBudget enables measuring how long events take from being sent to an EventStream to being flushed
+	// to the client, when not 0. A connection whose events take longer than LatencyBudget is lagging
+	// (see EventStream.Lagging) until its events are flushed within LatencyBudget again.
+	LatencyBudget time.Duration
+
+	// OnLagging, if not nil, is called with the connection's ID, and the latency of the events that exceeded
+	// LatencyBudget, each time a connection starts lagging. If nil, it is logged to Logger.
+	OnLagging func(connID string, latency time.Duration)
+
+	// DisconnectLagging enables closing connections once they are lagging, after sending them a ReasonSlowClient
+	// StreamErrorEvent, so that a client which cannot keep up reconnects, and catches up from its Last-Event-ID,
+	// e.g. with Replay, rather than falling ever further behind.
+	DisconnectLagging bool
+
+	// WriteTimeout enables setting a write deadline for each event written to a client when not 0,
+	// so that a client which stops reading cannot hold a connection open indefinitely.
+	// The connection is closed when a write times out: net/http's server cannot write to a connection
+	// again once a write to it has failed. Clients that reconnect may resume from their Last-Event-ID.
+	// It has no effect if the http.ResponseWriter does not support write deadlines (see http.ResponseController).
+	WriteTimeout time.Duration
+
+	// OnSlowClient, if not nil, is called with the connection's ID when a write to it times out, see WriteTimeout,
+	// before the connection is closed. If nil, it is logged to Logger.
+	OnSlowClient func(connID string)
+
+	// DeadLetter, if not nil, is called with each event that could not be delivered, along with why,
+	// e.g. to record it for later inspection and reprocessing: those dropped by TrySend, those not queued
+	// because of the Allocator's limit, and those that fail ValidateEvent, which events are only checked
+	// with while DeadLetter is set, or StrictEvents; otherwise, they are sent as by SanitizeEvent.
+	// It is called by the goroutine that sent the event, or the connection's, so it must not block.
+	// Dead letters are counted by reason in Stats whether or not it is set.
+	DeadLetter func(dl DeadLetter)
+
+	// StrictEvents enables checking events with ValidateEvent as they are sent with EventStream.Send, SendContext,
+	// and TrySend, which return its error (or false) for events that fail it, rather than queuing them, so that
+	// producers find out about events that would corrupt the stream, e.g. with an event name or ID built from
+	// untrusted input. They are also reported as DropInvalid dead letters.
+	StrictEvents bool
+
+	// FieldSpace, if set, sends a space after the colon of each field, e.g. "data: hello", rather than the
+	// compact form, e.g. "data:hello", see Encoder.FieldSpace.
+	FieldSpace bool
+
+	// FlushBatchBytes is the number of bytes of queued events encoded into a connection's buffer before it is
+	// written and flushed, so that events sent in quick succession share one write and flush, e.g. one HTTP/2
+	// DATA frame, rather than costing one each. The events queued while a batch is encoded are added to it.
+	// Events are not split between batches, so a batch may exceed it by the size of its last event.
+	// The default is DefaultFlushBatchBytes.
+	FlushBatchBytes int
+
+	// FlushBatchEvents enables limiting the number of queued events written and flushed together when not 0,
+	// see FlushBatchBytes, e.g. 1 to flush each event as it is sent.
+	FlushBatchEvents int
+
+	// Allocator, if not nil, is used to obtain buffers for encoding events, and accounts for the memory held
+	// by events queued on each EventStream. If it has a limit, EventStream.Send fails once it is reached.
+	// If nil, DefaultAllocator is used.
+	Allocator Allocator
+
+	// Snapshot, if not nil, provides an event carrying the current state, which is sent to each client when it
+	// connects, before any events are replayed or sent, so that it does not have to fetch the state separately.
+	// If the snapshot has a version, it is sent as the response's ETag, and clients that present the current
+	// version with an If-None-Match header, or the SnapshotVersionParam query parameter, are not sent it again.
+	// If it fails, the client is sent a ReasonServerError StreamErrorEvent, and the stream ends.
+	Snapshot SnapshotSource
+
+	// BeforeStream, if not nil, is called before the NewEventStreamHandler, e.g. to set CORS headers,
+	// "X-Accel-Buffering: no", or cookies, which are sent along with the stream's headers.
+	// It must not write to w. If it returns an error, the request is rejected, and no stream is started:
+	// with the error's status if it is an *HTTPError, and with a 500 otherwise.
+	BeforeStream func(w http.ResponseWriter, r *http.Request) error
+
+	// OnPanic, if not nil, is called with the connection's ID, the value, and the stack trace of each panic
+	// recovered from while serving a connection; if nil, panics are logged to Logger.
+	// A panic in the NewEventStreamHandler, or in a producer started by EventStream.Go, ends the stream with
+	// a ReasonServerError StreamErrorEvent. A panic elsewhere, e.g. in a DataReader, or another hook,
+	// closes the connection, since an event may have been partially written.
+	// Panics in goroutines started by other means cannot be recovered from.
+	OnPanic func(connID string, value interface{}, stack []byte)
+
+	// OnAnnotate, if not nil, is called with the stream's Context, the connection's ID, and each annotation
+	// recorded by EventStream.Annotate, e.g. to add it as an event to the connection's trace span.
+	// If nil, annotations are logged to Logger at the debug level.
+	OnAnnotate func(ctx context.Context, connID string, a Annotation)
+
+	// TimelineSize is the number of annotations each stream keeps for DebugHandler, see EventStream.Annotate.
+	// If 0, DefaultTimelineSize is used.
+	TimelineSize int
+
+	// Accept, if not nil, reports whether a request accepts the stream, replacing AcceptsEventStream, e.g. to skip
+	// the negotiation of internal clients whose Accept headers are not right. Requests it rejects are answered
+	// with 406 Not Acceptable.
+	Accept func(r *http.Request) bool
+
+	// ACL, if not nil, restricts which networks requests are served from, see NetworkACL.
+	// It is checked before anything else.
+	ACL *NetworkACL
+
+	// Screen, if not nil, is called with the Fingerprint of each request before it is served, e.g. to consult
+	// an abuse detection system, and decides whether it is allowed, denied, or tarpitted, see ScreenDecision.
+	// It is called for every request, including replay-only requests, so it should be fast; see
+	// TLSFingerprints for including a fingerprint of the client's TLS implementation.
+	Screen func(fp Fingerprint) ScreenDecision
+
+	// TarpitDelay is how long requests tarpitted by Screen are held open before they are rejected.
+	// The default is DefaultTarpitDelay.
+	TarpitDelay time.Duration
+
+	// Flags, if not nil, evaluates the feature flags events are behind for each client, see Event.Flag.
+	// Its results are cached for FlagCacheTTL, per principal and flag, for all of the Handler's connections.
+	// Events behind a flag are not sent if Flags is nil, or fails to evaluate the flag.
+	Flags FlagEvaluator
+
+	// Principal, if not nil, returns the principal a request is made by, e.g. the ID of its authenticated user,
+	// for evaluating feature flags, see Flags. If nil, the principal is empty.
+	Principal func(r *http.Request) string
+
+	// FlagCacheTTL is how long the results of Flags are cached for. If 0, DefaultFlagCacheTTL is used;
+	// if negative, results are not cached.
+	FlagCacheTTL time.Duration
+
+	// Cohort, if not nil, assigns each client to a cohort of the experiments events are part of, by its
+	// principal (see Principal), to decide which of their Variants it is sent, see Event.Experiment.
+	// It is called once per connection and experiment.
+	Cohort CohortFunc
+
+	// Localizer, if not nil, localizes the data of the events whose names are registered with it for each
+	// client, by the locale Locale returns for its request, e.g. to translate notifications server-side.
+	// Events are localized after their variant is chosen (see Cohort), and before they are encoded.
+	Localizer *Localizer
+
+	// Locale, if not nil, returns the locale of a request, e.g. from its user's settings, see Localizer.
+	// If nil, PreferredLocale is used.
+	Locale func(r *http.Request) string
+
+	// ReconnectLimiter, if not nil, delays or rejects the connections of clients that reconnect excessively.
+	// Replay-only requests are not counted.
+	ReconnectLimiter *ReconnectLimiter
+
+	// OnConnect, if not nil, is called after the NewEventStreamHandler has accepted a connection,
+	// with the connection's ID (see EventStream.ID), and the request that started it.
+	OnConnect func(connID string, r *http.Request)
+
+	// OnDisconnect, if not nil, is called with the connection's ID once a connection accepted
+	// by the NewEventStreamHandler ends.
+	OnDisconnect func(connID string)
+
+	// BaseContext, if not nil, returns the context each EventStream's Context is derived from, along with
+	// the request's context, e.g. one carrying a service's database handles and logger.
+	// The EventStream's Context has the values of both, preferring the request's, and is canceled once either
+	// is done, which ends the stream. If BaseContext returns nil, only the request's context is used.
+	//
+	// The context returned by BaseContext being done is taken as the server shutting down, so the stream
+	// ends as described by RegisterOnShutdown. To shut streams down gracefully along with the context given
+	// by http.Server.BaseContext, return it from BaseContext too.
+	BaseContext func(r *http.Request) context.Context
+
+	// DefaultRetry enables sending each client a retry field when it connects, when not 0, so that it waits
+	// DefaultRetry before reconnecting, rather than its own default, without having to set Retry on an event.
+	// It is flushed along with the response's headers, before any other events. It is sent in whole milliseconds.
+	DefaultRetry time.Duration
+
+	// Padding enables sending each client a comment of Padding bytes when it connects, when not 0,
+	// along with an "X-Accel-Buffering: no" header, for proxies that buffer responses until they have
+	// received enough of them, and old EventSource polyfills that only fire events once they have received
+	// a certain amount, e.g. DefaultPadding. The comment is flushed along with the response's headers,
+	// before DefaultRetry and any events.
+	Padding int
+
+	// ShutdownRetry enables telling clients how long to wait before reconnecting when the Handler shuts down,
+	// when not 0. Each client is sent a random delay between half of ShutdownRetry and ShutdownRetry,
+	// so that they do not all reconnect to the remaining servers at once.
+	ShutdownRetry time.Duration
+
+	// MaxConnectionAge enables ending connections once they have been open for this long, when not 0,
+	// so that clients reconnect on their own terms, rather than being cut off by a load balancer or proxy
+	// with a limit of its own. Each connection ends at a random point within the last tenth of
+	// MaxConnectionAge, so that clients that connected together do not all reconnect together. The events
+	// already queued are sent, followed by a ReasonMaxAge StreamErrorEvent, with a reconnection delay of
+	// DefaultRetry, or DefaultMaxAgeRetry if it is 0. The stream's Context is canceled at the same time.
+	MaxConnectionAge time.Duration
+
+	// MaxConnections, if not 0, is the most connections the Handler serves at once, including those being
+	// delayed by its ReconnectLimiter; requests for more are rejected with 503 Service Unavailable, rather than
+	// each taking up a goroutine and memory, with a Retry-After header of MaxConnectionsRetry if it is not 0.
+	// Replay-only requests are not counted.
+	MaxConnections int
+
+	// MaxConnectionsRetry is how long clients rejected by MaxConnections are told to wait before retrying.
+	MaxConnectionsRetry time.Duration
+
+	// OnMaxConnections, if not nil, is called with each request rejected by MaxConnections, e.g. to count
+	// them, or log an alert that the Handler needs more capacity.
+	OnMaxConnections func(r *http.Request)
+
+	// Park enables parking idle connections, to reduce the cost of large numbers of mostly idle
+	// connections, e.g. to topics with long silent periods.
+	// A parked connection is taken over from net/http's server (see http.Hijacker), so that it has
+	// no goroutine of its own: it is registered in a wake list shared by all of the Handler's connections,
+	// and a goroutine is only started to write to it when an event is sent, or a keep-alive is due,
+	// which are scheduled by a single shared timer.
+	//
+	// Only HTTP/1.x connections with a keep-alive interval are parked, since a parked connection only
+	// notices that the client has disconnected when writing to it fails. The EventStream's Context is
+	// then no longer the request's, but it is still canceled once the connection ends.
+	// Changes to the keep-alive interval by UpdateConfig are applied when a parked connection next wakes.
+	// Other connections keep their own goroutine, but still share the keep-alive timer.
+	Park bool
+
 	handler     NewEventStreamHandler
 	chanBufSize uint
-	bufPool     bufferPool
+	parking     *parkingLot
+	config      *handlerConfig
+	shutdown    *handlerShutdown
+	streams     *streamRegistry
+	stats       *handlerStats
+	flagCache   *flagCache
 }
 
 // NewHandler returns a *Handler which will call newEventStream on each http request,
 // which provides an EventStream for sending events to the client.
-func NewHandler(newEventStream NewEventStreamHandler) *Handler {
-	return NewHandlerBuffered(newEventStream, 0)
+// It is configured by opts, in order, see Option.
+func NewHandler(newEventStream NewEventStreamHandler, opts ...Option) *Handler {
+	h := NewHandlerBuffered(newEventStream, 0)
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 // NewHandlerBuffered allows for configuring the buffer size of Go channels used internally.
 // The default is unbuffered.
-// See NewHandler.
+// See NewHandler, and WithBufferSize.
 func NewHandlerBuffered(newEventStream NewEventStreamHandler, chanBufSize uint) *Handler {
 	return &Handler{
 		handler:     newEventStream,
 		chanBufSize: chanBufSize,
-		bufPool:     newBufferPool(),
+		parking:     new(parkingLot),
+		config:      new(handlerConfig),
+		shutdown:    newHandlerShutdown(),
+		streams:     new(streamRegistry),
+		stats:       new(handlerStats),
+		flagCache:   new(flagCache),
+	}
+}
+
+// UpdateConfig changes the Handler's configuration while it is serving connections, without restarting it.
+// update is called with a copy of the current configuration to modify, which is used for connections
+// made once UpdateConfig returns. Once UpdateConfig has been used, the Handler's fields must not be modified directly.
+//
+// Existing connections also switch to the new configuration before sending their next event, except for
+// what was settled when they connected: their negotiated extensions, Allocator, DuplicateWindow,
+// DeltaEncoding and DeltaSnapshotInterval, Replay settings, and OnConnect and OnDisconnect.
+// Their keep-alive interval is changed immediately, unless it was overridden by EventStream.SetKeepAlive.
+// A Config can be applied directly, see Config.Apply for how its BufferSize and MemoryLimit are updated.
+func (h *Handler) UpdateConfig(update func(cfg *Handler)) {
+	h.config.mu.Lock()
+	defer h.config.mu.Unlock()
+
+	cfg := *h.config.currentLocked(h)
+	update(&cfg)
+	cfg.handler = h.handler
+	cfg.parking = h.parking
+	cfg.config = h.config
+	cfg.shutdown = h.shutdown
+	cfg.streams = h.streams
+	cfg.stats = h.stats
+	cfg.flagCache = h.flagCache
+
+	h.config.current = &cfg
+	if h.config.changed != nil {
+		close(h.config.changed)
+	}
+	h.config.changed = make(chan struct{})
+}
+
+// handlerConfig holds the configuration of a Handler set by UpdateConfig.
+type handlerConfig struct {
+	mu      sync.Mutex
+	current *Handler
+	changed chan struct{}
+}
+
+// load returns h's current configuration, and a channel that is closed once it is next updated.
+func (h *Handler) load() (*Handler, <-chan struct{}) {
+	if h.config == nil {
+		return h, nil
+	}
+
+	h.config.mu.Lock()
+	defer h.config.mu.Unlock()
+
+	if h.config.changed == nil {
+		h.config.changed = make(chan struct{})
+	}
+	return h.config.currentLocked(h), h.config.changed
+}
+
+// currentLocked returns the configuration set by UpdateConfig, or h if there is none.
+// c.mu must be held.
+func (c *handlerConfig) currentLocked(h *Handler) *Handler {
+	if c.current != nil {
+		return c.current
+	}
+	return h
+}
+
+func (h *Handler) allocator() Allocator {
+	if h.Allocator != nil {
+		return h.Allocator
 	}
+	return DefaultAllocator
 }
 
 // ServeHTTP is Handler's implementation of http.Handler, and should not normally need to be
 // used directly by user of the API.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	flush := canFlush(w)
-	if flush == nil {
-		http.Error(w, "Flushing must be supported", http.StatusNotImplemented)
+	cfg, changed := h.load()
+	if !cfg.admit(w, r) {
 		return
 	}
+	h.serve(w, r, cfg, changed)
+}
+
+// admit reports whether r may be served, by the ACL, Accept, and Screen, and whether it can be streamed to,
+// responding to it otherwise. It is checked before anything else is done for a request, e.g. reading its body.
+func (h *Handler) admit(w http.ResponseWriter, r *http.Request) bool {
+	if h.ACL != nil && !h.ACL.check(w, r) {
+		h.stats.rejected.Add(1)
+		return false
+	}
+
+	if !canFlush(w) {
+		http.Error(w, "Flushing must be supported", http.StatusNotImplemented)
+		return false
+	}
 
-	if !isAcceptable(r) {
+	if accept := h.Accept; accept == nil && !AcceptsEventStream(r) || accept != nil && !accept(r) {
 		http.Error(w, `User agent must accept "Content-Type: text/event-stream"`, http.StatusNotAcceptable)
+		return false
+	}
+
+	return h.screen(w, r) && !rejectFinished(w, r)
+}
+
+// serve serves r, which h has admitted, with h being root's configuration when r was received,
+// and changed being closed once it is changed, see load.
+func (root *Handler) serve(w http.ResponseWriter, r *http.Request, h *Handler, changed <-chan struct{}) {
+	if !h.acquireConnection(w, r) {
 		return
 	}
 
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Content-Type", "text/event-stream")
+	if !h.limitReconnects(w, r) {
+		h.releaseConnection()
+		return
+	}
+
+	if !h.shutdown.enter() {
+		h.releaseConnection()
+		h.rejectShuttingDown(w)
+		return
+	}
+
+	// replay-only responses are served as streams are, up to the NewEventStreamHandler, which authorizes them,
+	// but without extensions, so that every client receives the same response
+	replayOnly := h.Replay != nil && r.URL.Query().Get(ReplayParam) == ReplayOnly
+
+	exts := make(extensionSet)
+	if !h.BrowserCompat && !replayOnly {
+		exts = h.supportedExtensions().intersect(requestedExtensions(r))
+	}
 
 	lastEventID := r.Header.Get("Last-Event-ID")
+	if id := r.URL.Query().Get(CatchUpParam); replayOnly && id != "" {
+		lastEventID = id
+	}
+	alloc := h.allocator()
 	stream := EventStream{
+		id:     newConnID(),
 		ctx:    r.Context(),
-		events: make(chan Event, h.chanBufSize),
+		r:      r,
+		events: make(chan queuedEvent, h.chanBufSize),
+		queue:  newQueueAccount(alloc),
+		settings: &streamSettings{
+			keepAlive: h.KeepAlive,
+		},
+		producers: new(producerGroup),
+		closer:    newStreamCloser(),
+		ended:     make(chan struct{}),
+		lagging:   new(atomic.Bool),
+		dropped:   new(atomic.Uint64),
+		state:     new(streamState),
+		h:         h,
+	}
+
+	c := &conn{
+		id:       stream.id,
+		h:        h,
+		alloc:    alloc,
+		w:        w,
+		rc:       http.NewResponseController(w),
+		buf:      alloc.GetBuffer(),
+		compress: exts[ExtGzip],
+		chunk:    exts[ExtChunk],
+		tags:     exts[ExtTags],
+		trace:    exts[ExtTrace],
+		lagging:  stream.lagging,
+		stats:    h.stats,
+		state:    stream.state,
+	}
+
+	var parked *parkedConn
+	defer func() {
+		if parked == nil {
+			c.end(&stream)
+		}
+	}()
+
+	// A connection that may be parked needs an EventStream that outlives the request's context,
+	// which is canceled once ServeHTTP returns; until it is parked, it is canceled along with it.
+	var stopDetach func() bool
+	cancel := func() {}
+	park := h.Park && canPark(w, r) && !replayOnly
+	if park {
+		stream.ctx, cancel = context.WithCancel(context.WithoutCancel(r.Context()))
+		stopDetach = context.AfterFunc(r.Context(), cancel)
+	}
+
+	merge := func(base context.Context) {
+		var stop context.CancelFunc
+		stream.ctx, stop = mergeContext(stream.ctx, base)
+		cancelParent := cancel
+		cancel = func() {
+			stop()
+			cancelParent()
+		}
+	}
+	if h.BaseContext != nil {
+		if c.base = h.BaseContext(r); c.base != nil {
+			merge(c.base)
+		}
+	}
+	merge(h.shutdown.ctx)
+
+	if h.MaxConnectionAge > 0 {
+		var stop context.CancelFunc
+		stream.ctx, stop = context.WithTimeoutCause(stream.ctx, h.maxConnectionAge(), errMaxConnectionAge)
+		cancelParent := cancel
+		cancel = func() {
+			stop()
+			cancelParent()
+		}
+	}
+
+	defer func() {
+		if parked == nil {
+			cancel()
+		}
+	}()
+
+	// a panic while serving the connection ends it, rather than the server's connection to the client;
+	// a connection that panics before it is parked is ended here
+	defer func() {
+		if v := recover(); v != nil {
+			c.recovered(v)
+			if parked != nil && parked.netConn != nil {
+				parked.netConn.Close()
+			}
+			parked = nil
+		}
+	}()
+	c.ctx = stream.ctx
+	stream.timeline = newTimeline(h.TimelineSize, c.annotated(stream.ctx))
+
+	if park {
+		stream.waker = new(waker)
+		if cap(stream.events) == 0 {
+			// parked connections only take events that are already queued
+			stream.events = make(chan queuedEvent, 1)
+		}
+		parked = &parkedConn{c: c, stream: stream, root: root, changed: changed, cancel: cancel}
+	}
+
+	if h.DuplicateWindow > 0 {
+		c.dups = newDuplicateFilter(h.DuplicateWindow)
+	}
+
+	if h.Principal != nil {
+		c.principal = h.Principal(r)
+	}
+
+	if h.Localizer != nil {
+		if h.Locale != nil {
+			c.locale = h.Locale(r)
+		} else {
+			c.locale = PreferredLocale(r)
+		}
+	}
+
+	if exts[ExtDelta] {
+		c.deltas = newDeltaEncoder(h.DeltaSnapshotInterval)
+	}
+
+	if h.BeforeStream != nil {
+		if err := h.BeforeStream(w, r); err != nil {
+			var httpErr *HTTPError
+			if !errors.As(err, &httpErr) {
+				c.log(slog.LevelError, "before stream hook failed", err)
+				httpErr = NewHTTPError(http.StatusInternalServerError, "")
+			}
+			httpErr.write(w)
+			return
+		}
+	}
+
+	stream.producers.recovered = c.recovered
+	stream.producers.hold()
+	err := c.callHandler(stream, lastEventID)
+	stream.producers.release(stream)
+
+	if replayOnly {
+		// nothing sent to the stream is, only the replayed events
+		stream.Close()
+		c.serveReplayOnly(w, lastEventID, err)
+		return
+	}
+
+	if interval := stream.settings.keepAlive; parked != nil && (err != nil || interval <= 0 || !stopDetach()) {
+		parked = nil
+	}
+
+	if err != nil {
+		if errors.Is(err, ErrNoReconnect) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			httpErr.write(w)
+			return
+		}
+
+		setStreamHeaders(w, exts)
+		var streamErr *StreamError
+		if !errors.As(err, &streamErr) {
+			if !errors.Is(err, errPanic) {
+				c.log(slog.LevelError, "stream handler failed", err)
+			}
+			streamErr = NewStreamError(ReasonServerError, "")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		c.writeStreamError(streamErr)
+		return
+	}
+
+	setStreamHeaders(w, exts)
+	if h.Padding > 0 {
+		w.Header().Set("X-Accel-Buffering", "no")
+	}
+
+	var snapshot *Event
+	if h.Snapshot != nil {
+		if snapshot, err = c.snapshot(w, r); err != nil {
+			c.log(slog.LevelError, "snapshot failed", err)
+			c.writeStreamError(NewStreamError(ReasonServerError, ""))
+			return
+		}
+	}
+
+	h.streams.add(stream)
+	h.stats.connections.Add(1)
+	if h.OnConnect != nil {
+		h.OnConnect(stream.id, r)
+	}
+	c.onDisconnect = h.OnDisconnect
+
+	if parked != nil {
+		netConn, err := c.hijack(w, r)
+		if err != nil {
+			c.log(slog.LevelError, "failed to park connection", err)
+			parked = nil
+			return
+		}
+		parked.netConn = netConn
 	}
-	if err := h.handler(stream, lastEventID); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+
+	if !c.preamble() {
+		if parked != nil {
+			parked.end()
+		}
 		return
 	}
 
-	var keepAlive <-chan time.Time
-	if h.KeepAlive > 0 {
-		ticker := time.NewTicker(h.KeepAlive)
-		defer ticker.Stop()
-		keepAlive = ticker.C
+	if snapshot != nil && !(c.send(*snapshot) && c.flush()) {
+		if parked != nil {
+			parked.end()
+		}
+		return
+	}
+
+	if h.Replay != nil && lastEventID != "" && !c.replay(c.ctx, lastEventID) {
+		if parked != nil {
+			parked.end()
+		}
+		return
+	}
+
+	if parked != nil {
+		if !c.flush() {
+			parked.end()
+			return
+		}
+		parked.park(stream.settings.keepAlive)
+		return
 	}
 
+	keepAlive := keepAliveTimer{lastWrite: &c.lastWrite}
+	keepAlive.reset(h, stream.settings.keepAlive)
+	defer keepAlive.stop()
+
 	for {
 		select {
-		case <-r.Context().Done():
+		case <-c.ctx.Done():
+			if c.shuttingDown() {
+				c.sendShutdown(&stream)
+			} else if c.expired() {
+				c.sendExpired(&stream)
+			}
 			return
 
 		case evt, ok := <-stream.events:
 			if !ok {
+				select {
+				case <-stream.queue.exceeded:
+					c.writeStreamError(memoryLimitError())
+				default:
+					if c.shuttingDown() {
+						c.sendShutdown(&stream)
+					} else if c.expired() {
+						c.sendExpired(&stream)
+					}
+				}
+				return
+			}
+			if !c.sendBatch(&stream, evt) {
+				return
+			}
+
+		case <-stream.queue.exceeded:
+			// deliver what was queued before the limit was reached, then tell the client why the stream ended
+			if c.sendQueued(&stream) {
+				c.writeStreamError(memoryLimitError())
+			}
+			return
+
+		case now := <-keepAlive.C:
+			if keepAlive.due(now) && !(c.writeKeepAlive() && c.flush()) {
 				return
 			}
 
-			buf := h.bufPool.Get()
-			if writeEvent(&buf, &evt) {
-				buf.WriteByte('\n')
-				w.Write(buf.Bytes())
-				flush()
+		case <-changed:
+			c.h, changed = root.load()
+			interval := stream.settings.keepAlive
+			if !stream.settings.hasKeepAlive {
+				interval = c.h.KeepAlive
 			}
-			h.bufPool.Put(buf)
+			keepAlive.reset(c.h, interval)
+		}
+	}
+}
+
+// keepAliveTimer delivers a connection's keep-alives, from its own timer, or from its Handler's
+// parkingLot if it is parked, each time the connection has been idle for the keep-alive interval.
+type keepAliveTimer struct {
+	C <-chan time.Time
+
+	// lastWrite is when the connection was last written to, see conn.lastWrite.
+	lastWrite *atomic.Int64
+
+	interval time.Duration
+	timer    *time.Timer
+	lot      *parkingLot
+	space    *parkingSpace
+}
+
+// reset changes the interval keep-alives are delivered at, and whether they come from h's parkingLot.
+// An interval of 0 stops them.
+func (k *keepAliveTimer) reset(h *Handler, interval time.Duration) {
+	if interval == k.interval && h.Park == (k.space != nil) {
+		return
+	}
+
+	k.stop()
+	k.interval = interval
+	if interval <= 0 {
+		return
+	}
+
+	if h.Park {
+		k.lot = h.parking
+		k.space = k.lot.park(interval, k.lastWrite, nil)
+		k.C = k.space.wake
+	} else {
+		k.timer = time.NewTimer(interval)
+		k.C = k.timer.C
+	}
+}
+
+// due is called when C delivers, and reports whether a keep-alive is due; if the connection has been written
+// to since the timer was set, it is set for when the connection will have been idle for the interval instead.
+func (k *keepAliveTimer) due(now time.Time) bool {
+	if k.timer == nil {
+		// the parkingLot only wakes idle connections
+		return true
+	}
+
+	if idle := time.Unix(0, k.lastWrite.Load()).Add(k.interval); idle.After(now) {
+		k.timer.Reset(idle.Sub(now))
+		return false
+	}
+	k.timer.Reset(k.interval)
+	return true
+}
+
+// stop stops delivering keep-alives.
+func (k *keepAliveTimer) stop() {
+	if k.timer != nil {
+		k.timer.Stop()
+		k.timer = nil
+	}
+	if k.space != nil {
+		k.lot.leave(k.space)
+		k.space = nil
+	}
+	k.C = nil
+	k.interval = 0
+}
+
+// conn holds the state of a single connection to a client.
+type conn struct {
+	id       string
+	h        *Handler
+	ctx      context.Context
+	base     context.Context
+	alloc    Allocator
+	w        io.Writer
+	rc       responseFlusher
+	buf      *bytes.Buffer
+	compress bool
+	chunk    bool
+	tags     bool
+	trace    bool
+	dups     *duplicateFilter
+	deltas   *deltaEncoder
+
+	// oldest is when the oldest event in buf was sent, to measure the latency of flushing it.
+	oldest  time.Time
+	lagging *atomic.Bool
+
+	// stats counts the events and bytes written, if not nil.
+	stats *handlerStats
+
+	// principal is the principal the connection's request was made by, see Handler.Principal.
+	principal string
+
+	// locale is the locale of the connection's request, see Handler.Locale.
+	locale string
+
+	// state is the state of the connection's EventStream.
+	state *streamState
+
+	// cohorts caches the cohort of each experiment the connection is assigned to, see Handler.Cohort.
+	cohorts map[string]string
+
+	// lastWrite is when the connection was last flushed, in Unix nanoseconds, so that keep-alives are only
+	// sent once it has been idle, see Handler.KeepAlive.
+	lastWrite atomic.Int64
+
+	onDisconnect func(connID string)
+}
+
+// responseFlusher flushes the writes to a connection; it is implemented by *http.ResponseController.
+type responseFlusher interface {
+	Flush() error
+	SetWriteDeadline(deadline time.Time) error
+}
+
+// end releases everything held by the connection once it has ended, and reports that it has.
+func (c *conn) end(stream *EventStream) {
+	stream.queue.close()
+	c.alloc.PutBuffer(c.buf)
+	c.h.streams.remove(c.id)
+
+	if c.onDisconnect != nil {
+		c.onDisconnect(c.id)
+	}
+	close(stream.ended)
+	c.h.releaseConnection()
+	c.h.shutdown.leave()
+}
+
+// DefaultFlushBatchBytes is the number of bytes of queued events that are encoded into a connection's
+// buffer before it is flushed when Handler.FlushBatchBytes is 0.
+const DefaultFlushBatchBytes = 32 * 1024
+
+// flushBatch returns the limits of the batches of queued events flushed together, see Handler.FlushBatchBytes:
+// the number of bytes, and the number of events, or 0 without a limit.
+func (c *conn) flushBatch() (maxBytes, maxEvents int) {
+	maxBytes = c.h.FlushBatchBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultFlushBatchBytes
+	}
+	return maxBytes, max(c.h.FlushBatchEvents, 0)
+}
+
+// sendBatch sends evt, followed by the events queued on stream while it is sent, without waiting for more,
+// until a batch is full, see Handler.FlushBatchBytes, and flushes them together.
+// It returns false if writing failed, and the connection should be closed.
+func (c *conn) sendBatch(stream *EventStream, evt queuedEvent) bool {
+	if !c.sendEvent(stream, evt) {
+		return false
+	}
+
+	maxBytes, maxEvents := c.flushBatch()
+	for n := 1; c.buf.Len() < maxBytes && (maxEvents == 0 || n < maxEvents); n++ {
+		var ok bool
+		select {
+		case evt, ok = <-stream.events:
+		default:
+		}
+		// nothing more is queued, or the stream is closed, which the connection's loop receives after flushing
+		if !ok {
+			break
+		}
 
-		case <-keepAlive:
-			w.Write([]byte(": keep-alive\n\n"))
+		if !c.sendEvent(stream, evt) {
+			return false
 		}
 	}
+	return c.flush()
 }
 
-func writeEvent(buf *bytes.Buffer, evt *Event) (wrote bool) {
-	if len(evt.Event) != 0 {
-		buf.WriteString("event:")
-		buf.WriteString(evt.Event)
-		buf.WriteByte('\n')
+// sendQueued sends the events already queued on stream, without waiting for more.
+// It returns false if writing failed, and the connection should be closed.
+func (c *conn) sendQueued(stream *EventStream) bool {
+	for n := len(stream.events); n > 0; n-- {
+		evt, ok := <-stream.events
+		if !ok {
+			break
+		}
+
+		if !c.sendEvent(stream, evt) {
+			return false
+		}
 	}
+	return true
+}
 
-	if len(evt.Data) != 0 {
-		lines := bytes.Split(evt.Data, []byte{'\n'})
-		for _, line := range lines {
-			buf.WriteString("data:")
-			buf.Write(line)
-			buf.WriteByte('\n')
+// sendEvent releases the memory held by evt, which was queued on stream, and sends it.
+// It returns false if writing failed, and the connection should be closed.
+func (c *conn) sendEvent(stream *EventStream, evt queuedEvent) bool {
+	stream.queue.release(eventSize(&evt.Event))
+	if c.oldest.IsZero() {
+		c.oldest = evt.sent
+	}
+	return c.send(evt.Event)
+}
+
+// send applies duplicate suppression, delta encoding, tags, compression, and chunking to evt as configured,
+// and encodes the result into the connection's buffer, to be written to the client by flush.
+// It returns false if writing failed, and the connection should be closed.
+func (c *conn) send(evt Event) bool {
+	if evt.Flag != "" && !c.flagEnabled(evt.Flag) {
+		if closer, ok := evt.DataReader.(io.Closer); ok {
+			closer.Close()
 		}
+		return true
 	}
 
-	if len(evt.ID) != 0 {
-		if evt.ID == " " {
-			buf.WriteString("id\n")
+	if c.h.DeadLetter != nil {
+		if err := ValidateEvent(evt); err != nil {
+			c.h.deadLetter(c.id, c.state.getTopic(), evt, DropInvalid, err)
+			return true
+		}
+	} else {
+		sanitizeEvent(&evt)
+	}
+
+	if len(evt.Variants) > 0 {
+		evt.Data = c.variant(&evt)
+		evt.Variants = nil
+	}
+
+	if c.h.Localizer != nil {
+		c.localize(&evt)
+	}
+
+	now := time.Now()
+
+	if c.dups != nil && c.dups.isDuplicate(&evt, now) {
+		return true
+	}
+
+	if c.stats != nil {
+		c.stats.events.Add(1)
+	}
+
+	if c.deltas != nil {
+		c.deltas.encode(&evt, now)
+	}
+
+	if c.tags && len(evt.Tags) > 0 {
+		evt.Data = encodeTags(evt.Tags, evt.Data)
+	}
+
+	if c.trace && evt.TraceParent != "" {
+		evt.Data = encodeTrace(evt.TraceParent, evt.Data)
+	}
+
+	if c.compress && len(evt.Data) > c.h.GzipThreshold && evt.DataReader == nil {
+		evt.Data = compressData(evt.Data)
+	}
+
+	if c.h.ChunkSize > 0 && len(evt.Data) > c.h.ChunkSize && evt.DataReader == nil {
+		if !c.chunk {
+			c.warn(fmt.Errorf("%w: event has %d bytes of data, which exceeds ChunkSize, but %s is not enabled",
+				ErrExtensionRequired, len(evt.Data), ExtChunk))
+			return c.write(&evt)
+		}
+
+		for _, chunk := range splitEvent(evt, c.h.ChunkSize) {
+			if !c.write(&chunk) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return c.write(&evt)
+}
+
+// shuttingDown reports whether the connection's Context is done because the Handler is shutting down,
+// rather than because the client disconnected.
+func (c *conn) shuttingDown() bool {
+	return c.h.shutdown.ctx.Err() != nil || c.base != nil && c.base.Err() != nil
+}
+
+// sendShutdown sends the events already queued on stream, followed by a ReasonShutdown StreamErrorEvent
+// telling the client when to reconnect, and flushes them.
+func (c *conn) sendShutdown(stream *EventStream) {
+	if !c.sendQueued(stream) {
+		return
+	}
+
+	evt := NewStreamError(ReasonShutdown, "").Event()
+	evt.Retry = c.h.shutdownRetry()
+	if c.write(&evt) {
+		c.flush()
+	}
+}
+
+// writeStreamError writes err to the client as a StreamErrorEvent, and flushes it.
+func (c *conn) writeStreamError(err *StreamError) bool {
+	evt := err.Event()
+	return c.write(&evt) && c.flush()
+}
+
+// warn reports err to the Handler's OnWarning hook, if set.
+func (c *conn) warn(err error) {
+	if c.h.OnWarning != nil {
+		c.h.OnWarning(c.id, err)
+		return
+	}
+	c.log(slog.LevelWarn, "sent event the client may not understand", err)
+}
+
+// log logs msg and err to the Handler's Logger, if set.
+func (c *conn) log(level slog.Level, msg string, err error) {
+	if c.h.Logger != nil {
+		c.h.Logger.LogAttrs(c.ctx, level, msg, slog.String("conn_id", c.id), slog.Any("error", err))
+	}
+}
+
+// write encodes evt into the connection's buffer.
+// If evt has a DataReader, the buffer is written to the client as the reader is consumed.
+// It returns false if the write failed, and the connection should be closed.
+func (c *conn) write(evt *Event) bool {
+	w := io.Writer(c.w)
+	if evt.DataReader != nil {
+		c.setWriteDeadline()
+		defer c.clearWriteDeadline()
+
+		if c.stats != nil {
+			w = countingWriter{w: w, stats: c.stats}
+		}
+	}
+	if err := appendEvent(w, c.buf, evt, c.h.FieldSpace); err != nil {
+		c.writeFailed(err)
+		return false
+	}
+	return true
+}
+
+// preamble writes what is sent to each client before any events, Padding and DefaultRetry, and flushes it.
+// It returns false if the write failed, and the connection should be closed.
+func (c *conn) preamble() bool {
+	if c.h.Padding <= 0 && c.h.DefaultRetry <= 0 {
+		return true
+	}
+
+	if c.h.Padding > 0 {
+		c.buf.WriteByte(':')
+		for i := len(":\n\n"); i < c.h.Padding; i++ {
+			c.buf.WriteByte(' ')
+		}
+		c.buf.WriteString("\n\n")
+	}
+	if c.h.DefaultRetry > 0 && !c.write(&Event{Retry: c.h.DefaultRetry}) {
+		return false
+	}
+	return c.flush()
+}
+
+// writeKeepAlive encodes a keep-alive into the connection's buffer, see Handler.KeepAlivePayload.
+// It returns false if the write failed, and the connection should be closed.
+func (c *conn) writeKeepAlive() bool {
+	if c.h.KeepAlivePayload == nil {
+		c.buf.WriteString(": keep-alive\n\n")
+		return true
+	}
+
+	evt := c.h.KeepAlivePayload()
+	return c.write(&evt)
+}
+
+// flush writes the connection's buffer to the client, and flushes it.
+// It returns false if flushing failed, and the connection should be closed.
+func (c *conn) flush() bool {
+	c.setWriteDeadline()
+	defer c.clearWriteDeadline()
+
+	n, err := c.w.Write(c.buf.Bytes())
+	c.buf.Reset()
+	if c.stats != nil {
+		c.stats.bytes.Add(uint64(n))
+	}
+	if err != nil {
+		c.writeFailed(err)
+		return false
+	}
+
+	if err := c.rc.Flush(); err != nil {
+		c.writeFailed(err)
+		return false
+	}
+	c.lastWrite.Store(time.Now().UnixNano())
+	return c.checkLatency()
+}
+
+// writeFailed reports a write to the client that failed because it timed out to OnSlowClient, or Logger.
+// Other failures, e.g. the client disconnecting, are not reported.
+func (c *conn) writeFailed(err error) {
+	if c.h.WriteTimeout <= 0 || !errors.Is(err, os.ErrDeadlineExceeded) {
+		return
+	}
+
+	if c.h.OnSlowClient != nil {
+		c.h.OnSlowClient(c.id)
+	} else {
+		c.log(slog.LevelWarn, "closing connection to slow client", err)
+	}
+}
+
+// checkLatency measures the latency of the events just flushed, from the oldest being sent to now,
+// against the Handler's LatencyBudget, and marks the connection as lagging if it was exceeded.
+// It returns false if the connection should be closed, since it is lagging, and DisconnectLagging is set.
+func (c *conn) checkLatency() bool {
+	sent := c.oldest
+	c.oldest = time.Time{}
+	if c.h.LatencyBudget <= 0 || sent.IsZero() || c.lagging == nil {
+		return true
+	}
+
+	latency := time.Since(sent)
+	if latency <= c.h.LatencyBudget {
+		c.lagging.Store(false)
+		return true
+	}
+
+	if !c.lagging.Swap(true) {
+		if c.h.OnLagging != nil {
+			c.h.OnLagging(c.id, latency)
 		} else {
-			buf.WriteString("id:")
-			buf.WriteString(evt.ID)
-			buf.WriteByte('\n')
+			c.log(slog.LevelWarn, "connection is lagging",
+				fmt.Errorf("latency of %s exceeds the budget of %s", latency, c.h.LatencyBudget))
 		}
 	}
 
-	if evt.Retry > 0 {
-		retry := strconv.FormatInt(evt.Retry.Milliseconds(), 10)
-		buf.WriteString("retry:")
-		buf.WriteString(retry)
-		buf.WriteByte('\n')
+	if c.h.DisconnectLagging {
+		c.writeStreamError(NewStreamError(ReasonSlowClient, "latency budget exceeded"))
+		return false
 	}
+	return true
+}
 
-	return buf.Len() > 0
+func (c *conn) setWriteDeadline() {
+	if c.h.WriteTimeout > 0 {
+		c.rc.SetWriteDeadline(time.Now().Add(c.h.WriteTimeout))
+	}
 }
 
-func canFlush(w http.ResponseWriter) func() {
-	f, ok := w.(http.Flusher)
-	if !ok {
-		return nil
+// clearWriteDeadline removes the deadline set for a write, so that it does not apply to later writes,
+// e.g. keep-alives after a long idle period.
+func (c *conn) clearWriteDeadline() {
+	if c.h.WriteTimeout > 0 {
+		c.rc.SetWriteDeadline(time.Time{})
 	}
-	return f.Flush
 }
 
-func isAcceptable(r *http.Request) bool {
+// setStreamHeaders sets the headers of an event stream response, with the extensions enabled by exts.
+// They are only set once the NewEventStreamHandler has accepted the stream, so that it can still reject
+// it with an HTTPError.
+func setStreamHeaders(w http.ResponseWriter, exts extensionSet) {
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Content-Type", "text/event-stream")
+	if len(exts) > 0 {
+		w.Header().Set(ExtensionsHeader, exts.String())
+	}
+}
+
+// newConnID returns a random 128-bit identifier, hex encoded.
+func newConnID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// canFlush reports whether w can be flushed, directly, or through the http.ResponseWriter it wraps,
+// as by middleware which provides an Unwrap method, see http.ResponseController.
+func canFlush(w http.ResponseWriter) bool {
+	return unwrapsTo(w, func(w http.ResponseWriter) bool {
+		_, ok := w.(http.Flusher)
+		return ok
+	})
+}
+
+// unwrapsTo reports whether w, or any http.ResponseWriter it wraps, satisfies ok.
+func unwrapsTo(w http.ResponseWriter, ok func(http.ResponseWriter) bool) bool {
+	for {
+		if ok(w) {
+			return true
+		}
+
+		wrapper, isWrapper := w.(interface{ Unwrap() http.ResponseWriter })
+		if !isWrapper {
+			return false
+		}
+		w = wrapper.Unwrap()
+	}
+}
+
+// AcceptsEventStream reports whether r accepts a "text/event-stream" response, according to its Accept headers,
+// as described by RFC 9110: the most specific media range matching it, of "text/event-stream", "text/*",
+// and "*/*", decides, so that e.g. "*/*, text/event-stream;q=0" does not accept it, and a q-value of 0 means
+// it is not acceptable. A request without an Accept header accepts any response.
+func AcceptsEventStream(r *http.Request) bool {
 	acceptedTypes := r.Header.Values("Accept")
 	if len(acceptedTypes) == 0 {
 		return true
 	}
 
-	for _, contentType := range acceptedTypes {
-		parts := strings.SplitN(contentType, ";", 2)
-		parts[0] = strings.TrimSpace(parts[0])
+	specificity, quality := 0, 0.0
+	for _, field := range acceptedTypes {
+		for _, mediaRange := range strings.Split(field, ",") {
+			params := strings.Split(mediaRange, ";")
 
-		if parts[0] == "text/event-stream" || parts[0] == "text/*" || parts[0] == "*/*" {
-			return true
+			s := 0
+			switch strings.ToLower(strings.TrimSpace(params[0])) {
+			case "text/event-stream":
+				s = 3
+			case "text/*":
+				s = 2
+			case "*/*":
+				s = 1
+			}
+			if s == 0 || s < specificity {
+				continue
+			}
+
+			q, ok := acceptQuality(params[1:])
+			if !ok {
+				continue
+			}
+			if s > specificity || q > quality {
+				specificity, quality = s, q
+			}
 		}
 	}
 
-	return false
+	return quality > 0
 }
 
-type bufferPool struct{ sync.Pool }
-
-func (p *bufferPool) Get() bytes.Buffer { return p.Pool.Get().(bytes.Buffer) }
-func (p *bufferPool) Put(buf bytes.Buffer) {
-	buf.Reset()
-	p.Pool.Put(buf)
-}
+// acceptQuality returns the q-value among the parameters of a media range in an Accept header, or 1
+// if it has none, and reports whether it is valid.
+func acceptQuality(params []string) (float64, bool) {
+	for _, param := range params {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if !strings.EqualFold(strings.TrimSpace(name), "q") {
+			continue
+		}
 
-func newBufferPool() bufferPool {
-	return bufferPool{
-		Pool: sync.Pool{
-			New: func() interface{} { return bytes.Buffer{} },
-		},
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return q, err == nil && q >= 0 && q <= 1
 	}
+	return 1, true
 }
@@ -1,14 +1,54 @@
 package sse
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
+func TestAcceptsEventStream(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		accept   []string
+		expected bool
+	}{
+		{nil, true},
+		{[]string{"text/event-stream"}, true},
+		{[]string{"Text/Event-Stream"}, true},
+		{[]string{"application/json, text/event-stream"}, true},
+		{[]string{"text/*"}, true},
+		{[]string{"*/*;q=0.1"}, true},
+		{[]string{"application/json"}, false},
+		{[]string{"text/event-stream;q=0"}, false},
+		{[]string{"text/event-stream; q=0.0"}, false},
+		{[]string{"*/*", "text/event-stream;q=0"}, false},
+		{[]string{"text/*;q=0, text/event-stream"}, true},
+		{[]string{"text/*;q=0, */*"}, false},
+		{[]string{"text/event-stream;q=2"}, false},
+		{[]string{"text/event-stream;q=high, */*"}, true},
+		{[]string{""}, false},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, accept := range tt.accept {
+			r.Header.Add("Accept", accept)
+		}
+		if accepted := AcceptsEventStream(r); accepted != tt.expected {
+			t.Errorf("%q: expected %v, but got %v", tt.accept, tt.expected, accepted)
+		}
+	}
+}
+
 func TestHandlerServeHTTP(t *testing.T) {
 	t.Parallel()
 
@@ -41,6 +81,80 @@ func TestHandlerServeHTTP(t *testing.T) {
 		}
 	})
 
+	for _, park := range []bool{false, true} {
+		park := park
+
+		t.Run("flushes through middleware wrapping the ResponseWriter", func(t *testing.T) {
+			t.Parallel()
+
+			h := NewHandler(func(stream EventStream, lastEventID string) error {
+				stream.Go(func(ctx context.Context) error {
+					return stream.Send(Event{Data: []byte("wrapped")})
+				})
+				return nil
+			})
+			h.KeepAlive = time.Hour
+			h.Park = park
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				h.ServeHTTP(unwrappingWriter{w}, r)
+			}))
+			t.Cleanup(srv.Close)
+
+			resp, err := srv.Client().Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusOK || string(body) != "data:wrapped\n\n" {
+				t.Errorf("park %v: expected the event, but got %s: %q", park, resp.Status, body)
+			}
+		})
+	}
+
+	for _, park := range []bool{false, true} {
+		park := park
+
+		t.Run("batches queued events into one write and flush", func(t *testing.T) {
+			t.Parallel()
+
+			tests := []struct {
+				name     string
+				bytes    int
+				events   int
+				expected int
+			}{
+				{"default", 0, 0, 1},
+				{"event limit", 0, 3, 4},
+				{"byte limit", len("data:0\n\n") * 2, 0, 5},
+				{"each event", 1, 0, 10},
+			}
+			for _, tt := range tests {
+				h := NewHandlerBuffered(func(stream EventStream, lastEventID string) error {
+					for i := 0; i < 10; i++ {
+						if err := stream.Send(Event{Data: []byte(strconv.Itoa(i))}); err != nil {
+							return err
+						}
+					}
+					return stream.Close()
+				}, 16)
+				h.KeepAlive = time.Hour
+				h.Park = park
+				h.FlushBatchBytes = tt.bytes
+				h.FlushBatchEvents = tt.events
+
+				w := &flushCountingWriter{ResponseWriter: httptest.NewRecorder()}
+				h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+				if w.writes != tt.expected || w.flushes < w.writes {
+					t.Errorf("park %v: %s: expected %d writes, each flushed, but got %d writes, and %d flushes",
+						park, tt.name, tt.expected, w.writes, w.flushes)
+				}
+			}
+		})
+	}
+
 	t.Run("handles Accept header", func(t *testing.T) {
 		t.Parallel()
 
@@ -115,6 +229,53 @@ func TestHandlerServeHTTP(t *testing.T) {
 				t.Errorf("expected status %d, but got %d", http.StatusOK, resp.StatusCode)
 			}
 		})
+
+		t.Run("rejects q=0", func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			req.Header.Add("Accept", "*/*, text/event-stream;q=0")
+
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusNotAcceptable {
+				t.Errorf("expected status %d, but got %d", http.StatusNotAcceptable, resp.StatusCode)
+			}
+		})
+	})
+
+	t.Run("replaces Accept negotiation", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			return stream.Close()
+		}, WithAccept(func(r *http.Request) bool { return r.Header.Get("X-Internal") != "" }))
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+
+		for _, internal := range []bool{true, false} {
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			req.Header.Set("Accept", "application/json")
+			if internal {
+				req.Header.Set("X-Internal", "1")
+			}
+
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			expected := http.StatusNotAcceptable
+			if internal {
+				expected = http.StatusOK
+			}
+			if resp.StatusCode != expected {
+				t.Errorf("internal %v: expected status %d, but got %d", internal, expected, resp.StatusCode)
+			}
+		}
 	})
 
 	t.Run("Sets headers", func(t *testing.T) {
@@ -150,6 +311,73 @@ func TestHandlerServeHTTP(t *testing.T) {
 		}
 	})
 
+	t.Run("Sets headers from BeforeStream", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			return stream.Close()
+		}, WithBeforeStream(func(w http.ResponseWriter, r *http.Request) error {
+			w.Header().Set("Access-Control-Allow-Origin", "https://example.com")
+			w.Header().Set("X-Accel-Buffering", "no")
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+			return nil
+		}))
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if origin := resp.Header.Get("Access-Control-Allow-Origin"); origin != "https://example.com" {
+			t.Errorf("expected Access-Control-Allow-Origin %q, but got %q", "https://example.com", origin)
+		}
+		if buffering := resp.Header.Get("X-Accel-Buffering"); buffering != "no" {
+			t.Errorf("expected X-Accel-Buffering %q, but got %q", "no", buffering)
+		}
+		if cookies := resp.Cookies(); len(cookies) != 1 || cookies[0].Value != "1" {
+			t.Errorf("expected session cookie, but got %v", cookies)
+		}
+		if cc := resp.Header.Get("Content-Type"); cc != "text/event-stream" {
+			t.Errorf("expected Content-Type %q, but got %q", "text/event-stream", cc)
+		}
+	})
+
+	t.Run("Rejects requests from BeforeStream", func(t *testing.T) {
+		t.Parallel()
+
+		for _, tt := range []struct {
+			err    error
+			status int
+		}{
+			{NewHTTPError(http.StatusForbidden, "origin not allowed"), http.StatusForbidden},
+			{errors.New("session store unavailable"), http.StatusInternalServerError},
+		} {
+			called := false
+			h := NewHandler(func(stream EventStream, lastEventID string) error {
+				called = true
+				return stream.Close()
+			}, WithBeforeStream(func(w http.ResponseWriter, r *http.Request) error { return tt.err }))
+			srv := httptest.NewServer(h)
+
+			resp, err := srv.Client().Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			srv.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Errorf("%v: expected status %d, but got %d", tt.err, tt.status, resp.StatusCode)
+			}
+			if called {
+				t.Errorf("%v: expected NewEventStreamHandler not to be called", tt.err)
+			}
+		}
+	})
+
 	t.Run("Passes Last-Event-ID", func(t *testing.T) {
 		t.Parallel()
 
@@ -177,6 +405,127 @@ func TestHandlerServeHTTP(t *testing.T) {
 		}
 	})
 
+	t.Run("sends comments", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			stream.Go(func(ctx context.Context) error {
+				return stream.SendComment("checkpoint 1")
+			})
+			return nil
+		})
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		if expected := ": checkpoint 1\n\n"; string(body) != expected {
+			t.Errorf("expected %q, but got %q", expected, body)
+		}
+	})
+
+	t.Run("sends padding on connect", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			stream.Go(func(ctx context.Context) error {
+				return stream.Send(Event{Data: []byte("hello")})
+			})
+			return nil
+		}, WithPadding(DefaultPadding), WithDefaultRetry(time.Second))
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if buffering := resp.Header.Get("X-Accel-Buffering"); buffering != "no" {
+			t.Errorf("expected X-Accel-Buffering %q, but got %q", "no", buffering)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		padding, rest, _ := strings.Cut(string(body), "\n\n")
+		if len(padding)+len("\n\n") != DefaultPadding || strings.Trim(padding, " ") != ":" {
+			t.Errorf("expected a %d byte comment, but got %q", DefaultPadding, padding)
+		}
+		if expected := "retry:1000\n\ndata:hello\n\n"; rest != expected {
+			t.Errorf("expected %q after the padding, but got %q", expected, rest)
+		}
+	})
+
+	for _, park := range []bool{false, true} {
+		park := park
+
+		t.Run("sends DefaultRetry on connect", func(t *testing.T) {
+			t.Parallel()
+
+			send := make(chan struct{})
+			h := NewHandler(func(stream EventStream, lastEventID string) error {
+				stream.Go(func(ctx context.Context) error {
+					<-send
+					return stream.Send(Event{Data: []byte("hello")})
+				})
+				return nil
+			}, WithDefaultRetry(3*time.Second), WithKeepAlive(time.Hour))
+			h.Park = park
+			srv := httptest.NewServer(h)
+			t.Cleanup(srv.Close)
+
+			resp, err := srv.Client().Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			// the retry field arrives before any events are sent
+			expected := "retry:3000\n\n"
+			buf := make([]byte, len(expected))
+			if _, err := io.ReadFull(resp.Body, buf); err != nil || string(buf) != expected {
+				t.Errorf("expected %q, but got %q (%v)", expected, buf, err)
+			}
+
+			close(send)
+			body, _ := io.ReadAll(resp.Body)
+			if expected := "data:hello\n\n"; string(body) != expected {
+				t.Errorf("expected %q, but got %q", expected, body)
+			}
+		})
+	}
+
+	t.Run("Passes the request", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			r := stream.Request()
+			if topic := r.URL.Query().Get("topic"); topic != "orders" {
+				t.Errorf("expected topic query parameter %q, but got %q", "orders", topic)
+			}
+			if auth := r.Header.Get("Authorization"); auth != "Bearer token" {
+				t.Errorf("expected Authorization header %q, but got %q", "Bearer token", auth)
+			}
+			return stream.Close()
+		})
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"?topic=orders", nil)
+		req.Header.Set("Authorization", "Bearer token")
+
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	})
+
 	t.Run("Sends Keep-Alive comments", func(t *testing.T) {
 		t.Parallel()
 
@@ -212,6 +561,77 @@ func TestHandlerServeHTTP(t *testing.T) {
 		}
 	})
 
+	for _, park := range []bool{false, true} {
+		park := park
+
+		t.Run("sends custom keep-alive payloads", func(t *testing.T) {
+			t.Parallel()
+
+			for _, tt := range []struct {
+				payload  func() Event
+				expected string
+			}{
+				{func() Event { return Event{Comment: "still here"} }, ": still here\n\n"},
+				{Heartbeat("heartbeat"), "event:heartbeat\ndata:{\"time\":"},
+			} {
+				h := NewHandler(func(stream EventStream, lastEventID string) error {
+					stream.Go(func(ctx context.Context) error {
+						time.Sleep(150 * time.Millisecond)
+						return nil
+					})
+					return nil
+				}, WithKeepAlive(50*time.Millisecond), WithKeepAlivePayload(tt.payload))
+				h.Park = park
+				srv := httptest.NewServer(h)
+
+				resp, err := srv.Client().Get(srv.URL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				srv.Close()
+
+				if !strings.HasPrefix(string(body), tt.expected) || bytes.Contains(body, []byte(": keep-alive")) {
+					t.Errorf("park %v: expected keep-alives starting with %q, but got %q", park, tt.expected, body)
+				}
+			}
+		})
+
+		t.Run("only sends Keep-Alive comments once idle", func(t *testing.T) {
+			t.Parallel()
+
+			h := NewHandler(func(stream EventStream, lastEventID string) error {
+				stream.Go(func(ctx context.Context) error {
+					for i := 0; i < 8; i++ {
+						if err := stream.Send(Event{Data: []byte("tick")}); err != nil {
+							return err
+						}
+						time.Sleep(30 * time.Millisecond)
+					}
+					time.Sleep(250 * time.Millisecond)
+					return nil
+				})
+				return nil
+			}, WithKeepAlive(100*time.Millisecond))
+			h.Park = park
+			srv := httptest.NewServer(h)
+			t.Cleanup(srv.Close)
+
+			resp, err := srv.Client().Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			keepAlive := bytes.Index(body, []byte(": keep-alive"))
+			if keepAlive < 0 || keepAlive < bytes.LastIndex(body, []byte("data:tick")) {
+				t.Errorf("park %v: expected keep-alives only after the last event, but got %q", park, body)
+			}
+		})
+	}
+
 	t.Run("writes", func(t *testing.T) {
 		t.Parallel()
 
@@ -262,4 +682,644 @@ func TestHandlerServeHTTP(t *testing.T) {
 			t.Errorf("expected 'retry:250' in response body, but was not found: %q", body)
 		}
 	})
+
+	t.Run("writes DataReader", func(t *testing.T) {
+		t.Parallel()
+
+		// long enough to be read in multiple chunks, with lines spanning chunk boundaries
+		line := strings.Repeat("x", 3000)
+		data := strings.Join([]string{line, line, line, line}, "\n")
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			go func() {
+				stream.Send(Event{
+					Event:      "large",
+					DataReader: strings.NewReader(data),
+					ID:         "1",
+				})
+				stream.Close()
+			}()
+			return nil
+		})
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		client := srv.Client()
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal("failed to read response body:", err)
+		}
+
+		expected := "event:large\n" +
+			"data:" + line + "\n" +
+			"data:" + line + "\n" +
+			"data:" + line + "\n" +
+			"data:" + line + "\n" +
+			"id:1\n\n"
+		if string(body) != expected {
+			t.Errorf("expected response body %q, but got %q", expected, body)
+		}
+	})
+
+	t.Run("assigns connection IDs", func(t *testing.T) {
+		t.Parallel()
+
+		streamIDs := make(chan string, 2)
+		connected := make(chan string, 2)
+		disconnected := make(chan string, 2)
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			streamIDs <- stream.ID()
+			return stream.Close()
+		})
+		h.OnConnect = func(connID string, r *http.Request) { connected <- connID }
+		h.OnDisconnect = func(connID string) { disconnected <- connID }
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		for i := 0; i < 2; i++ {
+			resp, err := srv.Client().Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		first, second := <-streamIDs, <-streamIDs
+		if first == "" || first == second {
+			t.Errorf("expected unique connection IDs, but got %q and %q", first, second)
+		}
+
+		for _, id := range []string{first, second} {
+			if connID := <-connected; connID != id {
+				t.Errorf("expected OnConnect to be called with %q, but got %q", id, connID)
+			}
+			if connID := <-disconnected; connID != id {
+				t.Errorf("expected OnDisconnect to be called with %q, but got %q", id, connID)
+			}
+		}
+	})
+
+	t.Run("closes connections to clients that stop reading", func(t *testing.T) {
+		t.Parallel()
+
+		large := Event{Data: bytes.Repeat([]byte("x"), 64*1024)}
+		h := NewHandler(FromSource(EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+			for {
+				if err := send(large); err != nil {
+					return err
+				}
+			}
+		})))
+		h.WriteTimeout = 100 * time.Millisecond
+
+		slow := make(chan string, 1)
+		h.OnSlowClient = func(connID string) { slow <- connID }
+		disconnected := make(chan string, 1)
+		h.OnDisconnect = func(connID string) { disconnected <- connID }
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		select {
+		case id := <-disconnected:
+			select {
+			case slowID := <-slow:
+				if slowID != id {
+					t.Errorf("expected OnSlowClient to be called with %q, but got %q", id, slowID)
+				}
+			default:
+				t.Error("expected OnSlowClient to be called")
+			}
+		case <-time.After(10 * time.Second):
+			t.Error("expected connection to be closed after write timed out")
+		}
+	})
+
+	t.Run("detects lagging connections", func(t *testing.T) {
+		t.Parallel()
+
+		lagging := make(chan time.Duration, 1)
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			stream.Go(func(ctx context.Context) error {
+				if err := stream.Send(Event{Data: []byte("late")}); err != nil {
+					return err
+				}
+
+				latency := <-lagging
+				if latency <= time.Nanosecond {
+					t.Errorf("expected latency over the budget, but got %s", latency)
+				}
+				if !stream.Lagging() {
+					t.Error("expected stream to be lagging")
+				}
+				return nil
+			})
+			return nil
+		})
+		h.LatencyBudget = time.Nanosecond
+		h.OnLagging = func(connID string, latency time.Duration) { lagging <- latency }
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		if !bytes.Contains(body, []byte("data:late\n\n")) {
+			t.Errorf("expected event to be sent, but got %q", body)
+		}
+	})
+
+	t.Run("disconnects lagging connections", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(FromSource(EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+			for {
+				if err := send(Event{Data: []byte("event")}); err != nil {
+					return err
+				}
+			}
+		})))
+		h.LatencyBudget = time.Nanosecond
+		h.DisconnectLagging = true
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		if !bytes.Contains(body, []byte(`"code":"slow_client"`)) {
+			t.Errorf("expected a slow_client stream error, but got %q", body)
+		}
+	})
+
+	t.Run("allows overriding keep-alive per stream", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			if lastEventID == "disable" {
+				stream.SetKeepAlive(0)
+			} else {
+				stream.SetKeepAlive(100 * time.Millisecond)
+			}
+
+			go func() {
+				<-time.After(350 * time.Millisecond)
+				stream.Close()
+			}()
+			return nil
+		})
+		h.KeepAlive = time.Hour
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		for _, tc := range []struct {
+			lastEventID string
+			expected    bool
+		}{
+			{"", true},
+			{"disable", false},
+		} {
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			req.Header.Set("Last-Event-ID", tc.lastEventID)
+
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatal("failed to read response body:", err)
+			}
+
+			if sent := bytes.Contains(body, []byte(": keep-alive")); sent != tc.expected {
+				t.Errorf("Last-Event-ID %q: expected keep-alive = %v, but got %q", tc.lastEventID, tc.expected, body)
+			}
+		}
+	})
+
+	t.Run("applies configuration updates to existing connections", func(t *testing.T) {
+		t.Parallel()
+
+		connected := make(chan struct{})
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			go func() {
+				<-time.After(10 * time.Second)
+				stream.Close()
+			}()
+			return nil
+		})
+		h.OnConnect = func(string, *http.Request) { close(connected) }
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		go func() {
+			<-connected
+			h.UpdateConfig(func(cfg *Handler) {
+				cfg.KeepAlive = 50 * time.Millisecond
+			})
+		}()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		line, err := bufio.NewReader(resp.Body).ReadString('\n')
+		if err != nil {
+			t.Fatal("failed to read response body:", err)
+		}
+		if line != ": keep-alive\n" {
+			t.Errorf("expected a keep-alive, but got %q", line)
+		}
+
+		if cfg, _ := h.load(); cfg.KeepAlive != 50*time.Millisecond || h.KeepAlive != 0 {
+			t.Errorf("expected the update to replace the configuration, but got %v", cfg.KeepAlive)
+		}
+	})
+
+	t.Run("Send returns once client disconnects", func(t *testing.T) {
+		t.Parallel()
+
+		sent := make(chan error, 1)
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			go func() {
+				<-stream.Context().Done()
+				sent <- stream.Send(Event{Data: []byte("too late")})
+			}()
+			return nil
+		})
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		go func() {
+			<-time.After(100 * time.Millisecond)
+			cancel()
+		}()
+		if resp, err := srv.Client().Do(req); err == nil {
+			resp.Body.Close()
+		}
+
+		select {
+		case err := <-sent:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("expected context.Canceled, but got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("expected Send to return after the client disconnected")
+		}
+	})
+
+	t.Run("SendContext returns once its context is done", func(t *testing.T) {
+		t.Parallel()
+
+		// nothing receives from events, as if the connection were busy writing
+		stream := EventStream{
+			ctx:    context.Background(),
+			events: make(chan queuedEvent),
+			queue:  newQueueAccount(DefaultAllocator),
+			closer: newStreamCloser(),
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		done := make(chan error, 1)
+		go func() { done <- stream.SendContext(ctx, Event{Data: []byte("blocked")}) }()
+
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("expected context.DeadlineExceeded, but got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("expected SendContext to return once its context was done")
+		}
+	})
+
+	t.Run("TrySend drops events the stream is not ready for", func(t *testing.T) {
+		t.Parallel()
+
+		stream := EventStream{
+			ctx:     context.Background(),
+			events:  make(chan queuedEvent, 1),
+			queue:   newQueueAccount(DefaultAllocator),
+			closer:  newStreamCloser(),
+			dropped: new(atomic.Uint64),
+		}
+
+		if !stream.TrySend(Event{Data: []byte("queued")}) {
+			t.Error("expected first event to be queued")
+		}
+		if stream.TrySend(Event{Data: []byte("dropped")}) {
+			t.Error("expected event to be dropped once the buffer is full")
+		}
+		if dropped := stream.Dropped(); dropped != 1 {
+			t.Errorf("expected 1 dropped event, but got %d", dropped)
+		}
+
+		<-stream.events
+		stream.Close()
+		if stream.TrySend(Event{Data: []byte("closed")}) {
+			t.Error("expected event to be dropped once the stream is closed")
+		}
+	})
+
+	t.Run("SendContext returns once client disconnects", func(t *testing.T) {
+		t.Parallel()
+
+		sent := make(chan error, 1)
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			go func() {
+				<-stream.Context().Done()
+				sent <- stream.SendContext(context.Background(), Event{Data: []byte("too late")})
+			}()
+			return nil
+		})
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		go func() {
+			<-time.After(100 * time.Millisecond)
+			cancel()
+		}()
+		if resp, err := srv.Client().Do(req); err == nil {
+			resp.Body.Close()
+		}
+
+		select {
+		case err := <-sent:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("expected context.Canceled, but got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("expected SendContext to return after the client disconnected")
+		}
+	})
+
+	t.Run("derives stream context from BaseContext", func(t *testing.T) {
+		t.Parallel()
+
+		base, cancelBase := context.WithCancel(context.WithValue(context.Background(), contextKey("db"), "handle"))
+		defer cancelBase()
+
+		values := make(chan [2]interface{}, 1)
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			ctx := stream.Context()
+			values <- [2]interface{}{ctx.Value(contextKey("db")), ctx.Value(http.ServerContextKey)}
+			go func() {
+				<-ctx.Done()
+				stream.Close()
+			}()
+			return nil
+		})
+		h.BaseContext = func(r *http.Request) context.Context { return base }
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		go func() {
+			<-time.After(50 * time.Millisecond)
+			cancelBase()
+		}()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if _, err := io.ReadAll(resp.Body); err != nil {
+			t.Error("expected the stream to end once BaseContext is done, but got", err)
+		}
+
+		v := <-values
+		if v[0] != "handle" || v[1] == nil {
+			t.Errorf("expected values of both contexts, but got %v", v)
+		}
+	})
+
+	t.Run("Wait returns once the connection ends", func(t *testing.T) {
+		t.Parallel()
+
+		streams := make(chan EventStream, 1)
+		var disconnected atomic.Bool
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			streams <- stream
+			return nil
+		})
+		h.OnDisconnect = func(string) { disconnected.Store(true) }
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		// the response starts with the first event
+		bodies := make(chan []byte, 1)
+		go func() {
+			resp, err := srv.Client().Get(srv.URL)
+			if err != nil {
+				bodies <- nil
+				return
+			}
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			bodies <- body
+		}()
+		stream := <-streams
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := stream.WaitContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected WaitContext to time out while connected, but got %v", err)
+		}
+
+		stream.Send(Event{Data: []byte("last")})
+		stream.Close()
+		stream.Wait()
+
+		if !disconnected.Load() {
+			t.Error("expected OnDisconnect to be called before Wait returns")
+		}
+		if body := <-bodies; string(body) != "data:last\n\n" {
+			t.Errorf("expected event to be flushed, but got %q", body)
+		}
+	})
+
+	t.Run("Done is closed once the client disconnects", func(t *testing.T) {
+		t.Parallel()
+
+		done := make(chan struct{})
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			stream.Go(func(ctx context.Context) error {
+				if err := stream.Send(Event{Data: []byte("first")}); err != nil {
+					return err
+				}
+				<-stream.Done()
+				close(done)
+				return nil
+			})
+			return nil
+		})
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		select {
+		case <-done:
+			t.Fatal("expected Done to be open while connected")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Error("expected Done to be closed once the client disconnected")
+		}
+	})
+
+	t.Run("Send and Close are safe to race", func(t *testing.T) {
+		t.Parallel()
+
+		const senders = 20
+
+		results := make(chan error, senders)
+		h := NewHandlerBuffered(func(stream EventStream, lastEventID string) error {
+			var ready sync.WaitGroup
+			ready.Add(senders)
+			for i := 0; i < senders; i++ {
+				go func() {
+					ready.Done()
+					results <- stream.Send(Event{Data: []byte("event")})
+				}()
+			}
+
+			go func() {
+				ready.Wait()
+				stream.Close()
+				stream.Close()
+			}()
+			return nil
+		}, 4)
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal("failed to read response body:", err)
+		}
+
+		sent := 0
+		for i := 0; i < senders; i++ {
+			switch err := <-results; {
+			case err == nil:
+				sent++
+			case !errors.Is(err, ErrStreamClosed):
+				t.Errorf("expected ErrStreamClosed, but got %v", err)
+			}
+		}
+		if received := bytes.Count(body, []byte("data:event\n\n")); received != sent {
+			t.Errorf("expected the %d events sent to be received, but got %d", sent, received)
+		}
+	})
+
+	t.Run("Send fails after Close", func(t *testing.T) {
+		t.Parallel()
+
+		errs := make(chan error, 1)
+		h := NewHandler(func(stream EventStream, lastEventID string) error {
+			stream.Close()
+			errs <- stream.Send(Event{Data: []byte("too late")})
+
+			if err := stream.SendContext(context.Background(), Event{Data: []byte("too late")}); !errors.Is(err, ErrStreamClosed) {
+				t.Errorf("expected SendContext to fail with ErrStreamClosed, but got %v", err)
+			}
+			if stream.TrySend(Event{Data: []byte("too late")}) {
+				t.Error("expected TrySend to fail")
+			}
+			if err := stream.CloseWithError(NewStreamError(ReasonServerError, "")); !errors.Is(err, ErrStreamClosed) {
+				t.Errorf("expected CloseWithError to fail with ErrStreamClosed, but got %v", err)
+			}
+			if err := stream.Close(); err != nil {
+				t.Errorf("expected closing again to succeed, but got %v", err)
+			}
+			return nil
+		})
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if err := <-errs; !errors.Is(err, ErrStreamClosed) {
+			t.Errorf("expected ErrStreamClosed, but got %v", err)
+		}
+	})
+}
+
+// flushCountingWriter counts the writes of data to an http.ResponseWriter, and the flushes of it.
+type flushCountingWriter struct {
+	http.ResponseWriter
+	writes  int
+	flushes int
 }
+
+func (w *flushCountingWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		w.writes++
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *flushCountingWriter) Flush() {
+	w.flushes++
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+// unwrappingWriter wraps an http.ResponseWriter as middleware does, hiding its other methods, but providing Unwrap.
+type unwrappingWriter struct {
+	http.ResponseWriter
+}
+
+func (w unwrappingWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -0,0 +1,324 @@
+package ssetest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sse "github.com/dabbertorres/go-server-sent-events"
+)
+
+// DefaultBrokerTimeout is the default BrokerSuite.Timeout.
+const DefaultBrokerTimeout = 5 * time.Second
+
+// ProbeEvent is the event name of the events a BrokerSuite publishes until its subscribers receive them,
+// since a Broker's subscribers may only start receiving events some time after they start streaming.
+// They are not counted as the events of the suite's tests.
+const ProbeEvent = "ssetest-probe"
+
+// BrokerSuite tests that a sse.Broker behaves as the Handlers streaming its topics rely on, e.g. for an adapter
+// of a message queue:
+//
+//	func TestBroker(t *testing.T) {
+//	    ssetest.BrokerSuite{
+//	        New:     func(t *testing.T) sse.Broker { return redisbroker.New(client) },
+//	        Resumes: true,
+//	    }.Run(t)
+//	}
+//
+// It checks that the events published to a topic are delivered to each of its subscribers in order, and only
+// to them, that none are lost while events are published, and subscribers subscribe and unsubscribe,
+// concurrently, that subscribers stop streaming once their context is done, and, if the Broker Resumes,
+// that subscribers resume after their last event ID. Run with the race detector, it also checks that the
+// Broker is safe for concurrent use.
+//
+// Each test publishes to topics of its own, so a Broker may be shared between them.
+type BrokerSuite struct {
+	// New returns the Broker for a test.
+	New func(t *testing.T) sse.Broker
+
+	// Resumes is whether the Broker keeps events to resume subscribers after their last event ID.
+	Resumes bool
+
+	// Timeout is how long to wait for each event to be delivered. If 0, DefaultBrokerTimeout is used.
+	Timeout time.Duration
+}
+
+// Run runs the suite's tests, as subtests of t.
+func (s BrokerSuite) Run(t *testing.T) {
+	t.Run("delivers events in order", s.testOrder)
+	t.Run("isolates topics", s.testTopics)
+	t.Run("loses no events under concurrency", s.testConcurrency)
+	t.Run("unsubscribes once done", s.testUnsubscribe)
+	if s.Resumes {
+		t.Run("resumes after the last event ID", s.testResume)
+	}
+	t.Run("ignores unknown last event IDs", s.testUnknownID)
+}
+
+func (s BrokerSuite) testOrder(t *testing.T) {
+	t.Parallel()
+
+	b, topic := s.New(t), s.topic(t)
+	subs := []*subscriber{s.subscribe(t, b, topic, ""), s.subscribe(t, b, topic, "")}
+	s.warmUp(t, b, topic, subs...)
+
+	const n = 100
+	for i := 0; i < n; i++ {
+		s.publish(t, b, topic, strconv.Itoa(i))
+	}
+	for _, sub := range subs {
+		for i := 0; i < n; i++ {
+			s.expect(t, sub, strconv.Itoa(i))
+		}
+	}
+}
+
+func (s BrokerSuite) testTopics(t *testing.T) {
+	t.Parallel()
+
+	b, topic := s.New(t), s.topic(t)
+	first, second := s.subscribe(t, b, topic+"/a", ""), s.subscribe(t, b, topic+"/b", "")
+	s.warmUp(t, b, topic+"/a", first)
+	s.warmUp(t, b, topic+"/b", second)
+
+	s.publish(t, b, topic+"/a", "a1")
+	s.publish(t, b, topic+"/b", "b1")
+	s.publish(t, b, topic+"/a", "a2")
+	s.expect(t, first, "a1")
+	s.expect(t, first, "a2")
+	s.expect(t, second, "b1")
+}
+
+func (s BrokerSuite) testConcurrency(t *testing.T) {
+	t.Parallel()
+
+	const (
+		publishers = 4
+		events     = 50
+		churn      = 20
+	)
+
+	b, topic := s.New(t), s.topic(t)
+	subs := []*subscriber{s.subscribe(t, b, topic, ""), s.subscribe(t, b, topic, ""), s.subscribe(t, b, topic, "")}
+	s.warmUp(t, b, topic, subs...)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, publishers)
+	for p := 0; p < publishers; p++ {
+		p := p
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < events; i++ {
+				id := fmt.Sprintf("%d-%d", p, i)
+				if err := b.Publish(context.Background(), topic, sse.Event{Data: []byte(id), ID: id}); err != nil {
+					errs <- fmt.Errorf("publishing %s: %w", id, err)
+					return
+				}
+			}
+		}()
+	}
+
+	// subscribers coming and going while events are published must not disturb the others
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < churn; i++ {
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				b.Subscribe(topic, "").Stream(ctx, func(evt sse.Event) error { return nil })
+			}()
+			time.Sleep(time.Millisecond)
+			cancel()
+			<-done
+		}
+	}()
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	for n, sub := range subs {
+		next := make([]int, publishers)
+		for received := 0; received < publishers*events; received++ {
+			evt := s.next(t, sub)
+
+			var p, i int
+			if _, err := fmt.Sscanf(evt.ID, "%d-%d", &p, &i); err != nil || p < 0 || p >= publishers {
+				t.Fatalf("subscriber %d: expected an event published by the test, but got %+v", n, evt)
+			}
+			if i != next[p] {
+				t.Fatalf("subscriber %d: expected event %d-%d, but got %s", n, p, next[p], evt.ID)
+			}
+			next[p]++
+		}
+	}
+}
+
+func (s BrokerSuite) testUnsubscribe(t *testing.T) {
+	t.Parallel()
+
+	b, topic := s.New(t), s.topic(t)
+	sub := s.subscribe(t, b, topic, "")
+	s.warmUp(t, b, topic, sub)
+
+	sub.cancel()
+	select {
+	case <-sub.done:
+	case <-time.After(s.timeout()):
+		t.Fatal("expected the subscriber to stop streaming once its context was done")
+	}
+
+	// publishing must not be held up by subscribers that have gone
+	s.publish(t, b, topic, "after")
+}
+
+func (s BrokerSuite) testResume(t *testing.T) {
+	t.Parallel()
+
+	b, topic := s.New(t), s.topic(t)
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		s.publish(t, b, topic, id)
+	}
+
+	sub := s.subscribe(t, b, topic, "3")
+	s.warmUp(t, b, topic, sub)
+	s.publish(t, b, topic, "6")
+
+	for _, id := range []string{"4", "5", "6"} {
+		s.expect(t, sub, id)
+	}
+}
+
+func (s BrokerSuite) testUnknownID(t *testing.T) {
+	t.Parallel()
+
+	b, topic := s.New(t), s.topic(t)
+	s.publish(t, b, topic, "1")
+
+	sub := s.subscribe(t, b, topic, "unknown")
+	s.warmUp(t, b, topic, sub)
+	s.publish(t, b, topic, "2")
+	s.expect(t, sub, "2")
+}
+
+func (s BrokerSuite) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return DefaultBrokerTimeout
+}
+
+// topic returns a topic for t that no other test publishes to.
+func (s BrokerSuite) topic(t *testing.T) string {
+	return "ssetest/" + strings.ReplaceAll(t.Name(), " ", "-") + "/" + strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+func (s BrokerSuite) publish(t *testing.T, b sse.Broker, topic, id string) {
+	t.Helper()
+
+	if err := b.Publish(context.Background(), topic, sse.Event{Data: []byte(id), ID: id}); err != nil {
+		t.Fatalf("publishing %s: %v", id, err)
+	}
+}
+
+// subscriber is a subscription to a topic of a Broker, streaming until the test ends.
+type subscriber struct {
+	events chan sse.Event
+	probed chan struct{}
+	done   chan struct{}
+	cancel context.CancelFunc
+}
+
+func (s BrokerSuite) subscribe(t *testing.T, b sse.Broker, topic, lastEventID string) *subscriber {
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := &subscriber{
+		events: make(chan sse.Event, 1024),
+		probed: make(chan struct{}),
+		done:   make(chan struct{}),
+		cancel: cancel,
+	}
+	t.Cleanup(func() {
+		cancel()
+		<-sub.done
+	})
+
+	var once sync.Once
+	go func() {
+		defer close(sub.done)
+		b.Subscribe(topic, lastEventID).Stream(ctx, func(evt sse.Event) error {
+			if evt.Event == ProbeEvent {
+				once.Do(func() { close(sub.probed) })
+				return nil
+			}
+
+			select {
+			case sub.events <- evt:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	return sub
+}
+
+// warmUp publishes ProbeEvents to topic until each of subs has received one.
+func (s BrokerSuite) warmUp(t *testing.T, b sse.Broker, topic string, subs ...*subscriber) {
+	t.Helper()
+
+	for deadline, i := time.Now().Add(s.timeout()), 0; ; i++ {
+		evt := sse.Event{Event: ProbeEvent, Data: []byte(strconv.Itoa(i))}
+		if err := b.Publish(context.Background(), topic, evt); err != nil {
+			t.Fatalf("publishing a probe: %v", err)
+		}
+
+		ready := true
+		for _, sub := range subs {
+			select {
+			case <-sub.probed:
+			default:
+				ready = false
+			}
+		}
+		if ready {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected subscribers of %q to receive the events published to it", topic)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// next returns the next event sub receives.
+func (s BrokerSuite) next(t *testing.T, sub *subscriber) sse.Event {
+	t.Helper()
+
+	select {
+	case evt := <-sub.events:
+		return evt
+	case <-time.After(s.timeout()):
+		t.Fatal("expected an event to be delivered")
+		return sse.Event{}
+	}
+}
+
+// expect checks that the next event sub receives is the one published with id.
+func (s BrokerSuite) expect(t *testing.T, sub *subscriber, id string) {
+	t.Helper()
+
+	if evt := s.next(t, sub); evt.ID != id || string(evt.Data) != id {
+		t.Fatalf("expected event %q, but got %+v", id, evt)
+	}
+}
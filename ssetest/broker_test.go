@@ -0,0 +1,29 @@
+package ssetest
+
+import (
+	"testing"
+
+	sse "github.com/dabbertorres/go-server-sent-events"
+)
+
+func TestBrokerSuite(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Bus", func(t *testing.T) {
+		t.Parallel()
+
+		BrokerSuite{
+			New:     func(t *testing.T) sse.Broker { return &sse.Bus{} },
+			Resumes: true,
+		}.Run(t)
+	})
+
+	t.Run("Tee", func(t *testing.T) {
+		t.Parallel()
+
+		BrokerSuite{
+			New:     func(t *testing.T) sse.Broker { return &sse.Tee{Broker: &sse.Bus{}} },
+			Resumes: true,
+		}.Run(t)
+	})
+}
@@ -0,0 +1,76 @@
+// Package ssetest provides utilities for testing the output of Server-Sent Events handlers.
+//
+// A typical test scripts a sequence of events, records the output of a configured *sse.Handler,
+// and compares it against a golden file:
+//
+//	h := sse.NewHandler(ssetest.Script(
+//	    sse.Event{Event: "hello", Data: []byte("world")},
+//	))
+//	ssetest.Golden(t, "testdata/hello.golden", ssetest.Record(h, httptest.NewRequest("GET", "/", nil)))
+//
+// Golden files are (re)written instead of compared when the UpdateEnv environment variable is set:
+//
+//	SSE_UPDATE_GOLDEN=1 go test ./...
+//
+// Implementations of sse.Broker can be tested with a BrokerSuite.
+package ssetest
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	sse "github.com/dabbertorres/go-server-sent-events"
+)
+
+// UpdateEnv is the environment variable that causes Golden to write golden files, when not empty.
+const UpdateEnv = "SSE_UPDATE_GOLDEN"
+
+// Script returns a sse.NewEventStreamHandler that sends events in order, then closes the stream.
+func Script(events ...sse.Event) sse.NewEventStreamHandler {
+	return func(stream sse.EventStream, lastEventID string) error {
+		go func() {
+			for _, evt := range events {
+				stream.Send(evt)
+			}
+			stream.Close()
+		}()
+		return nil
+	}
+}
+
+// Record serves r with h, and returns the response body once h returns.
+// h must eventually return on its own, e.g. by being a *sse.Handler serving a Script.
+func Record(h http.Handler, r *http.Request) []byte {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec.Body.Bytes()
+}
+
+// Golden compares got with the contents of the golden file at path, and reports a test error if they differ.
+// If the UpdateEnv environment variable is set, the golden file is written with got instead.
+func Golden(t testing.TB, path string, got []byte) {
+	t.Helper()
+
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create golden file directory: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("failed to write golden file: %v", err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (set %s=1 to create it): %v", UpdateEnv, err)
+	}
+
+	if !bytes.Equal(got, expected) {
+		t.Errorf("output does not match golden file %s (set %s=1 to update it)\nexpected:\n%q\ngot:\n%q", path, UpdateEnv, expected, got)
+	}
+}
@@ -0,0 +1,46 @@
+package ssetest
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	sse "github.com/dabbertorres/go-server-sent-events"
+)
+
+func TestGolden(t *testing.T) {
+	t.Parallel()
+
+	h := sse.NewHandler(Script(
+		sse.Event{Event: "hello", Data: []byte("multi\nline"), ID: "1"},
+		sse.Event{Data: []byte("second"), Retry: time.Second},
+		sse.Event{ResetID: true},
+	))
+
+	Golden(t, "testdata/script.golden", Record(h, httptest.NewRequest("GET", "/", nil)))
+}
+
+func TestGoldenMismatch(t *testing.T) {
+	t.Parallel()
+
+	if os.Getenv(UpdateEnv) != "" {
+		t.Skip("would overwrite the golden file")
+	}
+
+	h := sse.NewHandler(Script(sse.Event{Data: []byte("different")}))
+
+	ft := &fakeT{TB: t}
+	Golden(ft, "testdata/script.golden", Record(h, httptest.NewRequest("GET", "/", nil)))
+
+	if !ft.failed {
+		t.Error("expected mismatched output to fail the test")
+	}
+}
+
+type fakeT struct {
+	testing.TB
+	failed bool
+}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) { f.failed = true }
@@ -0,0 +1,77 @@
+package sse
+
+import (
+	"sync"
+)
+
+// streamState holds the values set on an EventStream, see EventStream.SetValue, along with the topic it
+// streams, if it streams a single one, for reporting dead letters.
+type streamState struct {
+	mu     sync.RWMutex
+	values map[string]interface{}
+	topic  string
+}
+
+func (s *streamState) setTopic(topic string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.topic = topic
+}
+
+// getTopic returns the topic set by setTopic, if any.
+func (s *streamState) getTopic() string {
+	if s == nil {
+		return ""
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.topic
+}
+
+// SetValue sets the value of key in the EventStream's state, e.g. the subscriber's display name or
+// preferences, which are available to the templates it renders, see SendTemplate.
+// It is safe to call concurrently. Setting a nil value deletes key.
+func (s EventStream) SetValue(key string, value interface{}) {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+
+	if value == nil {
+		delete(s.state.values, key)
+		return
+	}
+	if s.state.values == nil {
+		s.state.values = make(map[string]interface{})
+	}
+	s.state.values[key] = value
+}
+
+// Value returns the value of key in the EventStream's state, or nil if it has none, see SetValue.
+func (s EventStream) Value(key string) interface{} {
+	if s.state == nil {
+		return nil
+	}
+
+	s.state.mu.RLock()
+	defer s.state.mu.RUnlock()
+
+	return s.state.values[key]
+}
+
+// Values returns a copy of the EventStream's state, see SetValue.
+func (s EventStream) Values() map[string]interface{} {
+	values := make(map[string]interface{})
+	if s.state == nil {
+		return values
+	}
+
+	s.state.mu.RLock()
+	defer s.state.mu.RUnlock()
+
+	for k, v := range s.state.values {
+		values[k] = v
+	}
+	return values
+}
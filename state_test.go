@@ -0,0 +1,31 @@
+package sse
+
+import (
+	"testing"
+)
+
+func TestStreamState(t *testing.T) {
+	t.Parallel()
+
+	stream := EventStream{state: new(streamState)}
+	if v := stream.Value("name"); v != nil {
+		t.Errorf("expected no value, but got %v", v)
+	}
+
+	stream.SetValue("name", "alice")
+	stream.SetValue("plan", "pro")
+	if v := stream.Value("name"); v != "alice" {
+		t.Errorf("expected %q, but got %v", "alice", v)
+	}
+
+	values := stream.Values()
+	values["name"] = "bob"
+	if v := stream.Value("name"); v != "alice" {
+		t.Errorf("expected Values to return a copy, but got %v", v)
+	}
+
+	stream.SetValue("plan", nil)
+	if values := stream.Values(); len(values) != 1 {
+		t.Errorf("expected setting nil to delete the value, but got %v", values)
+	}
+}
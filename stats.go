@@ -0,0 +1,101 @@
+package sse
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// HandlerStats is a snapshot of a Handler's activity, see Handler.Stats.
+type HandlerStats struct {
+	// Active is the number of streams being served.
+	Active int
+
+	// Connections is the number of streams served since the Handler was created, including the active ones.
+	Connections uint64
+
+	// EventsSent is the number of events sent to clients, not counting comments, such as keep-alives,
+	// or events suppressed as duplicates.
+	EventsSent uint64
+
+	// BytesWritten is the number of bytes of streams written to clients.
+	BytesWritten uint64
+
+	// Rejected is the number of requests rejected by the Handler's ACL, Screen, ReconnectLimiter,
+	// or MaxConnections.
+	Rejected uint64
+
+	// DeadLetters is the number of events that could not be delivered, by reason, see Handler.DeadLetter.
+	DeadLetters map[string]uint64
+
+	// Streams holds the stats of each active stream, sorted by ID.
+	Streams []StreamStats
+}
+
+// StreamStats is a snapshot of an active stream's queue, see HandlerStats.
+type StreamStats struct {
+	// ID is the stream's ID, see EventStream.ID.
+	ID string
+
+	// QueuedEvents is the number of events sent on the stream that are waiting to be written to the client.
+	QueuedEvents int
+
+	// QueuedBytes is the memory held by those events, as accounted by the Handler's Allocator.
+	QueuedBytes int64
+
+	// Dropped is the number of events TrySend dropped, see EventStream.Dropped.
+	Dropped uint64
+
+	// Lagging reports whether the stream is lagging, see EventStream.Lagging.
+	Lagging bool
+}
+
+// handlerStats counts a Handler's activity.
+type handlerStats struct {
+	connections atomic.Uint64
+	events      atomic.Uint64
+	bytes       atomic.Uint64
+	rejected    atomic.Uint64
+
+	deadLetters deadLetterStats
+
+	// open is the number of connections being served, see Handler.MaxConnections.
+	open atomic.Int64
+}
+
+// Stats returns a snapshot of h's activity, e.g. for an operations dashboard.
+// The counts are gathered without stopping the Handler, so they may be slightly out of step with one another.
+func (h *Handler) Stats() HandlerStats {
+	streams := h.Streams()
+	stats := HandlerStats{
+		Active:       len(streams),
+		Connections:  h.stats.connections.Load(),
+		EventsSent:   h.stats.events.Load(),
+		BytesWritten: h.stats.bytes.Load(),
+		Rejected:     h.stats.rejected.Load(),
+		DeadLetters:  h.stats.deadLetters.snapshot(),
+		Streams:      make([]StreamStats, len(streams)),
+	}
+
+	for i, stream := range streams {
+		stats.Streams[i] = StreamStats{
+			ID:           stream.id,
+			QueuedEvents: len(stream.events),
+			QueuedBytes:  stream.queue.bytes(),
+			Dropped:      stream.Dropped(),
+			Lagging:      stream.Lagging(),
+		}
+	}
+	return stats
+}
+
+// countingWriter counts the bytes written to w in a Handler's stats.
+type countingWriter struct {
+	w     io.Writer
+	stats *handlerStats
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.stats.bytes.Add(uint64(n))
+	return n, err
+}
@@ -0,0 +1,62 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandlerStats(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	h := NewHandler(func(stream EventStream, lastEventID string) error {
+		stream.Go(func(ctx context.Context) error {
+			if err := stream.Send(Event{Data: []byte("hello")}); err != nil {
+				return err
+			}
+			<-release
+			return nil
+		})
+		return nil
+	})
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	if !scanner.Scan() || scanner.Text() != "data:hello" {
+		t.Fatalf("expected the event, but got %q", scanner.Text())
+	}
+
+	stats := h.Stats()
+	if stats.Active != 1 || stats.Connections != 1 {
+		t.Errorf("expected 1 active stream of 1 connection, but got %d of %d", stats.Active, stats.Connections)
+	}
+	if stats.EventsSent != 1 {
+		t.Errorf("expected 1 event sent, but got %d", stats.EventsSent)
+	}
+	if expected := uint64(len("data:hello\n\n")); stats.BytesWritten != expected {
+		t.Errorf("expected %d bytes written, but got %d", expected, stats.BytesWritten)
+	}
+	if len(stats.Streams) != 1 || stats.Streams[0].QueuedEvents != 0 || stats.Streams[0].QueuedBytes != 0 {
+		t.Errorf("expected 1 stream with nothing queued, but got %+v", stats.Streams)
+	}
+
+	close(release)
+	for deadline := time.Now().Add(5 * time.Second); h.Stats().Active != 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the stream to end")
+		}
+	}
+	if stats := h.Stats(); stats.Connections != 1 {
+		t.Errorf("expected the connection to still be counted, but got %d", stats.Connections)
+	}
+}
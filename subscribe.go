@@ -0,0 +1,251 @@
+package sse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrInvalidSubscription is returned (wrapped) when a SubscriptionDocument is malformed.
+var ErrInvalidSubscription = errors.New("sse: invalid subscription")
+
+// MaxSubscriptionSize is the maximum size of a SubscriptionDocument, in bytes.
+const MaxSubscriptionSize = 64 << 10
+
+// DefaultMaxTopics is the default maximum number of topics in a SubscriptionDocument.
+const DefaultMaxTopics = 32
+
+// SubscriptionDocument describes a subscription to several topics, for subscriptions that do not fit in a URL.
+// It is POSTed as JSON to a SubscriptionHandler, e.g. by a request from NewSubscriptionRequest:
+//
+//	{"topics": [{"topic": "orders/42", "after": "1234"}, {"topic": "alerts", "events": ["critical"]}]}
+type SubscriptionDocument struct {
+	Topics []TopicSubscription `json:"topics"`
+}
+
+// TopicSubscription is the subscription to a single topic of a SubscriptionDocument.
+type TopicSubscription struct {
+	Topic string `json:"topic"`
+
+	// Events, if not empty, limits the events delivered to those with these names.
+	Events []string `json:"events,omitempty"`
+
+	// Tags, if not empty, limits the events delivered to those with at least one of these tags. See Event.Tags.
+	Tags []string `json:"tags,omitempty"`
+
+	// After, if not empty, resumes the subscription after the event with this ID.
+	After string `json:"after,omitempty"`
+}
+
+// ParseSubscriptionDocument reads a SubscriptionDocument from r, and validates it, allowing up to maxTopics topics.
+// Unknown keys are rejected, so that misspelled fields are not silently ignored, as is anything after the document.
+func ParseSubscriptionDocument(r io.Reader, maxTopics int) (SubscriptionDocument, error) {
+	var doc SubscriptionDocument
+
+	data, err := io.ReadAll(io.LimitReader(r, MaxSubscriptionSize+1))
+	if err != nil {
+		return doc, err
+	}
+	if len(data) > MaxSubscriptionSize {
+		return doc, fmt.Errorf("%w: larger than %d bytes", ErrInvalidSubscription, MaxSubscriptionSize)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		return doc, fmt.Errorf("%w: %v", ErrInvalidSubscription, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return doc, fmt.Errorf("%w: content after the document", ErrInvalidSubscription)
+	}
+
+	return doc, doc.Validate(maxTopics)
+}
+
+// Validate reports whether d is a well formed subscription, to between 1 and maxTopics distinct topics,
+// with valid event names and IDs. The returned error wraps ErrInvalidSubscription if it is not.
+func (d SubscriptionDocument) Validate(maxTopics int) error {
+	if len(d.Topics) == 0 {
+		return fmt.Errorf("%w: no topics", ErrInvalidSubscription)
+	}
+	if len(d.Topics) > maxTopics {
+		return fmt.Errorf("%w: more than %d topics", ErrInvalidSubscription, maxTopics)
+	}
+
+	seen := make(map[string]bool, len(d.Topics))
+	for i, t := range d.Topics {
+		switch {
+		case t.Topic == "":
+			return fmt.Errorf("%w: topic %d has no name", ErrInvalidSubscription, i)
+		case seen[t.Topic]:
+			return fmt.Errorf("%w: topic %q is repeated", ErrInvalidSubscription, t.Topic)
+		case strings.ContainsAny(t.After, "\r\n\x00"):
+			return fmt.Errorf("%w: topic %q: after contains a line break or NUL character", ErrInvalidSubscription, t.Topic)
+		}
+		seen[t.Topic] = true
+
+		for _, name := range t.Events {
+			if name == "" || strings.ContainsAny(name, "\r\n\x00") {
+				return fmt.Errorf("%w: topic %q: invalid event name %q", ErrInvalidSubscription, t.Topic, name)
+			}
+		}
+		for _, tag := range t.Tags {
+			if tag == "" || strings.ContainsAny(tag, ",\r\n\x00") {
+				return fmt.Errorf("%w: topic %q: invalid tag %q", ErrInvalidSubscription, t.Topic, tag)
+			}
+		}
+	}
+	return nil
+}
+
+// NewSubscriptionRequest returns a request POSTing doc to the SubscriptionHandler at url.
+func NewSubscriptionRequest(ctx context.Context, url string, doc SubscriptionDocument) (*http.Request, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// SubscriptionHandler streams the events a Broker delivers for the topics of the SubscriptionDocument
+// POSTed to it. Invalid documents are rejected with a 400, before the stream starts.
+//
+// Each topic's events are delivered as they are published, interleaved with those of other topics
+// as defined by Join.
+// A Last-Event-ID header resumes the subscription to the document's topic if it has only one,
+// and no After; otherwise clients resume by POSTing a document with the Afters they need.
+type SubscriptionHandler struct {
+	// Handler serves the streams, and can be configured as usual.
+	*Handler
+
+	// MaxTopics is the maximum number of topics in a document. The default is DefaultMaxTopics.
+	MaxTopics int
+
+	// Join defines the order in which the events of the document's topics are delivered.
+	// The zero value delivers them as Combine does.
+	Join Join
+
+	// Transform, if not nil, returns the Transform to apply to the events of topic, e.g. a downsampler
+	// chosen by r's query parameters, or nil to deliver them as they are. Transforms are applied after
+	// the topic's events are filtered by name and tag.
+	// If it returns an error, the subscription is rejected with a 400, before the stream starts.
+	Transform func(r *http.Request, topic TopicSubscription) (Transform, error)
+
+	broker Broker
+}
+
+// NewSubscriptionHandler returns a *SubscriptionHandler subscribing to topics of b, whose Handler is configured by opts.
+func NewSubscriptionHandler(b Broker, opts ...Option) *SubscriptionHandler {
+	h := &SubscriptionHandler{broker: b}
+	h.Handler = NewHandler(h.stream, opts...)
+	return h
+}
+
+type subscriptionKey struct{}
+
+// subscription is a validated SubscriptionDocument, along with the Transforms of its topics.
+type subscription struct {
+	doc        SubscriptionDocument
+	transforms []Transform
+}
+
+// SubscriptionFromContext returns the SubscriptionDocument of the request ctx belongs to,
+// e.g. EventStream.Context, if it was served by a SubscriptionHandler.
+func SubscriptionFromContext(ctx context.Context) (SubscriptionDocument, bool) {
+	sub, ok := ctx.Value(subscriptionKey{}).(subscription)
+	return sub.doc, ok
+}
+
+// ServeHTTP validates the SubscriptionDocument POSTed in r, and streams its topics.
+// The Handler's checks of requests, e.g. its ACL, are made before the document is read.
+func (h *SubscriptionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Subscriptions must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg, changed := h.Handler.load()
+	if !cfg.admit(w, r) {
+		return
+	}
+
+	maxTopics := h.MaxTopics
+	if maxTopics <= 0 {
+		maxTopics = DefaultMaxTopics
+	}
+
+	doc, err := ParseSubscriptionDocument(r.Body, maxTopics)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sub := subscription{doc: doc}
+	if h.Transform != nil {
+		sub.transforms = make([]Transform, len(doc.Topics))
+		for i, t := range doc.Topics {
+			if sub.transforms[i], err = h.Transform(r, t); err != nil {
+				http.Error(w, fmt.Sprintf("%v: topic %q: %v", ErrInvalidSubscription, t.Topic, err), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	h.Handler.serve(w, r.WithContext(context.WithValue(r.Context(), subscriptionKey{}, sub)), cfg, changed)
+}
+
+func (h *SubscriptionHandler) stream(stream EventStream, lastEventID string) error {
+	sub, _ := stream.Context().Value(subscriptionKey{}).(subscription)
+	doc := sub.doc
+
+	if len(doc.Topics) == 1 {
+		stream.state.setTopic(doc.Topics[0].Topic)
+	}
+
+	sources := make([]EventSource, len(doc.Topics))
+	for i, t := range doc.Topics {
+		after := t.After
+		if after == "" && len(doc.Topics) == 1 {
+			after = lastEventID
+		}
+
+		sources[i] = h.broker.Subscribe(t.Topic, after)
+		if len(t.Events) > 0 {
+			sources[i] = filterEvents(sources[i], t.Events)
+		}
+		if len(t.Tags) > 0 {
+			sources[i] = FilterTags(t.Tags...)(sources[i])
+		}
+		if sub.transforms != nil && sub.transforms[i] != nil {
+			sources[i] = sub.transforms[i](sources[i])
+		}
+	}
+	return FromSource(h.Join.Sources(sources...))(stream, lastEventID)
+}
+
+// filterEvents returns an EventSource producing the events produced by src with one of names.
+func filterEvents(src EventSource, names []string) EventSource {
+	return EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+		return src.Stream(ctx, func(evt Event) error {
+			for _, name := range names {
+				if evt.Event == name {
+					return send(evt)
+				}
+			}
+			return nil
+		})
+	})
+}
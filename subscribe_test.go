@@ -0,0 +1,253 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSubscriptionDocument(t *testing.T) {
+	t.Parallel()
+
+	t.Run("parses documents", func(t *testing.T) {
+		t.Parallel()
+
+		doc, err := ParseSubscriptionDocument(strings.NewReader(`{
+			"topics": [{"topic": "orders/42", "after": "7"}, {"topic": "alerts", "events": ["critical"]}]
+		}`), DefaultMaxTopics)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []TopicSubscription{{Topic: "orders/42", After: "7"}, {Topic: "alerts", Events: []string{"critical"}}}
+		if len(doc.Topics) != 2 || doc.Topics[0].After != expected[0].After || doc.Topics[1].Events[0] != "critical" {
+			t.Errorf("expected %+v, but got %+v", expected, doc.Topics)
+		}
+	})
+
+	t.Run("rejects invalid documents", func(t *testing.T) {
+		t.Parallel()
+
+		for _, data := range []string{
+			`{}`,
+			`{"topics": []}`,
+			`{"topics": [{"topic": ""}]}`,
+			`{"topics": [{"topic": "a"}, {"topic": "a"}]}`,
+			`{"topics": [{"topic": "a"}, {"topic": "b"}, {"topic": "c"}]}`,
+			`{"topics": [{"topic": "a", "events": [""]}]}`,
+			`{"topics": [{"topic": "a", "tags": ["b,c"]}]}`,
+			`{"topics": [{"topic": "a", "after": "1\n"}]}`,
+			`{"topics": [{"topic": "a", "from": "1"}]}`,
+			`{"topics": [{"topic": "a"}]`,
+			`{"topics": [{"topic": "a"}]} garbage`,
+			`{"topics": [{"topic": "a"}]} {"topics": [{"topic": "b"}]}`,
+			`{"topics": [{"topic": "` + strings.Repeat("a", MaxSubscriptionSize) + `"}]}`,
+		} {
+			name := data
+			if len(name) > 64 {
+				name = name[:64]
+			}
+			if _, err := ParseSubscriptionDocument(strings.NewReader(data), 2); !errors.Is(err, ErrInvalidSubscription) {
+				t.Errorf("%s: expected ErrInvalidSubscription, but got %v", name, err)
+			}
+		}
+	})
+}
+
+// eventsBroker is a Broker whose topics each produce a set of events.
+type eventsBroker map[string][]Event
+
+func (b eventsBroker) Publish(ctx context.Context, topic string, evt Event) error { return nil }
+
+func (b eventsBroker) Subscribe(topic, lastEventID string) EventSource {
+	return EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+		for _, evt := range b[topic] {
+			evt.Data = []byte(topic + " after " + lastEventID)
+			if err := send(evt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func TestSubscriptionHandler(t *testing.T) {
+	t.Parallel()
+
+	b := eventsBroker{
+		"orders": {{Event: "created"}},
+		"alerts": {{Event: "info"}, {Event: "critical"}},
+		"tagged": {{Event: "urgent", Tags: []string{"urgent"}}, {Event: "routine"}},
+	}
+	srv := httptest.NewServer(NewSubscriptionHandler(b))
+	t.Cleanup(srv.Close)
+
+	subscribe := func(t *testing.T, doc SubscriptionDocument, lastEventID string) (*http.Response, string) {
+		t.Helper()
+
+		req, err := NewSubscriptionRequest(context.Background(), srv.URL, doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	t.Run("streams topics", func(t *testing.T) {
+		t.Parallel()
+
+		_, body := subscribe(t, SubscriptionDocument{Topics: []TopicSubscription{
+			{Topic: "orders", After: "1"},
+			{Topic: "alerts", Events: []string{"critical"}},
+		}}, "")
+
+		for _, expected := range []string{"event:created\ndata:orders after 1\n\n", "event:critical\ndata:alerts after \n\n"} {
+			if !strings.Contains(body, expected) {
+				t.Errorf("expected %q, but got %q", expected, body)
+			}
+		}
+		if strings.Contains(body, "event:info") {
+			t.Errorf("expected filtered events to be skipped, but got %q", body)
+		}
+	})
+
+	t.Run("filters topics by tag", func(t *testing.T) {
+		t.Parallel()
+
+		_, body := subscribe(t, SubscriptionDocument{Topics: []TopicSubscription{{Topic: "tagged", Tags: []string{"urgent"}}}}, "")
+		if expected := "event:urgent\ndata:tagged after \n\n"; body != expected {
+			t.Errorf("expected %q, but got %q", expected, body)
+		}
+	})
+
+	t.Run("resumes single topic from Last-Event-ID", func(t *testing.T) {
+		t.Parallel()
+
+		_, body := subscribe(t, SubscriptionDocument{Topics: []TopicSubscription{{Topic: "orders"}}}, "3")
+		if expected := "event:created\ndata:orders after 3\n\n"; body != expected {
+			t.Errorf("expected %q, but got %q", expected, body)
+		}
+	})
+
+	t.Run("rejects invalid documents", func(t *testing.T) {
+		t.Parallel()
+
+		resp, _ := subscribe(t, SubscriptionDocument{}, "")
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status %d, but got %d", http.StatusBadRequest, resp.StatusCode)
+		}
+	})
+
+	t.Run("applies transforms", func(t *testing.T) {
+		t.Parallel()
+
+		h := NewSubscriptionHandler(eventsBroker{"metrics": {{ID: "1"}, {ID: "2"}, {ID: "3"}}})
+		h.Transform = func(r *http.Request, topic TopicSubscription) (Transform, error) {
+			n, err := strconv.Atoi(r.URL.Query().Get("every"))
+			if err != nil {
+				return nil, err
+			}
+			return SampleEvery(n), nil
+		}
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+
+		for _, tc := range []struct {
+			query    string
+			status   int
+			expected string
+		}{
+			{"?every=2", http.StatusOK, "data:metrics after \nid:1\n\ndata:metrics after \nid:3\n\n"},
+			{"?every=often", http.StatusBadRequest, ""},
+		} {
+			doc := SubscriptionDocument{Topics: []TopicSubscription{{Topic: "metrics"}}}
+			req, err := NewSubscriptionRequest(context.Background(), srv.URL+tc.query, doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if resp.StatusCode != tc.status {
+				t.Errorf("%s: expected status %d, but got %d", tc.query, tc.status, resp.StatusCode)
+			}
+			if tc.status == http.StatusOK && string(body) != tc.expected {
+				t.Errorf("%s: expected %q, but got %q", tc.query, tc.expected, body)
+			}
+		}
+	})
+
+	t.Run("rejects requests before reading their document", func(t *testing.T) {
+		t.Parallel()
+
+		acl, err := ParseNetworkACL(nil, []string{"192.0.2.0/24"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := NewSubscriptionHandler(b, WithACL(acl))
+		h.Transform = func(*http.Request, TopicSubscription) (Transform, error) {
+			t.Error("expected the document not to be transformed")
+			return nil, nil
+		}
+
+		body := &readCounter{r: strings.NewReader(`{"topics": [{"topic": "orders"}]}`)}
+		req := httptest.NewRequest(http.MethodPost, "/", body)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("Accept", "text/event-stream")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("expected status %d, but got %d", http.StatusForbidden, w.Code)
+		}
+		if body.n != 0 {
+			t.Errorf("expected the body not to be read, but %d bytes were", body.n)
+		}
+	})
+
+	t.Run("requires POST", func(t *testing.T) {
+		t.Parallel()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("expected status %d, but got %d", http.StatusMethodNotAllowed, resp.StatusCode)
+		}
+	})
+}
+
+// readCounter counts the bytes read from r.
+type readCounter struct {
+	r io.Reader
+	n int
+}
+
+func (c *readCounter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
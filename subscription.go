@@ -0,0 +1,282 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// Subscription is an EventSource producing the events a Broker delivers for a topic, which can be paused and
+// resumed while it is streaming, e.g. while a client hides a live view, and whose filter and Transform can be
+// changed while it is streaming, e.g. as a client changes what it is viewing, without ending the stream, or
+// subscribing to the topic again.
+// It is safe for concurrent use, though it should only be streamed by one producer at a time.
+type Subscription struct {
+	// Buffer is the number of events kept while the subscription is paused, to send once it is resumed.
+	// Once it is full, or if it is 0, the subscription to the topic is ended instead, and made again once
+	// it is resumed, after the last event delivered, see LastEventID; the events published in between are only
+	// sent if the Broker still knows of that event. It must not be modified while the subscription is streaming.
+	Buffer int
+
+	broker Broker
+	topic  string
+
+	paused atomic.Bool
+	filter atomic.Pointer[func(Event) bool]
+	notify chan struct{}
+
+	mu          sync.Mutex
+	lastEventID string
+	transform   Transform
+	version     uint64 // of transform
+}
+
+// Subscribe returns a *Subscription to topic with b, after the event with lastEventID, as by Broker.Subscribe.
+func Subscribe(b Broker, topic, lastEventID string) *Subscription {
+	return &Subscription{
+		broker:      b,
+		topic:       topic,
+		notify:      make(chan struct{}, 1),
+		lastEventID: lastEventID,
+	}
+}
+
+// Pause stops sending events, until Resume is called.
+func (s *Subscription) Pause() { s.paused.Store(true) }
+
+// Resume sends the events kept while the subscription was paused, and continues sending the events published.
+func (s *Subscription) Resume() {
+	s.paused.Store(false)
+	s.changed()
+}
+
+// Paused reports whether the subscription is paused.
+func (s *Subscription) Paused() bool { return s.paused.Load() }
+
+// SetFilter sends only the events delivered for the topic that keep returns true for, from the next one,
+// or all of them if keep is nil. Events are filtered before they are passed to the subscription's Transform.
+func (s *Subscription) SetFilter(keep func(evt Event) bool) {
+	if keep == nil {
+		s.filter.Store(nil)
+		return
+	}
+	s.filter.Store(&keep)
+}
+
+// SetTransform applies t to the events delivered for the topic, from the next one, or no Transform if t is nil.
+// The Transform being replaced is ended first, sending any events it is holding, e.g. those of a window of
+// events, so that each event is passed to exactly one of them.
+func (s *Subscription) SetTransform(t Transform) {
+	s.mu.Lock()
+	s.transform = t
+	s.version++
+	s.mu.Unlock()
+
+	s.changed()
+}
+
+// changed notifies Stream that the subscription was resumed, or given a Transform.
+func (s *Subscription) changed() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// LastEventID returns the ID of the last event delivered for the topic, whether or not it was sent, or the ID
+// the subscription was made after if none with an ID have been, which it resumes after.
+func (s *Subscription) LastEventID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastEventID
+}
+
+// errTransformEnded is returned by Subscription.Stream's deliver once its Transform ended without an error.
+var errTransformEnded = errors.New("sse: transform ended")
+
+// transformRun is a run of a Transform by Subscription.Stream, transforming the events sent to in.
+type transformRun struct {
+	in   chan Event
+	done chan error
+}
+
+// Stream calls send for each event delivered for the topic while the subscription is not paused, until the
+// subscription to the topic ends, ctx is done, or send returns an error, and returns the error it ended with.
+func (s *Subscription) Stream(ctx context.Context, send func(Event) error) error {
+	var (
+		events   = make(chan Event)
+		done     chan error // nil while the subscription to the topic is ended
+		stop     context.CancelFunc
+		buffered []Event
+		run      *transformRun // nil without a Transform
+		version  uint64
+	)
+
+	start := func() {
+		var srcCtx context.Context
+		srcCtx, stop = context.WithCancel(ctx)
+		done = make(chan error, 1)
+
+		src := s.broker.Subscribe(s.topic, s.LastEventID())
+		go func(done chan<- error) {
+			done <- src.Stream(srcCtx, func(evt Event) error {
+				select {
+				case events <- evt:
+					return nil
+				case <-srcCtx.Done():
+					return srcCtx.Err()
+				}
+			})
+		}(done)
+	}
+	halt := func() {
+		stop()
+		<-done
+		done = nil
+	}
+
+	// swap ends the current run of a Transform, if any, and starts one of the subscription's Transform, if any
+	swap := func() error {
+		s.mu.Lock()
+		transform, v := s.transform, s.version
+		s.mu.Unlock()
+
+		if v == version {
+			return nil
+		}
+		version = v
+
+		if run != nil {
+			close(run.in)
+			err := <-run.done
+			run = nil
+			if err != nil {
+				return err
+			}
+		}
+
+		if transform != nil {
+			run = &transformRun{in: make(chan Event), done: make(chan error, 1)}
+			go func(run *transformRun) {
+				run.done <- transform(ChanSource(run.in)).Stream(ctx, send)
+			}(run)
+		}
+		return nil
+	}
+
+	deliver := func(evt Event) error {
+		if evt.ID != "" || evt.ResetID {
+			s.mu.Lock()
+			s.lastEventID = evt.ID
+			s.mu.Unlock()
+		}
+
+		if keep := s.filter.Load(); keep != nil && !(*keep)(evt) {
+			return nil
+		}
+
+		if run == nil {
+			return send(evt)
+		}
+		select {
+		case run.in <- evt:
+			return nil
+		case err := <-run.done:
+			run = nil
+			if err == nil {
+				err = errTransformEnded
+			}
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	flush := func() error {
+		for len(buffered) > 0 {
+			if err := deliver(buffered[0]); err != nil {
+				return err
+			}
+			buffered = buffered[1:]
+		}
+		buffered = nil
+		return nil
+	}
+
+	err := func() error {
+		if err := swap(); err != nil {
+			return err
+		}
+		start()
+
+		for {
+			var runDone chan error
+			if run != nil {
+				runDone = run.done
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+
+			case err := <-done:
+				done = nil
+				stop()
+				return err
+
+			case err := <-runDone:
+				run = nil
+				if err == nil {
+					err = errTransformEnded
+				}
+				return err
+
+			case <-s.notify:
+				if err := swap(); err != nil {
+					return err
+				}
+				if s.paused.Load() {
+					continue
+				}
+				if err := flush(); err != nil {
+					return err
+				}
+				if done == nil {
+					start()
+				}
+
+			case evt := <-events:
+				switch {
+				case !s.paused.Load():
+					if err := flush(); err != nil {
+						return err
+					}
+					if err := deliver(evt); err != nil {
+						return err
+					}
+				case len(buffered) < s.Buffer:
+					buffered = append(buffered, evt)
+				default:
+					// evt is dropped, to be delivered again once resumed after the last event delivered
+					halt()
+				}
+			}
+		}
+	}()
+
+	if done != nil {
+		halt()
+	}
+	// the events held by the Transform are sent once the topic's events end, as by the Transform itself
+	if run != nil {
+		close(run.in)
+		if runErr := <-run.done; err == nil {
+			err = runErr
+		}
+	}
+	if errors.Is(err, errTransformEnded) {
+		err = nil
+	}
+	return err
+}
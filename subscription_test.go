@@ -0,0 +1,191 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// streamSubscription streams sub into the returned channel, until the test ends, once it is subscribed
+// to topic with b.
+func streamSubscription(t *testing.T, b *Bus, topic string, sub *Subscription) chan Event {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, done := make(chan Event, 16), make(chan error, 1)
+	go func() {
+		done <- sub.Stream(ctx, func(evt Event) error {
+			events <- evt
+			return nil
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, but got %v", err)
+		}
+	})
+
+	for b.Subscribers(topic) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	return events
+}
+
+func receiveIDs(t *testing.T, events chan Event, expected ...string) {
+	t.Helper()
+
+	for _, id := range expected {
+		select {
+		case evt := <-events:
+			if evt.ID != id {
+				t.Fatalf("expected event %q, but got %q", id, evt.ID)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected event %q", id)
+		}
+	}
+	select {
+	case evt := <-events:
+		t.Fatalf("expected no more events, but got %q", evt.ID)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestSubscription(t *testing.T) {
+	t.Parallel()
+
+	publish := func(b *Bus, from, to int) {
+		for i := from; i <= to; i++ {
+			b.Publish(context.Background(), "orders", Event{ID: strconv.Itoa(i)})
+		}
+	}
+
+	t.Run("keeps events while paused", func(t *testing.T) {
+		t.Parallel()
+
+		var b Bus
+		sub := Subscribe(&b, "orders", "")
+		sub.Buffer = 10
+		events := streamSubscription(t, &b, "orders", sub)
+
+		publish(&b, 1, 1)
+		receiveIDs(t, events, "1")
+
+		sub.Pause()
+		if !sub.Paused() {
+			t.Error("expected the subscription to be paused")
+		}
+		publish(&b, 2, 3)
+		receiveIDs(t, events)
+
+		sub.Resume()
+		receiveIDs(t, events, "2", "3")
+		publish(&b, 4, 4)
+		receiveIDs(t, events, "4")
+	})
+
+	t.Run("resumes after the last event sent", func(t *testing.T) {
+		t.Parallel()
+
+		var b Bus
+		sub := Subscribe(&b, "orders", "")
+		events := streamSubscription(t, &b, "orders", sub)
+
+		publish(&b, 1, 1)
+		receiveIDs(t, events, "1")
+
+		sub.Pause()
+		publish(&b, 2, 3)
+		for deadline := time.Now().Add(time.Second); b.Subscribers("orders") != 0; time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatal("expected the subscription to the topic to be ended")
+			}
+		}
+		if id := sub.LastEventID(); id != "1" {
+			t.Errorf("expected to resume after %q, but got %q", "1", id)
+		}
+		publish(&b, 4, 4)
+
+		sub.Resume()
+		receiveIDs(t, events, "2", "3", "4")
+	})
+
+	t.Run("changes its filter while streaming", func(t *testing.T) {
+		t.Parallel()
+
+		var b Bus
+		sub := Subscribe(&b, "orders", "")
+		odd := func(evt Event) bool { id, _ := strconv.Atoi(evt.ID); return id%2 == 1 }
+		sub.SetFilter(odd)
+		events := streamSubscription(t, &b, "orders", sub)
+
+		publish(&b, 1, 4)
+		receiveIDs(t, events, "1", "3")
+
+		sub.SetFilter(func(evt Event) bool { return !odd(evt) })
+		publish(&b, 5, 8)
+		receiveIDs(t, events, "6", "8")
+
+		sub.SetFilter(nil)
+		publish(&b, 9, 10)
+		receiveIDs(t, events, "9", "10")
+		if id := sub.LastEventID(); id != "10" {
+			t.Errorf("expected to resume after %q, but got %q", "10", id)
+		}
+	})
+
+	t.Run("changes its transform while streaming", func(t *testing.T) {
+		t.Parallel()
+
+		var b Bus
+		sub := Subscribe(&b, "orders", "")
+		events := streamSubscription(t, &b, "orders", sub)
+
+		publish(&b, 1, 1)
+		receiveIDs(t, events, "1")
+
+		sub.SetTransform(LatestWins(time.Hour))
+		// once Stream has taken the change, the events it handles after it are transformed
+		for deadline := time.Now().Add(time.Second); len(sub.notify) != 0; time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatal("expected the transform to be applied")
+			}
+		}
+		publish(&b, 2, 3)
+		receiveIDs(t, events)
+
+		sub.SetTransform(nil)
+		receiveIDs(t, events, "3")
+		publish(&b, 4, 4)
+		receiveIDs(t, events, "4")
+	})
+
+	t.Run("resumes after the events kept once full", func(t *testing.T) {
+		t.Parallel()
+
+		var b Bus
+		sub := Subscribe(&b, "orders", "")
+		sub.Buffer = 1
+		events := streamSubscription(t, &b, "orders", sub)
+
+		publish(&b, 1, 1)
+		receiveIDs(t, events, "1")
+
+		sub.Pause()
+		publish(&b, 2, 4)
+		for deadline := time.Now().Add(time.Second); b.Subscribers("orders") != 0; time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatal("expected the subscription to the topic to be ended")
+			}
+		}
+
+		sub.Resume()
+		receiveIDs(t, events, "2", "3", "4")
+		if id := sub.LastEventID(); id != "4" {
+			t.Errorf("expected to resume after %q, but got %q", "4", id)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package sse
+
+import (
+	"bytes"
+	"context"
+	"strings"
+)
+
+// TagsPrefix marks the first data line of an Event as its tags, as a comma separated list.
+// The remaining data lines are the Event's data. See Event.Tags.
+const TagsPrefix = "sse-tags:"
+
+// encodeTags returns data prefixed by the envelope carrying tags.
+func encodeTags(tags []string, data []byte) []byte {
+	encoded := make([]byte, 0, len(TagsPrefix)+len(data)+16*len(tags))
+	encoded = append(encoded, TagsPrefix...)
+	for i, tag := range tags {
+		if i > 0 {
+			encoded = append(encoded, ',')
+		}
+		encoded = append(encoded, tag...)
+	}
+	if len(data) > 0 {
+		encoded = append(encoded, '\n')
+		encoded = append(encoded, data...)
+	}
+	return encoded
+}
+
+// DecodeTags returns evt with its Tags restored from its data, if they were sent in an envelope by a Handler,
+// and the envelope removed. Otherwise, evt is returned as is.
+// Events should be decompressed first, if needed (see DecodeGzip), and delta decoded after (see DeltaDecoder).
+func DecodeTags(evt Event) Event {
+	if !bytes.HasPrefix(evt.Data, []byte(TagsPrefix)) {
+		return evt
+	}
+
+	line, data := evt.Data[len(TagsPrefix):], []byte(nil)
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line, data = line[:i], line[i+1:]
+	}
+
+	evt.Tags = strings.Split(string(line), ",")
+	evt.Data = data
+	return evt
+}
+
+// HasTag reports whether evt has any of tags.
+func (e Event) HasTag(tags ...string) bool {
+	for _, have := range e.Tags {
+		for _, tag := range tags {
+			if have == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FilterTags returns a Transform that delivers only the events with at least one of tags.
+func FilterTags(tags ...string) Transform {
+	return func(src EventSource) EventSource {
+		return EventSourceFunc(func(ctx context.Context, send func(Event) error) error {
+			return src.Stream(ctx, func(evt Event) error {
+				if !evt.HasTag(tags...) {
+					return nil
+				}
+				return send(evt)
+			})
+		})
+	}
+}
+
+// Router dispatches received events to the handlers registered for their Event name, and for their tags,
+// so that a client can handle e.g. every "urgent" event the same way, whatever its name.
+// Events with tags should be decoded with DecodeTags first.
+// The zero value is ready to use. Handlers must be registered before events are dispatched.
+type Router struct {
+	byEvent map[string][]func(Event)
+	byTags  []tagRoute
+}
+
+// tagRoute is a handler registered for events with any of tags.
+type tagRoute struct {
+	tags []string
+	fn   func(Event)
+}
+
+// HandleEvent registers fn to be called with each event named name.
+func (r *Router) HandleEvent(name string, fn func(Event)) {
+	if r.byEvent == nil {
+		r.byEvent = make(map[string][]func(Event))
+	}
+	r.byEvent[name] = append(r.byEvent[name], fn)
+}
+
+// HandleTags registers fn to be called with each event with at least one of tags, once per event.
+func (r *Router) HandleTags(tags []string, fn func(Event)) {
+	r.byTags = append(r.byTags, tagRoute{tags: tags, fn: fn})
+}
+
+// Dispatch calls the handlers registered for evt's name, followed by those for its tags, in the order
+// they were registered. It reports whether any were called.
+func (r *Router) Dispatch(evt Event) bool {
+	handlers := r.byEvent[evt.Event]
+	called := len(handlers) > 0
+	for _, fn := range handlers {
+		fn(evt)
+	}
+
+	if len(evt.Tags) > 0 {
+		for _, route := range r.byTags {
+			if evt.HasTag(route.tags...) {
+				route.fn(evt)
+				called = true
+			}
+		}
+	}
+	return called
+}
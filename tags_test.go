@@ -0,0 +1,111 @@
+package sse
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeTags(t *testing.T) {
+	t.Parallel()
+
+	for _, evt := range []Event{
+		{Event: "order", Data: []byte("a\nb"), Tags: []string{"urgent", "billing"}},
+		{Event: "order", Tags: []string{"urgent"}},
+	} {
+		sent := evt
+		sent.Data = encodeTags(evt.Tags, evt.Data)
+		sent.Tags = nil
+
+		if decoded := DecodeTags(sent); !reflect.DeepEqual(decoded.Tags, evt.Tags) || string(decoded.Data) != string(evt.Data) {
+			t.Errorf("expected %+v, but got %+v", evt, decoded)
+		}
+	}
+
+	plain := Event{Data: []byte("no tags")}
+	if decoded := DecodeTags(plain); decoded.Tags != nil || string(decoded.Data) != "no tags" {
+		t.Errorf("expected event without tags to be returned as is, but got %+v", decoded)
+	}
+}
+
+func TestFilterTags(t *testing.T) {
+	t.Parallel()
+
+	src := ChanSource(func() chan Event {
+		events := make(chan Event, 3)
+		events <- Event{ID: "1", Tags: []string{"urgent"}}
+		events <- Event{ID: "2"}
+		events <- Event{ID: "3", Tags: []string{"info", "billing"}}
+		close(events)
+		return events
+	}())
+
+	events := collectEvents(t, FilterTags("billing", "urgent")(src))
+	if ids := eventIDs(events); ids != "1 3" {
+		t.Errorf("expected events %q, but got %q", "1 3", ids)
+	}
+}
+
+func TestRouter(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	var r Router
+	r.HandleEvent("order", func(evt Event) { calls = append(calls, "order:"+evt.ID) })
+	r.HandleTags([]string{"urgent", "billing"}, func(evt Event) { calls = append(calls, "urgent|billing:"+evt.ID) })
+	r.HandleTags([]string{"billing"}, func(evt Event) { calls = append(calls, "billing:"+evt.ID) })
+
+	for _, evt := range []Event{
+		{Event: "order", ID: "1", Tags: []string{"urgent", "billing"}},
+		{Event: "refund", ID: "2", Tags: []string{"billing"}},
+		{Event: "order", ID: "3"},
+	} {
+		if !r.Dispatch(evt) {
+			t.Errorf("expected event %s to be handled", evt.ID)
+		}
+	}
+	if r.Dispatch(Event{Event: "other", Tags: []string{"info"}}) {
+		t.Error("expected event without handlers not to be handled")
+	}
+
+	expected := "order:1 urgent|billing:1 billing:1 urgent|billing:2 billing:2 order:3"
+	if got := strings.Join(calls, " "); got != expected {
+		t.Errorf("expected calls %q, but got %q", expected, got)
+	}
+}
+
+func TestHandlerTags(t *testing.T) {
+	t.Parallel()
+
+	h := NewHandler(func(stream EventStream, lastEventID string) error {
+		stream.Go(func(ctx context.Context) error {
+			return stream.Send(Event{Event: "order", Data: []byte("42"), Tags: []string{"urgent"}})
+		})
+		return nil
+	})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{"plain client", "", "event:order\ndata:42\n\n"},
+		{"tags client", "?" + ExtensionsParam + "=tags", "event:order\ndata:" + TagsPrefix + "urgent\ndata:42\n\n"},
+	} {
+		resp, err := srv.Client().Get(srv.URL + tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if string(body) != tt.expected {
+			t.Errorf("%s: expected %q, but got %q", tt.name, tt.expected, body)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package sse
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultTeeBuffer is the default Tee.Buffer.
+const DefaultTeeBuffer = 1024
+
+// Sink receives copies of the events published through a Tee, e.g. an audit log, a metrics pipeline,
+// or an archive.
+type Sink interface {
+	// Write handles evt, which was published to topic. ctx is the context given to Tee.Run.
+	Write(ctx context.Context, topic string, evt Event) error
+}
+
+// SinkFunc is a function implementing Sink.
+type SinkFunc func(ctx context.Context, topic string, evt Event) error
+
+// Write implements Sink.
+func (f SinkFunc) Write(ctx context.Context, topic string, evt Event) error {
+	return f(ctx, topic, evt)
+}
+
+// Tee is a Broker that publishes events with another, and copies each event it publishes to Sinks,
+// asynchronously, so that subscribers are not held up by them. Subscribing is left to the other Broker.
+//
+// Each sink has a queue of its own, of Buffer events, which it is given the events from while Run is running;
+// when a sink's queue is full, the events published are dropped for it, rather than waiting for it to catch up,
+// see Dropped. The events copied are shared by all of the sinks, and must not be modified.
+//
+// Its fields must not be modified once it is in use.
+type Tee struct {
+	// Broker is the Broker events are published with, and subscribed to.
+	Broker Broker
+
+	// Sinks are the sinks events are copied to.
+	Sinks []Sink
+
+	// Buffer is the number of events queued for each sink. If 0, DefaultTeeBuffer is used.
+	Buffer int
+
+	// OnError, if not nil, is called with the errors returned by Sinks.
+	OnError func(err error)
+
+	once    sync.Once
+	queues  []chan teeEvent
+	dropped atomic.Uint64
+}
+
+// teeEvent is an event queued for a Sink, along with the topic it was published to.
+type teeEvent struct {
+	topic string
+	evt   Event
+}
+
+func (t *Tee) init() {
+	t.once.Do(func() {
+		size := t.Buffer
+		if size <= 0 {
+			size = DefaultTeeBuffer
+		}
+
+		t.queues = make([]chan teeEvent, len(t.Sinks))
+		for i := range t.queues {
+			t.queues[i] = make(chan teeEvent, size)
+		}
+	})
+}
+
+// Publish publishes evt to topic with Broker, and, if it succeeds, queues it for each of Sinks.
+func (t *Tee) Publish(ctx context.Context, topic string, evt Event) error {
+	if err := t.Broker.Publish(ctx, topic, evt); err != nil {
+		return err
+	}
+
+	t.init()
+	for _, queue := range t.queues {
+		select {
+		case queue <- teeEvent{topic: topic, evt: evt}:
+		default:
+			t.dropped.Add(1)
+		}
+	}
+	return nil
+}
+
+// Subscribe subscribes to topic with Broker.
+func (t *Tee) Subscribe(topic, lastEventID string) EventSource {
+	return t.Broker.Subscribe(topic, lastEventID)
+}
+
+// Run writes the events queued for each of Sinks to it, until ctx is done, and returns ctx's error.
+// Events still queued when it returns are written by the next call to Run.
+func (t *Tee) Run(ctx context.Context) error {
+	t.init()
+
+	var wg sync.WaitGroup
+	for i, sink := range t.Sinks {
+		i, sink, queue := i, sink, t.queues[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case e := <-queue:
+					if err := sink.Write(ctx, e.topic, e.evt); err != nil && t.OnError != nil {
+						t.OnError(fmt.Errorf("sse: tee sink %d: %w", i, err))
+					}
+				}
+			}
+		}()
+	}
+	<-ctx.Done()
+	wg.Wait()
+	return ctx.Err()
+}
+
+// Dropped returns the number of events that were dropped for a sink because its queue was full.
+func (t *Tee) Dropped() uint64 { return t.dropped.Load() }
@@ -0,0 +1,130 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTee(t *testing.T) {
+	t.Parallel()
+
+	t.Run("copies published events to each sink", func(t *testing.T) {
+		t.Parallel()
+
+		type copied struct {
+			topic string
+			data  string
+		}
+		audit, archive := make(chan copied, 1), make(chan copied, 1)
+		tee := &Tee{
+			Broker: noticeBroker{published: make(chan Event, 1)},
+			Sinks: []Sink{
+				SinkFunc(func(ctx context.Context, topic string, evt Event) error {
+					audit <- copied{topic, string(evt.Data)}
+					return nil
+				}),
+				SinkFunc(func(ctx context.Context, topic string, evt Event) error {
+					archive <- copied{topic, string(evt.Data)}
+					return nil
+				}),
+			},
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- tee.Run(ctx) }()
+
+		if err := tee.Publish(context.Background(), "orders/1", Event{Data: []byte("created")}); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := copied{"orders/1", "created"}
+		for _, sink := range []chan copied{audit, archive} {
+			select {
+			case c := <-sink:
+				if c != expected {
+					t.Errorf("expected %v, but got %v", expected, c)
+				}
+			case <-time.After(time.Second):
+				t.Error("expected the event to be copied to each sink")
+			}
+		}
+
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, but got %v", err)
+		}
+	})
+
+	t.Run("drops events for a sink that is not keeping up", func(t *testing.T) {
+		t.Parallel()
+
+		broker := noticeBroker{published: make(chan Event, 3)}
+		tee := &Tee{
+			Broker: broker,
+			Sinks:  []Sink{SinkFunc(func(ctx context.Context, topic string, evt Event) error { return nil })},
+			Buffer: 1,
+		}
+
+		for i := 0; i < 3; i++ {
+			if err := tee.Publish(context.Background(), "orders/1", Event{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if n := len(broker.published); n != 3 {
+			t.Errorf("expected every event to be published, but got %d", n)
+		}
+		if n := tee.Dropped(); n != 2 {
+			t.Errorf("expected 2 events to be dropped, but got %d", n)
+		}
+	})
+
+	t.Run("copies only events that were published", func(t *testing.T) {
+		t.Parallel()
+
+		tee := &Tee{
+			Broker: noticeBroker{err: errors.New("unavailable")},
+			Sinks:  []Sink{SinkFunc(func(ctx context.Context, topic string, evt Event) error { return nil })},
+			Buffer: 1,
+		}
+
+		if err := tee.Publish(context.Background(), "orders/1", Event{}); err == nil {
+			t.Error("expected the Broker's error, but got none")
+		}
+		if err := tee.Publish(context.Background(), "orders/1", Event{}); err == nil {
+			t.Error("expected the Broker's error, but got none")
+		}
+		if n := tee.Dropped(); n != 0 {
+			t.Errorf("expected no events to be queued, but %d were dropped", n)
+		}
+	})
+
+	t.Run("reports sink errors", func(t *testing.T) {
+		t.Parallel()
+
+		errs := make(chan error, 1)
+		failure := errors.New("disk full")
+		tee := &Tee{
+			Broker:  noticeBroker{published: make(chan Event, 1)},
+			Sinks:   []Sink{SinkFunc(func(ctx context.Context, topic string, evt Event) error { return failure })},
+			OnError: func(err error) { errs <- err },
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		go tee.Run(ctx)
+
+		tee.Publish(context.Background(), "orders/1", Event{})
+		select {
+		case err := <-errs:
+			if !errors.Is(err, failure) {
+				t.Errorf("expected %v, but got %v", failure, err)
+			}
+		case <-time.After(time.Second):
+			t.Error("expected OnError to be called")
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package sse
+
+import (
+	"bytes"
+	"io"
+)
+
+// Template is a template events are rendered from, e.g. a *template.Template of html/template,
+// for hypermedia-driven frontends swapping the HTML they receive into the page, or of text/template.
+type Template interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// TemplateData is the data templates are executed with by RenderEvent and SendTemplate:
+//
+//	<li>{{.State.name}} ordered {{.Data.Item}}</li>
+type TemplateData struct {
+	// Data is the data given to RenderEvent or SendTemplate.
+	Data interface{}
+
+	// State holds the values set on the EventStream the event is rendered for, see EventStream.SetValue.
+	// It is empty for RenderEvent.
+	State map[string]interface{}
+}
+
+// RenderEvent returns an event named event, with tmpl executed with data as its data, see TemplateData.
+// Line breaks in the output are converted to newlines, each of which is sent as a data line of its own,
+// so that the client receives the output as it was rendered, and it cannot inject fields into the event.
+func RenderEvent(event string, tmpl Template, data interface{}) (Event, error) {
+	return render(event, tmpl, TemplateData{Data: data, State: map[string]interface{}{}})
+}
+
+// SendTemplate renders an event as by RenderEvent, with the EventStream's state available to tmpl,
+// see TemplateData, and sends it. It is rendered when SendTemplate is called, so that a change to the
+// state afterwards does not change it. Errors are returned as by Send, or from executing tmpl.
+func (s EventStream) SendTemplate(event string, tmpl Template, data interface{}) error {
+	evt, err := render(event, tmpl, TemplateData{Data: data, State: s.Values()})
+	if err != nil {
+		return err
+	}
+	return s.Send(evt)
+}
+
+func render(event string, tmpl Template, data TemplateData) (Event, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return Event{}, err
+	}
+
+	out := bytes.ReplaceAll(buf.Bytes(), []byte("\r\n"), []byte("\n"))
+	out = bytes.ReplaceAll(out, []byte("\r"), []byte("\n"))
+	return Event{Event: event, Data: out}, nil
+}
@@ -0,0 +1,68 @@
+package sse
+
+import (
+	"context"
+	htmltemplate "html/template"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"text/template"
+)
+
+func TestRenderEvent(t *testing.T) {
+	t.Parallel()
+
+	t.Run("renders data lines", func(t *testing.T) {
+		t.Parallel()
+
+		tmpl := template.Must(template.New("").Parse("<ul>\r\n{{range .Data}}<li>{{.}}</li>\n{{end}}</ul>"))
+		evt, err := RenderEvent("items", tmpl, []string{"a", "b\rdata:forged"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ValidateEvent(evt); err != nil {
+			t.Errorf("expected a valid event, but got %v", err)
+		}
+		if expected := "<ul>\n<li>a</li>\n<li>b\ndata:forged</li>\n</ul>"; string(evt.Data) != expected {
+			t.Errorf("expected %q, but got %q", expected, evt.Data)
+		}
+		if evt.Event != "items" {
+			t.Errorf("expected event %q, but got %q", "items", evt.Event)
+		}
+	})
+
+	t.Run("returns execution errors", func(t *testing.T) {
+		t.Parallel()
+
+		tmpl := template.Must(template.New("").Parse("{{.Data.Missing}}"))
+		if _, err := RenderEvent("", tmpl, 42); err == nil {
+			t.Error("expected an error, but got none")
+		}
+	})
+}
+
+func TestSendTemplate(t *testing.T) {
+	t.Parallel()
+
+	tmpl := htmltemplate.Must(htmltemplate.New("").Parse("<p>{{.State.name}}: {{.Data}}</p>"))
+	h := NewHandler(func(stream EventStream, lastEventID string) error {
+		stream.SetValue("name", "<alice>")
+		stream.Go(func(ctx context.Context) error {
+			return stream.SendTemplate("message", tmpl, "hello")
+		})
+		return nil
+	})
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if expected := "event:message\ndata:<p>&lt;alice&gt;: hello</p>\n\n"; string(body) != expected {
+		t.Errorf("expected %q, but got %q", expected, body)
+	}
+}
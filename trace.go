@@ -0,0 +1,104 @@
+package sse
+
+import (
+	"bytes"
+	"context"
+	"strings"
+)
+
+// TraceParentHeader is the header W3C Trace Context propagates the traceparent of a request in,
+// e.g. for the request that publishes an event to be read with ContextWithTraceParent.
+const TraceParentHeader = "traceparent"
+
+// TracePrefix marks the first data line of an Event as its traceparent.
+// The remaining data lines are the Event's data, including any other envelope. See Event.TraceParent.
+const TracePrefix = "sse-trace:"
+
+type traceParentKey struct{}
+
+// ContextWithTraceParent returns a context.Context derived from ctx, carrying the W3C traceparent tp,
+// e.g. that of the request publishing events, from its TraceParentHeader, so that the events sent with it
+// (see EventStream.SendContext) continue its trace. tp is not carried if it is not a valid traceparent.
+func ContextWithTraceParent(ctx context.Context, tp string) context.Context {
+	if !validTraceParent(tp) {
+		return ctx
+	}
+	return context.WithValue(ctx, traceParentKey{}, tp)
+}
+
+// TraceParentFromContext returns the traceparent ctx carries, see ContextWithTraceParent, or "" if there is none.
+func TraceParentFromContext(ctx context.Context) string {
+	tp, _ := ctx.Value(traceParentKey{}).(string)
+	return tp
+}
+
+// TraceContext returns a context.Context derived from ctx, carrying evt's TraceParent, if it has one,
+// for the client to handle evt with, e.g. to start the span handling it as a child of the publisher's,
+// with a tracer's propagator reading TraceParentFromContext as the TraceParentHeader.
+// Events should be decoded with DecodeTrace first.
+func TraceContext(ctx context.Context, evt Event) context.Context {
+	return ContextWithTraceParent(ctx, evt.TraceParent)
+}
+
+// encodeTrace returns data prefixed by the envelope carrying tp.
+func encodeTrace(tp string, data []byte) []byte {
+	encoded := make([]byte, 0, len(TracePrefix)+len(tp)+1+len(data))
+	encoded = append(encoded, TracePrefix...)
+	encoded = append(encoded, tp...)
+	if len(data) > 0 {
+		encoded = append(encoded, '\n')
+		encoded = append(encoded, data...)
+	}
+	return encoded
+}
+
+// DecodeTrace returns evt with its TraceParent restored from its data, if it was sent in an envelope by a
+// Handler, and the envelope removed. Otherwise, evt is returned as is.
+// Events should be decompressed first, if needed (see DecodeGzip), and their tags decoded after (see DecodeTags).
+func DecodeTrace(evt Event) Event {
+	if !bytes.HasPrefix(evt.Data, []byte(TracePrefix)) {
+		return evt
+	}
+
+	line, data := evt.Data[len(TracePrefix):], []byte(nil)
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line, data = line[:i], line[i+1:]
+	}
+
+	evt.TraceParent = string(line)
+	evt.Data = data
+	return evt
+}
+
+// validTraceParent reports whether tp is a W3C traceparent: a version, trace ID, parent ID, and flags,
+// in lowercase hex, separated by dashes, with neither ID all zeroes. Versions after 00 may be followed by
+// further fields, after a dash, of lowercase hex and dashes only, so that a traceparent cannot carry a line
+// break into its envelope.
+func validTraceParent(tp string) bool {
+	const length = 2 + 1 + 32 + 1 + 16 + 1 + 2
+
+	if len(tp) < length || tp[2] != '-' || tp[35] != '-' || tp[52] != '-' {
+		return false
+	}
+	version, traceID, parentID, flags := tp[:2], tp[3:35], tp[36:52], tp[53:55]
+	if !lowerHex(version) || !lowerHex(traceID) || !lowerHex(parentID) || !lowerHex(flags) {
+		return false
+	}
+	if version == "ff" || version == "00" && len(tp) != length || len(tp) > length && tp[length] != '-' {
+		return false
+	}
+	if !lowerHex(strings.ReplaceAll(tp[length:], "-", "")) {
+		return false
+	}
+	return traceID != "00000000000000000000000000000000" && parentID != "0000000000000000"
+}
+
+// lowerHex reports whether s is made up of lowercase hex digits only.
+func lowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if (s[i] < '0' || s[i] > '9') && (s[i] < 'a' || s[i] > 'f') {
+			return false
+		}
+	}
+	return true
+}
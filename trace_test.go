@@ -0,0 +1,137 @@
+package sse
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestValidTraceParent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		tp    string
+		valid bool
+	}{
+		{testTraceParent, true},
+		{"cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-0123abcd", true},
+		{"cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", false},
+		{"cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01future", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00_4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7_01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0\n", false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-x\nevil", false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-FF", false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-ab-cd", true},
+	}
+	for _, tt := range tests {
+		if valid := validTraceParent(tt.tp); valid != tt.valid {
+			t.Errorf("%q: expected valid to be %t, but got %t", tt.tp, tt.valid, valid)
+		}
+	}
+}
+
+func TestContextWithTraceParent(t *testing.T) {
+	t.Parallel()
+
+	ctx := ContextWithTraceParent(context.Background(), testTraceParent)
+	if tp := TraceParentFromContext(ctx); tp != testTraceParent {
+		t.Errorf("expected traceparent %q, but got %q", testTraceParent, tp)
+	}
+
+	if tp := TraceParentFromContext(ContextWithTraceParent(context.Background(), "invalid")); tp != "" {
+		t.Errorf("expected an invalid traceparent not to be carried, but got %q", tp)
+	}
+
+	evt := DecodeTrace(Event{Data: encodeTrace(testTraceParent, nil)})
+	if tp := TraceParentFromContext(TraceContext(context.Background(), evt)); tp != testTraceParent {
+		t.Errorf("expected the event's traceparent %q, but got %q", testTraceParent, tp)
+	}
+}
+
+func TestDecodeTrace(t *testing.T) {
+	t.Parallel()
+
+	for _, evt := range []Event{
+		{Event: "order", Data: []byte("a\nb"), TraceParent: testTraceParent},
+		{Event: "order", TraceParent: testTraceParent},
+	} {
+		sent := evt
+		sent.Data = encodeTrace(evt.TraceParent, evt.Data)
+		sent.TraceParent = ""
+
+		if decoded := DecodeTrace(sent); decoded.TraceParent != evt.TraceParent || string(decoded.Data) != string(evt.Data) {
+			t.Errorf("expected %+v, but got %+v", evt, decoded)
+		}
+	}
+
+	// the tags envelope is inside the trace envelope
+	sent := Event{Data: encodeTrace(testTraceParent, encodeTags([]string{"urgent"}, []byte("42")))}
+	if decoded := DecodeTags(DecodeTrace(sent)); decoded.TraceParent != testTraceParent ||
+		len(decoded.Tags) != 1 || decoded.Tags[0] != "urgent" || string(decoded.Data) != "42" {
+		t.Errorf("expected the traceparent and tags to be decoded, but got %+v", decoded)
+	}
+
+	// a traceparent that would break its envelope is not sent, nor carried by a context
+	forged := "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-x\nevil"
+	if err := ValidateEvent(Event{TraceParent: forged}); err == nil {
+		t.Errorf("expected traceparent %q to be invalid", forged)
+	}
+	if tp := SanitizeEvent(Event{TraceParent: forged}).TraceParent; tp != "" {
+		t.Errorf("expected traceparent %q to be removed, but got %q", forged, tp)
+	}
+	if tp := TraceParentFromContext(ContextWithTraceParent(context.Background(), forged)); tp != "" {
+		t.Errorf("expected traceparent %q not to be carried, but got %q", forged, tp)
+	}
+
+	plain := Event{Data: []byte("no trace")}
+	if decoded := DecodeTrace(plain); decoded.TraceParent != "" || string(decoded.Data) != "no trace" {
+		t.Errorf("expected event without a traceparent to be returned as is, but got %+v", decoded)
+	}
+}
+
+func TestHandlerTrace(t *testing.T) {
+	t.Parallel()
+
+	h := NewHandler(func(stream EventStream, lastEventID string) error {
+		stream.Go(func(ctx context.Context) error {
+			ctx = ContextWithTraceParent(ctx, testTraceParent)
+			return stream.SendContext(ctx, Event{Event: "order", Data: []byte("42"), Tags: []string{"urgent"}})
+		})
+		return nil
+	})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{"plain client", "", "event:order\ndata:42\n\n"},
+		{"trace client", "?" + ExtensionsParam + "=trace", "event:order\ndata:" + TracePrefix + testTraceParent + "\ndata:42\n\n"},
+		{
+			"trace and tags client", "?" + ExtensionsParam + "=trace,tags",
+			"event:order\ndata:" + TracePrefix + testTraceParent + "\ndata:" + TagsPrefix + "urgent\ndata:42\n\n",
+		},
+	} {
+		resp, err := srv.Client().Get(srv.URL + tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if string(body) != tt.expected {
+			t.Errorf("%s: expected %q, but got %q", tt.name, tt.expected, body)
+		}
+	}
+}
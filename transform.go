@@ -0,0 +1,85 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrInvalidInterval is returned (wrapped) by the EventSources of Transforms that produce events per
+// interval of time, e.g. LatestWins, when they are given an interval that is not positive.
+var ErrInvalidInterval = errors.New("sse: invalid interval")
+
+// Transform wraps an EventSource, e.g. to filter, downsample, or aggregate the events it produces.
+// Transforms are applied to each topic of a subscription by a SubscriptionHandler's Transform hook.
+type Transform func(src EventSource) EventSource
+
+// Chain returns a Transform applying each of transforms in order, so that the first sees the original events.
+func Chain(transforms ...Transform) Transform {
+	return func(src EventSource) EventSource {
+		for _, t := range transforms {
+			src = t(src)
+		}
+		return src
+	}
+}
+
+// failTransform returns a Transform whose EventSources fail with err, without streaming their source.
+func failTransform(err error) Transform {
+	return func(EventSource) EventSource {
+		return EventSourceFunc(func(context.Context, func(Event) error) error { return err })
+	}
+}
+
+// windowed runs src, passing each event it produces to add, and sends the events returned by take every interval,
+// and once src has finished, so that a Transform can produce events per window of time, rather than per event.
+// add and take are called with mu held, so need no synchronization of their own.
+// interval must be positive, which the Transforms using it check when they are made.
+func windowed(ctx context.Context, src EventSource, interval time.Duration, send func(Event) error,
+	add func(evt Event, now time.Time), take func(now time.Time) []Event) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	done := make(chan error, 1)
+	go func() {
+		done <- src.Stream(ctx, func(evt Event) error {
+			mu.Lock()
+			add(evt, time.Now())
+			mu.Unlock()
+			return nil
+		})
+	}()
+
+	flush := func(now time.Time) error {
+		mu.Lock()
+		events := take(now)
+		mu.Unlock()
+
+		for _, evt := range events {
+			if err := send(evt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			if err := flush(now); err != nil {
+				return err
+			}
+
+		case err := <-done:
+			if err != nil {
+				return err
+			}
+			return flush(time.Now())
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package sse
+
+import (
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	t.Parallel()
+
+	// every 2nd of the first 6, then every 2nd of those
+	events := collectEvents(t, Chain(SampleEvery(2), SampleEvery(2))(numberedSource(noName, 6)))
+	if ids := eventIDs(events); ids != "1 5" {
+		t.Errorf("expected events %q, but got %q", "1 5", ids)
+	}
+}